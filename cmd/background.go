@@ -5,18 +5,40 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
 	"time"
 
 	billingConfigRepo "github.com/okiww/billing-loan-system/internal/billing_config/repositories"
 
 	"github.com/okiww/billing-loan-system/configs"
+	"github.com/okiww/billing-loan-system/helpers"
+	collectionRepo "github.com/okiww/billing-loan-system/internal/collection/repositories"
+	collectionService "github.com/okiww/billing-loan-system/internal/collection/services"
 	"github.com/okiww/billing-loan-system/internal/ctx/servicectx"
+	"github.com/okiww/billing-loan-system/internal/dto"
+	jobModel "github.com/okiww/billing-loan-system/internal/job/models"
+	jobRepo "github.com/okiww/billing-loan-system/internal/job/repositories"
+	jobService "github.com/okiww/billing-loan-system/internal/job/services"
+	loanModel "github.com/okiww/billing-loan-system/internal/loan/models"
 	"github.com/okiww/billing-loan-system/internal/loan/repositories"
 	loanService "github.com/okiww/billing-loan-system/internal/loan/services"
+	mandateModel "github.com/okiww/billing-loan-system/internal/mandate/models"
+	mandateRepo "github.com/okiww/billing-loan-system/internal/mandate/repositories"
+	mandateService "github.com/okiww/billing-loan-system/internal/mandate/services"
+	paymentRepo "github.com/okiww/billing-loan-system/internal/payment/repositories"
+	paymentService "github.com/okiww/billing-loan-system/internal/payment/services"
+	reminderModel "github.com/okiww/billing-loan-system/internal/reminder/models"
+	reminderRepo "github.com/okiww/billing-loan-system/internal/reminder/repositories"
+	reminderService "github.com/okiww/billing-loan-system/internal/reminder/services"
 	userRepo "github.com/okiww/billing-loan-system/internal/user/repositories"
 	userService "github.com/okiww/billing-loan-system/internal/user/services"
 	mysql "github.com/okiww/billing-loan-system/pkg/db"
 	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/okiww/billing-loan-system/pkg/mq"
 	"github.com/robfig/cron/v3"
 
 	"github.com/spf13/cobra"
@@ -33,92 +55,631 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		runCronJob()
+		asOf, _ := cmd.Flags().GetString("as-of")
+		backfillFrom, _ := cmd.Flags().GetString("backfill-from")
+		runCronJob(asOf, backfillFrom)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(backgroundCmd)
-}
 
-func runCronJob() {
-	// Create a new cron scheduler
-	c := cron.New(cron.WithLocation(time.Local))
+	backgroundCmd.Flags().String("as-of", "", "Run the bill status update once as of this date (YYYY-MM-DD) and exit")
+	backgroundCmd.Flags().String("backfill-from", "", "Run the bill status update once for every date from this date (YYYY-MM-DD) up to --as-of or today, in order, and exit")
+}
 
+func runCronJob(asOf, backfillFrom string) {
 	cfg := configs.InitConfig()
+	registry, err := newBackgroundJobRegistry(cfg.Scheduler)
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to load jobs: %v", err)
+	}
+
 	// initial connection to database
 	dbInit := mysql.InitDB(&cfg.DB)
 	db, err := dbInit.Connect()
 	if err != nil {
 		logger.Fatalf("failed to connect db")
 	}
+	db.Clock = InitClock(cfg.Clock)
+
+	broker := initBackgroundBroker(cfg, db)
+	defer broker.Close()
+
+	serviceCtx := newBackgroundServiceCtx(db, broker, cfg)
+	ctx := context.Background()
+
+	// replay mode, process the requested dates once without scheduling
+	if asOf != "" || backfillFrom != "" {
+		dates, err := billingDatesFromFlags(asOf, backfillFrom, serviceCtx.Clock.Now().In(registry.Location()))
+		if err != nil {
+			logger.GetLogger().Fatalf("[Cronjob] Invalid billing dates: %v", err)
+		}
+
+		updateBillStatuses, _ := registry.Get(jobUpdateBillStatuses)
+		_, err = runJob(ctx, serviceCtx, updateBillStatuses, registry.Params(dates))
+		if err != nil {
+			logger.GetLogger().Fatalf("[Cronjob] Error replay billing dates: %v", err)
+		}
+		return
+	}
+
+	schedules, err := registry.Schedules()
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to load jobs: %v", err)
+	}
 
-	// initial domain context
+	// Create a new cron scheduler
+	c := cron.New(cron.WithLocation(registry.Location()))
+	for _, schedule := range schedules {
+		if !schedule.Enabled {
+			logger.GetLogger().Infof("[Cronjob] Job %s is disabled", schedule.Name())
+			continue
+		}
+
+		j := schedule.Job
+		_, err = c.AddFunc(schedule.Schedule, func() {
+			_, err := runJob(ctx, serviceCtx, j, registry.Params(nil))
+			if err != nil {
+				logger.GetLogger().Errorf("[Cronjob] Error run %s with err: %v", j.Name(), err)
+			}
+		})
+		if err != nil {
+			logger.GetLogger().Fatal("Error adding cron job:", err)
+		}
+		logger.GetLogger().Infof("[Cronjob] Job %s scheduled at %s", schedule.Name(), schedule.Schedule)
+	}
+
+	// Start the cron scheduler in the background
+	c.Start()
+	select {}
+}
+
+// initBackgroundBroker connects to the message broker and declares the queues the background jobs publish to
+func initBackgroundBroker(cfg configs.Config, db *mysql.DBMySQL) mq.Broker {
+	broker := InitBroker(cfg, db)
+
+	reminderQueueName := cfg.Reminder.QueueName
+	if reminderQueueName == "" {
+		reminderQueueName = reminderModel.DefaultReminderQueueName
+	}
+	for _, queueName := range []string{reminderQueueName, cfg.RabbitMQ.QueueName} {
+		err := broker.DeclareQueue(queueName)
+		if err != nil {
+			logger.GetLogger().Fatalf("failed to declare queue %s: %v", queueName, err)
+		}
+	}
+	return broker
+}
+
+// newBackgroundServiceCtx initial domain context of the background jobs
+func newBackgroundServiceCtx(db *mysql.DBMySQL, publisher mq.Publisher, cfg configs.Config) servicectx.ServiceCtx {
 	loanRepository := repositories.NewLoanRepository(db)
 	loanBillRepository := repositories.NewLoanBillRepository(db)
 	userRepository := userRepo.NewUserRepository(db)
 	billingConfigRepository := billingConfigRepo.NewBillingConfigRepository(db)
+	collectionRepository := collectionRepo.NewCollectionRepository(db)
+	jobRepository := jobRepo.NewJobRepository(db)
+	reminderRepository := reminderRepo.NewReminderRepository(db)
+	paymentRepository := paymentRepo.NewPaymentRepository(db)
+	mandateRepository := mandateRepo.NewMandateRepository(db)
+	paymentSvc := paymentService.NewPaymentService(paymentRepository, loanRepository, loanBillRepository, db.Clock)
 
-	serviceCtx := servicectx.ServiceCtx{
-		LoanService: loanService.NewLoanService(loanRepository, loanBillRepository, billingConfigRepository),
-		UserService: userService.NewUserService(userRepository),
+	return servicectx.ServiceCtx{
+		LoanService:       loanService.NewLoanService(loanRepository, loanBillRepository, billingConfigRepository, db.Clock),
+		UserService:       userService.NewUserService(userRepository),
+		CollectionService: collectionService.NewCollectionService(collectionRepository, db.Clock),
+		JobService:        jobService.NewJobService(jobRepository, helpers.InstanceID()),
+		PaymentService:    paymentSvc,
+		ReminderService:   reminderService.NewReminderService(reminderRepository, publisher, cfg.Reminder, db.Clock),
+		MandateService:    mandateService.NewMandateService(mandateRepository, loanRepository, paymentSvc, cfg.AutoDebit, db.Clock),
+		Clock:             db.Clock,
 	}
+}
 
-	ctx := context.Background()
+// runJob runs a job under its lease and records the run
+func runJob(ctx context.Context, serviceCtx servicectx.ServiceCtx, j Job, params JobParams) (*jobModel.JobRunModel, error) {
+	return serviceCtx.JobService.Run(ctx, j.Name(), func(ctx context.Context, run *jobModel.JobRunModel) error {
+		return j.Run(ctx, serviceCtx, params, run)
+	})
+}
+
+const (
+	jobUpdateBillStatuses     = "update_bill_statuses"
+	jobWriteOffOverdueLoans   = "write_off_overdue_loans"
+	jobEvaluateDelinquency    = "evaluate_delinquency"
+	jobRefreshCollectionQueue = "refresh_collection_queue"
+	jobSendBillReminders      = "send_bill_reminders"
+	jobAccrueInterest         = "accrue_interest"
+	jobCollectAutoDebits      = "collect_auto_debits"
+)
 
-	_, err = c.AddFunc("*/1 * * * *", func() {
-		GenerateBillPaymentEveryWeek(ctx, serviceCtx)
-	}) // Should change to run every monday at 00:00 with 0 0 * * 1
+// newBackgroundJobRegistry registers every background job, the order is the order they are listed in
+func newBackgroundJobRegistry(config configs.SchedulerConfig) (*JobRegistry, error) {
+	registry, err := NewJobRegistry(config)
 	if err != nil {
-		logger.GetLogger().Fatal("Error adding cron job:", err)
+		return nil, err
 	}
 
-	// Start the cron scheduler in the background
-	c.Start()
-	select {}
+	registry.Register(
+		&job{
+			name:            jobUpdateBillStatuses,
+			description:     "Update PENDING bills to BILLED or OVERDUE, catching up on missed dates",
+			defaultSchedule: "0 * * * *",
+			run:             updateBillStatuses,
+			dryRun:          previewBillingChanges,
+		},
+		&job{
+			name:            jobWriteOffOverdueLoans,
+			description:     "Write off loans overdue past the configured days past due",
+			defaultSchedule: "30 0 * * *",
+			run:             writeOffOverdueLoans,
+		},
+		&job{
+			name:            jobEvaluateDelinquency,
+			description:     "Flag users with more than one OVERDUE bill as delinquent",
+			defaultSchedule: "0 1 * * 1",
+			run:             evaluateDelinquency,
+			dryRun:          previewBillingChanges,
+		},
+		&job{
+			name:            jobRefreshCollectionQueue,
+			description:     "Break expired promises to pay and rebuild the collection queue",
+			defaultSchedule: "0 6 * * *",
+			run:             refreshCollectionQueue,
+		},
+		&job{
+			name:            jobSendBillReminders,
+			description:     "Publish a reminder for the bills due in the configured days, once per bill and days before",
+			defaultSchedule: "0 * * * *",
+			run:             sendBillReminders,
+		},
+		&job{
+			name:            jobAccrueInterest,
+			description:     "Accrue the daily interest of declining balance loans on their outstanding principal",
+			defaultSchedule: "0 * * * *",
+			run:             accrueInterest,
+		},
+		&job{
+			name:            jobCollectAutoDebits,
+			description:     "Charge the BILLED bills of loans with an active mandate and retry the failed debits",
+			defaultSchedule: "15 * * * *",
+			run:             collectAutoDebits,
+		},
+	)
+	return registry, nil
 }
 
-// This function will be called by the cron job
-func GenerateBillPaymentEveryWeek(ctx context.Context, serviceCtx servicectx.ServiceCtx) {
-	logger.GetLogger().Info("[Cronjob] Running cron for update bills")
+// billingDatesFromFlags resolves the dates to replay from the --as-of and --backfill-from flags, the dates are
+// calendar days in the location of now
+func billingDatesFromFlags(asOf, backfillFrom string, now time.Time) ([]time.Time, error) {
+	to := helpers.TruncateToDay(now)
+	if asOf != "" {
+		date, err := time.ParseInLocation(dto.DateFormat, asOf, now.Location())
+		if err != nil {
+			return nil, fmt.Errorf("--as-of must be in YYYY-MM-DD format")
+		}
+		to = date
+	}
+
+	if backfillFrom == "" {
+		return []time.Time{to}, nil
+	}
 
-	logger.GetLogger().Info("[Cronjob] Fetch all active loan")
-	loans, err := serviceCtx.LoanService.GetAllActiveLoan(ctx)
+	from, err := time.ParseInLocation(dto.DateFormat, backfillFrom, now.Location())
 	if err != nil {
-		logger.Fatalf("[Cronjob] Error update loan bills")
-		return
+		return nil, fmt.Errorf("--backfill-from must be in YYYY-MM-DD format")
 	}
+	if from.After(to) {
+		return nil, fmt.Errorf("--backfill-from cannot be after %s", to.Format(dto.DateFormat))
+	}
+	return helpers.DatesBetween(from, to), nil
+}
 
+// updateBillStatuses update the bill statuses for each date in order, as of now it catches up since the last successful run.
+// Today is evaluated again on every run, the loans in a timezone behind the scheduler are billed once their day starts.
+// A date is retried on transient errors, the later dates are not processed when it still fails so the bills are never
+// evaluated out of order
+func updateBillStatuses(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, run *jobModel.JobRunModel) error {
+	dates := params.Dates
+	if len(dates) == 0 {
+		now := serviceCtx.Clock.Now().In(params.Location)
+		err := helpers.Retry(ctx, params.Retry, func() error {
+			var err error
+			dates, err = serviceCtx.LoanService.GetMissedBillingDates(ctx, now)
+			return err
+		})
+		if err != nil {
+			logger.GetLogger().Errorf("[Cronjob] Error get missed billing dates with err: %v", err)
+			return err
+		}
+		if len(dates) == 0 {
+			dates = []time.Time{helpers.TruncateToDay(now)}
+		}
+	}
+
+	for _, date := range dates {
+		logger.GetLogger().Infof("[Cronjob] Update loan bill statuses as of %s", date.Format(dto.DateFormat))
+		err := helpers.Retry(ctx, params.Retry, func() error {
+			return serviceCtx.LoanService.UpdateLoanBill(ctx, date)
+		})
+		if err != nil {
+			logger.GetLogger().Errorf("[Cronjob] Error update loan bills with err: %v", err)
+			run.FailedCount++
+			return err
+		}
+		run.ProcessedCount++
+	}
+	return nil
+}
+
+// previewBillingChanges print the bills which status would change and the users which would become delinquent as of
+// the last date to process, the statuses only move forward so replaying every missed date ends up in the same state
+func previewBillingChanges(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, out io.Writer) error {
+	asOf := serviceCtx.Clock.Now().In(params.Location)
+	if len(params.Dates) > 0 {
+		asOf = params.Dates[len(params.Dates)-1]
+	}
+
+	bills, loans, err := serviceCtx.LoanService.PreviewBillingChanges(ctx, asOf)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Dry run as of %s, nothing is written\n\n", asOf.Format(dto.DateFormat))
+	fmt.Fprintf(w, "%d bills would change status\n", len(bills))
+	if len(bills) > 0 {
+		fmt.Fprintln(w, "BILL ID\tLOAN ID\tUSER ID\tBILLING NUMBER\tBILLING DATE\tFROM\tTO")
+		for _, bill := range bills {
+			fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%s\t%s\t%s\n", bill.ID, bill.LoanID, bill.UserID, bill.BillingNumber, bill.BillingDate.Format(dto.DateFormat), bill.FromStatus, bill.ToStatus)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d users would become delinquent\n", countUsers(loans))
 	if len(loans) > 0 {
-		// UpdateLoanBill update loan bill status
-		logger.GetLogger().Info("[Cronjob] Update loan bill statuses")
-		err = serviceCtx.LoanService.UpdateLoanBill(ctx)
+		fmt.Fprintln(w, "USER ID\tLOAN ID\tOVERDUE BILLS")
+		for _, loan := range loans {
+			fmt.Fprintf(w, "%d\t%d\t%d\n", loan.UserID, loan.LoanID, loan.OverdueCount)
+		}
+	}
+	return w.Flush()
+}
+
+// countUsers count the distinct users of the delinquent loans, a user can have several loans
+func countUsers(loans []loanModel.DelinquencyChangeModel) int {
+	users := make(map[int64]struct{}, len(loans))
+	for _, loan := range loans {
+		users[loan.UserID] = struct{}{}
+	}
+	return len(users)
+}
+
+// writeOffOverdueLoans write off loans overdue for more than the configured days past due
+func writeOffOverdueLoans(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, run *jobModel.JobRunModel) error {
+	// loans already written off are no longer overdue, so the whole step is retried
+	var total int32
+	err := helpers.Retry(ctx, params.Retry, func() error {
+		written, err := serviceCtx.LoanService.WriteOffOverdueLoans(ctx)
+		total += written
+		return err
+	})
+	run.ProcessedCount = total
+	if err != nil {
+		logger.GetLogger().Errorf("[Cronjob] Error write off overdue loans with err: %v", err)
+		return err
+	}
+	logger.GetLogger().Infof("[Cronjob] %d loans written off", total)
+	return nil
+}
+
+// evaluateDelinquency if users has more than 1 OVERDUE bill, update users to delinquent. The active loans are read page by
+// page and their overdue bills counted per page, the users are updated by a bounded pool of workers. Transient errors are
+// retried with backoff, a loan which still fails is recorded on the run and the others carry on. Loans with at most 1
+// OVERDUE bill are counted as skipped
+func evaluateDelinquency(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, run *jobModel.JobRunModel) error {
+	loans := make(chan loanModel.LoanModel)
+	var processed, skipped atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < params.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for loan := range loans {
+				err := helpers.Retry(ctx, params.Retry, func() error {
+					return serviceCtx.UserService.UpdateUserToDelinquent(ctx, int32(loan.UserID))
+				})
+				if err != nil {
+					logger.GetLogger().Errorf("[Cronjob] Error Update User To Delinquent of loan %d with err: %v", loan.ID, err)
+					_ = serviceCtx.JobService.RecordFailure(ctx, run, loanReference(loan.ID), err)
+					continue
+				}
+				processed.Add(1)
+			}
+		}()
+	}
+
+	err := forEachActiveLoanPage(ctx, serviceCtx, params, func(page []loanModel.LoanModel) error {
+		ids := make([]int64, 0, len(page))
+		for _, loan := range page {
+			ids = append(ids, loan.ID)
+		}
+
+		var counts map[int64]int32
+		err := helpers.Retry(ctx, params.Retry, func() error {
+			var err error
+			counts, err = serviceCtx.LoanService.CountOverdueBillsByLoanIDs(ctx, ids)
+			return err
+		})
 		if err != nil {
-			logger.Fatalf("[Cronjob] Error update loan bills")
-			return
+			// the page can't be evaluated, record its loans and carry on with the next page
+			logger.GetLogger().Errorf("[Cronjob] Error Count loan bill overdue by loan ids with err: %v", err)
+			for _, loan := range page {
+				_ = serviceCtx.JobService.RecordFailure(ctx, run, loanReference(loan.ID), err)
+			}
+			return nil
 		}
 
-		logger.GetLogger().Info("[Cronjob] Count loan bill overdue by loan id")
-		for _, v := range loans {
-			total, err := serviceCtx.LoanService.CountLoanBillOverdueStatusesByID(ctx, int32(v.ID))
-			if err != nil {
-				logger.Fatalf("[Cronjob] Error Count loan bill overdue by loan id")
-				return
+		for _, loan := range page {
+			if counts[loan.ID] <= 1 {
+				skipped.Add(1)
+				continue
+			}
+			select {
+			case loans <- loan:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	close(loans)
+	wg.Wait()
+
+	run.ProcessedCount = processed.Load()
+	run.SkippedCount = skipped.Load()
+	return err
+}
+
+// forEachActiveLoanPage iterates over the active loans by keyset pagination on the id, so the portfolio is never loaded
+// into memory at once. A page which can't be fetched stops the iteration as the following pages depend on it
+func forEachActiveLoanPage(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, fn func(page []loanModel.LoanModel) error) error {
+	var afterID int64
+	for {
+		var page []loanModel.LoanModel
+		err := helpers.Retry(ctx, params.Retry, func() error {
+			var err error
+			page, err = serviceCtx.LoanService.GetActiveLoans(ctx, afterID, params.BatchSize)
+			return err
+		})
+		if err != nil {
+			logger.GetLogger().Errorf("[Cronjob] Error fetch active loans with err: %v", err)
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		if err := fn(page); err != nil {
+			return err
+		}
+
+		if len(page) < params.BatchSize {
+			return nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
+
+// loanReference identifies a loan in the failures of a job run
+func loanReference(id int64) string {
+	return fmt.Sprintf("loan:%d", id)
+}
+
+// refreshCollectionQueue break promise to pay past its promised date and refresh collection cases for overdue loans
+func refreshCollectionQueue(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, run *jobModel.JobRunModel) error {
+	logger.GetLogger().Info("[Cronjob] Break expired promise to pay")
+	var broken int32
+	err := helpers.Retry(ctx, params.Retry, func() error {
+		var err error
+		broken, err = serviceCtx.CollectionService.BreakExpiredPromises(ctx)
+		return err
+	})
+	if err != nil {
+		logger.GetLogger().Errorf("[Cronjob] Error break expired promise to pay with err: %v", err)
+		return err
+	}
+	logger.GetLogger().Infof("[Cronjob] %d promise to pay broken", broken)
+
+	logger.GetLogger().Info("[Cronjob] Build collection queue")
+	var queued int32
+	err = helpers.Retry(ctx, params.Retry, func() error {
+		var err error
+		queued, err = serviceCtx.CollectionService.BuildQueue(ctx)
+		return err
+	})
+	if err != nil {
+		logger.GetLogger().Errorf("[Cronjob] Error build collection queue with err: %v", err)
+		return err
+	}
+	run.ProcessedCount = queued
+	logger.GetLogger().Infof("[Cronjob] %d collection cases in queue", queued)
+	return nil
+}
+
+// sendBillReminders publish reminders of the upcoming bills, the reminders already sent are skipped so the whole step is retried
+func sendBillReminders(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, run *jobModel.JobRunModel) error {
+	var total int32
+	err := helpers.Retry(ctx, params.Retry, func() error {
+		sent, err := serviceCtx.ReminderService.SendReminders(ctx)
+		total += sent
+		return err
+	})
+	run.ProcessedCount = total
+	if err != nil {
+		logger.GetLogger().Errorf("[Cronjob] Error send bill reminders with err: %v", err)
+		return err
+	}
+	logger.GetLogger().Infof("[Cronjob] %d bill reminders sent", total)
+	return nil
+}
+
+// accrueInterest accrue the daily interest of the active declining balance loans, read page by page and accrued by a
+// bounded pool of workers. Each loan catches up on the days it missed up to yesterday in its timezone, the job runs hourly
+// so every timezone is accrued soon after its day ends. Loans with nothing to accrue are counted as skipped
+func accrueInterest(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, run *jobModel.JobRunModel) error {
+	states := make(chan loanModel.LoanInterestStateModel)
+	var processed, skipped atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < params.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for state := range states {
+				var days int32
+				err := helpers.Retry(ctx, params.Retry, func() error {
+					accrued, err := serviceCtx.LoanService.AccrueInterest(ctx, state)
+					days += accrued
+					return err
+				})
+				if err != nil {
+					logger.GetLogger().Errorf("[Cronjob] Error accrue interest of loan %d with err: %v", state.LoanID, err)
+					_ = serviceCtx.JobService.RecordFailure(ctx, run, loanReference(state.LoanID), err)
+					continue
+				}
+				if days == 0 {
+					skipped.Add(1)
+					continue
+				}
+				processed.Add(1)
+			}
+		}()
+	}
+
+	err := forEachInterestAccrualPage(ctx, serviceCtx, params, func(page []loanModel.LoanInterestStateModel) error {
+		for _, state := range page {
+			select {
+			case states <- state:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
+		}
+		return nil
+	})
+	close(states)
+	wg.Wait()
+
+	run.ProcessedCount = processed.Load()
+	run.SkippedCount = skipped.Load()
+	return err
+}
 
-			if total > 1 {
-				err := serviceCtx.UserService.UpdateUserToDelinquent(ctx, int32(v.UserID))
+// forEachInterestAccrualPage iterates over the active declining balance loans by keyset pagination on the id, like
+// forEachActiveLoanPage
+func forEachInterestAccrualPage(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, fn func(page []loanModel.LoanInterestStateModel) error) error {
+	var afterID int64
+	for {
+		var page []loanModel.LoanInterestStateModel
+		err := helpers.Retry(ctx, params.Retry, func() error {
+			var err error
+			page, err = serviceCtx.LoanService.GetInterestAccrualStates(ctx, afterID, params.BatchSize)
+			return err
+		})
+		if err != nil {
+			logger.GetLogger().Errorf("[Cronjob] Error fetch interest accrual states with err: %v", err)
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		if err := fn(page); err != nil {
+			return err
+		}
+
+		if len(page) < params.BatchSize {
+			return nil
+		}
+		afterID = page[len(page)-1].LoanID
+	}
+}
+
+// collectAutoDebits charge the BILLED bills of the loans with an active mandate, read page by page and charged by a
+// bounded pool of workers. A bill is billed on its billing day in the loan timezone so the hourly run charges it on
+// that day, the failed debits are picked up again once their retry is due. A debit isn't retried within the run, a
+// payment may have been created already, the bills not charged in this run are counted as skipped
+func collectAutoDebits(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, run *jobModel.JobRunModel) error {
+	debits := make(chan mandateModel.DueDebitModel)
+	var processed, skipped atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < params.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for due := range debits {
+				submitted, err := serviceCtx.MandateService.CollectDebit(ctx, due)
 				if err != nil {
-					logger.Fatalf("[Cronjob] Error Update User To Delinquent")
-					return
+					logger.GetLogger().Errorf("[Cronjob] Error collect debit of loan bill %d with err: %v", due.LoanBillID, err)
+					_ = serviceCtx.JobService.RecordFailure(ctx, run, loanBillReference(due.LoanBillID), err)
+					continue
 				}
+				if !submitted {
+					skipped.Add(1)
+					continue
+				}
+				processed.Add(1)
+			}
+		}()
+	}
+
+	err := forEachDueDebitPage(ctx, serviceCtx, params, func(page []mandateModel.DueDebitModel) error {
+		for _, due := range page {
+			select {
+			case debits <- due:
+			case <-ctx.Done():
+				return ctx.Err()
 			}
 		}
+		return nil
+	})
+	close(debits)
+	wg.Wait()
 
-	} else {
-		logger.GetLogger().Info("[Cronjob] There's no active loan at the moment")
+	run.ProcessedCount = processed.Load()
+	run.SkippedCount = skipped.Load()
+	return err
+}
+
+// forEachDueDebitPage iterates over the bills due to be charged through a mandate by keyset pagination on the bill id,
+// like forEachActiveLoanPage
+func forEachDueDebitPage(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, fn func(page []mandateModel.DueDebitModel) error) error {
+	var afterBillID int64
+	for {
+		var page []mandateModel.DueDebitModel
+		err := helpers.Retry(ctx, params.Retry, func() error {
+			var err error
+			page, err = serviceCtx.MandateService.GetDueDebits(ctx, afterBillID, params.BatchSize)
+			return err
+		})
+		if err != nil {
+			logger.GetLogger().Errorf("[Cronjob] Error fetch due debits with err: %v", err)
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		if err := fn(page); err != nil {
+			return err
+		}
+
+		if len(page) < params.BatchSize {
+			return nil
+		}
+		afterBillID = page[len(page)-1].LoanBillID
 	}
+}
 
-	logger.GetLogger().Info("[Cronjob] Cronjob done")
-	return
+func loanBillReference(id int64) string {
+	return fmt.Sprintf("loan_bill:%d", id)
 }
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/okiww/billing-loan-system/configs"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/event"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/okiww/billing-loan-system/pkg/mq"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultDeadLetterPageSize = 20
+
+// dlqCmd represents the dlq command
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "Inspect and replay the dead-lettered messages of the worker queue",
+	Long: `Inspect the messages the worker moved to the dead-letter queue once they ran out of attempts or failed
+permanently, replay them to the worker queue once the cause is fixed or purge them.`,
+}
+
+// dlqListCmd represents the dlq list command
+var dlqListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List a page of the dead-lettered messages with their failure reason and attempts",
+	Run: func(cmd *cobra.Command, args []string) {
+		offset, _ := cmd.Flags().GetInt("offset")
+		limit, _ := cmd.Flags().GetInt("limit")
+		runDeadLetterCommand(cmd, func(dlq mq.DeadLetterQueue, queueName string) error {
+			return listDeadLetters(dlq, queueName, offset, limit, os.Stdout)
+		})
+	},
+}
+
+// dlqShowCmd represents the dlq show command
+var dlqShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a dead-lettered message with its headers and body",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runDeadLetterCommand(cmd, func(dlq mq.DeadLetterQueue, queueName string) error {
+			return showDeadLetter(dlq, queueName, args[0], os.Stdout)
+		})
+	},
+}
+
+// dlqReplayCmd represents the dlq replay command
+var dlqReplayCmd = &cobra.Command{
+	Use:   "replay [id...]",
+	Short: "Move the selected dead-lettered messages, or all of them with --all, back to the worker queue",
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		runDeadLetterCommand(cmd, func(dlq mq.DeadLetterQueue, queueName string) error {
+			return replayDeadLetters(dlq, queueName, args, all, os.Stdout)
+		})
+	},
+}
+
+// dlqPurgeCmd represents the dlq purge command
+var dlqPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Drop every dead-lettered message, once confirmed",
+	Run: func(cmd *cobra.Command, args []string) {
+		yes, _ := cmd.Flags().GetBool("yes")
+		runDeadLetterCommand(cmd, func(dlq mq.DeadLetterQueue, queueName string) error {
+			return purgeDeadLetters(dlq, queueName, yes, os.Stdin, os.Stdout)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dlqCmd)
+	dlqCmd.AddCommand(dlqListCmd)
+	dlqCmd.AddCommand(dlqShowCmd)
+	dlqCmd.AddCommand(dlqReplayCmd)
+	dlqCmd.AddCommand(dlqPurgeCmd)
+
+	dlqCmd.PersistentFlags().String("queue", "", "Queue which dead-letter queue is inspected, defaults to rabbitMq.queueName")
+	dlqListCmd.Flags().Int("offset", 0, "Messages skipped from the head of the dead-letter queue")
+	dlqListCmd.Flags().Int("limit", defaultDeadLetterPageSize, "Messages listed")
+	dlqReplayCmd.Flags().Bool("all", false, "Replay every dead-lettered message")
+	dlqPurgeCmd.Flags().Bool("yes", false, "Purge without asking for confirmation")
+}
+
+// runDeadLetterCommand connects to the message broker and runs a dlq command against the dead-letter queue of the
+// worker queue
+func runDeadLetterCommand(cmd *cobra.Command, run func(dlq mq.DeadLetterQueue, queueName string) error) {
+	cfg := configs.InitConfig()
+	queueName, _ := cmd.Flags().GetString("queue")
+	if queueName == "" {
+		queueName = cfg.RabbitMQ.QueueName
+	}
+
+	// the database is only needed when it holds the messages
+	var db *mysql.DBMySQL
+	if cfg.Queue.Driver == mq.DriverDatabase {
+		var err error
+		db, err = mysql.InitDB(&cfg.DB).Connect()
+		if err != nil {
+			logger.Fatalf("failed to connect db")
+		}
+		defer db.CloseDB()
+	}
+	broker := InitBroker(cfg, db)
+	defer broker.Close()
+
+	dlq, ok := broker.(mq.DeadLetterQueue)
+	if !ok {
+		logger.GetLogger().Fatalf("queue driver %s has no dead-letter queue", cfg.Queue.Driver)
+	}
+	err := run(dlq, queueName)
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to run dlq %s on %s: %v", cmd.Name(), mq.DeadLetterQueueName(queueName), err)
+	}
+}
+
+func listDeadLetters(dlq mq.DeadLetterQueue, queueName string, offset, limit int, out io.Writer) error {
+	if offset < 0 || limit <= 0 {
+		return fmt.Errorf("invalid page offset %d limit %d", offset, limit)
+	}
+	deadLetters, err := dlq.DeadLetters(queueName, offset+limit)
+	if err != nil {
+		return err
+	}
+	if offset >= len(deadLetters) {
+		fmt.Fprintf(out, "No dead-lettered message from offset %d in %s\n", offset, mq.DeadLetterQueueName(queueName))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTYPE\tATTEMPTS\tFAILED AT\tERROR")
+	for _, deadLetter := range deadLetters[offset:] {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", deadLetter.ID, eventTypeOf(deadLetter.Body), deadLetter.Attempts,
+			orDash(deadLetter.FailedAt), orDash(deadLetter.Error))
+	}
+	return w.Flush()
+}
+
+func showDeadLetter(dlq mq.DeadLetterQueue, queueName, id string, out io.Writer) error {
+	deadLetters, err := dlq.DeadLetters(queueName, 0)
+	if err != nil {
+		return err
+	}
+	for _, deadLetter := range deadLetters {
+		if deadLetter.ID != id {
+			continue
+		}
+
+		fmt.Fprintf(out, "ID:        %s\n", deadLetter.ID)
+		fmt.Fprintf(out, "Type:      %s\n", eventTypeOf(deadLetter.Body))
+		fmt.Fprintf(out, "Attempts:  %d\n", deadLetter.Attempts)
+		fmt.Fprintf(out, "Failed at: %s\n", orDash(deadLetter.FailedAt))
+		fmt.Fprintf(out, "Error:     %s\n", orDash(deadLetter.Error))
+		fmt.Fprintln(out, "Headers:")
+		keys := make([]string, 0, len(deadLetter.Headers))
+		for key := range deadLetter.Headers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(out, "  %s: %v\n", key, deadLetter.Headers[key])
+		}
+		fmt.Fprintln(out, "Body:")
+		var body bytes.Buffer
+		if json.Indent(&body, deadLetter.Body, "  ", "  ") != nil {
+			body.Reset()
+			body.Write(deadLetter.Body)
+		}
+		fmt.Fprintf(out, "  %s\n", body.String())
+		return nil
+	}
+	return fmt.Errorf("no dead-lettered message with id %s", id)
+}
+
+func replayDeadLetters(dlq mq.DeadLetterQueue, queueName string, ids []string, all bool, out io.Writer) error {
+	if all == (len(ids) > 0) {
+		return fmt.Errorf("give the ids of the messages to replay or --all")
+	}
+
+	replayed, err := dlq.ReplayDeadLetters(queueName, ids)
+	fmt.Fprintf(out, "%d messages replayed to %s\n", replayed, queueName)
+	if err != nil {
+		return err
+	}
+	if !all && replayed < len(ids) {
+		return fmt.Errorf("%d of the %d messages not found", len(ids)-replayed, len(ids))
+	}
+	return nil
+}
+
+func purgeDeadLetters(dlq mq.DeadLetterQueue, queueName string, yes bool, in io.Reader, out io.Writer) error {
+	deadLetterQueueName := mq.DeadLetterQueueName(queueName)
+	if !yes {
+		deadLetters, err := dlq.DeadLetters(queueName, 0)
+		if err != nil {
+			return err
+		}
+		if len(deadLetters) == 0 {
+			fmt.Fprintf(out, "No dead-lettered message in %s\n", deadLetterQueueName)
+			return nil
+		}
+
+		fmt.Fprintf(out, "Drop the %d messages of %s? They can't be replayed afterwards [y/N]: ", len(deadLetters), deadLetterQueueName)
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Fprintln(out, "Purge canceled")
+			return nil
+		}
+	}
+
+	purged, err := dlq.PurgeDeadLetters(queueName)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%d messages purged from %s\n", purged, deadLetterQueueName)
+	return nil
+}
+
+// eventTypeOf the type of the event a message carries, messages published before the envelope have none
+func eventTypeOf(body []byte) string {
+	envelope, err := event.Decode(body)
+	if err != nil {
+		return "-"
+	}
+	return envelope.Type
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/okiww/billing-loan-system/pkg/mq"
+	"github.com/stretchr/testify/assert"
+)
+
+// newDeadLetterBroker an in-memory broker with dead-lettered payment messages
+func newDeadLetterBroker(t *testing.T, bodies ...string) *mq.Memory {
+	broker := mq.NewMemory()
+	for i, body := range bodies {
+		assert.NoError(t, broker.PublishMessage(mq.DeadLetterQueueName("payments"), body))
+		messages := broker.Messages(mq.DeadLetterQueueName("payments"))
+		messages[i].Headers[mq.HeaderDeadLetterID] = fmt.Sprintf("dead-%c", 'a'+i)
+		messages[i].Headers[mq.HeaderError] = "db unavailable"
+		messages[i].Headers[mq.HeaderAttempt] = int32(5)
+	}
+	return broker
+}
+
+func TestDeadLetterCommands(t *testing.T) {
+	envelope := `{"event_id":"4f1c2a9e-1","type":"payment.created","version":1,"occurred_at":"2024-12-23T10:00:00Z",` +
+		`"correlation_id":"4f1c2a9e-1","payload":{"payment_id":3}}`
+
+	t.Run("List a page of dead letters", func(t *testing.T) {
+		broker := newDeadLetterBroker(t, envelope, `{"ID":3}`, `{"ID":4}`)
+		defer broker.Close()
+
+		var out bytes.Buffer
+		assert.NoError(t, listDeadLetters(broker, "payments", 1, 1, &out))
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		assert.Len(t, lines, 2)
+		assert.Contains(t, lines[0], "ATTEMPTS")
+		assert.Regexp(t, `^dead-b\s+-\s+5\s+-\s+db unavailable$`, lines[1])
+	})
+
+	t.Run("Show a dead letter", func(t *testing.T) {
+		broker := newDeadLetterBroker(t, envelope)
+		defer broker.Close()
+
+		var out bytes.Buffer
+		assert.NoError(t, showDeadLetter(broker, "payments", "dead-a", &out))
+		assert.Contains(t, out.String(), "Type:      payment.created")
+		assert.Contains(t, out.String(), `"payment_id": 3`)
+		assert.Error(t, showDeadLetter(broker, "payments", "unknown", &out))
+	})
+
+	t.Run("Replay selected dead letters", func(t *testing.T) {
+		broker := newDeadLetterBroker(t, `{"ID":3}`, `{"ID":4}`)
+		defer broker.Close()
+
+		var out bytes.Buffer
+		assert.Error(t, replayDeadLetters(broker, "payments", nil, false, &out))
+		assert.Error(t, replayDeadLetters(broker, "payments", []string{"dead-b", "unknown"}, false, &out))
+		assert.Len(t, broker.Messages("payments"), 1)
+		assert.NoError(t, replayDeadLetters(broker, "payments", nil, true, &out))
+		assert.Len(t, broker.Messages("payments"), 2)
+		assert.Empty(t, broker.Messages(mq.DeadLetterQueueName("payments")))
+	})
+
+	t.Run("Purge once confirmed", func(t *testing.T) {
+		broker := newDeadLetterBroker(t, `{"ID":3}`, `{"ID":4}`)
+		defer broker.Close()
+
+		var out bytes.Buffer
+		assert.NoError(t, purgeDeadLetters(broker, "payments", false, strings.NewReader("n\n"), &out))
+		assert.Contains(t, out.String(), "Purge canceled")
+		assert.Len(t, broker.Messages(mq.DeadLetterQueueName("payments")), 2)
+
+		assert.NoError(t, purgeDeadLetters(broker, "payments", false, strings.NewReader("y\n"), &out))
+		assert.Contains(t, out.String(), "2 messages purged")
+		assert.Empty(t, broker.Messages(mq.DeadLetterQueueName("payments")))
+	})
+}
@@ -48,29 +48,13 @@ func ServeHttp() {
 	if err != nil {
 		log.Fatalf("failed to connect db")
 	}
+	db.Clock = InitClock(cfg.Clock)
 
-	// initial rabbitMQ
-	rabbitMQ, err := mq.NewRabbitMQ(cfg.RabbitMQ.Dsn)
-	if err != nil {
-		logger.GetLogger().Fatalf("failed to connect to RabbitMQ: %v", err)
-		return
-	}
-	defer rabbitMQ.Close()
-
-	// initial domain context
-	domainCtx := InitCtx(db, rabbitMQ, &cfg.RabbitMQ)
+	// initial message broker
+	broker := InitBroker(cfg, db)
+	defer broker.Close()
 
-	// initial router
-	router := mux.NewRouter()
-	rest.RegisterRoutes(router, rest.Domain{
-		Domain: domainCtx,
-	})
-
-	// Create the HTTP server
-	server := &http.Server{
-		Addr:    cfg.Http.Addr,
-		Handler: router,
-	}
+	server := newHttpServer(cfg, db, broker)
 
 	// Run the server in a separate goroutine
 	go func() {
@@ -107,3 +91,20 @@ func ServeHttp() {
 
 	log.Println("Server exited properly")
 }
+
+// newHttpServer builds the HTTP server of the API, the payment messages are published through publisher
+func newHttpServer(cfg configs.Config, db *mysql.DBMySQL, publisher mq.Publisher) *http.Server {
+	// initial domain context
+	domainCtx := InitCtx(db, publisher, &cfg.RabbitMQ, cfg.AutoDebit)
+
+	// initial router
+	router := mux.NewRouter()
+	rest.RegisterRoutes(router, rest.Domain{
+		Domain: domainCtx,
+	})
+
+	return &http.Server{
+		Addr:    cfg.Http.Addr,
+		Handler: router,
+	}
+}
@@ -0,0 +1,296 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/okiww/billing-loan-system/configs"
+	"github.com/okiww/billing-loan-system/helpers"
+	"github.com/okiww/billing-loan-system/internal/ctx/servicectx"
+	"github.com/okiww/billing-loan-system/internal/dto"
+	jobModel "github.com/okiww/billing-loan-system/internal/job/models"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/robfig/cron/v3"
+
+	"github.com/spf13/cobra"
+)
+
+// Job is a background job run by the scheduler, it declares its name and default schedule
+type Job interface {
+	Name() string
+	Description() string
+	DefaultSchedule() string
+	Run(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, run *jobModel.JobRunModel) error
+}
+
+// JobParams are the inputs of a job run, empty Dates means the job runs as of now
+type JobParams struct {
+	Dates     []time.Time
+	Location  *time.Location // Scheduler timezone, the dates are calendar days of this timezone
+	BatchSize int            // Loans fetched per page
+	Workers   int            // Loans processed concurrently
+	Retry     helpers.RetryPolicy
+}
+
+const (
+	defaultJobBatchSize = 500
+	defaultJobWorkers   = 8
+)
+
+// defaultJobRetryPolicy retries the transient errors of a job step for about a second before giving up on it
+var defaultJobRetryPolicy = helpers.RetryPolicy{
+	Attempts:     4,
+	InitialDelay: 100 * time.Millisecond,
+	MaxDelay:     time.Second,
+}
+
+// DryRunner is implemented by the jobs able to print the changes they would make without writing anything
+type DryRunner interface {
+	DryRun(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, out io.Writer) error
+}
+
+type job struct {
+	name            string
+	description     string
+	defaultSchedule string
+	run             func(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, run *jobModel.JobRunModel) error
+	dryRun          func(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, out io.Writer) error
+}
+
+func (j *job) Name() string            { return j.name }
+func (j *job) Description() string     { return j.description }
+func (j *job) DefaultSchedule() string { return j.defaultSchedule }
+
+func (j *job) Run(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, run *jobModel.JobRunModel) error {
+	return j.run(ctx, serviceCtx, params, run)
+}
+
+func (j *job) DryRun(ctx context.Context, serviceCtx servicectx.ServiceCtx, params JobParams, out io.Writer) error {
+	if j.dryRun == nil {
+		return fmt.Errorf("job %s does not support --dry-run", j.name)
+	}
+	return j.dryRun(ctx, serviceCtx, params, out)
+}
+
+// JobSchedule is a registered job with the schedule resolved from the configuration
+type JobSchedule struct {
+	Job
+	Schedule string
+	Enabled  bool
+}
+
+// JobRegistry holds the registered jobs in registration order
+type JobRegistry struct {
+	jobs     []Job
+	config   configs.SchedulerConfig
+	location *time.Location
+}
+
+// NewJobRegistry creates a registry evaluating the schedules in the configured timezone
+func NewJobRegistry(config configs.SchedulerConfig) (*JobRegistry, error) {
+	location := time.Local
+	if config.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scheduler timezone %s: %v", config.Timezone, err)
+		}
+	}
+
+	return &JobRegistry{config: config, location: location}, nil
+}
+
+func (r *JobRegistry) Register(jobs ...Job) {
+	r.jobs = append(r.jobs, jobs...)
+}
+
+func (r *JobRegistry) Get(name string) (Job, bool) {
+	for _, j := range r.jobs {
+		if j.Name() == name {
+			return j, true
+		}
+	}
+	return nil, false
+}
+
+func (r *JobRegistry) Location() *time.Location {
+	return r.location
+}
+
+// Params builds the inputs of a job run for the given dates with the configured batch size and workers
+func (r *JobRegistry) Params(dates []time.Time) JobParams {
+	params := JobParams{
+		Dates:     dates,
+		Location:  r.location,
+		BatchSize: r.config.BatchSize,
+		Workers:   r.config.Workers,
+		Retry:     defaultJobRetryPolicy,
+	}
+	if params.BatchSize <= 0 {
+		params.BatchSize = defaultJobBatchSize
+	}
+	if params.Workers <= 0 {
+		params.Workers = defaultJobWorkers
+	}
+	return params
+}
+
+// Schedules resolves the schedule and enablement of every job, configuration overrides the job defaults
+func (r *JobRegistry) Schedules() ([]JobSchedule, error) {
+	schedules := make([]JobSchedule, 0, len(r.jobs))
+	for _, j := range r.jobs {
+		schedule := JobSchedule{Job: j, Schedule: j.DefaultSchedule(), Enabled: true}
+		if override, ok := r.config.Jobs[j.Name()]; ok {
+			if override.Schedule != "" {
+				schedule.Schedule = override.Schedule
+			}
+			if override.Enabled != nil {
+				schedule.Enabled = *override.Enabled
+			}
+		}
+
+		if _, err := cron.ParseStandard(schedule.Schedule); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q for job %s: %v", schedule.Schedule, j.Name(), err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// NextRun get the next fire time of a schedule after the given time, in the registry timezone
+func (r *JobRegistry) NextRun(schedule string, after time.Time) (time.Time, error) {
+	parsed, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parsed.Next(after.In(r.location)), nil
+}
+
+// jobsCmd represents the jobs command
+var jobsCmd = &cobra.Command{
+	Use:     "jobs",
+	Aliases: []string{"job"},
+	Short:   "Manage the background jobs",
+}
+
+// jobsListCmd represents the jobs list command
+var jobsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the registered background jobs with their schedule and next run",
+	Run: func(cmd *cobra.Command, args []string) {
+		listJobs()
+	},
+}
+
+// jobsRunCmd represents the jobs run command
+var jobsRunCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Run a single registered background job once",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		date, _ := cmd.Flags().GetString("date")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		runJobOnce(args[0], date, dryRun)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(jobsCmd)
+	jobsCmd.AddCommand(jobsListCmd)
+	jobsCmd.AddCommand(jobsRunCmd)
+
+	jobsRunCmd.Flags().String("date", "", "Run the job as of this date (YYYY-MM-DD), defaults to now")
+	jobsRunCmd.Flags().Bool("dry-run", false, "Print the changes the job would make without writing anything")
+}
+
+func listJobs() {
+	cfg := configs.InitConfig()
+	registry, err := newBackgroundJobRegistry(cfg.Scheduler)
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to load jobs: %v", err)
+	}
+
+	schedules, err := registry.Schedules()
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to load jobs: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tSCHEDULE\tENABLED\tNEXT RUN (%s)\tDESCRIPTION\n", registry.Location())
+	for _, schedule := range schedules {
+		nextRun := "-"
+		if schedule.Enabled {
+			next, _ := registry.NextRun(schedule.Schedule, time.Now())
+			nextRun = next.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n", schedule.Name(), schedule.Schedule, schedule.Enabled, nextRun, schedule.Description())
+	}
+	w.Flush()
+}
+
+func runJobOnce(name, date string, dryRun bool) {
+	cfg := configs.InitConfig()
+	registry, err := newBackgroundJobRegistry(cfg.Scheduler)
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to load jobs: %v", err)
+	}
+
+	j, ok := registry.Get(name)
+	if !ok {
+		logger.GetLogger().Fatalf("job %s is not registered, see `billing jobs list`", name)
+	}
+
+	var dates []time.Time
+	if date != "" {
+		asOf, err := time.ParseInLocation(dto.DateFormat, date, registry.Location())
+		if err != nil {
+			logger.GetLogger().Fatalf("--date must be in YYYY-MM-DD format")
+		}
+		dates = []time.Time{asOf}
+	}
+	params := registry.Params(dates)
+
+	// initial connection to database
+	dbInit := mysql.InitDB(&cfg.DB)
+	db, err := dbInit.Connect()
+	if err != nil {
+		logger.Fatalf("failed to connect db")
+	}
+	db.Clock = InitClock(cfg.Clock)
+
+	broker := initBackgroundBroker(cfg, db)
+	defer broker.Close()
+
+	serviceCtx := newBackgroundServiceCtx(db, broker, cfg)
+	ctx := context.Background()
+
+	if dryRun {
+		dryRunner, ok := j.(DryRunner)
+		if !ok {
+			logger.GetLogger().Fatalf("job %s does not support --dry-run", name)
+		}
+		if err := dryRunner.DryRun(ctx, serviceCtx, params, os.Stdout); err != nil {
+			logger.GetLogger().Fatalf("failed to dry-run job %s: %v", name, err)
+		}
+		return
+	}
+
+	run, err := runJob(ctx, serviceCtx, j, params)
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to run job %s: %v", name, err)
+	}
+	if run.Status == jobModel.JobRunStatusSkipped {
+		fmt.Printf("job %s skipped, %s\n", name, run.Error)
+		return
+	}
+	fmt.Printf("job %s finished with status %s, processed %d, failed %d, skipped %d\n",
+		name, run.Status, run.ProcessedCount, run.FailedCount, run.SkippedCount)
+}
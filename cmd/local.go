@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/okiww/billing-loan-system/configs"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/okiww/billing-loan-system/pkg/mq"
+
+	"github.com/spf13/cobra"
+)
+
+// localCmd represents the local command
+var localCmd = &cobra.Command{
+	Use:   "local",
+	Short: "Run the http server, the relay and the worker in one process",
+	Long: `Run the http server, the outbox relay and the payment worker in one process over an in-memory broker, so the
+payment flow runs end to end locally without RabbitMQ. Messages are kept in memory and lost when the process exits.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		RunLocal()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(localCmd)
+}
+
+func RunLocal() {
+	cfg := configs.InitConfig()
+
+	// initial connection to database
+	dbInit := mysql.InitDB(&cfg.DB)
+	db, err := dbInit.Connect()
+	if err != nil {
+		logger.Fatalf("failed to connect db")
+	}
+	db.Clock = InitClock(cfg.Clock)
+
+	broker := mq.NewMemory()
+	defer broker.Close()
+
+	options := mq.NewConsumeOptions(cfg.RabbitMQ, loanKeyOf)
+	err = broker.DeclareQueueWithRetry(cfg.RabbitMQ.QueueName, options.Policy)
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to declare queue %s: %v", cfg.RabbitMQ.QueueName, err)
+		return
+	}
+	err = bindPaymentQueue(broker, cfg.RabbitMQ)
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to bind queue %s: %v", cfg.RabbitMQ.QueueName, err)
+		return
+	}
+
+	relay, err := newRelay(cfg, db, broker)
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to init relay: %v", err)
+		return
+	}
+	serviceCtx := newWorkerServiceCtx(db)
+	server := newHttpServer(cfg, db, broker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go runRelay(ctx, relay, relayPollInterval(cfg.Outbox))
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		err := runWorker(ctx, broker, &serviceCtx, cfg.RabbitMQ.QueueName, options)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logger.GetLogger().Fatalf("failed to consume messages from queue %s: %v", cfg.RabbitMQ.QueueName, err)
+		}
+	}()
+	go func() {
+		logger.GetLogger().Infof("Local server running on port %s with an in-memory broker", cfg.Http.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.GetLogger().Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	// Wait for termination signal
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	<-signalChan
+	logger.GetLogger().Info("Graceful shutdown: local stopping...")
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		logger.GetLogger().Errorf("Server forced to shutdown: %v", err)
+	}
+	cancel()
+	drainWorker(stopped, workerDrainTimeout(cfg.RabbitMQ))
+	if err := db.CloseDB(); err != nil {
+		logger.GetLogger().Errorf("failed close db %s", err.Error())
+	}
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/okiww/billing-loan-system/configs"
+	collection_mock "github.com/okiww/billing-loan-system/gen/mocks/collection"
+	loan_mock "github.com/okiww/billing-loan-system/gen/mocks/loan"
+	outbox_mock "github.com/okiww/billing-loan-system/gen/mocks/outbox"
+	payment_mock "github.com/okiww/billing-loan-system/gen/mocks/payment"
+	user_mock "github.com/okiww/billing-loan-system/gen/mocks/user"
+	"github.com/okiww/billing-loan-system/internal/ctx/servicectx"
+	outboxModel "github.com/okiww/billing-loan-system/internal/outbox/models"
+	outboxService "github.com/okiww/billing-loan-system/internal/outbox/services"
+	"github.com/okiww/billing-loan-system/internal/payment/models"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/okiww/billing-loan-system/pkg/mq"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPaymentFlow relays a payment written to the outbox through the exchange of the in-memory broker to the worker
+func TestPaymentFlow(t *testing.T) {
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	policy := mq.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	payment := models.Payment{ID: 3, UserID: 123, LoanID: 2, LoanBillID: 1, Amount: 1375000, Status: models.StatusPending}
+	recovery := payment
+	recovery.PaymentType = models.PaymentTypeRecovery
+
+	type mocks struct {
+		payment    *payment_mock.MockPaymentServiceInterface
+		collection *collection_mock.MockCollectionServiceInterface
+		loan       *loan_mock.MockLoanServiceInterface
+		user       *user_mock.MockUserServiceInterface
+	}
+
+	tests := []struct {
+		name      string
+		payload   string
+		mockCalls func(m mocks, done func())
+		wantDead  bool
+	}{
+		{
+			name: "Success process payment and cure delinquent user",
+			payload: `{"event_id":"4f1c2a9e-1","type":"payment.created","version":1,"occurred_at":"2024-12-23T10:00:00Z",` +
+				`"correlation_id":"4f1c2a9e-1","payload":{"payment_id":3,"user_id":123,"loan_id":2,"loan_bill_id":1,"amount":1375000,"status":"PENDING"}}`,
+			mockCalls: func(m mocks, done func()) {
+				m.payment.EXPECT().ProcessUpdatePayment(gomock.Any(), "4f1c2a9e-1", payment).Return(nil)
+				m.collection.EXPECT().ResolvePromises(gomock.Any(), int64(2)).Return(nil)
+				m.loan.EXPECT().CountLoanBillOverdueStatusesByID(gomock.Any(), int32(2)).Return(int32(1), nil)
+				m.user.EXPECT().IsDelinquent(gomock.Any(), int32(123)).Return(true, nil)
+				m.user.EXPECT().UpdateUserToNotDelinquent(gomock.Any(), int32(123)).DoAndReturn(func(ctx context.Context, userID int32) error {
+					done()
+					return nil
+				})
+			},
+		},
+		{
+			name:    "Success process recovery payment published before the envelope once retried",
+			payload: `{"ID":3,"UserID":123,"LoanID":2,"LoanBillID":1,"Amount":1375000,"PaymentType":"RECOVERY","Status":"PENDING"}`,
+			mockCalls: func(m mocks, done func()) {
+				gomock.InOrder(
+					m.payment.EXPECT().ProcessUpdatePayment(gomock.Any(), "payment.created:3", recovery).Return(errors.New("deadlock")),
+					m.payment.EXPECT().ProcessUpdatePayment(gomock.Any(), "payment.created:3", recovery).Return(nil),
+				)
+				m.collection.EXPECT().ResolvePromises(gomock.Any(), int64(2)).DoAndReturn(func(ctx context.Context, loanID int64) error {
+					done()
+					return nil
+				})
+			},
+		},
+		{
+			name:      "Dead-letter payload which can't be decoded",
+			payload:   `{"ID":"3"}`,
+			mockCalls: func(m mocks, done func()) {},
+			wantDead:  true,
+		},
+		{
+			name: "Dead-letter event without handler",
+			payload: `{"event_id":"4f1c2a9e-2","type":"payment.created","version":2,"occurred_at":"2024-12-23T10:00:00Z",` +
+				`"correlation_id":"4f1c2a9e-2","payload":{"payment_id":3}}`,
+			mockCalls: func(m mocks, done func()) {},
+			wantDead:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks{
+				payment:    payment_mock.NewMockPaymentServiceInterface(ctrl),
+				collection: collection_mock.NewMockCollectionServiceInterface(ctrl),
+				loan:       loan_mock.NewMockLoanServiceInterface(ctrl),
+				user:       user_mock.NewMockUserServiceInterface(ctrl),
+			}
+			processed := make(chan struct{})
+			tt.mockCalls(m, func() { close(processed) })
+
+			broker := mq.NewMemory()
+			defer broker.Close()
+			assert.NoError(t, broker.DeclareQueueWithRetry("payments", policy))
+			assert.NoError(t, bindPaymentQueue(broker, configs.RabbitMQConfig{QueueName: "payments"}))
+
+			mockOutboxRepo := outbox_mock.NewMockOutboxRepositoryInterface(ctrl)
+			mockOutboxRepo.EXPECT().ClaimPendingMessages(gomock.Any(), 10, outboxModel.DefaultClaimTimeout).Return([]outboxModel.OutboxMessageModel{{
+				ID: 1, AggregateType: outboxModel.AggregatePayment, AggregateID: 3, EventType: outboxModel.EventPaymentCreated,
+				Payload: tt.payload, Status: outboxModel.StatusPending,
+			}}, nil)
+			mockOutboxRepo.EXPECT().MarkSent(gomock.Any(), int64(1)).Return(nil)
+			cfg := configs.Config{RabbitMQ: configs.RabbitMQConfig{QueueName: "payments"}, Outbox: configs.OutboxConfig{BatchSize: 10}}
+			relay := outboxService.NewOutboxService(mockOutboxRepo, broker, outboxRoutes(cfg), cfg.Outbox)
+
+			serviceCtx := servicectx.ServiceCtx{
+				PaymentService:    m.payment,
+				CollectionService: m.collection,
+				LoanService:       m.loan,
+				UserService:       m.user,
+				Clock:             clock.Fixed(now),
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				_ = runWorker(ctx, broker, &serviceCtx, "payments", mq.ConsumeOptions{Policy: policy, Concurrency: 2, Prefetch: 4, Key: loanKeyOf})
+			}()
+
+			sent, err := relay.RelayPending(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, int32(1), sent)
+
+			if tt.wantDead {
+				assert.Eventually(t, func() bool {
+					return len(broker.Messages(mq.DeadLetterQueueName("payments"))) == 1
+				}, time.Second, time.Millisecond)
+				return
+			}
+			select {
+			case <-processed:
+			case <-time.After(time.Second):
+				t.Fatal("payment not processed by the worker")
+			}
+			assert.Empty(t, broker.Messages(mq.DeadLetterQueueName("payments")))
+		})
+	}
+}
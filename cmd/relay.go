@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/okiww/billing-loan-system/configs"
+	outboxModel "github.com/okiww/billing-loan-system/internal/outbox/models"
+	outboxRepo "github.com/okiww/billing-loan-system/internal/outbox/repositories"
+	outboxService "github.com/okiww/billing-loan-system/internal/outbox/services"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/okiww/billing-loan-system/pkg/mq"
+
+	"github.com/spf13/cobra"
+)
+
+// relayCmd represents the relay command
+var relayCmd = &cobra.Command{
+	Use:   "relay",
+	Short: "Publish the outbox messages to RabbitMQ",
+	Long: `Publish the events written to the outbox in the same transaction as the changes they announce to the RabbitMQ
+topic exchange, routed by their type, oldest first. A message which couldn't be published is retried with backoff until outbox.maxAttempts,
+the later messages are published meanwhile so the events of a loan or payment may arrive out of order.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		InitRelay()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(relayCmd)
+}
+
+func InitRelay() {
+	cfg := configs.InitConfig()
+
+	// initial connection to database
+	dbInit := mysql.InitDB(&cfg.DB)
+	db, err := dbInit.Connect()
+	if err != nil {
+		logger.Fatalf("failed to connect db")
+	}
+	db.Clock = InitClock(cfg.Clock)
+
+	// initial message broker
+	broker := InitBroker(cfg, db)
+	defer broker.Close()
+
+	relay, err := newRelay(cfg, db, broker)
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to init relay: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		<-signalChan
+		logger.GetLogger().Info("Graceful shutdown: relay stopping...")
+		cancel()
+	}()
+
+	pollInterval := relayPollInterval(cfg.Outbox)
+	logger.GetLogger().Infof("Relay started, polling the outbox every %s. Press CTRL+C to stop.", pollInterval)
+	runRelay(ctx, relay, pollInterval)
+}
+
+// newRelay declares the exchange the outbox messages are published to and builds the relay publishing to it
+func newRelay(cfg configs.Config, db *mysql.DBMySQL, broker mq.Broker) (outboxService.OutboxServiceInterface, error) {
+	err := broker.DeclareQueue(cfg.RabbitMQ.QueueName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare queue %s: %v", cfg.RabbitMQ.QueueName, err)
+	}
+	err = bindPaymentQueue(broker, cfg.RabbitMQ)
+	if err != nil {
+		return nil, err
+	}
+
+	return outboxService.NewOutboxService(outboxRepo.NewOutboxRepository(db), broker, outboxRoutes(cfg), cfg.Outbox), nil
+}
+
+// relayPollInterval how long the relay waits for new outbox messages once the outbox is drained
+func relayPollInterval(cfg configs.OutboxConfig) time.Duration {
+	if cfg.PollIntervalMs > 0 {
+		return time.Duration(cfg.PollIntervalMs) * time.Millisecond
+	}
+	return outboxModel.DefaultPollInterval
+}
+
+// outboxRoutes maps every event type written to the outbox to the exchange it is published to, routed by its type
+func outboxRoutes(cfg configs.Config) map[string]outboxModel.Route {
+	exchange := eventExchange(cfg.RabbitMQ)
+	routes := map[string]outboxModel.Route{
+		outboxModel.EventPaymentCreated: {Exchange: exchange, RoutingKey: outboxModel.EventPaymentCreated},
+	}
+	for _, eventType := range outboxModel.DomainEvents {
+		routes[eventType] = outboxModel.Route{Exchange: exchange, RoutingKey: eventType}
+	}
+	return routes
+}
+
+// eventExchange the topic exchange the events are published to
+func eventExchange(cfg configs.RabbitMQConfig) string {
+	if cfg.Exchange != "" {
+		return cfg.Exchange
+	}
+	return outboxModel.DefaultExchange
+}
+
+// bindPaymentQueue declares the event exchange and binds the declared payment queue to the payment.created events
+func bindPaymentQueue(broker mq.Broker, cfg configs.RabbitMQConfig) error {
+	exchange := eventExchange(cfg)
+	err := broker.DeclareExchange(exchange)
+	if err != nil {
+		return fmt.Errorf("failed to declare exchange %s: %v", exchange, err)
+	}
+	err = broker.BindQueue(cfg.QueueName, exchange, outboxModel.EventPaymentCreated)
+	if err != nil {
+		return fmt.Errorf("failed to bind queue %s to exchange %s: %v", cfg.QueueName, exchange, err)
+	}
+	return nil
+}
+
+// runRelay publishes the pending outbox messages until ctx is done, a full batch is followed by the next one right
+// away so a backlog is drained without waiting for the poll interval
+func runRelay(ctx context.Context, relay outboxService.OutboxServiceInterface, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			sent, err := relay.RelayPending(ctx)
+			if err != nil {
+				logger.GetLogger().Errorf("[Relay] Error relay outbox messages with err: %v", err)
+				break
+			}
+			if sent > 0 {
+				logger.GetLogger().Infof("[Relay] %d outbox messages published", sent)
+			}
+			if int(sent) < relay.BatchSize() {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,38 +1,85 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/okiww/billing-loan-system/configs"
 	billingConfigRepo "github.com/okiww/billing-loan-system/internal/billing_config/repositories"
+	collectionRepo "github.com/okiww/billing-loan-system/internal/collection/repositories"
+	collectionService "github.com/okiww/billing-loan-system/internal/collection/services"
 	"github.com/okiww/billing-loan-system/internal/ctx/servicectx"
 	"github.com/okiww/billing-loan-system/internal/loan/repositories"
 	"github.com/okiww/billing-loan-system/internal/loan/services"
+	mandateRepo "github.com/okiww/billing-loan-system/internal/mandate/repositories"
+	mandateService "github.com/okiww/billing-loan-system/internal/mandate/services"
 	paymentRepo "github.com/okiww/billing-loan-system/internal/payment/repositories"
 	paymentService "github.com/okiww/billing-loan-system/internal/payment/services"
 	userRepo "github.com/okiww/billing-loan-system/internal/user/repositories"
 	userService "github.com/okiww/billing-loan-system/internal/user/services"
+	"github.com/okiww/billing-loan-system/pkg/clock"
 	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/logger"
 	"github.com/okiww/billing-loan-system/pkg/mq"
 	"github.com/okiww/billing-loan-system/port/rest/handlerctx"
 	"github.com/okiww/billing-loan-system/port/rest/handlers"
 )
 
-func InitCtx(db *mysql.DBMySQL, mq *mq.RabbitMQ, rabbitMQCfg *configs.RabbitMQConfig) handlerctx.HandlerCtx {
+func InitCtx(db *mysql.DBMySQL, publisher mq.Publisher, rabbitMQCfg *configs.RabbitMQConfig, autoDebitCfg configs.AutoDebitConfig) handlerctx.HandlerCtx {
 	loanRepository := repositories.NewLoanRepository(db)
 	loanBillRepository := repositories.NewLoanBillRepository(db)
 	userRepository := userRepo.NewUserRepository(db)
 	paymentRepository := paymentRepo.NewPaymentRepository(db)
 	billingConfigRepository := billingConfigRepo.NewBillingConfigRepository(db)
+	collectionRepository := collectionRepo.NewCollectionRepository(db)
+	mandateRepository := mandateRepo.NewMandateRepository(db)
+	paymentSvc := paymentService.NewPaymentService(paymentRepository, loanRepository, loanBillRepository, db.Clock)
 
 	serviceCtx := servicectx.ServiceCtx{
-		LoanService:    services.NewLoanService(loanRepository, loanBillRepository, billingConfigRepository),
-		UserService:    userService.NewUserService(userRepository),
-		PaymentService: paymentService.NewPaymentService(paymentRepository, loanRepository, loanBillRepository),
+		LoanService:       services.NewLoanService(loanRepository, loanBillRepository, billingConfigRepository, db.Clock),
+		UserService:       userService.NewUserService(userRepository),
+		PaymentService:    paymentSvc,
+		CollectionService: collectionService.NewCollectionService(collectionRepository, db.Clock),
+		MandateService:    mandateService.NewMandateService(mandateRepository, loanRepository, paymentSvc, autoDebitCfg, db.Clock),
+		Clock:             db.Clock,
 	}
 
 	handlerCtx := handlerctx.HandlerCtx{
-		LoanHandler:    handlers.NewLoanHandler(serviceCtx),
-		PaymentHandler: handlers.NewPaymentHandler(serviceCtx, mq, rabbitMQCfg),
+		LoanHandler:       handlers.NewLoanHandler(serviceCtx),
+		PaymentHandler:    handlers.NewPaymentHandler(serviceCtx, publisher, rabbitMQCfg),
+		CollectionHandler: handlers.NewCollectionHandler(serviceCtx),
+		MandateHandler:    handlers.NewMandateHandler(serviceCtx),
 	}
 
 	return handlerCtx
 }
+
+// InitClock builds the clock of the application from the configuration, it is the wall clock unless time travel is configured
+func InitClock(cfg configs.ClockConfig) clock.Clock {
+	appClock, err := clock.New(cfg)
+	if err != nil {
+		logger.GetLogger().Fatalf("failed to init clock: %v", err)
+	}
+
+	if cfg.TravelTo != "" {
+		logger.GetLogger().Warnf("clock travelled to %s, frozen: %t", appClock.Now().Format(time.DateTime), cfg.Frozen)
+	}
+	return appClock
+}
+
+// InitBroker connects to the message broker picked by queue.driver, RabbitMQ unless the messages are kept in the
+// database so the application runs on MySQL alone
+func InitBroker(cfg configs.Config, db *mysql.DBMySQL) mq.Broker {
+	switch cfg.Queue.Driver {
+	case "", mq.DriverRabbitMQ:
+		rabbitMQ, err := mq.NewRabbitMQ(cfg.RabbitMQ.Dsn)
+		if err != nil {
+			logger.GetLogger().Fatalf("failed to connect to RabbitMQ: %v", err)
+		}
+		return rabbitMQ
+	case mq.DriverDatabase:
+		return mq.NewDatabase(db, cfg.Queue)
+	default:
+		logger.GetLogger().Fatalf("unknown queue driver %q, expected %s or %s", cfg.Queue.Driver, mq.DriverRabbitMQ, mq.DriverDatabase)
+		return nil
+	}
+}
@@ -6,17 +6,30 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/okiww/billing-loan-system/configs"
+	billingConfigRepo "github.com/okiww/billing-loan-system/internal/billing_config/repositories"
+	collectionRepo "github.com/okiww/billing-loan-system/internal/collection/repositories"
+	collectionService "github.com/okiww/billing-loan-system/internal/collection/services"
 	"github.com/okiww/billing-loan-system/internal/ctx/servicectx"
 	loanRepo "github.com/okiww/billing-loan-system/internal/loan/repositories"
+	loanService "github.com/okiww/billing-loan-system/internal/loan/services"
+	outboxModel "github.com/okiww/billing-loan-system/internal/outbox/models"
+	outboxService "github.com/okiww/billing-loan-system/internal/outbox/services"
 	"github.com/okiww/billing-loan-system/internal/payment/models"
 	paymentRepo "github.com/okiww/billing-loan-system/internal/payment/repositories"
 	"github.com/okiww/billing-loan-system/internal/payment/services"
+	userRepo "github.com/okiww/billing-loan-system/internal/user/repositories"
+	userService "github.com/okiww/billing-loan-system/internal/user/services"
 	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/event"
 	"github.com/okiww/billing-loan-system/pkg/logger"
 	"github.com/okiww/billing-loan-system/pkg/mq"
 
@@ -51,92 +64,196 @@ func InitWorker() {
 	if err != nil {
 		logger.Fatalf("failed to connect db")
 	}
+	db.Clock = InitClock(cfg.Clock)
 
-	// initial connection to rabbitMQ
-	rabbitMQ, err := mq.NewRabbitMQ(cfg.RabbitMQ.Dsn)
+	// initial message broker
+	broker := InitBroker(cfg, db)
+	defer broker.Close() // Ensure connection is closed when the function exits
+
+	// a failed message is retried with backoff through delay queues and dead-lettered once it runs out of attempts,
+	// the messages are handled concurrently by lanes keyed by loan so the payments of a loan are processed in order
+	options := mq.NewConsumeOptions(cfg.RabbitMQ, loanKeyOf)
+	err = broker.DeclareQueueWithRetry(cfg.RabbitMQ.QueueName, options.Policy)
 	if err != nil {
-		logger.GetLogger().Fatalf("failed to connect to RabbitMQ: %v", err)
+		logger.GetLogger().Fatalf("failed to declare queue %s: %v", cfg.RabbitMQ.QueueName, err)
 		return
 	}
-	defer rabbitMQ.Close() // Ensure connection is closed when the function exits
-
-	_, err = rabbitMQ.DeclareQueue(cfg.RabbitMQ.QueueName)
+	err = bindPaymentQueue(broker, cfg.RabbitMQ)
 	if err != nil {
-		logger.GetLogger().Fatalf("failed to declare queue %s: %v", cfg.RabbitMQ.QueueName, err)
+		logger.GetLogger().Fatalf("failed to bind queue %s: %v", cfg.RabbitMQ.QueueName, err)
 		return
 	}
 
-	// initial domain context
-	loanRepository := loanRepo.NewLoanRepository(db)
-	loanBillRepository := loanRepo.NewLoanBillRepository(db)
-	paymentRepository := paymentRepo.NewPaymentRepository(db)
-
-	serviceCtx := servicectx.ServiceCtx{
-		PaymentService: services.NewPaymentService(paymentRepository, loanRepository, loanBillRepository),
+	// without RabbitMQ there is no separate relay to run, the worker publishes the outbox messages itself
+	var relay outboxService.OutboxServiceInterface
+	if cfg.Queue.Driver == mq.DriverDatabase {
+		relay, err = newRelay(cfg, db, broker)
+		if err != nil {
+			logger.GetLogger().Fatalf("failed to init relay: %v", err)
+			return
+		}
 	}
 
-	messages, err := rabbitMQ.ConsumeMessages(cfg.RabbitMQ.QueueName)
-	if err != nil {
-		logger.GetLogger().Fatalf("failed to consume messages from queue %s: %v", cfg.RabbitMQ.QueueName, err)
-		return
-	}
+	serviceCtx := newWorkerServiceCtx(db)
 
-	logger.GetLogger().Info("Worker started, waiting for messages. Press CTRL+C to stop.")
+	logger.GetLogger().Infof("Worker started with %d lanes, waiting for messages. Press CTRL+C to stop.", options.Concurrency)
 
 	// Channel to listen for OS signals (e.g., SIGINT, SIGTERM)
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
-	// Process messages in a goroutine
+	// Process messages in a goroutine, a message is acknowledged once processed
+	ctx, cancel := context.WithCancel(context.Background())
+	if relay != nil {
+		go runRelay(ctx, relay, relayPollInterval(cfg.Outbox))
+	}
+	stopped := make(chan struct{})
 	go func() {
-		for msg := range messages {
-			logger.GetLogger().Infof("Received message: %s", string(msg.Body))
-			err := processPayment(context.Background(), &serviceCtx, msg.Body)
-			if err != nil {
-				logger.GetLogger().Errorf("Failed to process message: %v", err)
-			} else {
-				logger.GetLogger().Info("Message processed successfully")
-			}
+		defer close(stopped)
+		err := runWorker(ctx, broker, &serviceCtx, cfg.RabbitMQ.QueueName, options)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logger.GetLogger().Fatalf("failed to consume messages from queue %s: %v", cfg.RabbitMQ.QueueName, err)
 		}
 	}()
 
 	// Wait for termination signal
 	<-signalChan
 	logger.GetLogger().Info("Graceful shutdown: worker stopping...")
+	cancel()
+	drainWorker(stopped, workerDrainTimeout(cfg.RabbitMQ))
+}
+
+// drainWorker waits for the worker to handle the messages in flight once it stops consuming, a message still in
+// flight after timeout isn't acknowledged and is delivered again
+func drainWorker(stopped <-chan struct{}, timeout time.Duration) {
+	select {
+	case <-stopped:
+		logger.GetLogger().Info("Worker drained")
+	case <-time.After(timeout):
+		logger.GetLogger().Warnf("Worker not drained after %s, the messages in flight are delivered again", timeout)
+	}
+}
+
+// workerDrainTimeout how long the worker waits for the messages in flight on shutdown
+func workerDrainTimeout(cfg configs.RabbitMQConfig) time.Duration {
+	if cfg.DrainTimeoutMs > 0 {
+		return time.Duration(cfg.DrainTimeoutMs) * time.Millisecond
+	}
+	return mq.DefaultDrainTimeout
+}
+
+// newWorkerServiceCtx initial domain context of the worker
+func newWorkerServiceCtx(db *mysql.DBMySQL) servicectx.ServiceCtx {
+	loanRepository := loanRepo.NewLoanRepository(db)
+	loanBillRepository := loanRepo.NewLoanBillRepository(db)
+	paymentRepository := paymentRepo.NewPaymentRepository(db)
+	userRepository := userRepo.NewUserRepository(db)
+	billingConfigRepository := billingConfigRepo.NewBillingConfigRepository(db)
+	collectionRepository := collectionRepo.NewCollectionRepository(db)
+
+	return servicectx.ServiceCtx{
+		LoanService:       loanService.NewLoanService(loanRepository, loanBillRepository, billingConfigRepository, db.Clock),
+		UserService:       userService.NewUserService(userRepository),
+		PaymentService:    services.NewPaymentService(paymentRepository, loanRepository, loanBillRepository, db.Clock),
+		CollectionService: collectionService.NewCollectionService(collectionRepository, db.Clock),
+		Clock:             db.Clock,
+	}
+}
+
+// runWorker processes the payment messages of a queue until ctx is done, a message is acknowledged once processed
+func runWorker(ctx context.Context, subscriber mq.Subscriber, serviceCtx *servicectx.ServiceCtx, queueName string, options mq.ConsumeOptions) error {
+	registry := newWorkerRegistry(serviceCtx)
+	return subscriber.Consume(ctx, queueName, options, func(ctx context.Context, body []byte) error {
+		logger.GetLogger().Infof("Received message: %s", string(body))
+		err := registry.Dispatch(ctx, body)
+		if err != nil {
+			logger.GetLogger().Errorf("Failed to process message: %v", err)
+			return err
+		}
+		logger.GetLogger().Info("Message processed successfully")
+		return nil
+	})
+}
+
+// newWorkerRegistry registers the handler of every event the worker consumes
+func newWorkerRegistry(serviceCtx *servicectx.ServiceCtx) *event.Registry {
+	registry := event.NewRegistry()
+	event.Register(registry, outboxModel.EventPaymentCreated, models.PaymentCreatedVersion,
+		func(ctx context.Context, envelope event.Envelope, payload models.PaymentCreatedEvent) error {
+			return processPayment(ctx, serviceCtx, envelope.EventID, payload.Payment())
+		})
+
+	// payments published before the envelope carry the bare payment, identified by their event and payment
+	registry.Fallback(func(ctx context.Context, body []byte) error {
+		var payment models.Payment
+		err := json.Unmarshal(body, &payment)
+		if err != nil {
+			logger.GetLogger().Errorf("Failed to unmarshal message: %v", err)
+			// a body which can't be decoded never will, dead-letter it right away
+			return mq.Permanent(err)
+		}
+		return processPayment(ctx, serviceCtx, fmt.Sprintf("%s:%d", outboxModel.EventPaymentCreated, payment.ID), payment)
+	})
+	return registry
 }
 
-// processPayment processes the incoming RabbitMQ message body for payment
-func processPayment(ctx context.Context, serviceCtx *servicectx.ServiceCtx, body []byte) error {
-	var payment models.Payment
-	err := json.Unmarshal(body, &payment)
+// loanKeyOf the loan of a payment message, the lane of the message so the payments of a loan are processed in order
+func loanKeyOf(body []byte) string {
+	envelope, err := event.Decode(body)
 	if err != nil {
-		logger.GetLogger().Errorf("Failed to unmarshal message: %v", err)
-		return err
+		// payments published before the envelope carry the bare payment
+		var payment models.Payment
+		if json.Unmarshal(body, &payment) != nil {
+			return ""
+		}
+		return strconv.Itoa(payment.LoanID)
 	}
 
-	err = serviceCtx.PaymentService.ProcessUpdatePayment(ctx, payment)
+	var payload models.PaymentCreatedEvent
+	if json.Unmarshal(envelope.Payload, &payload) != nil {
+		return ""
+	}
+	return strconv.Itoa(payload.LoanID)
+}
+
+// processPayment processes a payment announced by a message, the message id makes a message delivered again a no-op
+func processPayment(ctx context.Context, serviceCtx *servicectx.ServiceCtx, messageID string, payment models.Payment) error {
+	err := serviceCtx.PaymentService.ProcessUpdatePayment(ctx, messageID, payment)
 	if err != nil {
 		logger.GetLogger().Errorf("Failed to process payment: %v", err)
 		return err
 	}
 
+	// resolve promise to pay made by collection agents for this loan
+	err = serviceCtx.CollectionService.ResolvePromises(ctx, int64(payment.LoanID))
+	if err != nil {
+		logger.GetLogger().Errorf("Failed to resolve promise to pay: %v", err)
+		return err
+	}
+
+	// recovery payments of a written off loan don't cure delinquency
+	if payment.PaymentType == models.PaymentTypeRecovery {
+		logger.GetLogger().Infof("Done Process Recovery Payment: %+v", payment)
+		return nil
+	}
+
 	total, err := serviceCtx.LoanService.CountLoanBillOverdueStatusesByID(ctx, int32(payment.LoanID))
 	if err != nil {
-		logger.Fatalf("Error Count loan bill overdue by loan id")
+		logger.GetLogger().Errorf("Error Count loan bill overdue by loan id: %v", err)
 		return err
 	}
 
 	// validate users isDelinquent or not
 	isDelinquent, err := serviceCtx.UserService.IsDelinquent(context.Background(), int32(payment.UserID))
 	if err != nil {
-		logger.Fatalf("Error Get User is Delinquent")
+		logger.GetLogger().Errorf("Error Get User is Delinquent: %v", err)
 		return err
 	}
 
 	if total < 2 && isDelinquent {
 		err := serviceCtx.UserService.UpdateUserToNotDelinquent(ctx, int32(payment.UserID))
 		if err != nil {
-			logger.Fatalf("Error update user to not delinquent")
+			logger.GetLogger().Errorf("Error update user to not delinquent: %v", err)
 			return err
 		}
 	}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoanKeyOf(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "Envelope keyed by the loan of its payment",
+			body: `{"event_id":"4f1c2a9e-1","type":"payment.created","version":1,"occurred_at":"2024-12-23T10:00:00Z",` +
+				`"correlation_id":"4f1c2a9e-1","payload":{"payment_id":3,"loan_id":2}}`,
+			want: "2",
+		},
+		{
+			name: "Bare payment keyed by its loan",
+			body: `{"ID":3,"UserID":123,"LoanID":5}`,
+			want: "5",
+		},
+		{
+			name: "Body which can't be decoded",
+			body: `not json`,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, loanKeyOf([]byte(tt.body)))
+		})
+	}
+}
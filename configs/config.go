@@ -16,9 +16,15 @@ const (
 )
 
 type Config struct {
-	Http     HttpConfig
-	DB       DBConfig
-	RabbitMQ RabbitMQConfig
+	Http      HttpConfig
+	DB        DBConfig
+	RabbitMQ  RabbitMQConfig
+	Scheduler SchedulerConfig
+	Clock     ClockConfig
+	Reminder  ReminderConfig
+	AutoDebit AutoDebitConfig
+	Outbox    OutboxConfig
+	Queue     QueueConfig
 }
 
 type HttpConfig struct {
@@ -39,8 +45,61 @@ type DBConfig struct {
 }
 
 type RabbitMQConfig struct {
-	Dsn       string
-	QueueName string
+	Dsn             string
+	QueueName       string
+	Exchange        string // Topic exchange the events are published to, routed by their type
+	MaxAttempts     int    // Deliveries of a message before it is moved to the dead-letter queue
+	RetryDelayMs    int    // Delay before the second delivery, doubled after every delivery
+	MaxRetryDelayMs int
+	Concurrency     int // Messages the worker handles concurrently, the messages of a loan are handled in order
+	Prefetch        int // Unacknowledged messages delivered to the worker ahead, defaults to twice the concurrency
+	DrainTimeoutMs  int // How long the worker waits for the messages in flight on shutdown
+}
+
+type SchedulerConfig struct {
+	Timezone  string                       // IANA timezone the schedules are evaluated in, defaults to the server local time
+	Jobs      map[string]JobScheduleConfig // Overrides keyed by job name
+	BatchSize int                          // Loans fetched per page by the jobs iterating over the portfolio
+	Workers   int                          // Loans processed concurrently by the jobs iterating over the portfolio
+}
+
+type JobScheduleConfig struct {
+	Schedule string // Cron expression, overrides the job default schedule
+	Enabled  *bool  // Jobs are enabled unless set to false
+}
+
+// ReminderConfig upcoming bill reminders published to the message broker
+type ReminderConfig struct {
+	DaysBefore []int  // Days before the billing date a reminder is sent, 0 is the billing date itself
+	QueueName  string // Queue the reminder events are published to
+}
+
+// AutoDebitConfig bills charged automatically through the mandates of the users
+type AutoDebitConfig struct {
+	RetryAfterHours []int // Hours after a failed debit each retry is made, the debit fails once they are exhausted
+}
+
+// OutboxConfig relay publishing the outbox messages to the message broker
+type OutboxConfig struct {
+	PollIntervalMs        int // How often the relay looks for pending messages
+	BatchSize             int // Messages published per poll
+	MaxAttempts           int // Attempts before a message is FAILED and left to an operator
+	InitialBackoffSeconds int // Delay before the second attempt, doubled after every attempt
+	MaxBackoffSeconds     int
+	ClaimTimeoutSeconds   int // How long a relay owns the batch it claimed, the batch is claimed again afterwards
+}
+
+// QueueConfig broker the messages go through, RabbitMQ or the database so a deployment runs on MySQL alone
+type QueueConfig struct {
+	Driver              string // "rabbitmq" (default) or "database"
+	PollIntervalMs      int    // How often a database consumer looks for visible messages once its queue is drained
+	VisibilityTimeoutMs int    // How long a claimed message stays invisible to the other consumers, renewed while it is handled
+}
+
+// ClockConfig time travel for staging, leave TravelTo empty to use the wall clock
+type ClockConfig struct {
+	TravelTo string // RFC3339 or YYYY-MM-DD, the application starts at this time
+	Frozen   bool   // Stop the clock at TravelTo instead of letting it run from there
 }
 
 func InitConfig() Config {
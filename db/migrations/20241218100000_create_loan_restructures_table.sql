@@ -0,0 +1,28 @@
+-- +goose Up
+-- Unpaid bills replaced by a new schedule are kept as SUPERSEDED for audit
+ALTER TABLE loan_bills MODIFY COLUMN status ENUM('PENDING', 'PAID', 'BILLED', 'OVERDUE', 'SUPERSEDED');
+
+CREATE TABLE IF NOT EXISTS loan_restructures (
+    id                           INTEGER PRIMARY KEY AUTO_INCREMENT,
+    loan_id                      INTEGER,
+    reason                       TEXT NOT NULL,
+    previous_outstanding_amount  INT,
+    new_outstanding_amount       INT,
+    previous_interest_percentage DECIMAL,
+    new_interest_percentage      DECIMAL,
+    previous_due_date            DATE,
+    new_due_date                 DATE,
+    new_terms                    INT,
+    installment_amount           INT,
+    superseded_bills             INT,
+    created_at                   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_loan_restructures_loan_id FOREIGN KEY (loan_id) REFERENCES loans (id)
+);
+
+-- +goose Down
+ALTER TABLE loan_restructures DROP FOREIGN KEY fk_loan_restructures_loan_id;
+DROP TABLE IF EXISTS loan_restructures;
+
+UPDATE loan_bills SET status = 'PENDING' WHERE status = 'SUPERSEDED';
+ALTER TABLE loan_bills MODIFY COLUMN status ENUM('PENDING', 'PAID', 'BILLED', 'OVERDUE');
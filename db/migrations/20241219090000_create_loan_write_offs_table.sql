@@ -0,0 +1,41 @@
+-- +goose Up
+ALTER TABLE loans MODIFY COLUMN status ENUM('ACTIVE', 'CLOSED', 'WRITTEN_OFF');
+ALTER TABLE loans ADD COLUMN recovered_amount INT NOT NULL DEFAULT 0 AFTER outstanding_amount;
+
+-- Unpaid bills of a written off loan are frozen so no job touches them anymore
+ALTER TABLE loan_bills MODIFY COLUMN status ENUM('PENDING', 'PAID', 'BILLED', 'OVERDUE', 'SUPERSEDED', 'WRITTEN_OFF');
+
+-- Recovery payments are collected after write-off and reported apart from regular repayments
+ALTER TABLE payments ADD COLUMN payment_type ENUM('REPAYMENT', 'RECOVERY') NOT NULL DEFAULT 'REPAYMENT' AFTER amount;
+
+CREATE TABLE IF NOT EXISTS loan_write_offs (
+    id                 INTEGER PRIMARY KEY AUTO_INCREMENT,
+    loan_id            INTEGER,
+    write_off_type     ENUM('MANUAL', 'AUTO'),
+    reason             TEXT NOT NULL,
+    written_off_amount INT,
+    days_past_due      INT,
+    created_at         TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_loan_write_offs_loan_id FOREIGN KEY (loan_id) REFERENCES loans (id),
+    UNIQUE KEY uq_loan_write_offs_loan_id (loan_id)
+);
+
+INSERT INTO `billing_configs` (`name`, `value`)
+VALUES
+    ('loan_write_off_days_past_due', '{"is_active":true,"value":90}');
+
+-- +goose Down
+DELETE FROM `billing_configs` WHERE `name` = 'loan_write_off_days_past_due';
+
+ALTER TABLE loan_write_offs DROP FOREIGN KEY fk_loan_write_offs_loan_id;
+DROP TABLE IF EXISTS loan_write_offs;
+
+ALTER TABLE payments DROP COLUMN payment_type;
+
+UPDATE loan_bills SET status = 'OVERDUE' WHERE status = 'WRITTEN_OFF';
+ALTER TABLE loan_bills MODIFY COLUMN status ENUM('PENDING', 'PAID', 'BILLED', 'OVERDUE', 'SUPERSEDED');
+
+ALTER TABLE loans DROP COLUMN recovered_amount;
+UPDATE loans SET status = 'ACTIVE' WHERE status = 'WRITTEN_OFF';
+ALTER TABLE loans MODIFY COLUMN status ENUM('ACTIVE', 'CLOSED');
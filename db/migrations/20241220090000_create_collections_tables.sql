@@ -0,0 +1,57 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS collection_cases (
+    id                  INTEGER PRIMARY KEY AUTO_INCREMENT,
+    loan_id             INTEGER NOT NULL,
+    user_id             INTEGER NOT NULL,
+    agent_id            INTEGER DEFAULT NULL,
+    status              ENUM('OPEN', 'CLOSED') NOT NULL DEFAULT 'OPEN',
+    priority_score      INT NOT NULL DEFAULT 0,
+    overdue_bills       INT NOT NULL DEFAULT 0,
+    overdue_amount      INT NOT NULL DEFAULT 0,
+    oldest_billing_date DATE,
+    days_past_due       INT NOT NULL DEFAULT 0,
+    assigned_at         TIMESTAMP NULL DEFAULT NULL,
+    created_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP NULL ON UPDATE CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_collection_cases_loan_id FOREIGN KEY (loan_id) REFERENCES loans (id),
+    UNIQUE KEY uq_collection_cases_loan_id (loan_id),
+    KEY idx_collection_cases_queue (status, priority_score)
+);
+
+CREATE TABLE IF NOT EXISTS collection_contact_attempts (
+    id           INTEGER PRIMARY KEY AUTO_INCREMENT,
+    case_id      INTEGER NOT NULL,
+    agent_id     INTEGER NOT NULL,
+    channel      ENUM('CALL', 'SMS', 'WHATSAPP', 'EMAIL', 'VISIT') NOT NULL,
+    outcome      ENUM('REACHED', 'NO_ANSWER', 'WRONG_NUMBER', 'REFUSED', 'PROMISED') NOT NULL,
+    note         TEXT,
+    attempted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_collection_contact_attempts_case_id FOREIGN KEY (case_id) REFERENCES collection_cases (id)
+);
+
+CREATE TABLE IF NOT EXISTS promise_to_pays (
+    id            INTEGER PRIMARY KEY AUTO_INCREMENT,
+    case_id       INTEGER NOT NULL,
+    loan_id       INTEGER NOT NULL,
+    agent_id      INTEGER NOT NULL,
+    amount        INT NOT NULL,
+    promised_date DATE NOT NULL,
+    status        ENUM('PENDING', 'KEPT', 'BROKEN') NOT NULL DEFAULT 'PENDING',
+    paid_amount   INT NOT NULL DEFAULT 0,
+    resolved_at   TIMESTAMP NULL DEFAULT NULL,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_promise_to_pays_case_id FOREIGN KEY (case_id) REFERENCES collection_cases (id),
+    KEY idx_promise_to_pays_loan_status (loan_id, status)
+);
+
+-- +goose Down
+ALTER TABLE promise_to_pays DROP FOREIGN KEY fk_promise_to_pays_case_id;
+ALTER TABLE collection_contact_attempts DROP FOREIGN KEY fk_collection_contact_attempts_case_id;
+ALTER TABLE collection_cases DROP FOREIGN KEY fk_collection_cases_loan_id;
+
+DROP TABLE IF EXISTS promise_to_pays;
+DROP TABLE IF EXISTS collection_contact_attempts;
+DROP TABLE IF EXISTS collection_cases;
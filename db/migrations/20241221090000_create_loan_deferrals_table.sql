@@ -0,0 +1,23 @@
+-- +goose Up
+-- A payment holiday shifts the unpaid bills of a loan forward, the cron leaves the loan alone while the window is active
+CREATE TABLE IF NOT EXISTS loan_deferrals (
+    id                   INTEGER PRIMARY KEY AUTO_INCREMENT,
+    loan_id              INTEGER,
+    reason               TEXT NOT NULL,
+    periods              INT,
+    first_billing_number INT,
+    deferred_bills       INT,
+    capitalized_interest INT NOT NULL DEFAULT 0,
+    previous_due_date    DATE,
+    new_due_date         DATE,
+    start_date           DATE,
+    end_date             DATE,
+    created_at           TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_loan_deferrals_loan_id FOREIGN KEY (loan_id) REFERENCES loans (id),
+    INDEX idx_loan_deferrals_window (loan_id, start_date, end_date)
+);
+
+-- +goose Down
+ALTER TABLE loan_deferrals DROP FOREIGN KEY fk_loan_deferrals_loan_id;
+DROP TABLE IF EXISTS loan_deferrals;
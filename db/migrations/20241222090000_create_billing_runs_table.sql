@@ -0,0 +1,15 @@
+-- +goose Up
+-- Ledger of the billing dates processed by the cron, used to catch up on the dates missed while it was down
+CREATE TABLE IF NOT EXISTS billing_runs (
+    id         INTEGER PRIMARY KEY AUTO_INCREMENT,
+    run_date   DATE NOT NULL,
+    status     ENUM('SUCCESS', 'FAILED') NOT NULL,
+    error      TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uq_billing_runs_run_date (run_date)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS billing_runs;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- Every execution of a background job, including the ones skipped because another instance held the lock
+CREATE TABLE IF NOT EXISTS job_runs (
+    id              INTEGER PRIMARY KEY AUTO_INCREMENT,
+    job_name        VARCHAR(100) NOT NULL,
+    instance_id     VARCHAR(255) NOT NULL,
+    status          ENUM('RUNNING', 'SUCCESS', 'FAILED', 'SKIPPED') NOT NULL,
+    processed_count INT NOT NULL DEFAULT 0,
+    failed_count    INT NOT NULL DEFAULT 0,
+    error           TEXT,
+    started_at      DATETIME NOT NULL,
+    finished_at     DATETIME,
+
+    INDEX idx_job_runs_job_name_started_at (job_name, started_at)
+);
+
+-- Lease per job, an instance only runs a job while it owns an unexpired lease
+CREATE TABLE IF NOT EXISTS job_locks (
+    job_name     VARCHAR(100) PRIMARY KEY,
+    owner        VARCHAR(255) NOT NULL,
+    locked_until DATETIME NOT NULL,
+    heartbeat_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS job_locks;
+DROP TABLE IF EXISTS job_runs;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Items a job run failed to process, the run carries on with the other items
+CREATE TABLE IF NOT EXISTS job_run_failures (
+    id         INTEGER PRIMARY KEY AUTO_INCREMENT,
+    job_run_id INTEGER NOT NULL,
+    reference  VARCHAR(255) NOT NULL,
+    error      TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    INDEX idx_job_run_failures_job_run_id (job_run_id),
+    FOREIGN KEY (job_run_id) REFERENCES job_runs(id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS job_run_failures;
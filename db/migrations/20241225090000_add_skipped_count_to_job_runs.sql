@@ -0,0 +1,6 @@
+-- +goose Up
+-- Items a job run had nothing to do for, reported next to the processed and failed counts
+ALTER TABLE job_runs ADD COLUMN skipped_count INT NOT NULL DEFAULT 0 AFTER failed_count;
+
+-- +goose Down
+ALTER TABLE job_runs DROP COLUMN skipped_count;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- IANA timezone the billing days, the bill statuses and the days past due of the loan are evaluated in
+ALTER TABLE loans ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Jakarta' AFTER loan_terms_per_week;
+
+-- +goose Down
+ALTER TABLE loans DROP COLUMN timezone;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Reminders sent for upcoming bills, one per bill and days before the billing date so a reminder is never sent twice
+CREATE TABLE IF NOT EXISTS bill_reminders (
+    id           INTEGER PRIMARY KEY AUTO_INCREMENT,
+    loan_bill_id INTEGER NOT NULL,
+    days_before  INTEGER NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uq_bill_reminders_loan_bill_id_days_before (loan_bill_id, days_before),
+    FOREIGN KEY (loan_bill_id) REFERENCES loan_bills(id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS bill_reminders;
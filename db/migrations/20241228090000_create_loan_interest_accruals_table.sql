@@ -0,0 +1,28 @@
+-- +goose Up
+-- FLAT loans charge the interest on the loan amount at creation, DECLINING_BALANCE loans accrue it daily on the
+-- outstanding principal with the day count convention of the loan
+ALTER TABLE loans
+    ADD COLUMN interest_method ENUM('FLAT', 'DECLINING_BALANCE') NOT NULL DEFAULT 'FLAT' AFTER interest_percentage,
+    ADD COLUMN day_count_convention ENUM('ACT/365', '30/360') NOT NULL DEFAULT 'ACT/365' AFTER interest_method;
+
+-- Interest accrued by a declining balance loan, one row per loan per day, added to the bill closing the period
+CREATE TABLE IF NOT EXISTS loan_interest_accruals (
+    id                 INTEGER PRIMARY KEY AUTO_INCREMENT,
+    loan_id            INTEGER NOT NULL,
+    loan_bill_id       INTEGER,
+    accrual_date       DATE NOT NULL,
+    principal          INT NOT NULL,
+    annual_rate        DECIMAL(7, 4) NOT NULL,
+    day_count_fraction DECIMAL(12, 10) NOT NULL,
+    interest           DECIMAL(15, 4) NOT NULL,
+    created_at         TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uq_loan_interest_accruals_loan_id_accrual_date (loan_id, accrual_date),
+    INDEX idx_loan_interest_accruals_loan_bill_id (loan_bill_id),
+    FOREIGN KEY (loan_id) REFERENCES loans(id),
+    FOREIGN KEY (loan_bill_id) REFERENCES loan_bills(id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS loan_interest_accruals;
+ALTER TABLE loans DROP COLUMN day_count_convention, DROP COLUMN interest_method;
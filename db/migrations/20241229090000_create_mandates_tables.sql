@@ -0,0 +1,37 @@
+-- +goose Up
+-- Auto-debit mandates, a user authorizes the bills of a loan to be charged on a payment method up to a max amount
+CREATE TABLE IF NOT EXISTS mandates (
+    id                   INTEGER PRIMARY KEY AUTO_INCREMENT,
+    user_id              INTEGER NOT NULL,
+    loan_id              INTEGER NOT NULL,
+    payment_method_token VARCHAR(255) NOT NULL,
+    max_amount           INT NOT NULL,
+    status               ENUM('ACTIVE', 'PAUSED', 'REVOKED') NOT NULL DEFAULT 'ACTIVE',
+    created_at           TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at           TIMESTAMP NULL ON UPDATE CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_mandates_loan_id FOREIGN KEY (loan_id) REFERENCES loans (id),
+    KEY idx_mandates_loan_id_status (loan_id, status)
+);
+
+-- Debits of a bill through a mandate, one row per mandate and bill carrying the attempts and the next retry
+CREATE TABLE IF NOT EXISTS mandate_debits (
+    id              INTEGER PRIMARY KEY AUTO_INCREMENT,
+    mandate_id      INTEGER NOT NULL,
+    loan_bill_id    INTEGER NOT NULL,
+    payment_id      INTEGER DEFAULT NULL,
+    status          ENUM('SUBMITTED', 'RETRYING', 'FAILED') NOT NULL,
+    attempts        INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NULL DEFAULT NULL,
+    last_error      VARCHAR(255) DEFAULT NULL,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP NULL ON UPDATE CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_mandate_debits_mandate_id FOREIGN KEY (mandate_id) REFERENCES mandates (id),
+    CONSTRAINT fk_mandate_debits_loan_bill_id FOREIGN KEY (loan_bill_id) REFERENCES loan_bills (id),
+    UNIQUE KEY uq_mandate_debits_mandate_id_loan_bill_id (mandate_id, loan_bill_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS mandate_debits;
+DROP TABLE IF EXISTS mandates;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Messages to publish to the broker, written in the same transaction as the change they announce and published by the
+-- relay once committed so a message is never lost when the broker is down or the process exits
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id              INTEGER PRIMARY KEY AUTO_INCREMENT,
+    aggregate_type  VARCHAR(50) NOT NULL,
+    aggregate_id    INTEGER NOT NULL,
+    event_type      VARCHAR(100) NOT NULL,
+    payload         TEXT NOT NULL,
+    status          ENUM('PENDING', 'SENT', 'FAILED') NOT NULL DEFAULT 'PENDING',
+    attempts        INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error      VARCHAR(255) DEFAULT NULL,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at         TIMESTAMP NULL DEFAULT NULL,
+
+    KEY idx_outbox_messages_status_next_attempt_at (status, next_attempt_at)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox_messages;
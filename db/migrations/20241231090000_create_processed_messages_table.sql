@@ -0,0 +1,14 @@
+-- +goose Up
+-- Messages a consumer processed, written in the same transaction as the change the message made so a message delivered
+-- again is not applied twice
+CREATE TABLE IF NOT EXISTS processed_messages (
+    id           INTEGER PRIMARY KEY AUTO_INCREMENT,
+    consumer     VARCHAR(50) NOT NULL,
+    message_id   VARCHAR(100) NOT NULL,
+    processed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uk_processed_messages_consumer_message_id (consumer, message_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS processed_messages;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- Messages of the queues when the broker is the database (queue.driver database), a consumer claims the visible
+-- messages with SKIP LOCKED and leases them until visible_at, a message whose lease expires is claimed again
+CREATE TABLE IF NOT EXISTS queue_messages (
+    id          BIGINT PRIMARY KEY AUTO_INCREMENT,
+    queue_name  VARCHAR(255) NOT NULL,
+    body        MEDIUMTEXT NOT NULL,
+    headers     TEXT NOT NULL,
+    deliveries  INT NOT NULL DEFAULT 0,
+    visible_at  DATETIME(3) NOT NULL,
+    lease_token VARCHAR(64) DEFAULT NULL,
+    created_at  DATETIME(3) NOT NULL,
+
+    KEY idx_queue_messages_queue_name_visible_at (queue_name, visible_at, id),
+    KEY idx_queue_messages_lease_token (lease_token)
+);
+
+-- Queues bound to the exchanges of the database broker, a message published to an exchange is copied to every queue
+-- whose pattern matches its routing key
+CREATE TABLE IF NOT EXISTS queue_bindings (
+    id         INTEGER PRIMARY KEY AUTO_INCREMENT,
+    exchange   VARCHAR(255) NOT NULL,
+    queue_name VARCHAR(255) NOT NULL,
+    pattern    VARCHAR(255) NOT NULL,
+
+    UNIQUE KEY uk_queue_bindings_exchange_queue_name_pattern (exchange, queue_name, pattern)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS queue_bindings;
+DROP TABLE IF EXISTS queue_messages;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Lease of a message claimed by a relay, the other relays skip it until the lease expires so a message is published
+-- once when several relays run
+ALTER TABLE outbox_messages ADD COLUMN claimed_until DATETIME(3) NULL DEFAULT NULL AFTER next_attempt_at;
+
+-- +goose Down
+ALTER TABLE outbox_messages DROP COLUMN claimed_until;
@@ -7,6 +7,7 @@ package loan_mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/okiww/billing-loan-system/internal/loan/models"
@@ -49,6 +50,36 @@ func (mr *MockLoanBillRepositoryInterfaceMockRecorder) CreateLoanBill(ctx, loanB
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLoanBill", reflect.TypeOf((*MockLoanBillRepositoryInterface)(nil).CreateLoanBill), ctx, loanBill)
 }
 
+// FetchLoansOverdueSince mocks base method.
+func (m *MockLoanBillRepositoryInterface) FetchLoansOverdueSince(ctx context.Context, cutoff time.Time) ([]models.LoanOverdueModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchLoansOverdueSince", ctx, cutoff)
+	ret0, _ := ret[0].([]models.LoanOverdueModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchLoansOverdueSince indicates an expected call of FetchLoansOverdueSince.
+func (mr *MockLoanBillRepositoryInterfaceMockRecorder) FetchLoansOverdueSince(ctx, cutoff interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchLoansOverdueSince", reflect.TypeOf((*MockLoanBillRepositoryInterface)(nil).FetchLoansOverdueSince), ctx, cutoff)
+}
+
+// GetLastBillingRunDate mocks base method.
+func (m *MockLoanBillRepositoryInterface) GetLastBillingRunDate(ctx context.Context) (*time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastBillingRunDate", ctx)
+	ret0, _ := ret[0].(*time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLastBillingRunDate indicates an expected call of GetLastBillingRunDate.
+func (mr *MockLoanBillRepositoryInterfaceMockRecorder) GetLastBillingRunDate(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastBillingRunDate", reflect.TypeOf((*MockLoanBillRepositoryInterface)(nil).GetLastBillingRunDate), ctx)
+}
+
 // GetLoanBillByID mocks base method.
 func (m *MockLoanBillRepositoryInterface) GetLoanBillByID(ctx context.Context, id int) (*models.LoanBillModel, error) {
 	m.ctrl.T.Helper()
@@ -94,16 +125,75 @@ func (mr *MockLoanBillRepositoryInterfaceMockRecorder) GetTotalLoanBillOverdueBy
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTotalLoanBillOverdueByLoanID", reflect.TypeOf((*MockLoanBillRepositoryInterface)(nil).GetTotalLoanBillOverdueByLoanID), ctx, id)
 }
 
+// GetTotalLoanBillOverdueByLoanIDs mocks base method.
+func (m *MockLoanBillRepositoryInterface) GetTotalLoanBillOverdueByLoanIDs(ctx context.Context, ids []int64) (map[int64]int32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTotalLoanBillOverdueByLoanIDs", ctx, ids)
+	ret0, _ := ret[0].(map[int64]int32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTotalLoanBillOverdueByLoanIDs indicates an expected call of GetTotalLoanBillOverdueByLoanIDs.
+func (mr *MockLoanBillRepositoryInterfaceMockRecorder) GetTotalLoanBillOverdueByLoanIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTotalLoanBillOverdueByLoanIDs", reflect.TypeOf((*MockLoanBillRepositoryInterface)(nil).GetTotalLoanBillOverdueByLoanIDs), ctx, ids)
+}
+
+// PreviewDelinquentLoans mocks base method.
+func (m *MockLoanBillRepositoryInterface) PreviewDelinquentLoans(ctx context.Context, asOf time.Time, timezone string) ([]models.DelinquencyChangeModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreviewDelinquentLoans", ctx, asOf, timezone)
+	ret0, _ := ret[0].([]models.DelinquencyChangeModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PreviewDelinquentLoans indicates an expected call of PreviewDelinquentLoans.
+func (mr *MockLoanBillRepositoryInterfaceMockRecorder) PreviewDelinquentLoans(ctx, asOf, timezone interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviewDelinquentLoans", reflect.TypeOf((*MockLoanBillRepositoryInterface)(nil).PreviewDelinquentLoans), ctx, asOf, timezone)
+}
+
+// PreviewLoanBillStatuses mocks base method.
+func (m *MockLoanBillRepositoryInterface) PreviewLoanBillStatuses(ctx context.Context, asOf time.Time, timezone string) ([]models.LoanBillStatusChangeModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreviewLoanBillStatuses", ctx, asOf, timezone)
+	ret0, _ := ret[0].([]models.LoanBillStatusChangeModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PreviewLoanBillStatuses indicates an expected call of PreviewLoanBillStatuses.
+func (mr *MockLoanBillRepositoryInterfaceMockRecorder) PreviewLoanBillStatuses(ctx, asOf, timezone interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviewLoanBillStatuses", reflect.TypeOf((*MockLoanBillRepositoryInterface)(nil).PreviewLoanBillStatuses), ctx, asOf, timezone)
+}
+
+// SaveBillingRun mocks base method.
+func (m *MockLoanBillRepositoryInterface) SaveBillingRun(ctx context.Context, run *models.BillingRunModel) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveBillingRun", ctx, run)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveBillingRun indicates an expected call of SaveBillingRun.
+func (mr *MockLoanBillRepositoryInterfaceMockRecorder) SaveBillingRun(ctx, run interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBillingRun", reflect.TypeOf((*MockLoanBillRepositoryInterface)(nil).SaveBillingRun), ctx, run)
+}
+
 // UpdateLoanBillStatuses mocks base method.
-func (m *MockLoanBillRepositoryInterface) UpdateLoanBillStatuses(ctx context.Context) error {
+func (m *MockLoanBillRepositoryInterface) UpdateLoanBillStatuses(ctx context.Context, asOf time.Time, timezone string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateLoanBillStatuses", ctx)
+	ret := m.ctrl.Call(m, "UpdateLoanBillStatuses", ctx, asOf, timezone)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateLoanBillStatuses indicates an expected call of UpdateLoanBillStatuses.
-func (mr *MockLoanBillRepositoryInterfaceMockRecorder) UpdateLoanBillStatuses(ctx interface{}) *gomock.Call {
+func (mr *MockLoanBillRepositoryInterfaceMockRecorder) UpdateLoanBillStatuses(ctx, asOf, timezone interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLoanBillStatuses", reflect.TypeOf((*MockLoanBillRepositoryInterface)(nil).UpdateLoanBillStatuses), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLoanBillStatuses", reflect.TypeOf((*MockLoanBillRepositoryInterface)(nil).UpdateLoanBillStatuses), ctx, asOf, timezone)
 }
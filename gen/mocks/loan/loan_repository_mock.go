@@ -11,6 +11,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 	sqlx "github.com/jmoiron/sqlx"
 	models "github.com/okiww/billing-loan-system/internal/loan/models"
+	repositories "github.com/okiww/billing-loan-system/internal/loan/repositories"
 )
 
 // MockLoanRepositoryInterface is a mock of LoanRepositoryInterface interface.
@@ -36,6 +37,35 @@ func (m *MockLoanRepositoryInterface) EXPECT() *MockLoanRepositoryInterfaceMockR
 	return m.recorder
 }
 
+// AccrueInterestInTx mocks base method.
+func (m *MockLoanRepositoryInterface) AccrueInterestInTx(ctx context.Context, accrual *models.LoanInterestAccrualModel) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccrueInterestInTx", ctx, accrual)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AccrueInterestInTx indicates an expected call of AccrueInterestInTx.
+func (mr *MockLoanRepositoryInterfaceMockRecorder) AccrueInterestInTx(ctx, accrual interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrueInterestInTx", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).AccrueInterestInTx), ctx, accrual)
+}
+
+// AddRecoveredAmount mocks base method.
+func (m *MockLoanRepositoryInterface) AddRecoveredAmount(ctx context.Context, loanID, amount int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddRecoveredAmount", ctx, loanID, amount)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddRecoveredAmount indicates an expected call of AddRecoveredAmount.
+func (mr *MockLoanRepositoryInterfaceMockRecorder) AddRecoveredAmount(ctx, loanID, amount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRecoveredAmount", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).AddRecoveredAmount), ctx, loanID, amount)
+}
+
 // CreateLoan mocks base method.
 func (m *MockLoanRepositoryInterface) CreateLoan(ctx context.Context, loan *models.LoanModel) (int64, error) {
 	m.ctrl.T.Helper()
@@ -51,19 +81,94 @@ func (mr *MockLoanRepositoryInterfaceMockRecorder) CreateLoan(ctx, loan interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLoan", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).CreateLoan), ctx, loan)
 }
 
+// DeferLoanInTx mocks base method.
+func (m *MockLoanRepositoryInterface) DeferLoanInTx(ctx context.Context, loanID int64, build repositories.DeferFunc) (*models.LoanDeferralModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeferLoanInTx", ctx, loanID, build)
+	ret0, _ := ret[0].(*models.LoanDeferralModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeferLoanInTx indicates an expected call of DeferLoanInTx.
+func (mr *MockLoanRepositoryInterfaceMockRecorder) DeferLoanInTx(ctx, loanID, build interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeferLoanInTx", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).DeferLoanInTx), ctx, loanID, build)
+}
+
 // FetchActiveLoan mocks base method.
-func (m *MockLoanRepositoryInterface) FetchActiveLoan(ctx context.Context) ([]models.LoanModel, error) {
+func (m *MockLoanRepositoryInterface) FetchActiveLoan(ctx context.Context, afterID int64, limit int) ([]models.LoanModel, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FetchActiveLoan", ctx)
+	ret := m.ctrl.Call(m, "FetchActiveLoan", ctx, afterID, limit)
 	ret0, _ := ret[0].([]models.LoanModel)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FetchActiveLoan indicates an expected call of FetchActiveLoan.
-func (mr *MockLoanRepositoryInterfaceMockRecorder) FetchActiveLoan(ctx interface{}) *gomock.Call {
+func (mr *MockLoanRepositoryInterfaceMockRecorder) FetchActiveLoan(ctx, afterID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchActiveLoan", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).FetchActiveLoan), ctx, afterID, limit)
+}
+
+// FetchActiveLoanTimezones mocks base method.
+func (m *MockLoanRepositoryInterface) FetchActiveLoanTimezones(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchActiveLoanTimezones", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchActiveLoanTimezones indicates an expected call of FetchActiveLoanTimezones.
+func (mr *MockLoanRepositoryInterfaceMockRecorder) FetchActiveLoanTimezones(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchActiveLoanTimezones", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).FetchActiveLoanTimezones), ctx)
+}
+
+// FetchInterestAccrualStates mocks base method.
+func (m *MockLoanRepositoryInterface) FetchInterestAccrualStates(ctx context.Context, afterID int64, limit int) ([]models.LoanInterestStateModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchInterestAccrualStates", ctx, afterID, limit)
+	ret0, _ := ret[0].([]models.LoanInterestStateModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchInterestAccrualStates indicates an expected call of FetchInterestAccrualStates.
+func (mr *MockLoanRepositoryInterfaceMockRecorder) FetchInterestAccrualStates(ctx, afterID, limit interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchActiveLoan", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).FetchActiveLoan), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchInterestAccrualStates", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).FetchInterestAccrualStates), ctx, afterID, limit)
+}
+
+// GetInterestState mocks base method.
+func (m *MockLoanRepositoryInterface) GetInterestState(ctx context.Context, loanID int64) (*models.LoanInterestStateModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInterestState", ctx, loanID)
+	ret0, _ := ret[0].(*models.LoanInterestStateModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInterestState indicates an expected call of GetInterestState.
+func (mr *MockLoanRepositoryInterfaceMockRecorder) GetInterestState(ctx, loanID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInterestState", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).GetInterestState), ctx, loanID)
+}
+
+// GetLoanByID mocks base method.
+func (m *MockLoanRepositoryInterface) GetLoanByID(ctx context.Context, id int64) (*models.LoanModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoanByID", ctx, id)
+	ret0, _ := ret[0].(*models.LoanModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoanByID indicates an expected call of GetLoanByID.
+func (mr *MockLoanRepositoryInterfaceMockRecorder) GetLoanByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoanByID", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).GetLoanByID), ctx, id)
 }
 
 // GetLoanByUserID mocks base method.
@@ -96,6 +201,36 @@ func (mr *MockLoanRepositoryInterfaceMockRecorder) GetLoanStatusByID(ctx, id int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoanStatusByID", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).GetLoanStatusByID), ctx, id)
 }
 
+// GetLoanWriteOffByLoanID mocks base method.
+func (m *MockLoanRepositoryInterface) GetLoanWriteOffByLoanID(ctx context.Context, loanID int64) (*models.LoanWriteOffSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoanWriteOffByLoanID", ctx, loanID)
+	ret0, _ := ret[0].(*models.LoanWriteOffSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoanWriteOffByLoanID indicates an expected call of GetLoanWriteOffByLoanID.
+func (mr *MockLoanRepositoryInterfaceMockRecorder) GetLoanWriteOffByLoanID(ctx, loanID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoanWriteOffByLoanID", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).GetLoanWriteOffByLoanID), ctx, loanID)
+}
+
+// RestructureLoanInTx mocks base method.
+func (m *MockLoanRepositoryInterface) RestructureLoanInTx(ctx context.Context, loanID int64, build repositories.RestructureFunc) (*models.LoanRestructureModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestructureLoanInTx", ctx, loanID, build)
+	ret0, _ := ret[0].(*models.LoanRestructureModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestructureLoanInTx indicates an expected call of RestructureLoanInTx.
+func (mr *MockLoanRepositoryInterfaceMockRecorder) RestructureLoanInTx(ctx, loanID, build interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestructureLoanInTx", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).RestructureLoanInTx), ctx, loanID, build)
+}
+
 // UpdateBilledLoanBillToPaid mocks base method.
 func (m *MockLoanRepositoryInterface) UpdateBilledLoanBillToPaid(ctx context.Context, tx *sqlx.Tx, id int) error {
 	m.ctrl.T.Helper()
@@ -137,3 +272,18 @@ func (mr *MockLoanRepositoryInterfaceMockRecorder) UpdateOutStandingAmountAndSta
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOutStandingAmountAndStatus", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).UpdateOutStandingAmountAndStatus), ctx, tx, id, amount)
 }
+
+// WriteOffLoanInTx mocks base method.
+func (m *MockLoanRepositoryInterface) WriteOffLoanInTx(ctx context.Context, loanID int64, build repositories.WriteOffFunc) (*models.LoanWriteOffModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteOffLoanInTx", ctx, loanID, build)
+	ret0, _ := ret[0].(*models.LoanWriteOffModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteOffLoanInTx indicates an expected call of WriteOffLoanInTx.
+func (mr *MockLoanRepositoryInterfaceMockRecorder) WriteOffLoanInTx(ctx, loanID, build interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteOffLoanInTx", reflect.TypeOf((*MockLoanRepositoryInterface)(nil).WriteOffLoanInTx), ctx, loanID, build)
+}
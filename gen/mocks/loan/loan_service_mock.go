@@ -7,6 +7,7 @@ package loan_mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	dto "github.com/okiww/billing-loan-system/internal/dto"
@@ -36,6 +37,21 @@ func (m *MockLoanServiceInterface) EXPECT() *MockLoanServiceInterfaceMockRecorde
 	return m.recorder
 }
 
+// AccrueInterest mocks base method.
+func (m *MockLoanServiceInterface) AccrueInterest(ctx context.Context, state models.LoanInterestStateModel) (int32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccrueInterest", ctx, state)
+	ret0, _ := ret[0].(int32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AccrueInterest indicates an expected call of AccrueInterest.
+func (mr *MockLoanServiceInterfaceMockRecorder) AccrueInterest(ctx, state interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrueInterest", reflect.TypeOf((*MockLoanServiceInterface)(nil).AccrueInterest), ctx, state)
+}
+
 // CountLoanBillOverdueStatusesByID mocks base method.
 func (m *MockLoanServiceInterface) CountLoanBillOverdueStatusesByID(ctx context.Context, id int32) (int32, error) {
 	m.ctrl.T.Helper()
@@ -51,6 +67,21 @@ func (mr *MockLoanServiceInterfaceMockRecorder) CountLoanBillOverdueStatusesByID
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountLoanBillOverdueStatusesByID", reflect.TypeOf((*MockLoanServiceInterface)(nil).CountLoanBillOverdueStatusesByID), ctx, id)
 }
 
+// CountOverdueBillsByLoanIDs mocks base method.
+func (m *MockLoanServiceInterface) CountOverdueBillsByLoanIDs(ctx context.Context, ids []int64) (map[int64]int32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountOverdueBillsByLoanIDs", ctx, ids)
+	ret0, _ := ret[0].(map[int64]int32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountOverdueBillsByLoanIDs indicates an expected call of CountOverdueBillsByLoanIDs.
+func (mr *MockLoanServiceInterfaceMockRecorder) CountOverdueBillsByLoanIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountOverdueBillsByLoanIDs", reflect.TypeOf((*MockLoanServiceInterface)(nil).CountOverdueBillsByLoanIDs), ctx, ids)
+}
+
 // CreateLoan mocks base method.
 func (m *MockLoanServiceInterface) CreateLoan(ctx context.Context, request dto.LoanRequest) error {
 	m.ctrl.T.Helper()
@@ -65,19 +96,64 @@ func (mr *MockLoanServiceInterfaceMockRecorder) CreateLoan(ctx, request interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLoan", reflect.TypeOf((*MockLoanServiceInterface)(nil).CreateLoan), ctx, request)
 }
 
-// GetAllActiveLoan mocks base method.
-func (m *MockLoanServiceInterface) GetAllActiveLoan(ctx context.Context) ([]models.LoanModel, error) {
+// DeferLoan mocks base method.
+func (m *MockLoanServiceInterface) DeferLoan(ctx context.Context, request dto.DeferLoanRequest) (*models.LoanDeferralModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeferLoan", ctx, request)
+	ret0, _ := ret[0].(*models.LoanDeferralModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeferLoan indicates an expected call of DeferLoan.
+func (mr *MockLoanServiceInterfaceMockRecorder) DeferLoan(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeferLoan", reflect.TypeOf((*MockLoanServiceInterface)(nil).DeferLoan), ctx, request)
+}
+
+// GetActiveLoans mocks base method.
+func (m *MockLoanServiceInterface) GetActiveLoans(ctx context.Context, afterID int64, limit int) ([]models.LoanModel, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllActiveLoan", ctx)
+	ret := m.ctrl.Call(m, "GetActiveLoans", ctx, afterID, limit)
 	ret0, _ := ret[0].([]models.LoanModel)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAllActiveLoan indicates an expected call of GetAllActiveLoan.
-func (mr *MockLoanServiceInterfaceMockRecorder) GetAllActiveLoan(ctx interface{}) *gomock.Call {
+// GetActiveLoans indicates an expected call of GetActiveLoans.
+func (mr *MockLoanServiceInterfaceMockRecorder) GetActiveLoans(ctx, afterID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveLoans", reflect.TypeOf((*MockLoanServiceInterface)(nil).GetActiveLoans), ctx, afterID, limit)
+}
+
+// GetInterestAccrualStates mocks base method.
+func (m *MockLoanServiceInterface) GetInterestAccrualStates(ctx context.Context, afterID int64, limit int) ([]models.LoanInterestStateModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInterestAccrualStates", ctx, afterID, limit)
+	ret0, _ := ret[0].([]models.LoanInterestStateModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInterestAccrualStates indicates an expected call of GetInterestAccrualStates.
+func (mr *MockLoanServiceInterfaceMockRecorder) GetInterestAccrualStates(ctx, afterID, limit interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllActiveLoan", reflect.TypeOf((*MockLoanServiceInterface)(nil).GetAllActiveLoan), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInterestAccrualStates", reflect.TypeOf((*MockLoanServiceInterface)(nil).GetInterestAccrualStates), ctx, afterID, limit)
+}
+
+// GetLoanWriteOff mocks base method.
+func (m *MockLoanServiceInterface) GetLoanWriteOff(ctx context.Context, loanID int64) (*models.LoanWriteOffSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoanWriteOff", ctx, loanID)
+	ret0, _ := ret[0].(*models.LoanWriteOffSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoanWriteOff indicates an expected call of GetLoanWriteOff.
+func (mr *MockLoanServiceInterfaceMockRecorder) GetLoanWriteOff(ctx, loanID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoanWriteOff", reflect.TypeOf((*MockLoanServiceInterface)(nil).GetLoanWriteOff), ctx, loanID)
 }
 
 // GetLoansWithBills mocks base method.
@@ -95,16 +171,107 @@ func (mr *MockLoanServiceInterfaceMockRecorder) GetLoansWithBills(ctx, userID in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoansWithBills", reflect.TypeOf((*MockLoanServiceInterface)(nil).GetLoansWithBills), ctx, userID)
 }
 
+// GetMissedBillingDates mocks base method.
+func (m *MockLoanServiceInterface) GetMissedBillingDates(ctx context.Context, asOf time.Time) ([]time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMissedBillingDates", ctx, asOf)
+	ret0, _ := ret[0].([]time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMissedBillingDates indicates an expected call of GetMissedBillingDates.
+func (mr *MockLoanServiceInterfaceMockRecorder) GetMissedBillingDates(ctx, asOf interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMissedBillingDates", reflect.TypeOf((*MockLoanServiceInterface)(nil).GetMissedBillingDates), ctx, asOf)
+}
+
+// GetPayoffQuote mocks base method.
+func (m *MockLoanServiceInterface) GetPayoffQuote(ctx context.Context, loanID int64, payoffDate time.Time) (*models.PayoffQuoteModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPayoffQuote", ctx, loanID, payoffDate)
+	ret0, _ := ret[0].(*models.PayoffQuoteModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPayoffQuote indicates an expected call of GetPayoffQuote.
+func (mr *MockLoanServiceInterfaceMockRecorder) GetPayoffQuote(ctx, loanID, payoffDate interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPayoffQuote", reflect.TypeOf((*MockLoanServiceInterface)(nil).GetPayoffQuote), ctx, loanID, payoffDate)
+}
+
+// PreviewBillingChanges mocks base method.
+func (m *MockLoanServiceInterface) PreviewBillingChanges(ctx context.Context, asOf time.Time) ([]models.LoanBillStatusChangeModel, []models.DelinquencyChangeModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PreviewBillingChanges", ctx, asOf)
+	ret0, _ := ret[0].([]models.LoanBillStatusChangeModel)
+	ret1, _ := ret[1].([]models.DelinquencyChangeModel)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// PreviewBillingChanges indicates an expected call of PreviewBillingChanges.
+func (mr *MockLoanServiceInterfaceMockRecorder) PreviewBillingChanges(ctx, asOf interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PreviewBillingChanges", reflect.TypeOf((*MockLoanServiceInterface)(nil).PreviewBillingChanges), ctx, asOf)
+}
+
+// RestructureLoan mocks base method.
+func (m *MockLoanServiceInterface) RestructureLoan(ctx context.Context, request dto.RestructureLoanRequest) (*models.LoanRestructureModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestructureLoan", ctx, request)
+	ret0, _ := ret[0].(*models.LoanRestructureModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestructureLoan indicates an expected call of RestructureLoan.
+func (mr *MockLoanServiceInterfaceMockRecorder) RestructureLoan(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestructureLoan", reflect.TypeOf((*MockLoanServiceInterface)(nil).RestructureLoan), ctx, request)
+}
+
 // UpdateLoanBill mocks base method.
-func (m *MockLoanServiceInterface) UpdateLoanBill(ctx context.Context) error {
+func (m *MockLoanServiceInterface) UpdateLoanBill(ctx context.Context, asOf time.Time) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateLoanBill", ctx)
+	ret := m.ctrl.Call(m, "UpdateLoanBill", ctx, asOf)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateLoanBill indicates an expected call of UpdateLoanBill.
-func (mr *MockLoanServiceInterfaceMockRecorder) UpdateLoanBill(ctx interface{}) *gomock.Call {
+func (mr *MockLoanServiceInterfaceMockRecorder) UpdateLoanBill(ctx, asOf interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLoanBill", reflect.TypeOf((*MockLoanServiceInterface)(nil).UpdateLoanBill), ctx, asOf)
+}
+
+// WriteOffLoan mocks base method.
+func (m *MockLoanServiceInterface) WriteOffLoan(ctx context.Context, request dto.WriteOffLoanRequest) (*models.LoanWriteOffModel, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteOffLoan", ctx, request)
+	ret0, _ := ret[0].(*models.LoanWriteOffModel)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteOffLoan indicates an expected call of WriteOffLoan.
+func (mr *MockLoanServiceInterfaceMockRecorder) WriteOffLoan(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteOffLoan", reflect.TypeOf((*MockLoanServiceInterface)(nil).WriteOffLoan), ctx, request)
+}
+
+// WriteOffOverdueLoans mocks base method.
+func (m *MockLoanServiceInterface) WriteOffOverdueLoans(ctx context.Context) (int32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteOffOverdueLoans", ctx)
+	ret0, _ := ret[0].(int32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteOffOverdueLoans indicates an expected call of WriteOffOverdueLoans.
+func (mr *MockLoanServiceInterfaceMockRecorder) WriteOffOverdueLoans(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLoanBill", reflect.TypeOf((*MockLoanServiceInterface)(nil).UpdateLoanBill), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteOffOverdueLoans", reflect.TypeOf((*MockLoanServiceInterface)(nil).WriteOffOverdueLoans), ctx)
 }
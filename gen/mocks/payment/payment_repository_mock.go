@@ -50,6 +50,21 @@ func (mr *MockPaymentRepositoryInterfaceMockRecorder) Create(ctx, payment interf
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockPaymentRepositoryInterface)(nil).Create), ctx, payment)
 }
 
+// CreateWithOutboxInTx mocks base method.
+func (m *MockPaymentRepositoryInterface) CreateWithOutboxInTx(ctx context.Context, payment *models.Payment, eventType string) (int32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWithOutboxInTx", ctx, payment, eventType)
+	ret0, _ := ret[0].(int32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWithOutboxInTx indicates an expected call of CreateWithOutboxInTx.
+func (mr *MockPaymentRepositoryInterfaceMockRecorder) CreateWithOutboxInTx(ctx, payment, eventType interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWithOutboxInTx", reflect.TypeOf((*MockPaymentRepositoryInterface)(nil).CreateWithOutboxInTx), ctx, payment, eventType)
+}
+
 // GetPaymentByID mocks base method.
 func (m *MockPaymentRepositoryInterface) GetPaymentByID(ctx context.Context, id int32) (*models.Payment, error) {
 	m.ctrl.T.Helper()
@@ -65,6 +80,35 @@ func (mr *MockPaymentRepositoryInterfaceMockRecorder) GetPaymentByID(ctx, id int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentByID", reflect.TypeOf((*MockPaymentRepositoryInterface)(nil).GetPaymentByID), ctx, id)
 }
 
+// MarkPaymentFailed mocks base method.
+func (m *MockPaymentRepositoryInterface) MarkPaymentFailed(ctx context.Context, id int32, note string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkPaymentFailed", ctx, id, note)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkPaymentFailed indicates an expected call of MarkPaymentFailed.
+func (mr *MockPaymentRepositoryInterfaceMockRecorder) MarkPaymentFailed(ctx, id, note interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkPaymentFailed", reflect.TypeOf((*MockPaymentRepositoryInterface)(nil).MarkPaymentFailed), ctx, id, note)
+}
+
+// ProcessPaymentInTx mocks base method.
+func (m *MockPaymentRepositoryInterface) ProcessPaymentInTx(ctx context.Context, messageID string, paymentID int32) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessPaymentInTx", ctx, messageID, paymentID)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProcessPaymentInTx indicates an expected call of ProcessPaymentInTx.
+func (mr *MockPaymentRepositoryInterfaceMockRecorder) ProcessPaymentInTx(ctx, messageID, paymentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessPaymentInTx", reflect.TypeOf((*MockPaymentRepositoryInterface)(nil).ProcessPaymentInTx), ctx, messageID, paymentID)
+}
+
 // UpdatePaymentStatus mocks base method.
 func (m *MockPaymentRepositoryInterface) UpdatePaymentStatus(ctx context.Context, id int32, status, note string) error {
 	m.ctrl.T.Helper()
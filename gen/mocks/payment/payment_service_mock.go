@@ -51,16 +51,31 @@ func (mr *MockPaymentServiceInterfaceMockRecorder) MakePayment(ctx, paymentReque
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakePayment", reflect.TypeOf((*MockPaymentServiceInterface)(nil).MakePayment), ctx, paymentRequest)
 }
 
+// MakeRecoveryPayment mocks base method.
+func (m *MockPaymentServiceInterface) MakeRecoveryPayment(ctx context.Context, paymentRequest *dto.RecoveryPaymentRequest) (*models.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MakeRecoveryPayment", ctx, paymentRequest)
+	ret0, _ := ret[0].(*models.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MakeRecoveryPayment indicates an expected call of MakeRecoveryPayment.
+func (mr *MockPaymentServiceInterfaceMockRecorder) MakeRecoveryPayment(ctx, paymentRequest interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeRecoveryPayment", reflect.TypeOf((*MockPaymentServiceInterface)(nil).MakeRecoveryPayment), ctx, paymentRequest)
+}
+
 // ProcessUpdatePayment mocks base method.
-func (m *MockPaymentServiceInterface) ProcessUpdatePayment(ctx context.Context, request models.Payment) error {
+func (m *MockPaymentServiceInterface) ProcessUpdatePayment(ctx context.Context, messageID string, request models.Payment) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ProcessUpdatePayment", ctx, request)
+	ret := m.ctrl.Call(m, "ProcessUpdatePayment", ctx, messageID, request)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // ProcessUpdatePayment indicates an expected call of ProcessUpdatePayment.
-func (mr *MockPaymentServiceInterfaceMockRecorder) ProcessUpdatePayment(ctx, request interface{}) *gomock.Call {
+func (mr *MockPaymentServiceInterfaceMockRecorder) ProcessUpdatePayment(ctx, messageID, request interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessUpdatePayment", reflect.TypeOf((*MockPaymentServiceInterface)(nil).ProcessUpdatePayment), ctx, request)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessUpdatePayment", reflect.TypeOf((*MockPaymentServiceInterface)(nil).ProcessUpdatePayment), ctx, messageID, request)
 }
@@ -1,6 +1,9 @@
 package helpers
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // GetNextMonday calculates the date for the next Monday (if today is Monday, the next Monday will be 7 days later).
 func GetNextMonday(currentDate time.Time) time.Time {
@@ -21,3 +24,68 @@ func GenerateLastBillDate(startDate time.Time, numWeeks int) time.Time {
 	lastBillDate := nextMonday.AddDate(0, 0, (numWeeks-1)*7) // Subtract 1 to get the last billing week
 	return lastBillDate
 }
+
+// DaysBetween counts the calendar days from one date to another, ignoring the time of day.
+func DaysBetween(from, to time.Time) int {
+	fromDate := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDate := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDate.Sub(fromDate).Hours() / 24)
+}
+
+// TruncateToDay drops the time of day, keeping the date in the same location.
+func TruncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// DatesBetween lists every calendar day from one date to another, both included.
+func DatesBetween(from, to time.Time) []time.Time {
+	var dates []time.Time
+	for date := TruncateToDay(from); !date.After(TruncateToDay(to)); date = date.AddDate(0, 0, 1) {
+		dates = append(dates, date)
+	}
+	return dates
+}
+
+// DefaultTimezone is the timezone of the loans which don't carry one
+const DefaultTimezone = "Asia/Jakarta"
+
+var locations sync.Map
+
+// LoadLocation loads an IANA timezone, an empty or unknown timezone falls back to DefaultTimezone.
+// Locations are cached as they are loaded for every loan.
+func LoadLocation(timezone string) *time.Location {
+	if timezone == "" {
+		timezone = DefaultTimezone
+	}
+	if location, ok := locations.Load(timezone); ok {
+		return location.(*time.Location)
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		if timezone == DefaultTimezone {
+			return time.UTC
+		}
+		return LoadLocation(DefaultTimezone)
+	}
+	locations.Store(timezone, location)
+	return location
+}
+
+// LocalDate get the calendar date of an instant in the given location, at midnight UTC like the DATE columns are read.
+func LocalDate(t time.Time, location *time.Location) time.Time {
+	local := t.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// DayCount30360 counts the days from one date to another on the 30/360 convention, every month has 30 days.
+func DayCount30360(from, to time.Time) int {
+	fromDay, toDay := from.Day(), to.Day()
+	if fromDay == 31 {
+		fromDay = 30
+	}
+	if toDay == 31 && fromDay == 30 {
+		toDay = 30
+	}
+	return (to.Year()-from.Year())*360 + (int(to.Month())-int(from.Month()))*30 + toDay - fromDay
+}
@@ -1,8 +1,21 @@
 package helpers
 
-import "flag"
+import (
+	"flag"
+	"fmt"
+	"os"
+)
 
 // IsTestEnv checks if the application is running in a test environment
 func IsTestEnv() bool {
 	return flag.Lookup("test.v") != nil
 }
+
+// InstanceID identifies the running process across containers, it prefixes the owner of the job leases
+func InstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
@@ -0,0 +1,39 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"github.com/okiww/billing-loan-system/pkg/errors"
+)
+
+// RetryPolicy how many times and how long apart a retryable operation is attempted
+type RetryPolicy struct {
+	Attempts     int           // Total attempts including the first one
+	InitialDelay time.Duration // Delay before the second attempt, doubled after every attempt
+	MaxDelay     time.Duration
+}
+
+// Retry calls fn until it succeeds, fails with a permanent error or runs out of attempts, waiting with exponential
+// backoff between attempts. The last error is returned
+func Retry(ctx context.Context, policy RetryPolicy, fn func() error) error {
+	delay := policy.InitialDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !errors.IsRetryable(err) || attempt >= policy.Attempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+	}
+}
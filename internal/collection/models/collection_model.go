@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// CollectionCaseModel represents the `collection_cases` table, one case per overdue loan
+type CollectionCaseModel struct {
+	ID                int64      `db:"id" json:"id"`
+	LoanID            int64      `db:"loan_id" json:"loan_id"`
+	UserID            int64      `db:"user_id" json:"user_id"`
+	AgentID           *int64     `db:"agent_id" json:"agent_id"`
+	Status            string     `db:"status" json:"status"` // e.g., 'OPEN', 'CLOSED'
+	PriorityScore     int32      `db:"priority_score" json:"priority_score"`
+	OverdueBills      int32      `db:"overdue_bills" json:"overdue_bills"`
+	OverdueAmount     int32      `db:"overdue_amount" json:"overdue_amount"`
+	OldestBillingDate time.Time  `db:"oldest_billing_date" json:"oldest_billing_date"`
+	DaysPastDue       int32      `db:"days_past_due" json:"days_past_due"`
+	AssignedAt        *time.Time `db:"assigned_at" json:"assigned_at"`
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt         *time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// ContactAttemptModel represents the `collection_contact_attempts` table
+type ContactAttemptModel struct {
+	ID          int64     `db:"id" json:"id"`
+	CaseID      int64     `db:"case_id" json:"case_id"`
+	AgentID     int64     `db:"agent_id" json:"agent_id"`
+	Channel     string    `db:"channel" json:"channel"` // e.g., 'CALL', 'SMS', 'WHATSAPP'
+	Outcome     string    `db:"outcome" json:"outcome"` // e.g., 'REACHED', 'NO_ANSWER', 'PROMISED'
+	Note        string    `db:"note" json:"note"`
+	AttemptedAt time.Time `db:"attempted_at" json:"attempted_at"`
+}
+
+// PromiseToPayModel represents the `promise_to_pays` table
+type PromiseToPayModel struct {
+	ID           int64      `db:"id" json:"id"`
+	CaseID       int64      `db:"case_id" json:"case_id"`
+	LoanID       int64      `db:"loan_id" json:"loan_id"`
+	AgentID      int64      `db:"agent_id" json:"agent_id"`
+	Amount       int32      `db:"amount" json:"amount"`
+	PromisedDate time.Time  `db:"promised_date" json:"promised_date"`
+	Status       string     `db:"status" json:"status"`           // e.g., 'PENDING', 'KEPT', 'BROKEN'
+	PaidAmount   int32      `db:"paid_amount" json:"paid_amount"` // Completed payments since the promise was made
+	ResolvedAt   *time.Time `db:"resolved_at" json:"resolved_at"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+}
+
+// OverdueLoanModel is an active loan with its overdue bills aggregated, the source of the collection queue
+type OverdueLoanModel struct {
+	LoanID            int64     `db:"loan_id"`
+	UserID            int64     `db:"user_id"`
+	OverdueBills      int32     `db:"overdue_bills"`
+	OverdueAmount     int32     `db:"overdue_amount"`
+	OldestBillingDate time.Time `db:"oldest_billing_date"`
+	Timezone          string    `db:"timezone"`
+}
+
+const (
+	CaseStatusOpen   = "OPEN"
+	CaseStatusClosed = "CLOSED"
+
+	PromiseStatusPending = "PENDING"
+	PromiseStatusKept    = "KEPT"
+	PromiseStatusBroken  = "BROKEN"
+
+	ChannelCall     = "CALL"
+	ChannelSMS      = "SMS"
+	ChannelWhatsApp = "WHATSAPP"
+	ChannelEmail    = "EMAIL"
+	ChannelVisit    = "VISIT"
+
+	OutcomeReached     = "REACHED"
+	OutcomeNoAnswer    = "NO_ANSWER"
+	OutcomeWrongNumber = "WRONG_NUMBER"
+	OutcomeRefused     = "REFUSED"
+	OutcomePromised    = "PROMISED"
+
+	// PriorityAmountUnit every overdue amount of this size adds one point on top of the days past due
+	PriorityAmountUnit = 100000
+)
+
+var (
+	Channels = map[string]bool{ChannelCall: true, ChannelSMS: true, ChannelWhatsApp: true, ChannelEmail: true, ChannelVisit: true}
+	Outcomes = map[string]bool{OutcomeReached: true, OutcomeNoAnswer: true, OutcomeWrongNumber: true, OutcomeRefused: true, OutcomePromised: true}
+)
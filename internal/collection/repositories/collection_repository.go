@@ -0,0 +1,243 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/okiww/billing-loan-system/helpers"
+	"github.com/okiww/billing-loan-system/internal/collection/models"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	repo     CollectionRepositoryInterface
+	repoLock sync.Once
+)
+
+type collectionRepository struct {
+	*mysql.DBMySQL
+}
+
+// FetchOverdueLoans aggregates the overdue bills of every active loan outside a deferral window
+func (c *collectionRepository) FetchOverdueLoans(ctx context.Context) ([]models.OverdueLoanModel, error) {
+	query := `
+		SELECT l.id AS loan_id, l.user_id, COUNT(lb.id) AS overdue_bills,
+		       SUM(lb.billing_total_amount) AS overdue_amount, MIN(lb.billing_date) AS oldest_billing_date, l.timezone
+		FROM loans l
+		JOIN loan_bills lb ON lb.loan_id = l.id
+		WHERE l.status = 'ACTIVE' AND lb.status = 'OVERDUE'
+		AND NOT EXISTS (
+			SELECT 1
+			FROM loan_deferrals ld
+			WHERE ld.loan_id = l.id AND DATE(?) BETWEEN ld.start_date AND ld.end_date
+		)
+		GROUP BY l.id, l.user_id, l.timezone
+	`
+	var loans []models.OverdueLoanModel
+	err := c.DB.SelectContext(ctx, &loans, query, c.Now())
+	if err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
+
+// UpsertCase opens a case for an overdue loan or refreshes the existing one, keeping its assignment
+func (c *collectionRepository) UpsertCase(ctx context.Context, collectionCase *models.CollectionCaseModel) error {
+	query := `
+		INSERT INTO collection_cases (loan_id, user_id, status, priority_score, overdue_bills, overdue_amount, oldest_billing_date, days_past_due)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			status = VALUES(status),
+			priority_score = VALUES(priority_score),
+			overdue_bills = VALUES(overdue_bills),
+			overdue_amount = VALUES(overdue_amount),
+			oldest_billing_date = VALUES(oldest_billing_date),
+			days_past_due = VALUES(days_past_due)
+	`
+	_, err := c.DB.ExecContext(ctx, query, collectionCase.LoanID, collectionCase.UserID, collectionCase.Status, collectionCase.PriorityScore,
+		collectionCase.OverdueBills, collectionCase.OverdueAmount, collectionCase.OldestBillingDate, collectionCase.DaysPastDue)
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"dataModel": collectionCase,
+		}).Error("error when save to collection_cases table")
+		return err
+	}
+	return nil
+}
+
+// CloseResolvedCases closes open cases whose loan has no overdue bill anymore or is on a payment holiday
+func (c *collectionRepository) CloseResolvedCases(ctx context.Context) (int64, error) {
+	query := `
+		UPDATE collection_cases cc
+		SET cc.status = 'CLOSED'
+		WHERE cc.status = 'OPEN'
+		AND NOT EXISTS (
+			SELECT 1
+			FROM loan_bills lb
+			JOIN loans l ON lb.loan_id = l.id
+			WHERE lb.loan_id = cc.loan_id AND l.status = 'ACTIVE' AND lb.status = 'OVERDUE'
+			AND NOT EXISTS (
+				SELECT 1
+				FROM loan_deferrals ld
+				WHERE ld.loan_id = l.id AND DATE(?) BETWEEN ld.start_date AND ld.end_date
+			)
+		)
+	`
+	result, err := c.DB.ExecContext(ctx, query, c.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetOpenCases retrieves the open cases by priority, optionally only the ones assigned to an agent
+func (c *collectionRepository) GetOpenCases(ctx context.Context, agentID int64, limit int) ([]models.CollectionCaseModel, error) {
+	query := `
+		SELECT id, loan_id, user_id, agent_id, status, priority_score, overdue_bills, overdue_amount,
+		       oldest_billing_date, days_past_due, assigned_at, created_at, updated_at
+		FROM collection_cases
+		WHERE status = 'OPEN' AND (? = 0 OR agent_id = ?)
+		ORDER BY priority_score DESC, id ASC
+		LIMIT ?
+	`
+	var cases []models.CollectionCaseModel
+	err := c.DB.SelectContext(ctx, &cases, query, agentID, agentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// GetCaseByID retrieves a collection case by its ID
+func (c *collectionRepository) GetCaseByID(ctx context.Context, id int64) (*models.CollectionCaseModel, error) {
+	query := `
+		SELECT id, loan_id, user_id, agent_id, status, priority_score, overdue_bills, overdue_amount,
+		       oldest_billing_date, days_past_due, assigned_at, created_at, updated_at
+		FROM collection_cases
+		WHERE id = ?
+	`
+	var collectionCase models.CollectionCaseModel
+	err := c.DB.GetContext(ctx, &collectionCase, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("no collection case found with id %d", id)
+		}
+		return nil, err
+	}
+	return &collectionCase, nil
+}
+
+// AssignCase assigns a collection case to an agent
+func (c *collectionRepository) AssignCase(ctx context.Context, caseID, agentID int64) error {
+	query := `
+		UPDATE collection_cases SET agent_id = ?, assigned_at = ? WHERE id = ?
+	`
+	_, err := c.DB.ExecContext(ctx, query, agentID, c.Now(), caseID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateContactAttempt inserts a contact attempt made by an agent
+func (c *collectionRepository) CreateContactAttempt(ctx context.Context, attempt *models.ContactAttemptModel) (int64, error) {
+	query := `
+		INSERT INTO collection_contact_attempts (case_id, agent_id, channel, outcome, note, attempted_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	result, err := c.DB.ExecContext(ctx, query, attempt.CaseID, attempt.AgentID, attempt.Channel, attempt.Outcome, attempt.Note, attempt.AttemptedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// CreatePromiseToPay inserts a promise-to-pay commitment
+func (c *collectionRepository) CreatePromiseToPay(ctx context.Context, promise *models.PromiseToPayModel) (int64, error) {
+	query := `
+		INSERT INTO promise_to_pays (case_id, loan_id, agent_id, amount, promised_date, status)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	result, err := c.DB.ExecContext(ctx, query, promise.CaseID, promise.LoanID, promise.AgentID, promise.Amount, promise.PromisedDate, promise.Status)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetPendingPromisesByLoanID retrieves the pending promises of a loan with the completed payments made since each promise
+func (c *collectionRepository) GetPendingPromisesByLoanID(ctx context.Context, loanID int64) ([]models.PromiseToPayModel, error) {
+	query := `
+		SELECT ptp.id, ptp.case_id, ptp.loan_id, ptp.agent_id, ptp.amount, ptp.promised_date, ptp.status,
+		       COALESCE(SUM(p.amount), 0) AS paid_amount, ptp.created_at
+		FROM promise_to_pays ptp
+		LEFT JOIN payments p ON p.loan_id = ptp.loan_id AND p.status = 'COMPLETED' AND p.created_at >= ptp.created_at
+		WHERE ptp.loan_id = ? AND ptp.status = 'PENDING'
+		GROUP BY ptp.id, ptp.case_id, ptp.loan_id, ptp.agent_id, ptp.amount, ptp.promised_date, ptp.status, ptp.created_at
+	`
+	var promises []models.PromiseToPayModel
+	err := c.DB.SelectContext(ctx, &promises, query, loanID)
+	if err != nil {
+		return nil, err
+	}
+	return promises, nil
+}
+
+// UpdatePromiseStatus resolves a promise as kept or broken
+func (c *collectionRepository) UpdatePromiseStatus(ctx context.Context, id int64, status string, paidAmount int32) error {
+	query := `
+		UPDATE promise_to_pays SET status = ?, paid_amount = ?, resolved_at = ? WHERE id = ? AND status = 'PENDING'
+	`
+	_, err := c.DB.ExecContext(ctx, query, status, paidAmount, c.Now(), id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// BreakExpiredPromises marks pending promises whose promised date has passed as broken
+func (c *collectionRepository) BreakExpiredPromises(ctx context.Context, asOf time.Time) (int64, error) {
+	query := `
+		UPDATE promise_to_pays SET status = 'BROKEN', resolved_at = ? WHERE status = 'PENDING' AND promised_date < ?
+	`
+	result, err := c.DB.ExecContext(ctx, query, c.Now(), asOf)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+type CollectionRepositoryInterface interface {
+	FetchOverdueLoans(ctx context.Context) ([]models.OverdueLoanModel, error)
+	UpsertCase(ctx context.Context, collectionCase *models.CollectionCaseModel) error
+	CloseResolvedCases(ctx context.Context) (int64, error)
+	GetOpenCases(ctx context.Context, agentID int64, limit int) ([]models.CollectionCaseModel, error)
+	GetCaseByID(ctx context.Context, id int64) (*models.CollectionCaseModel, error)
+	AssignCase(ctx context.Context, caseID, agentID int64) error
+	CreateContactAttempt(ctx context.Context, attempt *models.ContactAttemptModel) (int64, error)
+	CreatePromiseToPay(ctx context.Context, promise *models.PromiseToPayModel) (int64, error)
+	GetPendingPromisesByLoanID(ctx context.Context, loanID int64) ([]models.PromiseToPayModel, error)
+	UpdatePromiseStatus(ctx context.Context, id int64, status string, paidAmount int32) error
+	BreakExpiredPromises(ctx context.Context, asOf time.Time) (int64, error)
+}
+
+func NewCollectionRepository(db *mysql.DBMySQL) CollectionRepositoryInterface {
+	if helpers.IsTestEnv() { // Skip singleton in tests
+		return &collectionRepository{
+			db,
+		}
+	}
+
+	repoLock.Do(func() {
+		repo = &collectionRepository{
+			db,
+		}
+	})
+	return repo
+}
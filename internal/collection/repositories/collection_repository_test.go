@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/okiww/billing-loan-system/internal/collection/models"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/stretchr/testify/assert"
+	sqlmock "github.com/zhashkevych/go-sqlxmock"
+)
+
+func TestFetchOverdueLoans(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewCollectionRepository(&mysql.DBMySQL{DB: db})
+	oldestBillingDate := time.Now()
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    []models.OverdueLoanModel
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"loan_id", "user_id", "overdue_bills", "overdue_amount", "oldest_billing_date", "timezone"}).
+					AddRow(1, 1, 2, 250000, oldestBillingDate, "Asia/Jakarta")
+				mock.ExpectQuery(regexp.QuoteMeta("FROM loans l")).WillReturnRows(rows)
+			},
+			want: []models.OverdueLoanModel{
+				{LoanID: 1, UserID: 1, OverdueBills: 2, OverdueAmount: 250000, OldestBillingDate: oldestBillingDate, Timezone: "Asia/Jakarta"},
+			},
+		},
+		{
+			name: "Database Error",
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM loans l")).WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := repo.FetchOverdueLoans(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FetchOverdueLoans() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpsertCase(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewCollectionRepository(&mysql.DBMySQL{DB: db})
+	collectionCase := &models.CollectionCaseModel{
+		LoanID:            1,
+		UserID:            1,
+		Status:            models.CaseStatusOpen,
+		PriorityScore:     16,
+		OverdueBills:      2,
+		OverdueAmount:     250000,
+		OldestBillingDate: time.Now(),
+		DaysPastDue:       14,
+	}
+
+	tests := []struct {
+		name    string
+		mock    func()
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO collection_cases")).
+					WithArgs(collectionCase.LoanID, collectionCase.UserID, collectionCase.Status, collectionCase.PriorityScore,
+						collectionCase.OverdueBills, collectionCase.OverdueAmount, collectionCase.OldestBillingDate, collectionCase.DaysPastDue).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			name: "Database Error",
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO collection_cases")).
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			err := repo.UpsertCase(context.Background(), collectionCase)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UpsertCase() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetCaseByID(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewCollectionRepository(&mysql.DBMySQL{DB: db})
+
+	tests := []struct {
+		name    string
+		mock    func()
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"id", "loan_id", "user_id", "status"}).
+					AddRow(1, 1, 1, models.CaseStatusOpen)
+				mock.ExpectQuery(regexp.QuoteMeta("FROM collection_cases")).WithArgs(int64(1)).WillReturnRows(rows)
+			},
+		},
+		{
+			name: "Not Found",
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM collection_cases")).WithArgs(int64(1)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := repo.GetCaseByID(context.Background(), 1)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetCaseByID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, int64(1), got.ID)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBreakExpiredPromises(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewCollectionRepository(&mysql.DBMySQL{DB: db})
+	asOf := time.Now()
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE promise_to_pays SET status = 'BROKEN'")).
+		WithArgs(sqlmock.AnyArg(), asOf).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	total, err := repo.BreakExpiredPromises(context.Background(), asOf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/okiww/billing-loan-system/helpers"
+	"github.com/okiww/billing-loan-system/internal/collection/models"
+	"github.com/okiww/billing-loan-system/internal/collection/repositories"
+	"github.com/okiww/billing-loan-system/internal/dto"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+type collectionService struct {
+	collectionRepo repositories.CollectionRepositoryInterface
+	clock          clock.Clock
+}
+
+// BuildQueue opens or refreshes a case for every overdue loan and closes the cases that are no longer overdue
+func (c *collectionService) BuildQueue(ctx context.Context) (int32, error) {
+	logger.GetLogger().Info("[CollectionService][BuildQueue]")
+	loans, err := c.collectionRepo.FetchOverdueLoans(ctx)
+	if err != nil {
+		logger.GetLogger().Errorf("[CollectionService][BuildQueue] Error FetchOverdueLoans with err: %v", err)
+		return 0, err
+	}
+
+	now := c.clock.Now()
+	for _, loan := range loans {
+		daysPastDue := int32(helpers.DaysBetween(loan.OldestBillingDate, now.In(helpers.LoadLocation(loan.Timezone))))
+		err := c.collectionRepo.UpsertCase(ctx, &models.CollectionCaseModel{
+			LoanID:            loan.LoanID,
+			UserID:            loan.UserID,
+			Status:            models.CaseStatusOpen,
+			PriorityScore:     priorityScore(daysPastDue, loan.OverdueAmount),
+			OverdueBills:      loan.OverdueBills,
+			OverdueAmount:     loan.OverdueAmount,
+			OldestBillingDate: loan.OldestBillingDate,
+			DaysPastDue:       daysPastDue,
+		})
+		if err != nil {
+			logger.GetLogger().WithFields(logrus.Fields{
+				"loan_id": loan.LoanID,
+			}).Errorf("[CollectionService][BuildQueue] Error UpsertCase with err: %v", err)
+			return 0, err
+		}
+	}
+
+	closed, err := c.collectionRepo.CloseResolvedCases(ctx)
+	if err != nil {
+		logger.GetLogger().Errorf("[CollectionService][BuildQueue] Error CloseResolvedCases with err: %v", err)
+		return 0, err
+	}
+	logger.GetLogger().Infof("[CollectionService][BuildQueue] %d cases opened or refreshed, %d cases closed", len(loans), closed)
+
+	return int32(len(loans)), nil
+}
+
+// GetQueue get the open cases by priority, agentID 0 returns the whole queue
+func (c *collectionService) GetQueue(ctx context.Context, agentID int64, limit int) ([]models.CollectionCaseModel, error) {
+	logger.GetLogger().Info("[CollectionService][GetQueue]")
+	cases, err := c.collectionRepo.GetOpenCases(ctx, agentID, limit)
+	if err != nil {
+		logger.GetLogger().Errorf("[CollectionService][GetQueue] Error GetOpenCases with err: %v", err)
+		return []models.CollectionCaseModel{}, err
+	}
+	return cases, nil
+}
+
+func (c *collectionService) AssignCase(ctx context.Context, request dto.AssignCaseRequest) error {
+	logger.GetLogger().Info("[CollectionService][AssignCase]")
+	if _, err := c.getOpenCase(ctx, request.CaseID); err != nil {
+		return err
+	}
+
+	err := c.collectionRepo.AssignCase(ctx, request.CaseID, request.AgentID)
+	if err != nil {
+		logger.GetLogger().Errorf("[CollectionService][AssignCase] Error AssignCase with err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (c *collectionService) RecordContactAttempt(ctx context.Context, request dto.ContactAttemptRequest) (*models.ContactAttemptModel, error) {
+	logger.GetLogger().Info("[CollectionService][RecordContactAttempt]")
+	if !models.Channels[request.Channel] || !models.Outcomes[request.Outcome] {
+		return nil, errors.New(dto.ErrorInvalidContactAttempt)
+	}
+
+	if _, err := c.getOpenCase(ctx, request.CaseID); err != nil {
+		return nil, err
+	}
+
+	attempt := &models.ContactAttemptModel{
+		CaseID:      request.CaseID,
+		AgentID:     request.AgentID,
+		Channel:     request.Channel,
+		Outcome:     request.Outcome,
+		Note:        request.Note,
+		AttemptedAt: c.clock.Now(),
+	}
+	id, err := c.collectionRepo.CreateContactAttempt(ctx, attempt)
+	if err != nil {
+		logger.GetLogger().Errorf("[CollectionService][RecordContactAttempt] Error CreateContactAttempt with err: %v", err)
+		return nil, err
+	}
+	attempt.ID = id
+
+	return attempt, nil
+}
+
+func (c *collectionService) RecordPromiseToPay(ctx context.Context, request dto.PromiseToPayRequest) (*models.PromiseToPayModel, error) {
+	logger.GetLogger().Info("[CollectionService][RecordPromiseToPay]")
+	collectionCase, err := c.getOpenCase(ctx, request.CaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	promisedDate, err := time.Parse(dto.DateFormat, request.PromisedDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if helpers.DaysBetween(c.clock.Now(), promisedDate) < 0 {
+		return nil, errors.New(dto.ErrorPromisedDateInThePast)
+	}
+
+	promise := &models.PromiseToPayModel{
+		CaseID:       collectionCase.ID,
+		LoanID:       collectionCase.LoanID,
+		AgentID:      request.AgentID,
+		Amount:       request.Amount,
+		PromisedDate: promisedDate,
+		Status:       models.PromiseStatusPending,
+	}
+	id, err := c.collectionRepo.CreatePromiseToPay(ctx, promise)
+	if err != nil {
+		logger.GetLogger().Errorf("[CollectionService][RecordPromiseToPay] Error CreatePromiseToPay with err: %v", err)
+		return nil, err
+	}
+	promise.ID = id
+
+	return promise, nil
+}
+
+// ResolvePromises marks the pending promises of a loan as kept once the completed payments cover the promised amount,
+// or as broken once the promised date has passed without enough payments
+func (c *collectionService) ResolvePromises(ctx context.Context, loanID int64) error {
+	logger.GetLogger().Info("[CollectionService][ResolvePromises]")
+	promises, err := c.collectionRepo.GetPendingPromisesByLoanID(ctx, loanID)
+	if err != nil {
+		logger.GetLogger().Errorf("[CollectionService][ResolvePromises] Error GetPendingPromisesByLoanID with err: %v", err)
+		return err
+	}
+
+	now := c.clock.Now()
+	for _, promise := range promises {
+		var status string
+		switch {
+		case promise.PaidAmount >= promise.Amount:
+			status = models.PromiseStatusKept
+		case helpers.DaysBetween(promise.PromisedDate, now) > 0:
+			status = models.PromiseStatusBroken
+		default:
+			continue
+		}
+
+		err := c.collectionRepo.UpdatePromiseStatus(ctx, promise.ID, status, promise.PaidAmount)
+		if err != nil {
+			logger.GetLogger().WithFields(logrus.Fields{
+				"promise_id": promise.ID,
+			}).Errorf("[CollectionService][ResolvePromises] Error UpdatePromiseStatus with err: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BreakExpiredPromises marks every pending promise whose promised date has passed as broken
+func (c *collectionService) BreakExpiredPromises(ctx context.Context) (int32, error) {
+	logger.GetLogger().Info("[CollectionService][BreakExpiredPromises]")
+	total, err := c.collectionRepo.BreakExpiredPromises(ctx, c.clock.Now())
+	if err != nil {
+		logger.GetLogger().Errorf("[CollectionService][BreakExpiredPromises] Error BreakExpiredPromises with err: %v", err)
+		return 0, err
+	}
+	return int32(total), nil
+}
+
+func (c *collectionService) getOpenCase(ctx context.Context, caseID int64) (*models.CollectionCaseModel, error) {
+	collectionCase, err := c.collectionRepo.GetCaseByID(ctx, caseID)
+	if err != nil {
+		logger.GetLogger().Errorf("[CollectionService][getOpenCase] Error GetCaseByID with err: %v", err)
+		return nil, err
+	}
+
+	if collectionCase.Status != models.CaseStatusOpen {
+		return nil, errors.New(dto.ErrorCollectionCaseIsClosed)
+	}
+	return collectionCase, nil
+}
+
+// priorityScore ranks a case by its days past due, plus one point for every PriorityAmountUnit overdue
+func priorityScore(daysPastDue, overdueAmount int32) int32 {
+	return daysPastDue + overdueAmount/models.PriorityAmountUnit
+}
+
+type CollectionServiceInterface interface {
+	BuildQueue(ctx context.Context) (int32, error)
+	GetQueue(ctx context.Context, agentID int64, limit int) ([]models.CollectionCaseModel, error)
+	AssignCase(ctx context.Context, request dto.AssignCaseRequest) error
+	RecordContactAttempt(ctx context.Context, request dto.ContactAttemptRequest) (*models.ContactAttemptModel, error)
+	RecordPromiseToPay(ctx context.Context, request dto.PromiseToPayRequest) (*models.PromiseToPayModel, error)
+	ResolvePromises(ctx context.Context, loanID int64) error
+	BreakExpiredPromises(ctx context.Context) (int32, error)
+}
+
+func NewCollectionService(collectionRepo repositories.CollectionRepositoryInterface, clk clock.Clock) CollectionServiceInterface {
+	return &collectionService{collectionRepo, clk}
+}
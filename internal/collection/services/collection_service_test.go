@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	collection_mock "github.com/okiww/billing-loan-system/gen/mocks/collection"
+	"github.com/okiww/billing-loan-system/internal/collection/models"
+	"github.com/okiww/billing-loan-system/internal/dto"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildQueue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := collection_mock.NewMockCollectionRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	service := NewCollectionService(mockRepo, clock.Fixed(now))
+
+	oldestBillingDate := now.AddDate(0, 0, -14)
+
+	tests := []struct {
+		name          string
+		mockRepoCalls func()
+		expected      int32
+		expectedErr   error
+		wantErr       bool
+	}{
+		{
+			name: "Success build queue",
+			mockRepoCalls: func() {
+				mockRepo.EXPECT().
+					FetchOverdueLoans(context.Background()).
+					Return([]models.OverdueLoanModel{
+						{LoanID: 1, UserID: 1, OverdueBills: 2, OverdueAmount: 250000, OldestBillingDate: oldestBillingDate},
+					}, nil)
+				mockRepo.EXPECT().
+					UpsertCase(context.Background(), &models.CollectionCaseModel{
+						LoanID:            1,
+						UserID:            1,
+						Status:            models.CaseStatusOpen,
+						PriorityScore:     16,
+						OverdueBills:      2,
+						OverdueAmount:     250000,
+						OldestBillingDate: oldestBillingDate,
+						DaysPastDue:       14,
+					}).
+					Return(nil)
+				mockRepo.EXPECT().
+					CloseResolvedCases(context.Background()).
+					Return(int64(1), nil)
+			},
+			expected: 1,
+		},
+		{
+			name: "Error fetch overdue loans",
+			mockRepoCalls: func() {
+				mockRepo.EXPECT().
+					FetchOverdueLoans(context.Background()).
+					Return(nil, errors.New("db error"))
+			},
+			expectedErr: errors.New("db error"),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockRepoCalls()
+
+			total, err := service.BuildQueue(context.Background())
+			if tt.wantErr {
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, total)
+		})
+	}
+}
+
+func TestRecordContactAttempt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := collection_mock.NewMockCollectionRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	service := NewCollectionService(mockRepo, clock.Fixed(now))
+
+	tests := []struct {
+		name          string
+		request       dto.ContactAttemptRequest
+		mockRepoCalls func()
+		expectedErr   error
+		wantErr       bool
+	}{
+		{
+			name:    "Success record contact attempt",
+			request: dto.ContactAttemptRequest{CaseID: 1, AgentID: 2, Channel: models.ChannelCall, Outcome: models.OutcomeReached},
+			mockRepoCalls: func() {
+				mockRepo.EXPECT().
+					GetCaseByID(context.Background(), int64(1)).
+					Return(&models.CollectionCaseModel{ID: 1, Status: models.CaseStatusOpen}, nil)
+				mockRepo.EXPECT().
+					CreateContactAttempt(context.Background(), gomock.Any()).
+					Return(int64(10), nil)
+			},
+		},
+		{
+			name:          "Invalid channel",
+			request:       dto.ContactAttemptRequest{CaseID: 1, AgentID: 2, Channel: "PIGEON", Outcome: models.OutcomeReached},
+			mockRepoCalls: func() {},
+			expectedErr:   errors.New(dto.ErrorInvalidContactAttempt),
+			wantErr:       true,
+		},
+		{
+			name:    "Case is closed",
+			request: dto.ContactAttemptRequest{CaseID: 1, AgentID: 2, Channel: models.ChannelSMS, Outcome: models.OutcomeNoAnswer},
+			mockRepoCalls: func() {
+				mockRepo.EXPECT().
+					GetCaseByID(context.Background(), int64(1)).
+					Return(&models.CollectionCaseModel{ID: 1, Status: models.CaseStatusClosed}, nil)
+			},
+			expectedErr: errors.New(dto.ErrorCollectionCaseIsClosed),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockRepoCalls()
+
+			attempt, err := service.RecordContactAttempt(context.Background(), tt.request)
+			if tt.wantErr {
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+				assert.Nil(t, attempt)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(10), attempt.ID)
+			}
+		})
+	}
+}
+
+func TestRecordPromiseToPay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := collection_mock.NewMockCollectionRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	service := NewCollectionService(mockRepo, clock.Fixed(now))
+
+	tests := []struct {
+		name          string
+		request       dto.PromiseToPayRequest
+		mockRepoCalls func()
+		expectedErr   error
+		wantErr       bool
+	}{
+		{
+			name:    "Success record promise to pay",
+			request: dto.PromiseToPayRequest{CaseID: 1, AgentID: 2, Amount: 50000, PromisedDate: now.AddDate(0, 0, 3).Format(dto.DateFormat)},
+			mockRepoCalls: func() {
+				mockRepo.EXPECT().
+					GetCaseByID(context.Background(), int64(1)).
+					Return(&models.CollectionCaseModel{ID: 1, LoanID: 5, Status: models.CaseStatusOpen}, nil)
+				mockRepo.EXPECT().
+					CreatePromiseToPay(context.Background(), gomock.Any()).
+					Return(int64(7), nil)
+			},
+		},
+		{
+			name:    "Promised date in the past",
+			request: dto.PromiseToPayRequest{CaseID: 1, AgentID: 2, Amount: 50000, PromisedDate: now.AddDate(0, 0, -3).Format(dto.DateFormat)},
+			mockRepoCalls: func() {
+				mockRepo.EXPECT().
+					GetCaseByID(context.Background(), int64(1)).
+					Return(&models.CollectionCaseModel{ID: 1, LoanID: 5, Status: models.CaseStatusOpen}, nil)
+			},
+			expectedErr: errors.New(dto.ErrorPromisedDateInThePast),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockRepoCalls()
+
+			promise, err := service.RecordPromiseToPay(context.Background(), tt.request)
+			if tt.wantErr {
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+				assert.Nil(t, promise)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(7), promise.ID)
+				assert.Equal(t, int64(5), promise.LoanID)
+				assert.Equal(t, models.PromiseStatusPending, promise.Status)
+			}
+		})
+	}
+}
+
+func TestResolvePromises(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := collection_mock.NewMockCollectionRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	service := NewCollectionService(mockRepo, clock.Fixed(now))
+
+	mockRepo.EXPECT().
+		GetPendingPromisesByLoanID(context.Background(), int64(1)).
+		Return([]models.PromiseToPayModel{
+			{ID: 1, Amount: 50000, PaidAmount: 50000, PromisedDate: now.AddDate(0, 0, 2)},
+			{ID: 2, Amount: 50000, PaidAmount: 10000, PromisedDate: now.AddDate(0, 0, -2)},
+			{ID: 3, Amount: 50000, PaidAmount: 10000, PromisedDate: now.AddDate(0, 0, 2)},
+		}, nil)
+	mockRepo.EXPECT().
+		UpdatePromiseStatus(context.Background(), int64(1), models.PromiseStatusKept, int32(50000)).
+		Return(nil)
+	mockRepo.EXPECT().
+		UpdatePromiseStatus(context.Background(), int64(2), models.PromiseStatusBroken, int32(10000)).
+		Return(nil)
+
+	err := service.ResolvePromises(context.Background(), 1)
+	assert.NoError(t, err)
+}
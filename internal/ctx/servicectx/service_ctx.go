@@ -1,13 +1,23 @@
 package servicectx
 
 import (
+	collectionService "github.com/okiww/billing-loan-system/internal/collection/services"
+	jobService "github.com/okiww/billing-loan-system/internal/job/services"
 	"github.com/okiww/billing-loan-system/internal/loan/services"
+	mandateService "github.com/okiww/billing-loan-system/internal/mandate/services"
 	services2 "github.com/okiww/billing-loan-system/internal/payment/services"
+	reminderService "github.com/okiww/billing-loan-system/internal/reminder/services"
 	userService "github.com/okiww/billing-loan-system/internal/user/services"
+	"github.com/okiww/billing-loan-system/pkg/clock"
 )
 
 type ServiceCtx struct {
-	LoanService    services.LoanServiceInterface
-	UserService    userService.UserServiceInterface
-	PaymentService services2.PaymentServiceInterface
+	LoanService       services.LoanServiceInterface
+	UserService       userService.UserServiceInterface
+	PaymentService    services2.PaymentServiceInterface
+	CollectionService collectionService.CollectionServiceInterface
+	JobService        jobService.JobServiceInterface
+	ReminderService   reminderService.ReminderServiceInterface
+	MandateService    mandateService.MandateServiceInterface
+	Clock             clock.Clock
 }
@@ -0,0 +1,77 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/okiww/billing-loan-system/pkg/errors"
+)
+
+type AssignCaseRequest struct {
+	CaseID  int64 `json:"case_id"`
+	AgentID int64 `json:"agent_id"`
+}
+
+func (r *AssignCaseRequest) Validate() error {
+	if r.CaseID <= 0 {
+		return errors.New("case_id must be greater than 0")
+	}
+	if r.AgentID <= 0 {
+		return errors.New("agent_id must be greater than 0")
+	}
+	return nil
+}
+
+type ContactAttemptRequest struct {
+	CaseID  int64  `json:"case_id"`
+	AgentID int64  `json:"agent_id"`
+	Channel string `json:"channel"` // CALL, SMS, WHATSAPP, EMAIL, VISIT
+	Outcome string `json:"outcome"` // REACHED, NO_ANSWER, WRONG_NUMBER, REFUSED, PROMISED
+	Note    string `json:"note"`
+}
+
+func (r *ContactAttemptRequest) Validate() error {
+	if r.CaseID <= 0 {
+		return errors.New("case_id must be greater than 0")
+	}
+	if r.AgentID <= 0 {
+		return errors.New("agent_id must be greater than 0")
+	}
+	if len(r.Channel) == 0 {
+		return errors.New("channel cannot be empty")
+	}
+	if len(r.Outcome) == 0 {
+		return errors.New("outcome cannot be empty")
+	}
+	return nil
+}
+
+type PromiseToPayRequest struct {
+	CaseID       int64  `json:"case_id"`
+	AgentID      int64  `json:"agent_id"`
+	Amount       int32  `json:"amount"`
+	PromisedDate string `json:"promised_date"` // YYYY-MM-DD
+}
+
+func (r *PromiseToPayRequest) Validate() error {
+	if r.CaseID <= 0 {
+		return errors.New("case_id must be greater than 0")
+	}
+	if r.AgentID <= 0 {
+		return errors.New("agent_id must be greater than 0")
+	}
+	if r.Amount <= 0 {
+		return errors.New("amount must be greater than zero")
+	}
+	if _, err := time.Parse(DateFormat, r.PromisedDate); err != nil {
+		return errors.New("promised_date must be in YYYY-MM-DD format")
+	}
+	return nil
+}
+
+const (
+	DateFormat = "2006-01-02"
+
+	ErrorCollectionCaseIsClosed = "collection case is closed"
+	ErrorPromisedDateInThePast  = "promised date cannot be in the past"
+	ErrorInvalidContactAttempt  = "contact attempt channel or outcome is not valid"
+)
@@ -7,9 +7,12 @@ import (
 )
 
 type LoanRequest struct {
-	UserID     int    `json:"user_id"`
-	Name       string `json:"name"`
-	LoanAmount int32  `json:"loan_amount"`
+	UserID             int    `json:"user_id"`
+	Name               string `json:"name"`
+	LoanAmount         int32  `json:"loan_amount"`
+	Timezone           string `json:"timezone"`             // IANA timezone of the borrower, optional, defaults to Asia/Jakarta
+	InterestMethod     string `json:"interest_method"`      // FLAT or DECLINING_BALANCE, optional, defaults to FLAT
+	DayCountConvention string `json:"day_count_convention"` // ACT/365 or 30/360 for DECLINING_BALANCE, optional, defaults to ACT/365
 }
 
 type LoanResponse struct {
@@ -22,6 +25,9 @@ type LoanResponse struct {
 	StartDate          time.Time `json:"start_date"`
 	DueDate            time.Time `json:"due_date"`
 	LoanTermsPerWeek   int       `json:"loan_terms_per_week"`
+	Timezone           string    `json:"timezone"`
+	InterestMethod     string    `json:"interest_method"`
+	DayCountConvention string    `json:"day_count_convention"`
 	CreatedAt          time.Time `json:"created_at"`
 	UpdatedAt          time.Time `json:"updated_at"`
 }
@@ -42,5 +48,109 @@ func (r *LoanRequest) Validate() error {
 		return errors.New("loan_amount must be greater than 0")
 	}
 
+	// Check Timezone
+	if r.Timezone != "" {
+		if _, err := time.LoadLocation(r.Timezone); err != nil {
+			return errors.New("timezone must be a valid IANA timezone")
+		}
+	}
+
+	// Check InterestMethod
+	if r.InterestMethod != "" && r.InterestMethod != "FLAT" && r.InterestMethod != "DECLINING_BALANCE" {
+		return errors.New("interest_method must be FLAT or DECLINING_BALANCE")
+	}
+
+	// Check DayCountConvention
+	if r.DayCountConvention != "" && r.DayCountConvention != "ACT/365" && r.DayCountConvention != "30/360" {
+		return errors.New("day_count_convention must be ACT/365 or 30/360")
+	}
+
+	return nil
+}
+
+type RestructureLoanRequest struct {
+	LoanID             int64    `json:"loan_id"`
+	Reason             string   `json:"reason"`
+	Tenor              int32    `json:"tenor"`                         // New number of weekly bills, optional
+	InstallmentAmount  int32    `json:"installment_amount"`            // New weekly bill amount, optional
+	InterestPercentage *float64 `json:"interest_percentage,omitempty"` // New interest for the remaining principal, optional
+}
+
+func (r *RestructureLoanRequest) Validate() error {
+	if r.LoanID <= 0 {
+		return errors.New("loan_id must be greater than 0")
+	}
+
+	if len(r.Reason) == 0 {
+		return errors.New("reason cannot be empty")
+	}
+
+	if r.Tenor < 0 || r.InstallmentAmount < 0 {
+		return errors.New("tenor and installment_amount cannot be negative")
+	}
+
+	if r.Tenor > 0 && r.InstallmentAmount > 0 {
+		return errors.New("tenor and installment_amount cannot be set together")
+	}
+
+	if r.InterestPercentage != nil && *r.InterestPercentage < 0 {
+		return errors.New("interest_percentage cannot be negative")
+	}
+
+	if r.Tenor == 0 && r.InstallmentAmount == 0 && r.InterestPercentage == nil {
+		return errors.New("one of tenor, installment_amount or interest_percentage must be changed")
+	}
+
 	return nil
 }
+
+type WriteOffLoanRequest struct {
+	LoanID int64  `json:"loan_id"`
+	Reason string `json:"reason"`
+}
+
+func (r *WriteOffLoanRequest) Validate() error {
+	if r.LoanID <= 0 {
+		return errors.New("loan_id must be greater than 0")
+	}
+
+	if len(r.Reason) == 0 {
+		return errors.New("reason cannot be empty")
+	}
+
+	return nil
+}
+
+type DeferLoanRequest struct {
+	LoanID             int64  `json:"loan_id"`
+	Reason             string `json:"reason"`
+	Periods            int32  `json:"periods"`             // Number of weeks to shift the bills by
+	FromBillingNumber  int    `json:"from_billing_number"` // First bill to shift, optional, defaults to the next unpaid bill
+	CapitalizeInterest bool   `json:"capitalize_interest"` // Charge the interest of the holiday on the deferred bills
+}
+
+func (r *DeferLoanRequest) Validate() error {
+	if r.LoanID <= 0 {
+		return errors.New("loan_id must be greater than 0")
+	}
+
+	if len(r.Reason) == 0 {
+		return errors.New("reason cannot be empty")
+	}
+
+	if r.Periods <= 0 {
+		return errors.New("periods must be greater than 0")
+	}
+
+	if r.FromBillingNumber < 0 {
+		return errors.New("from_billing_number cannot be negative")
+	}
+
+	return nil
+}
+
+const (
+	ErrorLoanHasNoUnpaidBills     = "loan has no unpaid bills"
+	ErrorLoanHasNoDeferrableBills = "loan has no pending bills to defer"
+	ErrorPayoffDateInThePast      = "payoff date cannot be in the past"
+)
@@ -0,0 +1,51 @@
+package dto
+
+import "github.com/okiww/billing-loan-system/pkg/errors"
+
+type MandateRequest struct {
+	UserID             int64  `json:"user_id"`
+	LoanID             int64  `json:"loan_id"`
+	PaymentMethodToken string `json:"payment_method_token"`
+	MaxAmount          int32  `json:"max_amount"`
+}
+
+func (r *MandateRequest) Validate() error {
+	if r.UserID <= 0 {
+		return errors.New("user_id must be greater than 0")
+	}
+	if r.LoanID <= 0 {
+		return errors.New("loan_id must be greater than 0")
+	}
+	if len(r.PaymentMethodToken) == 0 {
+		return errors.New("payment_method_token cannot be empty")
+	}
+	if r.MaxAmount <= 0 {
+		return errors.New("max_amount must be greater than zero")
+	}
+	return nil
+}
+
+// MandateStatusRequest pauses, resumes or revokes a mandate of the user
+type MandateStatusRequest struct {
+	MandateID int64 `json:"mandate_id"`
+	UserID    int64 `json:"user_id"`
+}
+
+func (r *MandateStatusRequest) Validate() error {
+	if r.MandateID <= 0 {
+		return errors.New("mandate_id must be greater than 0")
+	}
+	if r.UserID <= 0 {
+		return errors.New("user_id must be greater than 0")
+	}
+	return nil
+}
+
+const (
+	ErrorLoanNotOwnedByUser    = "loan does not belong to the user"
+	ErrorMandateNotOwnedByUser = "mandate does not belong to the user"
+	ErrorMandateAlreadyExists  = "loan already has a mandate"
+	ErrorMandateIsNotActive    = "mandate is not active"
+	ErrorMandateIsNotPaused    = "mandate is not paused"
+	ErrorMandateIsRevoked      = "mandate is revoked"
+)
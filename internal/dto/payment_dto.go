@@ -22,8 +22,28 @@ func (r *PaymentRequest) Validate() error {
 	return nil
 }
 
+type RecoveryPaymentRequest struct {
+	UserID int `json:"user_id"`
+	LoanID int `json:"loan_id"`
+	Amount int `json:"amount"`
+}
+
+func (r *RecoveryPaymentRequest) Validate() error {
+	if r.UserID == 0 {
+		return errors.New("user_id is required")
+	}
+	if r.LoanID == 0 {
+		return errors.New("loan_id is required")
+	}
+	if r.Amount <= 0 {
+		return errors.New("amount must be greater than zero")
+	}
+	return nil
+}
+
 const (
 	ErrorLoanBillStatusNotBilled       = "loan bill status is not billed"
 	ErrorPaymentAmountNotMatchWithBill = "payment amount not match with the bill"
 	ErrorLoanIsNotActive               = "loan is not active"
+	ErrorLoanIsNotWrittenOff           = "loan is not written off"
 )
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// JobRunModel represents the `job_runs` table, one row per execution of a background job
+type JobRunModel struct {
+	ID             int64      `db:"id" json:"id"`
+	JobName        string     `db:"job_name" json:"job_name"`
+	InstanceID     string     `db:"instance_id" json:"instance_id"`
+	Status         string     `db:"status" json:"status"` // e.g., 'RUNNING', 'SUCCESS', 'FAILED', 'SKIPPED'
+	ProcessedCount int32      `db:"processed_count" json:"processed_count"`
+	FailedCount    int32      `db:"failed_count" json:"failed_count"`
+	SkippedCount   int32      `db:"skipped_count" json:"skipped_count"`
+	Error          string     `db:"error" json:"error"`
+	StartedAt      time.Time  `db:"started_at" json:"started_at"`
+	FinishedAt     *time.Time `db:"finished_at" json:"finished_at"`
+}
+
+// JobRunFailureModel represents the `job_run_failures` table, one row per item a job run failed to process
+type JobRunFailureModel struct {
+	ID        int64     `db:"id" json:"id"`
+	JobRunID  int64     `db:"job_run_id" json:"job_run_id"`
+	Reference string    `db:"reference" json:"reference"` // e.g., 'loan:1'
+	Error     string    `db:"error" json:"error"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+const (
+	JobRunStatusRunning = "RUNNING"
+	JobRunStatusSuccess = "SUCCESS"
+	JobRunStatusFailed  = "FAILED"
+	JobRunStatusSkipped = "SKIPPED"
+
+	JobRunErrorLocked = "lock is held by another instance"
+
+	// DefaultLeaseTTL a lease not renewed for this long is stale and can be taken over by another instance
+	DefaultLeaseTTL = 5 * time.Minute
+)
@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/okiww/billing-loan-system/helpers"
+	"github.com/okiww/billing-loan-system/internal/job/models"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	repo     JobRepositoryInterface
+	repoLock sync.Once
+)
+
+type jobRepository struct {
+	*mysql.DBMySQL
+}
+
+// CreateRun inserts a job run and sets its ID
+func (j *jobRepository) CreateRun(ctx context.Context, run *models.JobRunModel) error {
+	query := `
+		INSERT INTO job_runs (job_name, instance_id, status, processed_count, failed_count, skipped_count, error, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := j.DB.ExecContext(ctx, query, run.JobName, run.InstanceID, run.Status, run.ProcessedCount, run.FailedCount, run.SkippedCount, run.Error, run.StartedAt, run.FinishedAt)
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"dataModel": run,
+		}).Error("error when save to job_runs table")
+		return err
+	}
+
+	run.ID, err = result.LastInsertId()
+	return err
+}
+
+// FinishRun saves the final status, processed, failed and skipped counts and error of a job run
+func (j *jobRepository) FinishRun(ctx context.Context, run *models.JobRunModel) error {
+	query := `
+		UPDATE job_runs SET status = ?, processed_count = ?, failed_count = ?, skipped_count = ?, error = ?, finished_at = ? WHERE id = ?
+	`
+	_, err := j.DB.ExecContext(ctx, query, run.Status, run.ProcessedCount, run.FailedCount, run.SkippedCount, run.Error, run.FinishedAt, run.ID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateRunFailure inserts an item a job run failed to process
+func (j *jobRepository) CreateRunFailure(ctx context.Context, failure *models.JobRunFailureModel) error {
+	query := `
+		INSERT INTO job_run_failures (job_run_id, reference, error) VALUES (?, ?, ?)
+	`
+	_, err := j.DB.ExecContext(ctx, query, failure.JobRunID, failure.Reference, failure.Error)
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"dataModel": failure,
+		}).Error("error when save to job_run_failures table")
+		return err
+	}
+	return nil
+}
+
+// AcquireLock takes the lease of a job when it is free or expired, a held lease is only extended by RenewLock. The
+// lease is timed by the database clock so the clocks of the instances don't decide who holds it
+func (j *jobRepository) AcquireLock(ctx context.Context, jobName, owner string, ttl time.Duration) (bool, error) {
+	// owner is assigned first so the following columns only change when the lease was taken
+	query := `
+		INSERT INTO job_locks (job_name, owner, locked_until, heartbeat_at)
+		VALUES (?, ?, NOW(3) + INTERVAL ? MICROSECOND, NOW(3))
+		ON DUPLICATE KEY UPDATE
+			owner = IF(locked_until < NOW(3), VALUES(owner), owner),
+			locked_until = IF(owner = VALUES(owner), VALUES(locked_until), locked_until),
+			heartbeat_at = IF(owner = VALUES(owner), VALUES(heartbeat_at), heartbeat_at)
+	`
+	_, err := j.DB.ExecContext(ctx, query, jobName, owner, ttl.Microseconds())
+	if err != nil {
+		return false, err
+	}
+
+	var currentOwner string
+	err = j.DB.QueryRowContext(ctx, `SELECT owner FROM job_locks WHERE job_name = ?`, jobName).Scan(&currentOwner)
+	if err != nil {
+		return false, err
+	}
+	return currentOwner == owner, nil
+}
+
+// RenewLock extends the lease of a job by the database clock, returns false when the lease has been taken over
+func (j *jobRepository) RenewLock(ctx context.Context, jobName, owner string, ttl time.Duration) (bool, error) {
+	query := `
+		UPDATE job_locks SET locked_until = NOW(3) + INTERVAL ? MICROSECOND, heartbeat_at = NOW(3)
+		WHERE job_name = ? AND owner = ?
+	`
+	result, err := j.DB.ExecContext(ctx, query, ttl.Microseconds(), jobName, owner)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ReleaseLock frees the lease of a job if still owned
+func (j *jobRepository) ReleaseLock(ctx context.Context, jobName, owner string) error {
+	query := `
+		DELETE FROM job_locks WHERE job_name = ? AND owner = ?
+	`
+	_, err := j.DB.ExecContext(ctx, query, jobName, owner)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type JobRepositoryInterface interface {
+	CreateRun(ctx context.Context, run *models.JobRunModel) error
+	FinishRun(ctx context.Context, run *models.JobRunModel) error
+	CreateRunFailure(ctx context.Context, failure *models.JobRunFailureModel) error
+	AcquireLock(ctx context.Context, jobName, owner string, ttl time.Duration) (bool, error)
+	RenewLock(ctx context.Context, jobName, owner string, ttl time.Duration) (bool, error)
+	ReleaseLock(ctx context.Context, jobName, owner string) error
+}
+
+func NewJobRepository(db *mysql.DBMySQL) JobRepositoryInterface {
+	if helpers.IsTestEnv() { // Skip singleton in tests
+		return &jobRepository{
+			db,
+		}
+	}
+
+	repoLock.Do(func() {
+		repo = &jobRepository{
+			db,
+		}
+	})
+	return repo
+}
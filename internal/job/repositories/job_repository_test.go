@@ -0,0 +1,282 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/okiww/billing-loan-system/internal/job/models"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/stretchr/testify/assert"
+	sqlmock "github.com/zhashkevych/go-sqlxmock"
+)
+
+func TestCreateRun(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewJobRepository(&mysql.DBMySQL{DB: db})
+	run := &models.JobRunModel{
+		JobName:    "generate_bill_payment",
+		InstanceID: "host-1",
+		Status:     models.JobRunStatusRunning,
+		StartedAt:  time.Now(),
+	}
+
+	tests := []struct {
+		name    string
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO job_runs")).
+					WithArgs(run.JobName, run.InstanceID, run.Status, run.ProcessedCount, run.FailedCount, run.SkippedCount, run.Error, run.StartedAt, run.FinishedAt).
+					WillReturnResult(sqlmock.NewResult(7, 1))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO job_runs")).
+					WillReturnError(assert.AnError)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			err := repo.CreateRun(context.Background(), run)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateRun() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, int64(7), run.ID)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFinishRun(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewJobRepository(&mysql.DBMySQL{DB: db})
+	finishedAt := time.Now()
+	run := &models.JobRunModel{
+		ID:             7,
+		Status:         models.JobRunStatusSuccess,
+		ProcessedCount: 10,
+		FailedCount:    1,
+		SkippedCount:   4,
+		FinishedAt:     &finishedAt,
+	}
+
+	tests := []struct {
+		name    string
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE job_runs SET status = ?, processed_count = ?, failed_count = ?, skipped_count = ?")).
+					WithArgs(run.Status, run.ProcessedCount, run.FailedCount, run.SkippedCount, run.Error, run.FinishedAt, run.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE job_runs")).
+					WillReturnError(assert.AnError)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			err := repo.FinishRun(context.Background(), run)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FinishRun() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewJobRepository(&mysql.DBMySQL{DB: db})
+
+	tests := []struct {
+		name    string
+		owner   string
+		want    bool
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name:  "Acquired",
+			owner: "host-1",
+			want:  true,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO job_locks")).
+					WithArgs("generate_bill_payment", "host-1", int64(time.Minute/time.Microsecond)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT owner FROM job_locks WHERE job_name = ?")).
+					WithArgs("generate_bill_payment").
+					WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("host-1"))
+			},
+		},
+		{
+			name:  "Held By Another Instance",
+			owner: "host-2",
+			want:  false,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO job_locks")).
+					WithArgs("generate_bill_payment", "host-2", int64(time.Minute/time.Microsecond)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT owner FROM job_locks WHERE job_name = ?")).
+					WithArgs("generate_bill_payment").
+					WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("host-1"))
+			},
+		},
+		{
+			name:  "Held By Another Run Of The Same Instance",
+			owner: "host-1-b",
+			want:  false,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("owner = IF(locked_until < NOW(3), VALUES(owner), owner)")).
+					WithArgs("generate_bill_payment", "host-1-b", int64(time.Minute/time.Microsecond)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT owner FROM job_locks WHERE job_name = ?")).
+					WithArgs("generate_bill_payment").
+					WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("host-1-a"))
+			},
+		},
+		{
+			name:    "Database Error",
+			owner:   "host-1",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO job_locks")).
+					WillReturnError(assert.AnError)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := repo.AcquireLock(context.Background(), "generate_bill_payment", tt.owner, time.Minute)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AcquireLock() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRenewLock(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewJobRepository(&mysql.DBMySQL{DB: db})
+
+	tests := []struct {
+		name string
+		want bool
+		mock func()
+	}{
+		{
+			name: "Renewed",
+			want: true,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE job_locks SET locked_until = NOW(3) + INTERVAL ? MICROSECOND, heartbeat_at = NOW(3)")).
+					WithArgs(int64(time.Minute/time.Microsecond), "generate_bill_payment", "host-1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "Taken Over",
+			want: false,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE job_locks SET locked_until = NOW(3) + INTERVAL ? MICROSECOND, heartbeat_at = NOW(3)")).
+					WithArgs(int64(time.Minute/time.Microsecond), "generate_bill_payment", "host-1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := repo.RenewLock(context.Background(), "generate_bill_payment", "host-1", time.Minute)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateRunFailure(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewJobRepository(&mysql.DBMySQL{DB: db})
+	failure := &models.JobRunFailureModel{JobRunID: 7, Reference: "loan:1", Error: "db error"}
+
+	tests := []struct {
+		name    string
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO job_run_failures")).
+					WithArgs(failure.JobRunID, failure.Reference, failure.Error).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO job_run_failures")).
+					WillReturnError(assert.AnError)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			err := repo.CreateRunFailure(context.Background(), failure)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateRunFailure() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/okiww/billing-loan-system/internal/job/models"
+	"github.com/okiww/billing-loan-system/internal/job/repositories"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+type jobService struct {
+	jobRepo    repositories.JobRepositoryInterface
+	instanceID string
+	leaseTTL   time.Duration
+	mu         sync.Mutex // guards the failed count of the runs, jobs can record failures concurrently
+}
+
+// Run executes a job while holding its lease so only one run at a time, every run is recorded in job_runs.
+// A run is SKIPPED when another run, of this instance or another one, owns an unexpired lease, the lease is renewed
+// while the job runs and the job context is cancelled if the lease is lost. The job reports what it processed on the run
+func (j *jobService) Run(ctx context.Context, jobName string, fn func(ctx context.Context, run *models.JobRunModel) error) (*models.JobRunModel, error) {
+	logger.GetLogger().Infof("[JobService][Run] %s", jobName)
+	run := &models.JobRunModel{
+		JobName:    jobName,
+		InstanceID: j.instanceID,
+		Status:     models.JobRunStatusRunning,
+		StartedAt:  time.Now(),
+	}
+
+	// the lease is owned by the run, not the instance, so a job fired again while its previous run is still going
+	// is skipped and can't release the lease of that run
+	owner := j.newLockOwner()
+	acquired, err := j.jobRepo.AcquireLock(ctx, jobName, owner, j.leaseTTL)
+	if err != nil {
+		logger.GetLogger().Errorf("[JobService][Run] Error AcquireLock with err: %v", err)
+		return nil, err
+	}
+
+	if !acquired {
+		logger.GetLogger().Infof("[JobService][Run] Skip %s, %s", jobName, models.JobRunErrorLocked)
+		finishedAt := time.Now()
+		run.Status = models.JobRunStatusSkipped
+		run.Error = models.JobRunErrorLocked
+		run.FinishedAt = &finishedAt
+		if err := j.jobRepo.CreateRun(ctx, run); err != nil {
+			logger.GetLogger().Errorf("[JobService][Run] Error CreateRun with err: %v", err)
+			return nil, err
+		}
+		return run, nil
+	}
+	defer func() {
+		if err := j.jobRepo.ReleaseLock(ctx, jobName, owner); err != nil {
+			logger.GetLogger().Errorf("[JobService][Run] Error ReleaseLock with err: %v", err)
+		}
+	}()
+
+	err = j.jobRepo.CreateRun(ctx, run)
+	if err != nil {
+		logger.GetLogger().Errorf("[JobService][Run] Error CreateRun with err: %v", err)
+		return nil, err
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go j.heartbeat(jobCtx, cancel, jobName, owner, done)
+
+	jobErr := fn(jobCtx, run)
+	close(done)
+	cancel()
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = models.JobRunStatusSuccess
+	if jobErr != nil {
+		run.Status = models.JobRunStatusFailed
+		run.Error = jobErr.Error()
+	}
+	logger.GetLogger().Infof("[JobService][Run] %s finished with status %s, processed %d, failed %d, skipped %d",
+		jobName, run.Status, run.ProcessedCount, run.FailedCount, run.SkippedCount)
+
+	err = j.jobRepo.FinishRun(ctx, run)
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"job_run_id": run.ID,
+		}).Errorf("[JobService][Run] Error FinishRun with err: %v", err)
+		if jobErr == nil {
+			return run, err
+		}
+	}
+
+	return run, jobErr
+}
+
+// RecordFailure records an item the run failed to process and counts it on the run, the run carries on with the other items.
+// It is safe to call from concurrent workers
+func (j *jobService) RecordFailure(ctx context.Context, run *models.JobRunModel, reference string, cause error) error {
+	j.mu.Lock()
+	run.FailedCount++
+	j.mu.Unlock()
+
+	err := j.jobRepo.CreateRunFailure(ctx, &models.JobRunFailureModel{
+		JobRunID:  run.ID,
+		Reference: reference,
+		Error:     cause.Error(),
+	})
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"job_run_id": run.ID,
+			"reference":  reference,
+		}).Errorf("[JobService][RecordFailure] Error CreateRunFailure with err: %v", err)
+		return err
+	}
+	return nil
+}
+
+// heartbeat renews the lease until the job is done, cancelling the job when the lease is lost
+func (j *jobService) heartbeat(ctx context.Context, cancel context.CancelFunc, jobName, owner string, done <-chan struct{}) {
+	ticker := time.NewTicker(j.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			renewed, err := j.jobRepo.RenewLock(ctx, jobName, owner, j.leaseTTL)
+			if err != nil {
+				// the lease is still valid until it expires, try again on the next tick
+				logger.GetLogger().Errorf("[JobService][heartbeat] Error RenewLock with err: %v", err)
+				continue
+			}
+			if !renewed {
+				logger.GetLogger().Errorf("[JobService][heartbeat] Lease of %s has been taken over, cancelling the job", jobName)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// newLockOwner generates the owner of the lease of a run, the instance id followed by a random suffix
+func (j *jobService) newLockOwner() string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%s", j.instanceID, hex.EncodeToString(suffix))
+}
+
+type JobServiceInterface interface {
+	Run(ctx context.Context, jobName string, fn func(ctx context.Context, run *models.JobRunModel) error) (*models.JobRunModel, error)
+	RecordFailure(ctx context.Context, run *models.JobRunModel, reference string, cause error) error
+}
+
+func NewJobService(jobRepo repositories.JobRepositoryInterface, instanceID string) JobServiceInterface {
+	return &jobService{
+		jobRepo:    jobRepo,
+		instanceID: instanceID,
+		leaseTTL:   models.DefaultLeaseTTL,
+	}
+}
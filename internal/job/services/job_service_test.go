@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	job_mock "github.com/okiww/billing-loan-system/gen/mocks/job"
+	"github.com/okiww/billing-loan-system/internal/job/models"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := job_mock.NewMockJobRepositoryInterface(ctrl)
+	service := NewJobService(mockRepo, "host-1")
+
+	// the lease is owned by the run, the owner of a run is released with the lease it acquired
+	var owner string
+	acquireLock := func(acquired bool) {
+		mockRepo.EXPECT().AcquireLock(gomock.Any(), "generate_bill_payment", gomock.Any(), models.DefaultLeaseTTL).
+			DoAndReturn(func(_ context.Context, _, runOwner string, _ time.Duration) (bool, error) {
+				assert.True(t, strings.HasPrefix(runOwner, "host-1-"), "owner %s", runOwner)
+				assert.NotEqual(t, owner, runOwner, "every run owns its lease")
+				owner = runOwner
+				return acquired, nil
+			})
+	}
+	releaseLock := func() {
+		mockRepo.EXPECT().ReleaseLock(gomock.Any(), "generate_bill_payment", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, runOwner string) error {
+				assert.Equal(t, owner, runOwner)
+				return nil
+			})
+	}
+
+	tests := []struct {
+		name           string
+		fn             func(ctx context.Context, run *models.JobRunModel) error
+		mockRepoCalls  func()
+		expectedStatus string
+		expectedErr    error
+	}{
+		{
+			name: "Success",
+			fn: func(ctx context.Context, run *models.JobRunModel) error {
+				run.ProcessedCount = 3
+				return nil
+			},
+			mockRepoCalls: func() {
+				acquireLock(true)
+				mockRepo.EXPECT().CreateRun(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().FinishRun(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, run *models.JobRunModel) error {
+						assert.Equal(t, models.JobRunStatusSuccess, run.Status)
+						assert.Equal(t, int32(3), run.ProcessedCount)
+						assert.NotNil(t, run.FinishedAt)
+						return nil
+					})
+				releaseLock()
+			},
+			expectedStatus: models.JobRunStatusSuccess,
+		},
+		{
+			name: "Job Failed",
+			fn: func(ctx context.Context, run *models.JobRunModel) error {
+				return errors.New("db error")
+			},
+			mockRepoCalls: func() {
+				acquireLock(true)
+				mockRepo.EXPECT().CreateRun(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().FinishRun(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, run *models.JobRunModel) error {
+						assert.Equal(t, models.JobRunStatusFailed, run.Status)
+						assert.Equal(t, "db error", run.Error)
+						return nil
+					})
+				releaseLock()
+			},
+			expectedStatus: models.JobRunStatusFailed,
+			expectedErr:    errors.New("db error"),
+		},
+		{
+			name: "Skipped When Locked",
+			fn: func(ctx context.Context, run *models.JobRunModel) error {
+				t.Error("job should not run without the lock")
+				return nil
+			},
+			mockRepoCalls: func() {
+				acquireLock(false)
+				mockRepo.EXPECT().CreateRun(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, run *models.JobRunModel) error {
+						assert.Equal(t, models.JobRunStatusSkipped, run.Status)
+						assert.Equal(t, models.JobRunErrorLocked, run.Error)
+						return nil
+					})
+			},
+			expectedStatus: models.JobRunStatusSkipped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockRepoCalls()
+
+			run, err := service.Run(context.Background(), "generate_bill_payment", tt.fn)
+			if tt.expectedErr != nil {
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedStatus, run.Status)
+		})
+	}
+}
+
+func TestRecordFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := job_mock.NewMockJobRepositoryInterface(ctrl)
+	service := NewJobService(mockRepo, "host-1")
+
+	tests := []struct {
+		name          string
+		mockRepoCalls func()
+		expectedErr   error
+	}{
+		{
+			name: "Success",
+			mockRepoCalls: func() {
+				mockRepo.EXPECT().CreateRunFailure(gomock.Any(), &models.JobRunFailureModel{JobRunID: 7, Reference: "loan:1", Error: "db error"}).Return(nil)
+			},
+		},
+		{
+			name: "Error Create Run Failure",
+			mockRepoCalls: func() {
+				mockRepo.EXPECT().CreateRunFailure(gomock.Any(), gomock.Any()).Return(errors.New("insert error"))
+			},
+			expectedErr: errors.New("insert error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockRepoCalls()
+
+			run := &models.JobRunModel{ID: 7}
+			err := service.RecordFailure(context.Background(), run, "loan:1", errors.New("db error"))
+			if tt.expectedErr != nil {
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, int32(1), run.FailedCount)
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// BillingRunModel represents the `billing_runs` table, one row per billing date processed
+type BillingRunModel struct {
+	ID        int64     `db:"id" json:"id"`
+	RunDate   time.Time `db:"run_date" json:"run_date"`
+	Status    string    `db:"status" json:"status"` // e.g., 'SUCCESS', 'FAILED'
+	Error     string    `db:"error" json:"error"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+const (
+	BillingRunStatusSuccess = "SUCCESS"
+	BillingRunStatusFailed  = "FAILED"
+)
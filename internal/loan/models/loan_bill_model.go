@@ -16,6 +16,25 @@ type LoanBillModel struct {
 }
 
 const (
-	StatusActive = "ACTIVE"
-	StatusClosed = "CLOSED"
+	StatusActive     = "ACTIVE"
+	StatusClosed     = "CLOSED"
+	StatusWrittenOff = "WRITTEN_OFF"
 )
+
+// LoanBillStatusChangeModel is a bill which status would change when the bill statuses are updated as of a date
+type LoanBillStatusChangeModel struct {
+	ID            int       `db:"id" json:"id"`
+	LoanID        int64     `db:"loan_id" json:"loan_id"`
+	UserID        int64     `db:"user_id" json:"user_id"`
+	BillingNumber int       `db:"billing_number" json:"billing_number"`
+	BillingDate   time.Time `db:"billing_date" json:"billing_date"`
+	FromStatus    string    `db:"from_status" json:"from_status"`
+	ToStatus      string    `db:"to_status" json:"to_status"`
+}
+
+// DelinquencyChangeModel is a loan which would flag its user as delinquent
+type DelinquencyChangeModel struct {
+	LoanID       int64 `db:"loan_id" json:"loan_id"`
+	UserID       int64 `db:"user_id" json:"user_id"`
+	OverdueCount int   `db:"overdue_count" json:"overdue_count"`
+}
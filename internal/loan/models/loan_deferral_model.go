@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// LoanDeferralModel represents the `loan_deferrals` table, a payment holiday granted on a loan
+type LoanDeferralModel struct {
+	ID                  int64     `db:"id" json:"id"`
+	LoanID              int64     `db:"loan_id" json:"loan_id"`
+	Reason              string    `db:"reason" json:"reason"`
+	Periods             int32     `db:"periods" json:"periods"`                           // Number of weeks the bills are shifted by
+	FirstBillingNumber  int       `db:"first_billing_number" json:"first_billing_number"` // First bill shifted, every later unpaid bill follows
+	DeferredBills       int32     `db:"deferred_bills" json:"deferred_bills"`
+	CapitalizedInterest int32     `db:"capitalized_interest" json:"capitalized_interest"` // Interest of the holiday added to the deferred bills
+	PreviousDueDate     time.Time `db:"previous_due_date" json:"previous_due_date"`
+	NewDueDate          time.Time `db:"new_due_date" json:"new_due_date"`
+	StartDate           time.Time `db:"start_date" json:"start_date"` // Holiday window, bills are neither billed nor overdue in between
+	EndDate             time.Time `db:"end_date" json:"end_date"`
+	CreatedAt           time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Schema versions of the loan and bill event payloads
+const (
+	LoanCreatedVersion       = 1
+	LoanClosedVersion        = 1
+	BillStatusChangedVersion = 1
+)
+
+// LoanCreatedEvent the payload of the loan.created event, published once a loan is saved
+type LoanCreatedEvent struct {
+	LoanID            int64     `json:"loan_id"`
+	UserID            int64     `json:"user_id"`
+	LoanAmount        int32     `json:"loan_amount"`
+	LoanTotalAmount   int32     `json:"loan_total_amount"`
+	InterestMethod    string    `json:"interest_method"`
+	LoanTermsPerWeek  int32     `json:"loan_terms_per_week"`
+	StartDate         time.Time `json:"start_date"`
+	DueDate           time.Time `json:"due_date"`
+	Timezone          string    `json:"timezone"`
+	OutstandingAmount int32     `json:"outstanding_amount"`
+}
+
+// NewLoanCreatedEvent builds the payload announcing a loan
+func NewLoanCreatedEvent(id int64, loan LoanModel) LoanCreatedEvent {
+	return LoanCreatedEvent{
+		LoanID:            id,
+		UserID:            loan.UserID,
+		LoanAmount:        loan.LoanAmount,
+		LoanTotalAmount:   loan.LoanTotalAmount,
+		InterestMethod:    loan.InterestMethod,
+		LoanTermsPerWeek:  loan.LoanTermsPerWeek,
+		StartDate:         loan.StartDate,
+		DueDate:           loan.DueDate,
+		Timezone:          loan.Timezone,
+		OutstandingAmount: loan.OutstandingAmount,
+	}
+}
+
+// LoanClosedEvent the payload of the loan.closed event, published once the last bill of a loan is paid
+type LoanClosedEvent struct {
+	LoanID    int64 `json:"loan_id"`
+	UserID    int64 `json:"user_id"`
+	PaymentID int64 `json:"payment_id"` // The payment which paid the last bill
+}
+
+// BillStatusChangedEvent the payload of the bill.billed and bill.overdue events, published when the bill statuses are
+// updated by the background job
+type BillStatusChangedEvent struct {
+	LoanBillID    int       `json:"loan_bill_id"`
+	LoanID        int64     `json:"loan_id"`
+	UserID        int64     `json:"user_id"`
+	BillingNumber int       `json:"billing_number"`
+	BillingDate   time.Time `json:"billing_date"`
+	FromStatus    string    `json:"from_status"`
+	ToStatus      string    `json:"to_status"`
+}
+
+// NewBillStatusChangedEvent builds the payload announcing the new status of a bill
+func NewBillStatusChangedEvent(change LoanBillStatusChangeModel) BillStatusChangedEvent {
+	return BillStatusChangedEvent{
+		LoanBillID:    change.ID,
+		LoanID:        change.LoanID,
+		UserID:        change.UserID,
+		BillingNumber: change.BillingNumber,
+		BillingDate:   change.BillingDate,
+		FromStatus:    change.FromStatus,
+		ToStatus:      change.ToStatus,
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// LoanInterestAccrualModel represents the `loan_interest_accruals` table, the interest a declining balance loan accrued
+// on one day
+type LoanInterestAccrualModel struct {
+	ID               int64     `db:"id" json:"id"`
+	LoanID           int64     `db:"loan_id" json:"loan_id"`
+	LoanBillID       *int64    `db:"loan_bill_id" json:"loan_bill_id"` // Bill the interest is charged on
+	AccrualDate      time.Time `db:"accrual_date" json:"accrual_date"`
+	Principal        int32     `db:"principal" json:"principal"`     // Outstanding principal the interest is computed on
+	AnnualRate       float64   `db:"annual_rate" json:"annual_rate"` // Interest percentage per year
+	DayCountFraction float64   `db:"day_count_fraction" json:"day_count_fraction"`
+	Interest         float64   `db:"interest" json:"interest"`
+	CreatedAt        time.Time `db:"created_at" json:"created_at"`
+}
+
+// LoanInterestStateModel is a loan with its outstanding principal and the last day it accrued interest
+type LoanInterestStateModel struct {
+	LoanID               int64      `db:"loan_id"`
+	Status               string     `db:"status"`
+	InterestMethod       string     `db:"interest_method"`
+	InterestPercentage   float64    `db:"interest_percentage"`
+	DayCountConvention   string     `db:"day_count_convention"`
+	Timezone             string     `db:"timezone"`
+	StartDate            time.Time  `db:"start_date"`
+	OutstandingAmount    int32      `db:"outstanding_amount"`
+	PrincipalOutstanding int32      `db:"principal_outstanding"` // Loan amount less the principal of the paid bills
+	LastAccrualDate      *time.Time `db:"last_accrual_date"`
+}
+
+// PayoffQuoteModel is the amount to pay to close a loan on a date
+type PayoffQuoteModel struct {
+	LoanID               int64     `json:"loan_id"`
+	AsOf                 time.Time `json:"as_of"`
+	InterestMethod       string    `json:"interest_method"`
+	PrincipalOutstanding int32     `json:"principal_outstanding"`
+	InterestOutstanding  int32     `json:"interest_outstanding"` // Interest charged on the unpaid bills
+	ProjectedInterest    int32     `json:"projected_interest"`   // Interest accruing from the last accrual up to the payoff date
+	PayoffAmount         int32     `json:"payoff_amount"`
+}
@@ -14,6 +14,9 @@ type LoanModel struct {
 	StartDate          time.Time `db:"start_date" json:"start_date"`
 	DueDate            time.Time `db:"due_date" json:"due_date"`
 	LoanTermsPerWeek   int32     `db:"loan_terms_per_week" json:"loan_terms_per_week"`
+	Timezone           string    `db:"timezone" json:"timezone"`                         // IANA timezone the billing days are evaluated in
+	InterestMethod     string    `db:"interest_method" json:"interest_method"`           // e.g., 'FLAT', 'DECLINING_BALANCE'
+	DayCountConvention string    `db:"day_count_convention" json:"day_count_convention"` // e.g., 'ACT/365', '30/360'
 	CreatedAt          time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt          time.Time `db:"updated_at" json:"updated_at"`
 }
@@ -30,6 +33,9 @@ type LoanWithBills struct {
 	StartDate          time.Time       `db:"start_date" json:"start_date"`
 	DueDate            time.Time       `db:"due_date" json:"due_date"`
 	LoanTermsPerWeek   int32           `db:"loan_terms_per_week" json:"loan_terms_per_week"`
+	Timezone           string          `db:"timezone" json:"timezone"`
+	InterestMethod     string          `db:"interest_method" json:"interest_method"`
+	DayCountConvention string          `db:"day_count_convention" json:"day_count_convention"`
 	CreatedAt          time.Time       `db:"created_at" json:"created_at"`
 	UpdatedAt          time.Time       `db:"updated_at" json:"updated_at"`
 	LoanBills          []LoanBillModel `json:"loan_bills"`
@@ -40,12 +46,22 @@ type LoanWithBillsResponse struct {
 }
 
 const (
-	StatusPending = "PENDING"
-	StatusBilled  = "BILLED"
-	StatusPaid    = "PAID"
-
-	ConfigInterestPercentage  = "loan_interest_percentage"
-	ConfigTermsPerWeek        = "loan_term_per_week"
-	DefaultInterestPercentage = 10
-	DefaultLoanTermsPerWeek   = 50
+	StatusPending    = "PENDING"
+	StatusBilled     = "BILLED"
+	StatusPaid       = "PAID"
+	StatusOverdue    = "OVERDUE"
+	StatusSuperseded = "SUPERSEDED"
+
+	ConfigInterestPercentage   = "loan_interest_percentage"
+	ConfigTermsPerWeek         = "loan_term_per_week"
+	ConfigWriteOffDaysPastDue  = "loan_write_off_days_past_due"
+	DefaultInterestPercentage  = 10
+	DefaultLoanTermsPerWeek    = 50
+	DefaultWriteOffDaysPastDue = 90
+
+	InterestMethodFlat             = "FLAT"
+	InterestMethodDecliningBalance = "DECLINING_BALANCE"
+
+	DayCountACT365 = "ACT/365"
+	DayCount30360  = "30/360"
 )
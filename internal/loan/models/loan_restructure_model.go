@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// LoanRestructureModel represents the `loan_restructures` table, an audit row for every schedule change
+type LoanRestructureModel struct {
+	ID                         int64     `db:"id" json:"id"`
+	LoanID                     int64     `db:"loan_id" json:"loan_id"`
+	Reason                     string    `db:"reason" json:"reason"`
+	PreviousOutstandingAmount  int32     `db:"previous_outstanding_amount" json:"previous_outstanding_amount"`
+	NewOutstandingAmount       int32     `db:"new_outstanding_amount" json:"new_outstanding_amount"`
+	PreviousInterestPercentage float64   `db:"previous_interest_percentage" json:"previous_interest_percentage"`
+	NewInterestPercentage      float64   `db:"new_interest_percentage" json:"new_interest_percentage"`
+	PreviousDueDate            time.Time `db:"previous_due_date" json:"previous_due_date"`
+	NewDueDate                 time.Time `db:"new_due_date" json:"new_due_date"`
+	NewTerms                   int32     `db:"new_terms" json:"new_terms"`                   // Number of bills in the new schedule
+	InstallmentAmount          int32     `db:"installment_amount" json:"installment_amount"` // Regular bill amount of the new schedule
+	SupersededBills            int32     `db:"superseded_bills" json:"superseded_bills"`
+	CreatedAt                  time.Time `db:"created_at" json:"created_at"`
+}
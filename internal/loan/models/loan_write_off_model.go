@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// LoanWriteOffModel represents the `loan_write_offs` table
+type LoanWriteOffModel struct {
+	ID               int64     `db:"id" json:"id"`
+	LoanID           int64     `db:"loan_id" json:"loan_id"`
+	WriteOffType     string    `db:"write_off_type" json:"write_off_type"` // e.g., 'MANUAL', 'AUTO'
+	Reason           string    `db:"reason" json:"reason"`
+	WrittenOffAmount int32     `db:"written_off_amount" json:"written_off_amount"` // Outstanding amount at the time of write-off
+	DaysPastDue      int32     `db:"days_past_due" json:"days_past_due"`
+	CreatedAt        time.Time `db:"created_at" json:"created_at"`
+}
+
+// LoanWriteOffSummary is a write-off together with what has been recovered since
+type LoanWriteOffSummary struct {
+	LoanWriteOffModel
+	RecoveredAmount int32 `db:"recovered_amount" json:"recovered_amount"`
+}
+
+// LoanOverdueModel is an active loan with the billing date of its oldest overdue bill
+type LoanOverdueModel struct {
+	LoanID            int64     `db:"loan_id"`
+	UserID            int64     `db:"user_id"`
+	OldestBillingDate time.Time `db:"oldest_billing_date"`
+	Timezone          string    `db:"timezone"`
+}
+
+const (
+	WriteOffTypeManual = "MANUAL"
+	WriteOffTypeAuto   = "AUTO"
+
+	WriteOffReasonDaysPastDue = "overdue for more than %d days"
+)
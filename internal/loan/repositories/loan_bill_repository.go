@@ -2,15 +2,43 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/okiww/billing-loan-system/helpers"
 	"github.com/okiww/billing-loan-system/internal/loan/models"
+	outboxModel "github.com/okiww/billing-loan-system/internal/outbox/models"
+	outboxRepo "github.com/okiww/billing-loan-system/internal/outbox/repositories"
 	mysql "github.com/okiww/billing-loan-system/pkg/db"
 	"github.com/okiww/billing-loan-system/pkg/logger"
 	"github.com/sirupsen/logrus"
 )
 
+// previewLoanBillStatusesQuery selects the bills which status changes when the bill statuses are updated, with the
+// status they change to
+const previewLoanBillStatusesQuery = `
+		SELECT lb.id, lb.loan_id, l.user_id, lb.billing_number, lb.billing_date, lb.status AS from_status,
+		       CASE
+		           WHEN lb.billing_date = DATE(?) THEN 'BILLED'
+		           ELSE 'OVERDUE'
+		       END AS to_status
+		FROM loan_bills lb
+		JOIN loans l ON lb.loan_id = l.id
+		WHERE l.status = 'ACTIVE' AND l.timezone = ?
+		AND (
+			(lb.status = 'PENDING' AND lb.billing_date <= DATE(?))
+			OR (lb.status = 'BILLED' AND lb.billing_date < DATE(?))
+		)
+		AND NOT EXISTS (
+			SELECT 1
+			FROM loan_deferrals ld
+			WHERE ld.loan_id = lb.loan_id AND DATE(?) BETWEEN ld.start_date AND ld.end_date
+		)
+		ORDER BY lb.loan_id, lb.billing_number
+	`
+
 type loanBillRepository struct {
 	*mysql.DBMySQL
 }
@@ -29,42 +57,87 @@ func (l *loanBillRepository) CreateLoanBill(ctx context.Context, loanBill *model
 	return err
 }
 
-// UpdateLoanBillStatuses Update loan bill statuses
-func (l *loanBillRepository) UpdateLoanBillStatuses(ctx context.Context) error {
-	query := `
-		UPDATE loan_bills 
-		SET status = CASE
-			WHEN billing_date = CURDATE() THEN 'BILLED'
-			WHEN billing_date < CURDATE() THEN 'OVERDUE'
-		END
-		WHERE loan_id IN (
-			SELECT id 
-			FROM loans 
-			WHERE status = 'ACTIVE'
-		) 
-		AND (billing_date <= CURDATE())
-	`
-	_, err := l.DB.ExecContext(ctx, query)
+// UpdateLoanBillStatuses Update loan bill statuses of the loans in the given timezone as of the given local date,
+// loans inside a deferral window are skipped. A bill.billed or bill.overdue event is written to the outbox for every
+// bill updated, in the same transaction
+func (l *loanBillRepository) UpdateLoanBillStatuses(ctx context.Context, asOf time.Time, timezone string) error {
+	err := l.ExecTx(ctx, l.DB, func(tx *sqlx.Tx) error {
+		var changes []models.LoanBillStatusChangeModel
+		err := tx.SelectContext(ctx, &changes, previewLoanBillStatusesQuery+" FOR UPDATE", asOf, timezone, asOf, asOf, asOf)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE loan_bills 
+			SET status = CASE
+				WHEN billing_date = DATE(?) THEN 'BILLED'
+				WHEN billing_date < DATE(?) THEN 'OVERDUE'
+			END
+			WHERE loan_id IN (
+				SELECT id 
+				FROM loans 
+				WHERE status = 'ACTIVE' AND timezone = ?
+			) 
+			AND status IN ('PENDING', 'BILLED')
+			AND (billing_date <= DATE(?))
+			AND NOT EXISTS (
+				SELECT 1
+				FROM loan_deferrals ld
+				WHERE ld.loan_id = loan_bills.loan_id AND DATE(?) BETWEEN ld.start_date AND ld.end_date
+			)
+		`
+		_, err = tx.ExecContext(ctx, query, asOf, asOf, timezone, asOf, asOf)
+		if err != nil {
+			return err
+		}
+
+		now := l.Now()
+		for _, change := range changes {
+			eventType := outboxModel.EventBillBilled
+			if change.ToStatus == models.StatusOverdue {
+				eventType = outboxModel.EventBillOverdue
+			}
+			message, err := outboxModel.NewMessage(ctx, outboxModel.AggregateLoanBill, int64(change.ID), eventType,
+				models.BillStatusChangedVersion, now, models.NewBillStatusChangedEvent(change))
+			if err != nil {
+				return err
+			}
+			err = outboxRepo.InsertMessageInTx(ctx, tx, message)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		logger.GetLogger().Error(err.Error())
 		return err
 	}
 
-	logger.GetLogger().Info("loan bill statuses updated successfully")
+	logger.GetLogger().Infof("loan bill statuses in %s as of %s updated successfully", timezone, asOf.Format(time.DateOnly))
 	return nil
 }
 
-// GetTotalLoanBillOverdueByLoanID get total overdue loan by id
+// GetTotalLoanBillOverdueByLoanID count the OVERDUE bills of an active loan, a loan inside a deferral window on its
+// local date has none
 func (l *loanBillRepository) GetTotalLoanBillOverdueByLoanID(ctx context.Context, id int32) (int, error) {
 	query := `
 		SELECT COUNT(lb.id) AS overdue_count
 		FROM loan_bills lb
 		JOIN loans l ON lb.loan_id = l.id
 		WHERE l.id = ? AND l.status = 'ACTIVE' AND lb.status = 'OVERDUE'
+		AND NOT EXISTS (
+			SELECT 1
+			FROM loan_deferrals ld
+			WHERE ld.loan_id = l.id AND DATE(?) BETWEEN ld.start_date AND ld.end_date
+		)
 	`
 
 	var count int
-	err := l.DB.QueryRowContext(ctx, query, id).Scan(&count)
+	err := l.forEachTimezone(ctx, []int64{int64(id)}, func(_ string, today time.Time) error {
+		return l.DB.QueryRowContext(ctx, query, id, today).Scan(&count)
+	})
 	if err != nil {
 		logger.GetLogger().WithFields(logrus.Fields{
 			"error": err,
@@ -76,6 +149,78 @@ func (l *loanBillRepository) GetTotalLoanBillOverdueByLoanID(ctx context.Context
 	return count, nil
 }
 
+// GetTotalLoanBillOverdueByLoanIDs count the OVERDUE bills of each of the given active loans, one query per timezone of
+// the loans so the deferral windows are checked on the local date of every loan
+func (l *loanBillRepository) GetTotalLoanBillOverdueByLoanIDs(ctx context.Context, ids []int64) (map[int64]int32, error) {
+	counts := make(map[int64]int32, len(ids))
+	err := l.forEachTimezone(ctx, ids, func(timezone string, today time.Time) error {
+		query, args, err := sqlx.In(`
+			SELECT lb.loan_id, COUNT(lb.id) AS overdue_count
+			FROM loan_bills lb
+			JOIN loans l ON lb.loan_id = l.id
+			WHERE l.id IN (?) AND l.timezone = ? AND l.status = 'ACTIVE' AND lb.status = 'OVERDUE'
+			AND NOT EXISTS (
+				SELECT 1
+				FROM loan_deferrals ld
+				WHERE ld.loan_id = l.id AND DATE(?) BETWEEN ld.start_date AND ld.end_date
+			)
+			GROUP BY lb.loan_id
+		`, ids, timezone, today)
+		if err != nil {
+			return err
+		}
+
+		var rows []struct {
+			LoanID       int64 `db:"loan_id"`
+			OverdueCount int32 `db:"overdue_count"`
+		}
+		err = l.DB.SelectContext(ctx, &rows, l.DB.Rebind(query), args...)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			counts[row.LoanID] = row.OverdueCount
+		}
+		return nil
+	})
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"error": err,
+			"ids":   ids,
+		}).Error("failed to get count loan overdue by ids")
+		return nil, err
+	}
+	return counts, nil
+}
+
+// forEachTimezone calls fn with every timezone of the given loans, or of the active loans when no loan is given, and
+// the current local date in it
+func (l *loanBillRepository) forEachTimezone(ctx context.Context, ids []int64, fn func(timezone string, today time.Time) error) error {
+	query, args := `SELECT DISTINCT timezone FROM loans WHERE status = 'ACTIVE' ORDER BY timezone`, []interface{}{}
+	if len(ids) > 0 {
+		var err error
+		query, args, err = sqlx.In(`SELECT DISTINCT timezone FROM loans WHERE id IN (?) ORDER BY timezone`, ids)
+		if err != nil {
+			return err
+		}
+	}
+
+	var timezones []string
+	err := l.DB.SelectContext(ctx, &timezones, l.DB.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	now := l.Now()
+	for _, timezone := range timezones {
+		err := fn(timezone, helpers.LocalDate(now, helpers.LoadLocation(timezone)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (l *loanBillRepository) GetLoanBillsByLoanID(ctx context.Context, loanID int) ([]models.LoanBillModel, error) {
 	query := `
 		SELECT id, loan_id, billing_date, billing_amount, billing_total_amount, 
@@ -120,12 +265,133 @@ func (l *loanBillRepository) GetLoanBillByID(ctx context.Context, id int) (*mode
 	return loan, nil
 }
 
+// PreviewLoanBillStatuses get the bills of the loans in the given timezone which status would change when the bill statuses
+// are updated as of the given local date, nothing is written
+func (l *loanBillRepository) PreviewLoanBillStatuses(ctx context.Context, asOf time.Time, timezone string) ([]models.LoanBillStatusChangeModel, error) {
+	var bills []models.LoanBillStatusChangeModel
+	err := l.DB.SelectContext(ctx, &bills, previewLoanBillStatusesQuery, asOf, timezone, asOf, asOf, asOf)
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"error": err,
+			"as_of": asOf,
+		}).Error("failed to preview loan bill statuses")
+		return nil, err
+	}
+	return bills, nil
+}
+
+// PreviewDelinquentLoans get the loans in the given timezone of users not yet delinquent which would have more than
+// 1 OVERDUE bill once the bill statuses are updated as of the given local date, nothing is written
+func (l *loanBillRepository) PreviewDelinquentLoans(ctx context.Context, asOf time.Time, timezone string) ([]models.DelinquencyChangeModel, error) {
+	query := `
+		SELECT l.id AS loan_id, l.user_id, COUNT(lb.id) AS overdue_count
+		FROM loans l
+		JOIN users u ON u.id = l.user_id
+		JOIN loan_bills lb ON lb.loan_id = l.id
+		WHERE l.status = 'ACTIVE' AND l.timezone = ? AND u.is_delinquent = 0
+		AND (
+			lb.status = 'OVERDUE'
+			OR (lb.status IN ('PENDING', 'BILLED') AND lb.billing_date < DATE(?))
+		)
+		AND NOT EXISTS (
+			SELECT 1
+			FROM loan_deferrals ld
+			WHERE ld.loan_id = l.id AND DATE(?) BETWEEN ld.start_date AND ld.end_date
+		)
+		GROUP BY l.id, l.user_id
+		HAVING COUNT(lb.id) > 1
+		ORDER BY l.user_id, l.id
+	`
+	var loans []models.DelinquencyChangeModel
+	err := l.DB.SelectContext(ctx, &loans, query, timezone, asOf, asOf)
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"error": err,
+			"as_of": asOf,
+		}).Error("failed to preview delinquent loans")
+		return nil, err
+	}
+	return loans, nil
+}
+
+// FetchLoansOverdueSince retrieves active loans whose oldest overdue bill is due on or before the cutoff date,
+// loans inside a deferral window on their local date are left out
+func (l *loanBillRepository) FetchLoansOverdueSince(ctx context.Context, cutoff time.Time) ([]models.LoanOverdueModel, error) {
+	query := `
+		SELECT l.id AS loan_id, l.user_id, MIN(lb.billing_date) AS oldest_billing_date, l.timezone
+		FROM loans l
+		JOIN loan_bills lb ON lb.loan_id = l.id
+		WHERE l.status = 'ACTIVE' AND l.timezone = ? AND lb.status = 'OVERDUE'
+		AND NOT EXISTS (
+			SELECT 1
+			FROM loan_deferrals ld
+			WHERE ld.loan_id = l.id AND DATE(?) BETWEEN ld.start_date AND ld.end_date
+		)
+		GROUP BY l.id, l.user_id, l.timezone
+		HAVING MIN(lb.billing_date) <= ?
+	`
+	var loans []models.LoanOverdueModel
+	err := l.forEachTimezone(ctx, nil, func(timezone string, today time.Time) error {
+		var overdue []models.LoanOverdueModel
+		err := l.DB.SelectContext(ctx, &overdue, query, timezone, today, cutoff)
+		loans = append(loans, overdue...)
+		return err
+	})
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"error":  err,
+			"cutoff": cutoff,
+		}).Error("failed to fetch loans overdue since cutoff")
+		return nil, err
+	}
+	return loans, nil
+}
+
+func (l *loanBillRepository) GetLastBillingRunDate(ctx context.Context) (*time.Time, error) {
+	query := `
+		SELECT MAX(run_date) FROM billing_runs WHERE status = 'SUCCESS'
+	`
+	var runDate sql.NullTime
+	err := l.DB.QueryRowContext(ctx, query).Scan(&runDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if !runDate.Valid {
+		return nil, nil
+	}
+	return &runDate.Time, nil
+}
+
+// SaveBillingRun records the result of a billing date, a replayed date overrides its previous result
+func (l *loanBillRepository) SaveBillingRun(ctx context.Context, run *models.BillingRunModel) error {
+	query := `
+		INSERT INTO billing_runs (run_date, status, error)
+		VALUES (DATE(?), ?, ?)
+		ON DUPLICATE KEY UPDATE status = VALUES(status), error = VALUES(error)
+	`
+	_, err := l.DB.ExecContext(ctx, query, run.RunDate, run.Status, run.Error)
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"dataModel": run,
+		}).Error("error when save to billing_runs table")
+		return err
+	}
+	return nil
+}
+
 type LoanBillRepositoryInterface interface {
 	CreateLoanBill(ctx context.Context, loanBill *models.LoanBillModel) error
-	UpdateLoanBillStatuses(ctx context.Context) error
+	UpdateLoanBillStatuses(ctx context.Context, asOf time.Time, timezone string) error
 	GetTotalLoanBillOverdueByLoanID(ctx context.Context, id int32) (int, error)
+	GetTotalLoanBillOverdueByLoanIDs(ctx context.Context, ids []int64) (map[int64]int32, error)
 	GetLoanBillsByLoanID(ctx context.Context, loanID int) ([]models.LoanBillModel, error)
 	GetLoanBillByID(ctx context.Context, id int) (*models.LoanBillModel, error)
+	FetchLoansOverdueSince(ctx context.Context, cutoff time.Time) ([]models.LoanOverdueModel, error)
+	GetLastBillingRunDate(ctx context.Context) (*time.Time, error)
+	SaveBillingRun(ctx context.Context, run *models.BillingRunModel) error
+	PreviewLoanBillStatuses(ctx context.Context, asOf time.Time, timezone string) ([]models.LoanBillStatusChangeModel, error)
+	PreviewDelinquentLoans(ctx context.Context, asOf time.Time, timezone string) ([]models.DelinquencyChangeModel, error)
 }
 
 func NewLoanBillRepository(db *mysql.DBMySQL) LoanBillRepositoryInterface {
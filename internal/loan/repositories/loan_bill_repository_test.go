@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/okiww/billing-loan-system/internal/loan/models"
+	"github.com/okiww/billing-loan-system/pkg/clock"
 	mysql "github.com/okiww/billing-loan-system/pkg/db"
 	"github.com/okiww/billing-loan-system/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -143,7 +144,7 @@ func TestCreateLoanBill(t *testing.T) {
 }
 
 func TestUpdateLoanBillStatuses(t *testing.T) {
-	// Create a mock database
+	// Initialize mock database and repository
 	db, mock, err := sqlmock.Newx()
 	if err != nil {
 		t.Fatalf("failed to create mock database: %v", err)
@@ -151,8 +152,11 @@ func TestUpdateLoanBillStatuses(t *testing.T) {
 	defer db.Close()
 
 	// Create the repository with the mocked DB
-	mockDB := &mysql.DBMySQL{DB: db}
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	mockDB := &mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)}
 	repo := NewLoanBillRepository(mockDB)
+	asOf := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+	changeColumns := []string{"id", "loan_id", "user_id", "billing_number", "billing_date", "from_status", "to_status"}
 
 	// Test cases
 	tests := []struct {
@@ -165,10 +169,23 @@ func TestUpdateLoanBillStatuses(t *testing.T) {
 			name: "Success - Statuses Updated",
 			s:    repo,
 			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+					WithArgs(asOf, "Asia/Jakarta", asOf, asOf, asOf).
+					WillReturnRows(sqlmock.NewRows(changeColumns).
+						AddRow(1, 2, 3, 1, asOf.AddDate(0, 0, -7), "BILLED", "OVERDUE").
+						AddRow(2, 2, 3, 2, asOf, "PENDING", "BILLED"))
 				// Mock the database query and its result
 				mock.ExpectExec(`UPDATE loan_bills`).
-					WithArgs().
-					WillReturnResult(sqlmock.NewResult(1, 1)) // Simulate a successful update
+					WithArgs(asOf, asOf, "Asia/Jakarta", asOf, asOf).
+					WillReturnResult(sqlmock.NewResult(1, 2)) // Simulate a successful update
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox_messages`)).
+					WithArgs("loan_bill", int64(1), "bill.overdue", sqlmock.AnyArg(), "PENDING", now).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox_messages`)).
+					WithArgs("loan_bill", int64(2), "bill.billed", sqlmock.AnyArg(), "PENDING", now).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
 			},
 			wantErr: false,
 		},
@@ -176,10 +193,15 @@ func TestUpdateLoanBillStatuses(t *testing.T) {
 			name: "Failure - Database Error",
 			s:    repo,
 			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).
+					WithArgs(asOf, "Asia/Jakarta", asOf, asOf, asOf).
+					WillReturnRows(sqlmock.NewRows(changeColumns))
 				// Mock the database query and simulate an error
 				mock.ExpectExec(`UPDATE loan_bills`).
-					WithArgs().
+					WithArgs(asOf, asOf, "Asia/Jakarta", asOf, asOf).
 					WillReturnError(errors.New("db error")) // Simulate a database error
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 		},
@@ -192,7 +214,7 @@ func TestUpdateLoanBillStatuses(t *testing.T) {
 			tt.mock()
 
 			// Call the method
-			err := tt.s.UpdateLoanBillStatuses(context.Background())
+			err := tt.s.UpdateLoanBillStatuses(context.Background(), asOf, "Asia/Jakarta")
 
 			// Check if the error state matches the expected result
 			if (err != nil) != tt.wantErr {
@@ -214,7 +236,10 @@ func TestGetTotalLoanBillOverdueByLoanID(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	mockDB := &mysql.DBMySQL{DB: db}
+	// already the 23rd in Jakarta, the deferral windows are checked on the local date
+	now := time.Date(2024, 12, 22, 20, 0, 0, 0, time.UTC)
+	today := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+	mockDB := &mysql.DBMySQL{DB: db, Clock: clock.Fixed(now)}
 	repo := NewLoanBillRepository(mockDB)
 
 	type args struct {
@@ -237,12 +262,15 @@ func TestGetTotalLoanBillOverdueByLoanID(t *testing.T) {
 			want:    3,
 			wantErr: false,
 			mock: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT timezone FROM loans WHERE id IN (?) ORDER BY timezone")).
+					WithArgs(int64(a.id)).
+					WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Asia/Jakarta"))
 				mock.ExpectQuery(regexp.QuoteMeta(
 					`SELECT COUNT(lb.id) AS overdue_count
 					 FROM loan_bills lb
 					 JOIN loans l ON lb.loan_id = l.id
 					 WHERE l.id = ? AND l.status = 'ACTIVE' AND lb.status = 'OVERDUE'`)).
-					WithArgs(a.id).
+					WithArgs(a.id, today).
 					WillReturnRows(sqlmock.NewRows([]string{"overdue_count"}).AddRow(3))
 			},
 		},
@@ -255,15 +283,32 @@ func TestGetTotalLoanBillOverdueByLoanID(t *testing.T) {
 			want:    0,
 			wantErr: false,
 			mock: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT timezone FROM loans WHERE id IN (?) ORDER BY timezone")).
+					WithArgs(int64(a.id)).
+					WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Asia/Jakarta"))
 				mock.ExpectQuery(regexp.QuoteMeta(
 					`SELECT COUNT(lb.id) AS overdue_count
 					 FROM loan_bills lb
 					 JOIN loans l ON lb.loan_id = l.id
 					 WHERE l.id = ? AND l.status = 'ACTIVE' AND lb.status = 'OVERDUE'`)).
-					WithArgs(a.id).
+					WithArgs(a.id, today).
 					WillReturnRows(sqlmock.NewRows([]string{"overdue_count"}).AddRow(0))
 			},
 		},
+		{
+			name: "Success - Loan Not Found",
+			repo: repo,
+			args: args{
+				id: 4,
+			},
+			want:    0,
+			wantErr: false,
+			mock: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT timezone FROM loans WHERE id IN (?) ORDER BY timezone")).
+					WithArgs(int64(a.id)).
+					WillReturnRows(sqlmock.NewRows([]string{"timezone"}))
+			},
+		},
 		{
 			name: "Database Error",
 			repo: repo,
@@ -273,12 +318,15 @@ func TestGetTotalLoanBillOverdueByLoanID(t *testing.T) {
 			want:    0,
 			wantErr: true,
 			mock: func(a args) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT timezone FROM loans WHERE id IN (?) ORDER BY timezone")).
+					WithArgs(int64(a.id)).
+					WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Asia/Jakarta"))
 				mock.ExpectQuery(regexp.QuoteMeta(
 					`SELECT COUNT(lb.id) AS overdue_count
 					 FROM loan_bills lb
 					 JOIN loans l ON lb.loan_id = l.id
 					 WHERE l.id = ? AND l.status = 'ACTIVE' AND lb.status = 'OVERDUE'`)).
-					WithArgs(a.id).
+					WithArgs(a.id, today).
 					WillReturnError(errors.New("db error"))
 			},
 		},
@@ -532,3 +580,389 @@ func TestGetLoanBillByID(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchLoansOverdueSince(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// already the 23rd in Jakarta, the deferral windows are checked on the local date
+	now := time.Date(2024, 12, 22, 20, 0, 0, 0, time.UTC)
+	today := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+	mockDB := &mysql.DBMySQL{DB: db, Clock: clock.Fixed(now)}
+	repo := NewLoanBillRepository(mockDB)
+
+	cutoff := time.Date(2024, 9, 16, 0, 0, 0, 0, time.UTC)
+	oldest := time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC)
+	query := `
+		SELECT l.id AS loan_id, l.user_id, MIN(lb.billing_date) AS oldest_billing_date, l.timezone
+		FROM loans l
+		JOIN loan_bills lb ON lb.loan_id = l.id
+		WHERE l.status = 'ACTIVE' AND l.timezone = ? AND lb.status = 'OVERDUE'
+		AND NOT EXISTS (
+			SELECT 1
+			FROM loan_deferrals ld
+			WHERE ld.loan_id = l.id AND DATE(?) BETWEEN ld.start_date AND ld.end_date
+		)
+		GROUP BY l.id, l.user_id, l.timezone
+		HAVING MIN(lb.billing_date) <= ?
+	`
+
+	tests := []struct {
+		name    string
+		want    []models.LoanOverdueModel
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			want: []models.LoanOverdueModel{{LoanID: 1, UserID: 2, OldestBillingDate: oldest, Timezone: "Asia/Jakarta"}},
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT timezone FROM loans WHERE status = 'ACTIVE' ORDER BY timezone")).
+					WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Asia/Jakarta"))
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs("Asia/Jakarta", today, cutoff).
+					WillReturnRows(sqlmock.NewRows([]string{"loan_id", "user_id", "oldest_billing_date", "timezone"}).AddRow(1, 2, oldest, "Asia/Jakarta"))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT timezone FROM loans WHERE status = 'ACTIVE' ORDER BY timezone")).
+					WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Asia/Jakarta"))
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs("Asia/Jakarta", today, cutoff).
+					WillReturnError(errors.New("db error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.FetchLoansOverdueSince(context.Background(), cutoff)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FetchLoansOverdueSince() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FetchLoansOverdueSince() got = %v, want %v", got, tt.want)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetLastBillingRunDate(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db}
+	repo := NewLoanBillRepository(mockDB)
+
+	runDate := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+	query := `SELECT MAX(run_date) FROM billing_runs WHERE status = 'SUCCESS'`
+
+	tests := []struct {
+		name    string
+		want    *time.Time
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			want: &runDate,
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WillReturnRows(sqlmock.NewRows([]string{"run_date"}).AddRow(runDate))
+			},
+		},
+		{
+			name: "Never Ran",
+			want: nil,
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WillReturnRows(sqlmock.NewRows([]string{"run_date"}).AddRow(nil))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WillReturnError(errors.New("db error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.GetLastBillingRunDate(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetLastBillingRunDate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSaveBillingRun(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db}
+	repo := NewLoanBillRepository(mockDB)
+
+	run := &models.BillingRunModel{
+		RunDate: time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC),
+		Status:  models.BillingRunStatusSuccess,
+	}
+
+	tests := []struct {
+		name    string
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO billing_runs`)).
+					WithArgs(run.RunDate, run.Status, run.Error).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO billing_runs`)).
+					WithArgs(run.RunDate, run.Status, run.Error).
+					WillReturnError(errors.New("db error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			err := repo.SaveBillingRun(context.Background(), run)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SaveBillingRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPreviewLoanBillStatuses(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db}
+	repo := NewLoanBillRepository(mockDB)
+
+	asOf := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+	billingDate := time.Date(2024, 12, 16, 0, 0, 0, 0, time.UTC)
+	query := `
+		SELECT lb.id, lb.loan_id, l.user_id, lb.billing_number, lb.billing_date, lb.status AS from_status,
+		       CASE
+		           WHEN lb.billing_date = DATE(?) THEN 'BILLED'
+		           ELSE 'OVERDUE'
+		       END AS to_status
+		FROM loan_bills lb
+		JOIN loans l ON lb.loan_id = l.id
+		WHERE l.status = 'ACTIVE' AND l.timezone = ?
+	`
+
+	tests := []struct {
+		name    string
+		want    []models.LoanBillStatusChangeModel
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			want: []models.LoanBillStatusChangeModel{
+				{ID: 1, LoanID: 2, UserID: 3, BillingNumber: 1, BillingDate: billingDate, FromStatus: "BILLED", ToStatus: "OVERDUE"},
+			},
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(asOf, "Asia/Jakarta", asOf, asOf, asOf).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "loan_id", "user_id", "billing_number", "billing_date", "from_status", "to_status"}).
+						AddRow(1, 2, 3, 1, billingDate, "BILLED", "OVERDUE"))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(asOf, "Asia/Jakarta", asOf, asOf, asOf).
+					WillReturnError(errors.New("db error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.PreviewLoanBillStatuses(context.Background(), asOf, "Asia/Jakarta")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PreviewLoanBillStatuses() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PreviewLoanBillStatuses() got = %v, want %v", got, tt.want)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPreviewDelinquentLoans(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db}
+	repo := NewLoanBillRepository(mockDB)
+
+	asOf := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+	query := `
+		SELECT l.id AS loan_id, l.user_id, COUNT(lb.id) AS overdue_count
+		FROM loans l
+		JOIN users u ON u.id = l.user_id
+		JOIN loan_bills lb ON lb.loan_id = l.id
+		WHERE l.status = 'ACTIVE' AND l.timezone = ? AND u.is_delinquent = 0
+	`
+
+	tests := []struct {
+		name    string
+		want    []models.DelinquencyChangeModel
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			want: []models.DelinquencyChangeModel{{LoanID: 1, UserID: 2, OverdueCount: 2}},
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs("Asia/Jakarta", asOf, asOf).
+					WillReturnRows(sqlmock.NewRows([]string{"loan_id", "user_id", "overdue_count"}).AddRow(1, 2, 2))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs("Asia/Jakarta", asOf, asOf).
+					WillReturnError(errors.New("db error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.PreviewDelinquentLoans(context.Background(), asOf, "Asia/Jakarta")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PreviewDelinquentLoans() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PreviewDelinquentLoans() got = %v, want %v", got, tt.want)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetTotalLoanBillOverdueByLoanIDs(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// already the 23rd in Jakarta, the deferral windows are checked on the local date
+	now := time.Date(2024, 12, 22, 20, 0, 0, 0, time.UTC)
+	today := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+	mockDB := &mysql.DBMySQL{DB: db, Clock: clock.Fixed(now)}
+	repo := NewLoanBillRepository(mockDB)
+
+	query := `
+		SELECT lb.loan_id, COUNT(lb.id) AS overdue_count
+		FROM loan_bills lb
+		JOIN loans l ON lb.loan_id = l.id
+		WHERE l.id IN (?, ?) AND l.timezone = ? AND l.status = 'ACTIVE' AND lb.status = 'OVERDUE'
+	`
+
+	tests := []struct {
+		name    string
+		want    map[int64]int32
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			want: map[int64]int32{1: 2},
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT timezone FROM loans WHERE id IN (?, ?) ORDER BY timezone")).
+					WithArgs(int64(1), int64(2)).
+					WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Asia/Jakarta"))
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(int64(1), int64(2), "Asia/Jakarta", today).
+					WillReturnRows(sqlmock.NewRows([]string{"loan_id", "overdue_count"}).AddRow(1, 2))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT timezone FROM loans WHERE id IN (?, ?) ORDER BY timezone")).
+					WithArgs(int64(1), int64(2)).
+					WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Asia/Jakarta"))
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(int64(1), int64(2), "Asia/Jakarta", today).
+					WillReturnError(errors.New("db error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.GetTotalLoanBillOverdueByLoanIDs(context.Background(), []int64{1, 2})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetTotalLoanBillOverdueByLoanIDs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetTotalLoanBillOverdueByLoanIDs() got = %v, want %v", got, tt.want)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
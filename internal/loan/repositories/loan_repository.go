@@ -2,12 +2,16 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"time"
+	"math"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/okiww/billing-loan-system/helpers"
 	"github.com/okiww/billing-loan-system/internal/loan/models"
+	outboxModel "github.com/okiww/billing-loan-system/internal/outbox/models"
+	outboxRepo "github.com/okiww/billing-loan-system/internal/outbox/repositories"
 	mysql "github.com/okiww/billing-loan-system/pkg/db"
 	"github.com/okiww/billing-loan-system/pkg/logger"
 	"github.com/sirupsen/logrus"
@@ -46,42 +50,69 @@ func (l *loanRepository) GetLoanStatusByID(ctx context.Context, id int64) (*mode
 	return loan, nil
 }
 
-// CreateLoan inserts a new loan into the database
+// CreateLoan inserts a new loan into the database, the loan.created event is written to the outbox in the same transaction
 func (l *loanRepository) CreateLoan(ctx context.Context, loan *models.LoanModel) (int64, error) {
-	query := `INSERT INTO loans (user_id, name, loan_amount, loan_total_amount, outstanding_amount, interest_percentage, status, start_date, due_date, loan_terms_per_week)
-              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	result, err := l.DB.ExecContext(ctx, query, loan.UserID, loan.Name, loan.LoanAmount, loan.LoanTotalAmount, loan.OutstandingAmount, loan.InterestPercentage, loan.Status, loan.StartDate, loan.DueDate, loan.LoanTermsPerWeek)
-	if err != nil {
-		logger.GetLogger().WithFields(logrus.Fields{
-			"dataModel": loan,
-		}).Error("error when save to loans table")
-		return 0, err
-	}
+	var id int64
+	err := l.ExecTx(ctx, l.DB, func(tx *sqlx.Tx) error {
+		query := `INSERT INTO loans (user_id, name, loan_amount, loan_total_amount, outstanding_amount, interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		result, err := tx.ExecContext(ctx, query, loan.UserID, loan.Name, loan.LoanAmount, loan.LoanTotalAmount, loan.OutstandingAmount, loan.InterestPercentage, loan.Status, loan.StartDate, loan.DueDate, loan.LoanTermsPerWeek, loan.Timezone, loan.InterestMethod, loan.DayCountConvention)
+		if err != nil {
+			logger.GetLogger().WithFields(logrus.Fields{
+				"dataModel": loan,
+			}).Error("error when save to loans table")
+			return err
+		}
 
-	// Get the last inserted ID
-	id, err := result.LastInsertId()
+		// Get the last inserted ID
+		id, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		message, err := outboxModel.NewMessage(ctx, outboxModel.AggregateLoan, id, outboxModel.EventLoanCreated, models.LoanCreatedVersion,
+			l.Now(), models.NewLoanCreatedEvent(id, *loan))
+		if err != nil {
+			return err
+		}
+		return outboxRepo.InsertMessageInTx(ctx, tx, message)
+	})
 	if err != nil {
 		return 0, err
 	}
-	return id, err
+	return id, nil
 }
 
-// FetchActiveLoan retrieves loans with an ACTIVE status
-func (l *loanRepository) FetchActiveLoan(ctx context.Context) ([]models.LoanModel, error) {
+func (l *loanRepository) FetchActiveLoan(ctx context.Context, afterID int64, limit int) ([]models.LoanModel, error) {
 	query := `
 		SELECT id, user_id, name, loan_amount, loan_total_amount, outstanding_amount, 
-		       interest_percentage, status, start_date, due_date, loan_terms_per_week
+		       interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention
 		FROM loans
-		WHERE status = 'ACTIVE'
+		WHERE status = 'ACTIVE' AND id > ?
+		ORDER BY id
+		LIMIT ?
 	`
 	var activeLoans []models.LoanModel
-	err := l.DB.SelectContext(ctx, &activeLoans, query)
+	err := l.DB.SelectContext(ctx, &activeLoans, query, afterID, limit)
 	if err != nil {
 		return nil, err
 	}
 	return activeLoans, nil
 }
 
+// FetchActiveLoanTimezones retrieves the distinct timezones of the ACTIVE loans
+func (l *loanRepository) FetchActiveLoanTimezones(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT DISTINCT timezone FROM loans WHERE status = 'ACTIVE' ORDER BY timezone
+	`
+	var timezones []string
+	err := l.DB.SelectContext(ctx, &timezones, query)
+	if err != nil {
+		return nil, err
+	}
+	return timezones, nil
+}
+
 // UpdateLoanAndLoanBillsInTx update loan bills and loans
 func (l *loanRepository) UpdateLoanAndLoanBillsInTx(ctx context.Context, loanID, loanBillID, amount int) error {
 	err := l.ExecTx(ctx, l.DB, func(tx *sqlx.Tx) error {
@@ -119,7 +150,7 @@ func (l *loanRepository) UpdateBilledLoanBillToPaid(ctx context.Context, tx *sql
 	query := `
 		UPDATE loan_bills SET status = ?, updated_at = ? WHERE id = ?
 	`
-	_, err := tx.ExecContext(ctx, query, models.StatusPaid, time.Now(), id)
+	_, err := tx.ExecContext(ctx, query, models.StatusPaid, l.Now(), id)
 	if err != nil {
 		return err
 	}
@@ -147,7 +178,7 @@ func (l *loanRepository) UpdateOutStandingAmountAndStatus(ctx context.Context, t
 func (l *loanRepository) GetLoanByUserID(ctx context.Context, userID int) ([]models.LoanModel, error) {
 	query := `
 		SELECT id, user_id, name, loan_amount, loan_total_amount, outstanding_amount, 
-		       interest_percentage, status, start_date, due_date, loan_terms_per_week
+		       interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention
 		FROM loans
 		WHERE user_id = ?
 	`
@@ -159,14 +190,442 @@ func (l *loanRepository) GetLoanByUserID(ctx context.Context, userID int) ([]mod
 	return loans, nil
 }
 
+// GetLoanByID retrieves a full loan row by its ID
+func (l *loanRepository) GetLoanByID(ctx context.Context, id int64) (*models.LoanModel, error) {
+	query := `
+		SELECT id, user_id, name, loan_amount, loan_total_amount, outstanding_amount, 
+		       interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention
+		FROM loans
+		WHERE id = ?
+	`
+	var loan models.LoanModel
+	err := l.DB.GetContext(ctx, &loan, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("no loan found with id %d", id)
+		}
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// lockLoanAndBills locks a loan and its bills for the rest of the transaction, a payment settled concurrently waits
+// for the lock so the loan can't change under the caller
+func lockLoanAndBills(ctx context.Context, tx *sqlx.Tx, loanID int64) (*models.LoanModel, []models.LoanBillModel, error) {
+	var loan models.LoanModel
+	err := tx.GetContext(ctx, &loan, `
+		SELECT id, user_id, name, loan_amount, loan_total_amount, outstanding_amount, 
+		       interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention
+		FROM loans
+		WHERE id = ?
+		FOR UPDATE
+	`, loanID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("no loan found with id %d", loanID)
+		}
+		logger.GetLogger().Errorf("[LoanRepository][lockLoanAndBills] Error lock loan with err: %v", err)
+		return nil, nil, err
+	}
+
+	var bills []models.LoanBillModel
+	err = tx.SelectContext(ctx, &bills, `
+		SELECT id, loan_id, billing_date, billing_amount, billing_total_amount, 
+		       billing_number, status, created_at, updated_at 
+		FROM loan_bills
+		WHERE loan_id = ?
+		ORDER BY billing_number ASC
+		FOR UPDATE
+	`, loanID)
+	if err != nil {
+		logger.GetLogger().Errorf("[LoanRepository][lockLoanAndBills] Error lock loan bills with err: %v", err)
+		return nil, nil, err
+	}
+	return &loan, bills, nil
+}
+
+// RestructureFunc builds the new schedule of a loan from the loan and its bills locked by RestructureLoanInTx,
+// the loan is updated in place
+type RestructureFunc func(loan *models.LoanModel, bills []models.LoanBillModel) ([]models.LoanBillModel, *models.LoanRestructureModel, error)
+
+// RestructureLoanInTx locks a loan and its bills, supersedes the unpaid bills, saves the new schedule built by build,
+// updates the loan and records the restructure for audit in one transaction. A payment settled concurrently waits for
+// the lock so a bill paid meanwhile isn't rolled into the new schedule
+func (l *loanRepository) RestructureLoanInTx(ctx context.Context, loanID int64, build RestructureFunc) (*models.LoanRestructureModel, error) {
+	var restructure *models.LoanRestructureModel
+	err := l.ExecTx(ctx, l.DB, func(tx *sqlx.Tx) error {
+		loan, loanBills, err := lockLoanAndBills(ctx, tx, loanID)
+		if err != nil {
+			return err
+		}
+
+		bills, built, err := build(loan, loanBills)
+		if err != nil {
+			return err
+		}
+		restructure = built
+
+		// Keep the replaced bills for audit instead of deleting them
+		result, err := tx.ExecContext(ctx, `
+			UPDATE loan_bills SET status = ?, updated_at = ?
+			WHERE loan_id = ? AND status IN (?, ?, ?)
+		`, models.StatusSuperseded, l.Now(), loan.ID, models.StatusPending, models.StatusBilled, models.StatusOverdue)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanRepository][RestructureLoanInTx] Error supersede loan bills with err: %v", err)
+			return err
+		}
+
+		superseded, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		restructure.SupersededBills = int32(superseded)
+
+		for _, bill := range bills {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO loan_bills (loan_id, billing_date, billing_amount, billing_total_amount, billing_number, status, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			`, bill.LoanID, bill.BillingDate, bill.BillingAmount, bill.BillingTotalAmount, bill.BillingNumber, bill.Status, bill.CreatedAt, bill.UpdatedAt)
+			if err != nil {
+				logger.GetLogger().Errorf("[LoanRepository][RestructureLoanInTx] Error insert loan bill %d with err: %v", bill.BillingNumber, err)
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE loans
+			SET loan_total_amount = ?, outstanding_amount = ?, interest_percentage = ?, due_date = ?, loan_terms_per_week = ?
+			WHERE id = ?
+		`, loan.LoanTotalAmount, loan.OutstandingAmount, loan.InterestPercentage, loan.DueDate, loan.LoanTermsPerWeek, loan.ID)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanRepository][RestructureLoanInTx] Error update loan with err: %v", err)
+			return err
+		}
+
+		result, err = tx.ExecContext(ctx, `
+			INSERT INTO loan_restructures (loan_id, reason, previous_outstanding_amount, new_outstanding_amount, previous_interest_percentage,
+			                               new_interest_percentage, previous_due_date, new_due_date, new_terms, installment_amount, superseded_bills)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, restructure.LoanID, restructure.Reason, restructure.PreviousOutstandingAmount, restructure.NewOutstandingAmount, restructure.PreviousInterestPercentage,
+			restructure.NewInterestPercentage, restructure.PreviousDueDate, restructure.NewDueDate, restructure.NewTerms, restructure.InstallmentAmount, restructure.SupersededBills)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanRepository][RestructureLoanInTx] Error insert loan restructure with err: %v", err)
+			return err
+		}
+
+		restructure.ID, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restructure, nil
+}
+
+// DeferFunc builds the deferral of a loan from the loan and its bills locked by DeferLoanInTx, it returns the bills
+// shifted to their new billing date
+type DeferFunc func(loan *models.LoanModel, bills []models.LoanBillModel) ([]models.LoanBillModel, *models.LoanDeferralModel, error)
+
+// DeferLoanInTx locks a loan and its bills, shifts the deferred bills built by build to their new billing date, adds the
+// capitalized interest to the loan and records the deferral in one transaction. The amounts of the loan are added to
+// rather than overwritten so they stay right whatever was paid before the lock
+func (l *loanRepository) DeferLoanInTx(ctx context.Context, loanID int64, build DeferFunc) (*models.LoanDeferralModel, error) {
+	var deferral *models.LoanDeferralModel
+	err := l.ExecTx(ctx, l.DB, func(tx *sqlx.Tx) error {
+		loan, loanBills, err := lockLoanAndBills(ctx, tx, loanID)
+		if err != nil {
+			return err
+		}
+
+		bills, built, err := build(loan, loanBills)
+		if err != nil {
+			return err
+		}
+		deferral = built
+
+		for _, bill := range bills {
+			_, err := tx.ExecContext(ctx, `
+				UPDATE loan_bills SET billing_date = ?, billing_total_amount = ?, status = ?, updated_at = ?
+				WHERE id = ? AND status IN (?, ?)
+			`, bill.BillingDate, bill.BillingTotalAmount, models.StatusPending, l.Now(), bill.ID, models.StatusPending, models.StatusBilled)
+			if err != nil {
+				logger.GetLogger().Errorf("[LoanRepository][DeferLoanInTx] Error shift loan bill %d with err: %v", bill.BillingNumber, err)
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE loans
+			SET loan_total_amount = loan_total_amount + ?, outstanding_amount = outstanding_amount + ?, due_date = ?
+			WHERE id = ?
+		`, deferral.CapitalizedInterest, deferral.CapitalizedInterest, deferral.NewDueDate, loan.ID)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanRepository][DeferLoanInTx] Error update loan with err: %v", err)
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO loan_deferrals (loan_id, reason, periods, first_billing_number, deferred_bills, capitalized_interest,
+			                            previous_due_date, new_due_date, start_date, end_date)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, deferral.LoanID, deferral.Reason, deferral.Periods, deferral.FirstBillingNumber, deferral.DeferredBills, deferral.CapitalizedInterest,
+			deferral.PreviousDueDate, deferral.NewDueDate, deferral.StartDate, deferral.EndDate)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanRepository][DeferLoanInTx] Error insert loan deferral with err: %v", err)
+			return err
+		}
+
+		deferral.ID, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deferral, nil
+}
+
+// WriteOffFunc builds the write-off of a loan from the loan and its bills locked by WriteOffLoanInTx, a nil write-off
+// leaves the loan as is
+type WriteOffFunc func(loan *models.LoanModel, bills []models.LoanBillModel) (*models.LoanWriteOffModel, error)
+
+// WriteOffLoanInTx locks a loan and its bills, moves the loan to WRITTEN_OFF, freezes its unpaid bills and records the
+// write-off built by build in one transaction. The written-off amount is taken from the locked loan so a payment settled
+// concurrently is accounted for. Returns nil when build left the loan as is
+func (l *loanRepository) WriteOffLoanInTx(ctx context.Context, loanID int64, build WriteOffFunc) (*models.LoanWriteOffModel, error) {
+	var writeOff *models.LoanWriteOffModel
+	err := l.ExecTx(ctx, l.DB, func(tx *sqlx.Tx) error {
+		loan, bills, err := lockLoanAndBills(ctx, tx, loanID)
+		if err != nil {
+			return err
+		}
+
+		writeOff, err = build(loan, bills)
+		if err != nil || writeOff == nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			UPDATE loans SET status = ? WHERE id = ? AND status = ?
+		`, models.StatusWrittenOff, writeOff.LoanID, models.StatusActive)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanRepository][WriteOffLoanInTx] Error update loan status with err: %v", err)
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("no active loan found with id %d", writeOff.LoanID)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE loan_bills SET status = ?, updated_at = ?
+			WHERE loan_id = ? AND status IN (?, ?, ?)
+		`, models.StatusWrittenOff, l.Now(), writeOff.LoanID, models.StatusPending, models.StatusBilled, models.StatusOverdue)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanRepository][WriteOffLoanInTx] Error freeze loan bills with err: %v", err)
+			return err
+		}
+
+		result, err = tx.ExecContext(ctx, `
+			INSERT INTO loan_write_offs (loan_id, write_off_type, reason, written_off_amount, days_past_due)
+			VALUES (?, ?, ?, ?, ?)
+		`, writeOff.LoanID, writeOff.WriteOffType, writeOff.Reason, writeOff.WrittenOffAmount, writeOff.DaysPastDue)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanRepository][WriteOffLoanInTx] Error insert loan write off with err: %v", err)
+			return err
+		}
+
+		writeOff.ID, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return writeOff, nil
+}
+
+// GetLoanWriteOffByLoanID retrieves the write-off of a loan with the amount recovered so far
+func (l *loanRepository) GetLoanWriteOffByLoanID(ctx context.Context, loanID int64) (*models.LoanWriteOffSummary, error) {
+	query := `
+		SELECT wo.id, wo.loan_id, wo.write_off_type, wo.reason, wo.written_off_amount, wo.days_past_due, wo.created_at,
+		       l.recovered_amount
+		FROM loan_write_offs wo
+		JOIN loans l ON wo.loan_id = l.id
+		WHERE wo.loan_id = ?
+	`
+	var summary models.LoanWriteOffSummary
+	err := l.DB.GetContext(ctx, &summary, query, loanID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("no write off found for loan id %d", loanID)
+		}
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// AddRecoveredAmount adds a completed recovery payment to a written off loan
+func (l *loanRepository) AddRecoveredAmount(ctx context.Context, loanID, amount int) error {
+	query := `
+		UPDATE loans SET recovered_amount = recovered_amount + ? WHERE id = ? AND status = ?
+	`
+	result, err := l.DB.ExecContext(ctx, query, amount, loanID, models.StatusWrittenOff)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("no written off loan found with id %d", loanID)
+	}
+	return nil
+}
+
+// FetchInterestAccrualStates retrieves a page of ACTIVE declining balance loans ordered by id, starting after the given
+// id, with their outstanding principal and last accrual date
+func (l *loanRepository) FetchInterestAccrualStates(ctx context.Context, afterID int64, limit int) ([]models.LoanInterestStateModel, error) {
+	query := `
+		SELECT l.id AS loan_id, l.status, l.interest_method, l.interest_percentage, l.day_count_convention, l.timezone,
+		       l.start_date, l.outstanding_amount,
+		       l.loan_amount - COALESCE((SELECT SUM(lb.billing_amount) FROM loan_bills lb WHERE lb.loan_id = l.id AND lb.status = 'PAID'), 0) AS principal_outstanding,
+		       (SELECT MAX(a.accrual_date) FROM loan_interest_accruals a WHERE a.loan_id = l.id) AS last_accrual_date
+		FROM loans l
+		WHERE l.status = 'ACTIVE' AND l.interest_method = 'DECLINING_BALANCE' AND l.id > ?
+		ORDER BY l.id
+		LIMIT ?
+	`
+	var states []models.LoanInterestStateModel
+	err := l.DB.SelectContext(ctx, &states, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// GetInterestState retrieves a loan with its outstanding principal and last accrual date
+func (l *loanRepository) GetInterestState(ctx context.Context, loanID int64) (*models.LoanInterestStateModel, error) {
+	query := `
+		SELECT l.id AS loan_id, l.status, l.interest_method, l.interest_percentage, l.day_count_convention, l.timezone,
+		       l.start_date, l.outstanding_amount,
+		       l.loan_amount - COALESCE((SELECT SUM(lb.billing_amount) FROM loan_bills lb WHERE lb.loan_id = l.id AND lb.status = 'PAID'), 0) AS principal_outstanding,
+		       (SELECT MAX(a.accrual_date) FROM loan_interest_accruals a WHERE a.loan_id = l.id) AS last_accrual_date
+		FROM loans l
+		WHERE l.id = ?
+	`
+	var state models.LoanInterestStateModel
+	err := l.DB.GetContext(ctx, &state, query, loanID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("no loan found with id %d", loanID)
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+// AccrueInterestInTx records the interest a loan accrued on a day and charges it on the bill closing the period, the
+// first unpaid bill due after the day, or the last unpaid bill once the schedule is behind. The bill total is recomputed
+// from the accruals of the bill and the difference is added to the loan. False when the day was already accrued
+func (l *loanRepository) AccrueInterestInTx(ctx context.Context, accrual *models.LoanInterestAccrualModel) (bool, error) {
+	var accrued bool
+	err := l.ExecTx(ctx, l.DB, func(tx *sqlx.Tx) error {
+		var bill *models.LoanBillModel
+		var candidate models.LoanBillModel
+		err := tx.GetContext(ctx, &candidate, `
+			SELECT id, billing_amount, billing_total_amount
+			FROM loan_bills
+			WHERE loan_id = ? AND status IN (?, ?, ?)
+			ORDER BY billing_date > DATE(?) DESC, IF(billing_date > DATE(?), billing_date, NULL), billing_date DESC
+			LIMIT 1
+			FOR UPDATE
+		`, accrual.LoanID, models.StatusPending, models.StatusBilled, models.StatusOverdue, accrual.AccrualDate, accrual.AccrualDate)
+		if err == nil {
+			bill = &candidate
+			billID := int64(candidate.ID)
+			accrual.LoanBillID = &billID
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			logger.GetLogger().Errorf("[LoanRepository][AccrueInterestInTx] Error get loan bill with err: %v", err)
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			INSERT IGNORE INTO loan_interest_accruals (loan_id, loan_bill_id, accrual_date, principal, annual_rate, day_count_fraction, interest)
+			VALUES (?, ?, DATE(?), ?, ?, ?, ?)
+		`, accrual.LoanID, accrual.LoanBillID, accrual.AccrualDate, accrual.Principal, accrual.AnnualRate, accrual.DayCountFraction, accrual.Interest)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanRepository][AccrueInterestInTx] Error insert interest accrual with err: %v", err)
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return nil
+		}
+		accrued = true
+		if bill == nil {
+			return nil
+		}
+
+		var interest float64
+		err = tx.GetContext(ctx, &interest, `
+			SELECT COALESCE(SUM(interest), 0) FROM loan_interest_accruals WHERE loan_bill_id = ?
+		`, bill.ID)
+		if err != nil {
+			return err
+		}
+
+		billingTotalAmount := bill.BillingAmount + int32(math.Round(interest))
+		difference := billingTotalAmount - bill.BillingTotalAmount
+		if difference == 0 {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE loan_bills SET billing_total_amount = ?, updated_at = ? WHERE id = ?
+		`, billingTotalAmount, l.Now(), bill.ID)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanRepository][AccrueInterestInTx] Error update loan bill total with err: %v", err)
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE loans SET loan_total_amount = loan_total_amount + ?, outstanding_amount = outstanding_amount + ? WHERE id = ?
+		`, difference, difference, accrual.LoanID)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanRepository][AccrueInterestInTx] Error update loan amounts with err: %v", err)
+		}
+		return err
+	})
+	return accrued, err
+}
+
 type LoanRepositoryInterface interface {
 	GetLoanStatusByID(ctx context.Context, id int64) (*models.LoanModel, error)
 	CreateLoan(ctx context.Context, loan *models.LoanModel) (int64, error)
-	FetchActiveLoan(ctx context.Context) ([]models.LoanModel, error)
+	FetchActiveLoan(ctx context.Context, afterID int64, limit int) ([]models.LoanModel, error)
+	FetchActiveLoanTimezones(ctx context.Context) ([]string, error)
 	UpdateLoanAndLoanBillsInTx(ctx context.Context, loanID, loanBillID, amount int) error
 	UpdateBilledLoanBillToPaid(ctx context.Context, tx *sqlx.Tx, id int) error
 	UpdateOutStandingAmountAndStatus(ctx context.Context, tx *sqlx.Tx, id, amount int) error
 	GetLoanByUserID(ctx context.Context, userID int) ([]models.LoanModel, error)
+	GetLoanByID(ctx context.Context, id int64) (*models.LoanModel, error)
+	RestructureLoanInTx(ctx context.Context, loanID int64, build RestructureFunc) (*models.LoanRestructureModel, error)
+	DeferLoanInTx(ctx context.Context, loanID int64, build DeferFunc) (*models.LoanDeferralModel, error)
+	WriteOffLoanInTx(ctx context.Context, loanID int64, build WriteOffFunc) (*models.LoanWriteOffModel, error)
+	GetLoanWriteOffByLoanID(ctx context.Context, loanID int64) (*models.LoanWriteOffSummary, error)
+	AddRecoveredAmount(ctx context.Context, loanID, amount int) error
+	FetchInterestAccrualStates(ctx context.Context, afterID int64, limit int) ([]models.LoanInterestStateModel, error)
+	GetInterestState(ctx context.Context, loanID int64) (*models.LoanInterestStateModel, error)
+	AccrueInterestInTx(ctx context.Context, accrual *models.LoanInterestAccrualModel) (bool, error)
 }
 
 func NewLoanRepository(db *mysql.DBMySQL) LoanRepositoryInterface {
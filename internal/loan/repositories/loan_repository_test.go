@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/okiww/billing-loan-system/internal/loan/models"
+	"github.com/okiww/billing-loan-system/pkg/clock"
 	mysql "github.com/okiww/billing-loan-system/pkg/db"
 	"github.com/okiww/billing-loan-system/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -118,7 +119,8 @@ func TestCreateLoan(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	mockDB := &mysql.DBMySQL{DB: db}
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	mockDB := &mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)}
 	repo := NewLoanRepository(mockDB)
 
 	mockStartDate := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
@@ -154,6 +156,7 @@ func TestCreateLoan(t *testing.T) {
 			want:    1, // Expected ID of the created loan
 			wantErr: false,
 			mock: func(a args) {
+				mock.ExpectBegin()
 				mock.ExpectExec(regexp.QuoteMeta(
 					`INSERT INTO loans`)).
 					WithArgs(
@@ -167,8 +170,15 @@ func TestCreateLoan(t *testing.T) {
 						a.loan.StartDate,
 						a.loan.DueDate,
 						a.loan.LoanTermsPerWeek,
+						a.loan.Timezone,
+						a.loan.InterestMethod,
+						a.loan.DayCountConvention,
 					).
 					WillReturnResult(sqlmock.NewResult(1, 1)) // Simulate success, returning ID 1
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox_messages`)).
+					WithArgs("loan", int64(1), "loan.created", sqlmock.AnyArg(), "PENDING", now).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
 			},
 		},
 		{
@@ -191,6 +201,7 @@ func TestCreateLoan(t *testing.T) {
 			want:    0, // Expected ID is 0 due to error
 			wantErr: true,
 			mock: func(a args) {
+				mock.ExpectBegin()
 				mock.ExpectExec(regexp.QuoteMeta(
 					`INSERT INTO loans`)).
 					WithArgs(
@@ -204,8 +215,12 @@ func TestCreateLoan(t *testing.T) {
 						a.loan.StartDate,
 						a.loan.DueDate,
 						a.loan.LoanTermsPerWeek,
+						a.loan.Timezone,
+						a.loan.InterestMethod,
+						a.loan.DayCountConvention,
 					).
 					WillReturnError(errors.New("db error")) // Simulate a DB error
+				mock.ExpectRollback()
 			},
 		},
 		{
@@ -301,10 +316,13 @@ func TestFetchActiveLoan(t *testing.T) {
 			mock: func() {
 				mock.ExpectQuery(regexp.QuoteMeta(`
 					SELECT id, user_id, name, loan_amount, loan_total_amount, outstanding_amount, 
-		       			interest_percentage, status, start_date, due_date, loan_terms_per_week
+		       			interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention
 					FROM loans
-					WHERE status = 'ACTIVE'
+					WHERE status = 'ACTIVE' AND id > ?
+					ORDER BY id
+					LIMIT ?
 				`)).
+					WithArgs(0, 100).
 					WillReturnRows(sqlmock.NewRows([]string{
 						"id", "user_id", "name", "loan_amount", "loan_total_amount", "outstanding_amount",
 						"interest_percentage", "status",
@@ -322,10 +340,13 @@ func TestFetchActiveLoan(t *testing.T) {
 			mock: func() {
 				mock.ExpectQuery(regexp.QuoteMeta(`
 					SELECT id, user_id, name, loan_amount, loan_total_amount, outstanding_amount, 
-		       			interest_percentage, status, start_date, due_date, loan_terms_per_week
+		       			interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention
 					FROM loans
-					WHERE status = 'ACTIVE'
+					WHERE status = 'ACTIVE' AND id > ?
+					ORDER BY id
+					LIMIT ?
 				`)).
+					WithArgs(0, 100).
 					WillReturnRows(sqlmock.NewRows([]string{
 						"id", "user_id", "name", "loan_amount", "loan_total_amount", "outstanding_amount",
 						"interest_percentage", "status",
@@ -340,10 +361,13 @@ func TestFetchActiveLoan(t *testing.T) {
 			mock: func() {
 				mock.ExpectQuery(regexp.QuoteMeta(`
 					SELECT id, user_id, name, loan_amount, loan_total_amount, outstanding_amount, 
-		       			interest_percentage, status, start_date, due_date, loan_terms_per_week
+		       			interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention
 					FROM loans
-					WHERE status = 'ACTIVE'
+					WHERE status = 'ACTIVE' AND id > ?
+					ORDER BY id
+					LIMIT ?
 				`)).
+					WithArgs(0, 100).
 					WillReturnError(errors.New("db error"))
 			},
 		},
@@ -354,7 +378,7 @@ func TestFetchActiveLoan(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock()
 
-			got, err := tt.s.FetchActiveLoan(context.Background())
+			got, err := tt.s.FetchActiveLoan(context.Background(), 0, 100)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("FetchActiveLoan() error = %v, wantErr %v", err, tt.wantErr)
@@ -434,7 +458,7 @@ func TestLoanRepository_GetLoanByUserID(t *testing.T) {
 			mock: func(a args) {
 				mock.ExpectQuery(regexp.QuoteMeta(`
 					SELECT id, user_id, name, loan_amount, loan_total_amount, outstanding_amount, 
-						   interest_percentage, status, start_date, due_date, loan_terms_per_week
+						   interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention
 					FROM loans
 					WHERE user_id = ?
 				`)).
@@ -456,7 +480,7 @@ func TestLoanRepository_GetLoanByUserID(t *testing.T) {
 			mock: func(a args) {
 				mock.ExpectQuery(regexp.QuoteMeta(`
 					SELECT id, user_id, name, loan_amount, loan_total_amount, outstanding_amount, 
-						   interest_percentage, status, start_date, due_date, loan_terms_per_week
+						   interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention
 					FROM loans
 					WHERE user_id = ?
 				`)).
@@ -475,7 +499,7 @@ func TestLoanRepository_GetLoanByUserID(t *testing.T) {
 			mock: func(a args) {
 				mock.ExpectQuery(regexp.QuoteMeta(`
 					SELECT id, user_id, name, loan_amount, loan_total_amount, outstanding_amount, 
-						   interest_percentage, status, start_date, due_date, loan_terms_per_week
+						   interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention
 					FROM loans
 					WHERE user_id = ?
 				`)).
@@ -509,3 +533,765 @@ func TestLoanRepository_GetLoanByUserID(t *testing.T) {
 		})
 	}
 }
+
+func TestLoanRepository_GetLoanByID(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db}
+	repo := NewLoanRepository(mockDB)
+
+	mockStartDate := time.Date(2024, 12, 16, 10, 0, 0, 0, time.UTC)
+	query := `
+		SELECT id, user_id, name, loan_amount, loan_total_amount, outstanding_amount, 
+		       interest_percentage, status, start_date, due_date, loan_terms_per_week, timezone, interest_method, day_count_convention
+		FROM loans
+		WHERE id = ?
+	`
+	columns := []string{
+		"id", "user_id", "name", "loan_amount", "loan_total_amount", "outstanding_amount",
+		"interest_percentage", "status", "start_date", "due_date", "loan_terms_per_week",
+	}
+
+	tests := []struct {
+		name    string
+		id      int64
+		want    *models.LoanModel
+		wantErr bool
+		mock    func(id int64)
+	}{
+		{
+			name: "Success",
+			id:   1,
+			want: &models.LoanModel{
+				ID:                 1,
+				UserID:             123,
+				Name:               "Test Loan 1",
+				LoanAmount:         1000,
+				LoanTotalAmount:    1100,
+				OutstandingAmount:  1100,
+				InterestPercentage: 10,
+				Status:             "ACTIVE",
+				StartDate:          mockStartDate,
+				DueDate:            mockStartDate,
+				LoanTermsPerWeek:   5,
+			},
+			mock: func(id int64) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(id).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, 123, "Test Loan 1", 1000, 1100, 1100, 10, "ACTIVE", mockStartDate, mockStartDate, 5))
+			},
+		},
+		{
+			name:    "Loan Not Found",
+			id:      99,
+			wantErr: true,
+			mock: func(id int64) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(id).
+					WillReturnError(sql.ErrNoRows)
+			},
+		},
+		{
+			name:    "Database Error",
+			id:      2,
+			wantErr: true,
+			mock: func(id int64) {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(id).
+					WillReturnError(errors.New("db error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.id)
+
+			got, err := repo.GetLoanByID(context.Background(), tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetLoanByID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetLoanByID() got = %v, want %v", got, tt.want)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Errorf("Expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+var (
+	lockedLoanColumns = []string{"id", "user_id", "name", "loan_amount", "loan_total_amount", "outstanding_amount", "interest_percentage",
+		"status", "start_date", "due_date", "loan_terms_per_week", "timezone", "interest_method", "day_count_convention"}
+	lockedBillColumns = []string{"id", "loan_id", "billing_date", "billing_amount", "billing_total_amount", "billing_number", "status",
+		"created_at", "updated_at"}
+)
+
+// expectLockLoanAndBills expects loan 1 and its bills to be locked FOR UPDATE
+func expectLockLoanAndBills(mock sqlmock.Sqlmock, loan, bills *sqlmock.Rows) {
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM loans`) + ".*FOR UPDATE").WithArgs(int64(1)).WillReturnRows(loan)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM loan_bills`) + ".*FOR UPDATE").WithArgs(int64(1)).WillReturnRows(bills)
+}
+
+func TestLoanRepository_RestructureLoanInTx(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx}
+	repo := NewLoanRepository(mockDB)
+
+	mockDueDate := time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC)
+	loan := &models.LoanModel{ID: 1, LoanTotalAmount: 4400, OutstandingAmount: 3300, InterestPercentage: 10, DueDate: mockDueDate, LoanTermsPerWeek: 7}
+	bills := []models.LoanBillModel{
+		{LoanID: 1, BillingDate: mockDueDate, BillingAmount: 3000, BillingTotalAmount: 3300, BillingNumber: 5, Status: models.StatusPending},
+	}
+	lockLoan := func() {
+		expectLockLoanAndBills(mock,
+			sqlmock.NewRows(lockedLoanColumns).
+				AddRow(1, 1, "loan", 4000, 4400, 3300, 10, models.StatusActive, mockDueDate, mockDueDate, 4, "UTC", "", ""),
+			sqlmock.NewRows(lockedBillColumns).
+				AddRow(1, 1, mockDueDate, 1000, 1100, 1, models.StatusPaid, mockDueDate, mockDueDate).
+				AddRow(2, 1, mockDueDate, 1000, 1100, 2, models.StatusBilled, mockDueDate, mockDueDate))
+	}
+
+	tests := []struct {
+		name     string
+		wantErr  bool
+		buildErr error
+		mock     func()
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectBegin()
+				lockLoan()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE loan_bills SET status = ?`)).
+					WithArgs(models.StatusSuperseded, sqlmock.AnyArg(), loan.ID, models.StatusPending, models.StatusBilled, models.StatusOverdue).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO loan_bills`)).
+					WillReturnResult(sqlmock.NewResult(5, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE loans`)).
+					WithArgs(loan.LoanTotalAmount, loan.OutstandingAmount, loan.InterestPercentage, loan.DueDate, loan.LoanTermsPerWeek, loan.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO loan_restructures`)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:     "Rollback when the schedule can't be built",
+			wantErr:  true,
+			buildErr: errors.New("loan is not active"),
+			mock: func() {
+				mock.ExpectBegin()
+				lockLoan()
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name:    "Rollback on error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectBegin()
+				lockLoan()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE loan_bills SET status = ?`)).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO loan_bills`)).
+					WillReturnError(errors.New("db error"))
+				mock.ExpectRollback()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			restructure, err := repo.RestructureLoanInTx(context.Background(), 1, func(locked *models.LoanModel, lockedBills []models.LoanBillModel) ([]models.LoanBillModel, *models.LoanRestructureModel, error) {
+				if locked.ID != 1 || len(lockedBills) != 2 || lockedBills[0].Status != models.StatusPaid {
+					t.Errorf("unexpected locked loan %+v bills %+v", locked, lockedBills)
+				}
+				if tt.buildErr != nil {
+					return nil, nil, tt.buildErr
+				}
+				*locked = *loan
+				return bills, &models.LoanRestructureModel{LoanID: 1, Reason: "hardship"}, nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RestructureLoanInTx() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && (restructure.ID != 1 || restructure.SupersededBills != 3) {
+				t.Errorf("RestructureLoanInTx() unexpected restructure %+v", restructure)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Errorf("Expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoanRepository_WriteOffLoanInTx(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx}
+	repo := NewLoanRepository(mockDB)
+
+	billingDate := time.Date(2024, 12, 9, 0, 0, 0, 0, time.UTC)
+	lockLoan := func(outstandingAmount int) {
+		expectLockLoanAndBills(mock,
+			sqlmock.NewRows(lockedLoanColumns).
+				AddRow(1, 1, "loan", 4000, 4400, outstandingAmount, 10, models.StatusActive, billingDate, billingDate, 4, "UTC", "", ""),
+			sqlmock.NewRows(lockedBillColumns).
+				AddRow(2, 1, billingDate, 1000, 1100, 2, models.StatusOverdue, billingDate, billingDate))
+	}
+
+	tests := []struct {
+		name         string
+		wantErr      bool
+		wantWriteOff bool
+		skip         bool
+		mock         func()
+	}{
+		{
+			name:         "Success",
+			wantWriteOff: true,
+			mock: func() {
+				mock.ExpectBegin()
+				// the amount written off is the outstanding amount of the locked loan
+				lockLoan(1100)
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE loans SET status = ? WHERE id = ? AND status = ?`)).
+					WithArgs(models.StatusWrittenOff, int64(1), models.StatusActive).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE loan_bills SET status = ?`)).
+					WithArgs(models.StatusWrittenOff, sqlmock.AnyArg(), int64(1), models.StatusPending, models.StatusBilled, models.StatusOverdue).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO loan_write_offs`)).
+					WithArgs(int64(1), models.WriteOffTypeManual, "deceased", int32(1100), int32(14)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Loan Left As Is",
+			skip: true,
+			mock: func() {
+				mock.ExpectBegin()
+				lockLoan(1100)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:    "Loan Not Active",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectBegin()
+				lockLoan(1100)
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE loans SET status = ? WHERE id = ? AND status = ?`)).
+					WithArgs(models.StatusWrittenOff, int64(1), models.StatusActive).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			writeOff, err := repo.WriteOffLoanInTx(context.Background(), 1, func(locked *models.LoanModel, lockedBills []models.LoanBillModel) (*models.LoanWriteOffModel, error) {
+				if len(lockedBills) != 1 || lockedBills[0].Status != models.StatusOverdue {
+					t.Errorf("WriteOffLoanInTx() built from bills %+v, want the locked rows", lockedBills)
+				}
+				if tt.skip {
+					return nil, nil
+				}
+				return &models.LoanWriteOffModel{
+					LoanID:           locked.ID,
+					WriteOffType:     models.WriteOffTypeManual,
+					Reason:           "deceased",
+					WrittenOffAmount: locked.OutstandingAmount,
+					DaysPastDue:      14,
+				}, nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WriteOffLoanInTx() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantWriteOff && (writeOff == nil || writeOff.ID != 1) {
+				t.Errorf("WriteOffLoanInTx() got %+v, want id 1", writeOff)
+			}
+			if !tt.wantWriteOff && writeOff != nil {
+				t.Errorf("WriteOffLoanInTx() got %+v, want none", writeOff)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Errorf("Expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoanRepository_DeferLoanInTx(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx}
+	repo := NewLoanRepository(mockDB)
+
+	billingDate := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	dueDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	lockLoan := func() {
+		expectLockLoanAndBills(mock,
+			sqlmock.NewRows(lockedLoanColumns).
+				AddRow(1, 1, "loan", 4000, 4400, 2200, 10, models.StatusActive, dueDate, dueDate, 4, "UTC", "", ""),
+			sqlmock.NewRows(lockedBillColumns).
+				AddRow(3, 1, billingDate, 1000, 1100, 3, models.StatusPending, billingDate, billingDate))
+	}
+
+	tests := []struct {
+		name     string
+		wantErr  bool
+		buildErr error
+		mock     func()
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectBegin()
+				lockLoan()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE loan_bills SET billing_date = ?, billing_total_amount = ?, status = ?, updated_at = ?`)).
+					WithArgs(billingDate.AddDate(0, 0, 14), int32(1150), models.StatusPending, sqlmock.AnyArg(), 3, models.StatusPending, models.StatusBilled).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				// the capitalized interest is added so a payment settled before the lock isn't overwritten
+				mock.ExpectExec(regexp.QuoteMeta(`SET loan_total_amount = loan_total_amount + ?, outstanding_amount = outstanding_amount + ?, due_date = ?`)).
+					WithArgs(int32(50), int32(50), dueDate.AddDate(0, 0, 14), int64(1)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO loan_deferrals`)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:     "Rollback when the deferral can't be built",
+			wantErr:  true,
+			buildErr: errors.New("loan is not active"),
+			mock: func() {
+				mock.ExpectBegin()
+				lockLoan()
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name:    "Error Shift Loan Bill",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectBegin()
+				lockLoan()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE loan_bills SET billing_date = ?`)).
+					WillReturnError(errors.New("db error"))
+				mock.ExpectRollback()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			deferral, err := repo.DeferLoanInTx(context.Background(), 1, func(locked *models.LoanModel, lockedBills []models.LoanBillModel) ([]models.LoanBillModel, *models.LoanDeferralModel, error) {
+				if locked.OutstandingAmount != 2200 || len(lockedBills) != 1 {
+					t.Errorf("DeferLoanInTx() built from loan %+v and bills %+v, want the locked rows", locked, lockedBills)
+				}
+				if tt.buildErr != nil {
+					return nil, nil, tt.buildErr
+				}
+				bill := lockedBills[0]
+				bill.BillingDate = bill.BillingDate.AddDate(0, 0, 14)
+				bill.BillingTotalAmount += 50
+				return []models.LoanBillModel{bill}, &models.LoanDeferralModel{
+					LoanID: 1, Reason: "flood", Periods: 2, FirstBillingNumber: 3, DeferredBills: 1, CapitalizedInterest: 50,
+					PreviousDueDate: locked.DueDate, NewDueDate: locked.DueDate.AddDate(0, 0, 14),
+				}, nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DeferLoanInTx() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && deferral.ID != 1 {
+				t.Errorf("DeferLoanInTx() got id = %v, want 1", deferral.ID)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Errorf("Expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoanRepository_AddRecoveredAmount(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db}
+	repo := NewLoanRepository(mockDB)
+
+	query := `UPDATE loans SET recovered_amount = recovered_amount + ? WHERE id = ? AND status = ?`
+	tests := []struct {
+		name    string
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta(query)).
+					WithArgs(500, 1, models.StatusWrittenOff).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:    "Loan Not Written Off",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta(query)).
+					WithArgs(500, 1, models.StatusWrittenOff).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta(query)).
+					WithArgs(500, 1, models.StatusWrittenOff).
+					WillReturnError(errors.New("db error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			err := repo.AddRecoveredAmount(context.Background(), 1, 500)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AddRecoveredAmount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Errorf("Expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoanRepository_FetchActiveLoanTimezones(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db}
+	repo := NewLoanRepository(mockDB)
+
+	query := `SELECT DISTINCT timezone FROM loans WHERE status = 'ACTIVE' ORDER BY timezone`
+	tests := []struct {
+		name    string
+		want    []string
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			want: []string{"Asia/Jakarta", "Asia/Makassar"},
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Asia/Jakarta").AddRow("Asia/Makassar"))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WillReturnError(errors.New("db error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.FetchActiveLoanTimezones(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FetchActiveLoanTimezones() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Errorf("Expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoanRepository_FetchInterestAccrualStates(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db}
+	repo := NewLoanRepository(mockDB)
+
+	startDate := time.Date(2024, 12, 16, 0, 0, 0, 0, time.UTC)
+	lastAccrualDate := time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC)
+	query := `WHERE l.status = 'ACTIVE' AND l.interest_method = 'DECLINING_BALANCE' AND l.id > ?`
+	columns := []string{"loan_id", "status", "interest_method", "interest_percentage", "day_count_convention", "timezone",
+		"start_date", "outstanding_amount", "principal_outstanding", "last_accrual_date"}
+
+	tests := []struct {
+		name    string
+		want    []models.LoanInterestStateModel
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			want: []models.LoanInterestStateModel{
+				{LoanID: 1, Status: models.StatusActive, InterestMethod: models.InterestMethodDecliningBalance, InterestPercentage: 12,
+					DayCountConvention: models.DayCountACT365, Timezone: "Asia/Jakarta", StartDate: startDate, OutstandingAmount: 1000,
+					PrincipalOutstanding: 1000, LastAccrualDate: &lastAccrualDate},
+				{LoanID: 2, Status: models.StatusActive, InterestMethod: models.InterestMethodDecliningBalance, InterestPercentage: 12,
+					DayCountConvention: models.DayCount30360, Timezone: "Asia/Jakarta", StartDate: startDate, OutstandingAmount: 500,
+					PrincipalOutstanding: 500},
+			},
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(int64(0), 2).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "ACTIVE", "DECLINING_BALANCE", 12, "ACT/365", "Asia/Jakarta", startDate, 1000, 1000, lastAccrualDate).
+						AddRow(2, "ACTIVE", "DECLINING_BALANCE", 12, "30/360", "Asia/Jakarta", startDate, 500, 500, nil))
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(int64(0), 2).
+					WillReturnError(errors.New("db error"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.FetchInterestAccrualStates(context.Background(), 0, 2)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FetchInterestAccrualStates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Errorf("Expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoanRepository_GetInterestState(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mockDB := &mysql.DBMySQL{DB: db}
+	repo := NewLoanRepository(mockDB)
+
+	startDate := time.Date(2024, 12, 16, 0, 0, 0, 0, time.UTC)
+	query := `FROM loans l WHERE l.id = ?`
+	columns := []string{"loan_id", "status", "interest_method", "interest_percentage", "day_count_convention", "timezone",
+		"start_date", "outstanding_amount", "principal_outstanding", "last_accrual_date"}
+
+	tests := []struct {
+		name    string
+		want    *models.LoanInterestStateModel
+		wantErr bool
+		mock    func()
+	}{
+		{
+			name: "Success",
+			want: &models.LoanInterestStateModel{LoanID: 1, Status: models.StatusActive, InterestMethod: models.InterestMethodFlat,
+				InterestPercentage: 10, DayCountConvention: models.DayCountACT365, Timezone: "Asia/Jakarta", StartDate: startDate,
+				OutstandingAmount: 1100, PrincipalOutstanding: 1000},
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(int64(1)).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "ACTIVE", "FLAT", 10, "ACT/365", "Asia/Jakarta", startDate, 1100, 1000, nil))
+			},
+		},
+		{
+			name:    "Not Found",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta(query)).
+					WithArgs(int64(1)).
+					WillReturnError(sql.ErrNoRows)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.GetInterestState(context.Background(), 1)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetInterestState() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Errorf("Expectations were not met: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoanRepository_AccrueInterestInTx(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	mockDB := &mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)}
+	repo := NewLoanRepository(mockDB)
+
+	accrualDate := time.Date(2024, 12, 22, 0, 0, 0, 0, time.UTC)
+	billQuery := regexp.QuoteMeta(`SELECT id, billing_amount, billing_total_amount FROM loan_bills`)
+	insertQuery := regexp.QuoteMeta(`INSERT IGNORE INTO loan_interest_accruals`)
+	sumQuery := regexp.QuoteMeta(`SELECT COALESCE(SUM(interest), 0) FROM loan_interest_accruals WHERE loan_bill_id = ?`)
+
+	tests := []struct {
+		name       string
+		want       bool
+		wantBillID *int64
+		wantErr    bool
+		mock       func()
+	}{
+		{
+			name:       "Success - Interest Charged On The Next Bill",
+			want:       true,
+			wantBillID: func() *int64 { id := int64(7); return &id }(),
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(billQuery).
+					WithArgs(int64(1), models.StatusPending, models.StatusBilled, models.StatusOverdue, accrualDate, accrualDate).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "billing_amount", "billing_total_amount"}).AddRow(7, 20000, 20040))
+				mock.ExpectExec(insertQuery).
+					WithArgs(int64(1), sqlmock.AnyArg(), accrualDate, int32(1000000), float64(12), 1.0/365, 1000000*0.12/365).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(sumQuery).
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows([]string{"interest"}).AddRow(361.6438))
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE loan_bills SET billing_total_amount = ?, updated_at = ? WHERE id = ?`)).
+					WithArgs(int32(20362), now, 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE loans SET loan_total_amount = loan_total_amount + ?, outstanding_amount = outstanding_amount + ? WHERE id = ?`)).
+					WithArgs(int32(322), int32(322), int64(1)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:       "Already Accrued",
+			want:       false,
+			wantBillID: func() *int64 { id := int64(7); return &id }(),
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(billQuery).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "billing_amount", "billing_total_amount"}).AddRow(7, 20000, 20040))
+				mock.ExpectExec(insertQuery).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "No Unpaid Bill",
+			want: true,
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(billQuery).
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectExec(insertQuery).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:    "Database Error",
+			wantErr: true,
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(billQuery).
+					WillReturnError(errors.New("db error"))
+				mock.ExpectRollback()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			accrual := &models.LoanInterestAccrualModel{
+				LoanID:           1,
+				AccrualDate:      accrualDate,
+				Principal:        1000000,
+				AnnualRate:       12,
+				DayCountFraction: 1.0 / 365,
+				Interest:         1000000 * 0.12 / 365,
+			}
+			got, err := repo.AccrueInterestInTx(context.Background(), accrual)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AccrueInterestInTx() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+			if !tt.wantErr {
+				assert.Equal(t, tt.wantBillID, accrual.LoanBillID)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Errorf("Expectations were not met: %v", err)
+			}
+		})
+	}
+}
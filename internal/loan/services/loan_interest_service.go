@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/okiww/billing-loan-system/helpers"
+	"github.com/okiww/billing-loan-system/internal/dto"
+	"github.com/okiww/billing-loan-system/internal/loan/models"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// GetInterestAccrualStates get a page of active declining balance loans with their outstanding principal
+func (l *loanService) GetInterestAccrualStates(ctx context.Context, afterID int64, limit int) ([]models.LoanInterestStateModel, error) {
+	logger.GetLogger().Info("[LoanService][GetInterestAccrualStates]")
+	states, err := l.loanRepo.FetchInterestAccrualStates(ctx, afterID, limit)
+	if err != nil {
+		logger.GetLogger().Errorf("[LoanService][GetInterestAccrualStates] Error FetchInterestAccrualStates with err: %v", err)
+		return nil, err
+	}
+	return states, nil
+}
+
+// AccrueInterest accrues the interest of a declining balance loan on its outstanding principal for every day since its
+// last accrual up to yesterday in the loan timezone, a day is accrued once. Returns the days accrued
+func (l *loanService) AccrueInterest(ctx context.Context, state models.LoanInterestStateModel) (int32, error) {
+	if state.PrincipalOutstanding <= 0 {
+		return 0, nil
+	}
+
+	yesterday := helpers.LocalDate(l.clock.Now(), helpers.LoadLocation(state.Timezone)).AddDate(0, 0, -1)
+	var days int32
+	for day := nextAccrualDate(state); !day.After(yesterday); day = day.AddDate(0, 0, 1) {
+		fraction := dayCountFraction(state.DayCountConvention, day, day.AddDate(0, 0, 1))
+		accrued, err := l.loanRepo.AccrueInterestInTx(ctx, &models.LoanInterestAccrualModel{
+			LoanID:           state.LoanID,
+			AccrualDate:      day,
+			Principal:        state.PrincipalOutstanding,
+			AnnualRate:       state.InterestPercentage,
+			DayCountFraction: fraction,
+			Interest:         float64(state.PrincipalOutstanding) * state.InterestPercentage / 100 * fraction,
+		})
+		if err != nil {
+			logger.GetLogger().WithFields(logrus.Fields{
+				"loan_id":      state.LoanID,
+				"accrual_date": day,
+			}).Errorf("[LoanService][AccrueInterest] Error AccrueInterestInTx with err: %v", err)
+			return days, err
+		}
+		if accrued {
+			days++
+		}
+	}
+
+	return days, nil
+}
+
+// GetPayoffQuote get the amount to pay to close a loan on the given date, today in the loan timezone when zero. The
+// interest a declining balance loan accrues until then on its current principal is added to the unpaid bills
+func (l *loanService) GetPayoffQuote(ctx context.Context, loanID int64, payoffDate time.Time) (*models.PayoffQuoteModel, error) {
+	logger.GetLogger().Info("[LoanService][GetPayoffQuote]")
+	state, err := l.loanRepo.GetInterestState(ctx, loanID)
+	if err != nil {
+		logger.GetLogger().Errorf("[LoanService][GetPayoffQuote] Error GetInterestState with err: %v", err)
+		return nil, err
+	}
+
+	if state.Status != models.StatusActive {
+		return nil, errors.New(dto.ErrorLoanIsNotActive)
+	}
+
+	today := helpers.LocalDate(l.clock.Now(), helpers.LoadLocation(state.Timezone))
+	if payoffDate.IsZero() {
+		payoffDate = today
+	}
+	payoffDate = helpers.LocalDate(payoffDate, payoffDate.Location())
+	if payoffDate.Before(today) {
+		return nil, errors.New(dto.ErrorPayoffDateInThePast)
+	}
+
+	var projectedInterest float64
+	if state.InterestMethod == models.InterestMethodDecliningBalance && state.PrincipalOutstanding > 0 {
+		for day := nextAccrualDate(*state); day.Before(payoffDate); day = day.AddDate(0, 0, 1) {
+			fraction := dayCountFraction(state.DayCountConvention, day, day.AddDate(0, 0, 1))
+			projectedInterest += float64(state.PrincipalOutstanding) * state.InterestPercentage / 100 * fraction
+		}
+	}
+
+	quote := &models.PayoffQuoteModel{
+		LoanID:               state.LoanID,
+		AsOf:                 payoffDate,
+		InterestMethod:       state.InterestMethod,
+		PrincipalOutstanding: state.PrincipalOutstanding,
+		InterestOutstanding:  state.OutstandingAmount - state.PrincipalOutstanding,
+		ProjectedInterest:    int32(math.Round(projectedInterest)),
+	}
+	quote.PayoffAmount = state.OutstandingAmount + quote.ProjectedInterest
+	return quote, nil
+}
+
+// nextAccrualDate get the first day a loan hasn't accrued interest for, the start date when it never accrued
+func nextAccrualDate(state models.LoanInterestStateModel) time.Time {
+	if state.LastAccrualDate != nil {
+		return helpers.LocalDate(*state.LastAccrualDate, state.LastAccrualDate.Location()).AddDate(0, 0, 1)
+	}
+	return helpers.LocalDate(state.StartDate, state.StartDate.Location())
+}
+
+// dayCountFraction get the fraction of a year between two dates on the day count convention of the loan
+func dayCountFraction(convention string, from, to time.Time) float64 {
+	if convention == models.DayCount30360 {
+		return float64(helpers.DayCount30360(from, to)) / 360
+	}
+	return float64(helpers.DaysBetween(from, to)) / 365
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	billing_config_mock "github.com/okiww/billing-loan-system/gen/mocks/billing_config"
+	loan_mock "github.com/okiww/billing-loan-system/gen/mocks/loan"
+	"github.com/okiww/billing-loan-system/internal/dto"
+	"github.com/okiww/billing-loan-system/internal/loan/models"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccrueInterest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
+	mockBillingConfig := billing_config_mock.NewMockBillingConfigRepositoryInterface(ctrl)
+
+	// 17:00 on Dec 23 in Asia/Jakarta, loans accrue up to Dec 22
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(mockLoanRepo, mockLoanBillRepo, mockBillingConfig, clock.Fixed(now))
+
+	lastAccrualDate := time.Date(2024, 12, 19, 0, 0, 0, 0, time.UTC)
+	state := models.LoanInterestStateModel{
+		LoanID:               1,
+		Status:               models.StatusActive,
+		InterestMethod:       models.InterestMethodDecliningBalance,
+		InterestPercentage:   10,
+		DayCountConvention:   models.DayCountACT365,
+		Timezone:             "Asia/Jakarta",
+		StartDate:            time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+		OutstandingAmount:    365000,
+		PrincipalOutstanding: 365000,
+		LastAccrualDate:      &lastAccrualDate,
+	}
+
+	tests := []struct {
+		name     string
+		state    func() models.LoanInterestStateModel
+		setup    func()
+		wantDays int32
+		wantErr  bool
+	}{
+		{
+			name:  "Success - Catch Up Every Day Since The Last Accrual",
+			state: func() models.LoanInterestStateModel { return state },
+			setup: func() {
+				for _, day := range []int{20, 21, 22} {
+					mockLoanRepo.EXPECT().
+						AccrueInterestInTx(gomock.Any(), &models.LoanInterestAccrualModel{
+							LoanID:           1,
+							AccrualDate:      time.Date(2024, 12, day, 0, 0, 0, 0, time.UTC),
+							Principal:        365000,
+							AnnualRate:       10,
+							DayCountFraction: 1.0 / 365,
+							Interest:         float64(365000) * 10 / 100 * (1.0 / 365),
+						}).
+						Return(true, nil)
+				}
+			},
+			wantDays: 3,
+		},
+		{
+			name: "Success - 30/360 Never Accrued",
+			state: func() models.LoanInterestStateModel {
+				s := state
+				s.DayCountConvention = models.DayCount30360
+				s.StartDate = time.Date(2024, 12, 22, 0, 0, 0, 0, time.UTC)
+				s.LastAccrualDate = nil
+				return s
+			},
+			setup: func() {
+				mockLoanRepo.EXPECT().
+					AccrueInterestInTx(gomock.Any(), &models.LoanInterestAccrualModel{
+						LoanID:           1,
+						AccrualDate:      time.Date(2024, 12, 22, 0, 0, 0, 0, time.UTC),
+						Principal:        365000,
+						AnnualRate:       10,
+						DayCountFraction: 1.0 / 360,
+						Interest:         float64(365000) * 10 / 100 * (1.0 / 360),
+					}).
+					Return(true, nil)
+			},
+			wantDays: 1,
+		},
+		{
+			name: "Skip - Day Already Accrued",
+			state: func() models.LoanInterestStateModel {
+				s := state
+				day := time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC)
+				s.LastAccrualDate = &day
+				return s
+			},
+			setup: func() {
+				mockLoanRepo.EXPECT().AccrueInterestInTx(gomock.Any(), gomock.Any()).Return(false, nil)
+			},
+			wantDays: 0,
+		},
+		{
+			name: "Skip - No Outstanding Principal",
+			state: func() models.LoanInterestStateModel {
+				s := state
+				s.PrincipalOutstanding = 0
+				return s
+			},
+			setup:    func() {},
+			wantDays: 0,
+		},
+		{
+			name:  "Error - AccrueInterestInTx Fails",
+			state: func() models.LoanInterestStateModel { return state },
+			setup: func() {
+				mockLoanRepo.EXPECT().AccrueInterestInTx(gomock.Any(), gomock.Any()).Return(true, nil)
+				mockLoanRepo.EXPECT().AccrueInterestInTx(gomock.Any(), gomock.Any()).Return(false, errors.New("db error"))
+			},
+			wantDays: 1,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			days, err := loanService.AccrueInterest(context.Background(), tt.state())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantDays, days)
+		})
+	}
+}
+
+func TestGetPayoffQuote(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
+	mockBillingConfig := billing_config_mock.NewMockBillingConfigRepositoryInterface(ctrl)
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(mockLoanRepo, mockLoanBillRepo, mockBillingConfig, clock.Fixed(now))
+
+	lastAccrualDate := time.Date(2024, 12, 22, 0, 0, 0, 0, time.UTC)
+	declining := &models.LoanInterestStateModel{
+		LoanID:               1,
+		Status:               models.StatusActive,
+		InterestMethod:       models.InterestMethodDecliningBalance,
+		InterestPercentage:   10,
+		DayCountConvention:   models.DayCountACT365,
+		Timezone:             "Asia/Jakarta",
+		StartDate:            time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+		OutstandingAmount:    400000,
+		PrincipalOutstanding: 365000,
+		LastAccrualDate:      &lastAccrualDate,
+	}
+	flat := &models.LoanInterestStateModel{
+		LoanID:               2,
+		Status:               models.StatusActive,
+		InterestMethod:       models.InterestMethodFlat,
+		InterestPercentage:   10,
+		DayCountConvention:   models.DayCountACT365,
+		Timezone:             "Asia/Jakarta",
+		StartDate:            time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+		OutstandingAmount:    110000,
+		PrincipalOutstanding: 100000,
+	}
+
+	tests := []struct {
+		name       string
+		loanID     int64
+		payoffDate time.Time
+		setup      func()
+		want       *models.PayoffQuoteModel
+		wantErr    string
+	}{
+		{
+			name:       "Success - Declining Balance Projects Interest Up To The Payoff Date",
+			loanID:     1,
+			payoffDate: time.Date(2024, 12, 26, 0, 0, 0, 0, time.UTC),
+			setup: func() {
+				mockLoanRepo.EXPECT().GetInterestState(gomock.Any(), int64(1)).Return(declining, nil)
+			},
+			want: &models.PayoffQuoteModel{
+				LoanID:               1,
+				AsOf:                 time.Date(2024, 12, 26, 0, 0, 0, 0, time.UTC),
+				InterestMethod:       models.InterestMethodDecliningBalance,
+				PrincipalOutstanding: 365000,
+				InterestOutstanding:  35000,
+				ProjectedInterest:    300,
+				PayoffAmount:         400300,
+			},
+		},
+		{
+			name:   "Success - Flat Loan Pays Its Outstanding Amount Today",
+			loanID: 2,
+			setup: func() {
+				mockLoanRepo.EXPECT().GetInterestState(gomock.Any(), int64(2)).Return(flat, nil)
+			},
+			want: &models.PayoffQuoteModel{
+				LoanID:               2,
+				AsOf:                 time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC),
+				InterestMethod:       models.InterestMethodFlat,
+				PrincipalOutstanding: 100000,
+				InterestOutstanding:  10000,
+				PayoffAmount:         110000,
+			},
+		},
+		{
+			name:       "Error - Payoff Date In The Past",
+			loanID:     1,
+			payoffDate: time.Date(2024, 12, 22, 0, 0, 0, 0, time.UTC),
+			setup: func() {
+				mockLoanRepo.EXPECT().GetInterestState(gomock.Any(), int64(1)).Return(declining, nil)
+			},
+			wantErr: dto.ErrorPayoffDateInThePast,
+		},
+		{
+			name:   "Error - Loan Is Not Active",
+			loanID: 3,
+			setup: func() {
+				mockLoanRepo.EXPECT().GetInterestState(gomock.Any(), int64(3)).
+					Return(&models.LoanInterestStateModel{LoanID: 3, Status: models.StatusPaid}, nil)
+			},
+			wantErr: dto.ErrorLoanIsNotActive,
+		},
+		{
+			name:   "Error - Loan Not Found",
+			loanID: 4,
+			setup: func() {
+				mockLoanRepo.EXPECT().GetInterestState(gomock.Any(), int64(4)).
+					Return(nil, errors.New("no loan found with id 4"))
+			},
+			wantErr: "no loan found with id 4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			got, err := loanService.GetPayoffQuote(context.Background(), tt.loanID, tt.payoffDate)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Nil(t, got)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
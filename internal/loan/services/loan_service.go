@@ -16,6 +16,8 @@ import (
 	"github.com/okiww/billing-loan-system/helpers"
 	"github.com/okiww/billing-loan-system/internal/dto"
 	"github.com/okiww/billing-loan-system/internal/loan/repositories"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	"github.com/okiww/billing-loan-system/pkg/errors"
 	"github.com/okiww/billing-loan-system/pkg/logger"
 	"github.com/sirupsen/logrus"
 )
@@ -24,6 +26,7 @@ type loanService struct {
 	loanRepo          repositories.LoanRepositoryInterface
 	loanBillRepo      repositories.LoanBillRepositoryInterface
 	billingConfigRepo billingConfigRepo.BillingConfigRepositoryInterface
+	clock             clock.Clock
 }
 
 func (l *loanService) CreateLoan(ctx context.Context, request dto.LoanRequest) error {
@@ -54,8 +57,20 @@ func (l *loanService) CreateLoan(ctx context.Context, request dto.LoanRequest) e
 		loanTermsPerWeek = int(loanTermsPerWeekConfig.Value)
 	}
 
-	// Create a new loan
+	// Create a new loan, its billing days are the calendar days of the borrower timezone
+	location := helpers.LoadLocation(request.Timezone)
+	today := helpers.LocalDate(l.clock.Now(), location)
 	loanTotalAmount := int32(float64(request.LoanAmount) + (float64(request.LoanAmount) * 10 / 100))
+	interestMethod := models.InterestMethodFlat
+	if request.InterestMethod == models.InterestMethodDecliningBalance {
+		// the interest accrues daily on the outstanding principal, it is added to the bills as it accrues
+		interestMethod = models.InterestMethodDecliningBalance
+		loanTotalAmount = request.LoanAmount
+	}
+	dayCountConvention := models.DayCountACT365
+	if request.DayCountConvention == models.DayCount30360 {
+		dayCountConvention = models.DayCount30360
+	}
 	newLoan := &models.LoanModel{
 		UserID:             int64(request.UserID),
 		Name:               request.Name,
@@ -64,9 +79,12 @@ func (l *loanService) CreateLoan(ctx context.Context, request dto.LoanRequest) e
 		OutstandingAmount:  loanTotalAmount,
 		InterestPercentage: float64(interestPercentage), // TODO Should be get From Config
 		Status:             models.StatusActive,
-		StartDate:          time.Now(),
-		DueDate:            helpers.GenerateLastBillDate(time.Now(), 4),
+		StartDate:          today,
+		DueDate:            helpers.GenerateLastBillDate(today, 4),
 		LoanTermsPerWeek:   int32(loanTermsPerWeek), // TODO should be get from config
+		Timezone:           location.String(),
+		InterestMethod:     interestMethod,
+		DayCountConvention: dayCountConvention,
 	}
 
 	id, err := l.loanRepo.CreateLoan(ctx, newLoan)
@@ -89,28 +107,141 @@ func (l *loanService) CreateLoan(ctx context.Context, request dto.LoanRequest) e
 	return nil
 }
 
-func (l *loanService) UpdateLoanBill(ctx context.Context) error {
+// UpdateLoanBill update loan bill statuses as of the given date and records the result in the billing runs ledger.
+// The bills of each loan are evaluated in the loan timezone, see billingDateIn
+func (l *loanService) UpdateLoanBill(ctx context.Context, asOf time.Time) error {
 	logger.GetLogger().Info("[LoanService][UpdateLoanBill]")
+
 	// this is for update loan bill from pending to billed
-	err := l.loanBillRepo.UpdateLoanBillStatuses(ctx)
+	run := &models.BillingRunModel{RunDate: helpers.TruncateToDay(asOf), Status: models.BillingRunStatusSuccess}
+	err := l.updateLoanBillStatuses(ctx, asOf)
 	if err != nil {
 		logger.GetLogger().Error("[LoanService][UpdateLoanBill] Error when update loan bill statuses")
+		run.Status = models.BillingRunStatusFailed
+		run.Error = err.Error()
+	}
+
+	if saveErr := l.loanBillRepo.SaveBillingRun(ctx, run); saveErr != nil {
+		logger.GetLogger().Errorf("[LoanService][UpdateLoanBill] Error SaveBillingRun with err: %v", saveErr)
+		if err == nil {
+			err = saveErr
+		}
+	}
+	return err
+}
+
+// updateLoanBillStatuses update the bill statuses timezone by timezone, on the local date of each timezone
+func (l *loanService) updateLoanBillStatuses(ctx context.Context, asOf time.Time) error {
+	timezones, err := l.loanRepo.FetchActiveLoanTimezones(ctx)
+	if err != nil {
+		logger.GetLogger().Errorf("[LoanService][UpdateLoanBill] Error FetchActiveLoanTimezones with err: %v", err)
 		return err
 	}
+
+	for _, timezone := range timezones {
+		err := l.loanBillRepo.UpdateLoanBillStatuses(ctx, l.billingDateIn(asOf, timezone), timezone)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (l *loanService) GetAllActiveLoan(ctx context.Context) ([]models.LoanModel, error) {
-	logger.GetLogger().Info("[LoanService][GetAllActiveLoan]")
-	loans, err := l.loanRepo.FetchActiveLoan(ctx)
+// billingDateIn get the date the bills of a timezone are evaluated on for the given billing date. A timezone which
+// hasn't reached the billing date yet is evaluated on its own today, so a bill is never billed before its day starts
+// in the loan timezone
+func (l *loanService) billingDateIn(asOf time.Time, timezone string) time.Time {
+	date := helpers.LocalDate(asOf, asOf.Location())
+	today := helpers.LocalDate(l.clock.Now(), helpers.LoadLocation(timezone))
+	if today.Before(date) {
+		return today
+	}
+	return date
+}
+
+// GetMissedBillingDates get every billing date after the last successful run up to the given date, in order
+func (l *loanService) GetMissedBillingDates(ctx context.Context, asOf time.Time) ([]time.Time, error) {
+	logger.GetLogger().Info("[LoanService][GetMissedBillingDates]")
+	asOf = helpers.TruncateToDay(asOf)
+
+	lastRunDate, err := l.loanBillRepo.GetLastBillingRunDate(ctx)
+	if err != nil {
+		logger.GetLogger().Errorf("[LoanService][GetMissedBillingDates] Error GetLastBillingRunDate with err: %v", err)
+		return nil, err
+	}
+
+	if lastRunDate == nil {
+		return []time.Time{asOf}, nil
+	}
+
+	// the ledger stores dates only, keep them in the location of the as of date
+	from := time.Date(lastRunDate.Year(), lastRunDate.Month(), lastRunDate.Day(), 0, 0, 0, 0, asOf.Location()).AddDate(0, 0, 1)
+	return helpers.DatesBetween(from, asOf), nil
+}
+
+// PreviewBillingChanges get the bills which status would change and the loans which would flag their user as delinquent
+// when the bill statuses are updated as of the given date, without writing anything
+func (l *loanService) PreviewBillingChanges(ctx context.Context, asOf time.Time) ([]models.LoanBillStatusChangeModel, []models.DelinquencyChangeModel, error) {
+	logger.GetLogger().Info("[LoanService][PreviewBillingChanges]")
+	timezones, err := l.loanRepo.FetchActiveLoanTimezones(ctx)
 	if err != nil {
-		logger.GetLogger().Errorf("[LoanService][GetAllActiveLoan] Error when fetch active loans with err: %v", err)
+		logger.GetLogger().Errorf("[LoanService][PreviewBillingChanges] Error FetchActiveLoanTimezones with err: %v", err)
+		return nil, nil, err
+	}
+
+	var (
+		bills []models.LoanBillStatusChangeModel
+		loans []models.DelinquencyChangeModel
+	)
+	for _, timezone := range timezones {
+		localDate := l.billingDateIn(asOf, timezone)
+		timezoneBills, err := l.loanBillRepo.PreviewLoanBillStatuses(ctx, localDate, timezone)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanService][PreviewBillingChanges] Error PreviewLoanBillStatuses with err: %v", err)
+			return nil, nil, err
+		}
+
+		timezoneLoans, err := l.loanBillRepo.PreviewDelinquentLoans(ctx, localDate, timezone)
+		if err != nil {
+			logger.GetLogger().Errorf("[LoanService][PreviewBillingChanges] Error PreviewDelinquentLoans with err: %v", err)
+			return nil, nil, err
+		}
+
+		bills = append(bills, timezoneBills...)
+		loans = append(loans, timezoneLoans...)
+	}
+
+	return bills, loans, nil
+}
+
+// GetActiveLoans get a page of active loans ordered by id, pass the last id of the previous page to get the next one
+func (l *loanService) GetActiveLoans(ctx context.Context, afterID int64, limit int) ([]models.LoanModel, error) {
+	logger.GetLogger().Info("[LoanService][GetActiveLoans]")
+	loans, err := l.loanRepo.FetchActiveLoan(ctx, afterID, limit)
+	if err != nil {
+		logger.GetLogger().Errorf("[LoanService][GetActiveLoans] Error when fetch active loans with err: %v", err)
 		return []models.LoanModel{}, err
 	}
 
 	return loans, nil
 }
 
+// CountOverdueBillsByLoanIDs count the OVERDUE bills of each loan in one query, loans without overdue bills are left out
+func (l *loanService) CountOverdueBillsByLoanIDs(ctx context.Context, ids []int64) (map[int64]int32, error) {
+	logger.GetLogger().Info("[LoanService][CountOverdueBillsByLoanIDs]")
+	if len(ids) == 0 {
+		return map[int64]int32{}, nil
+	}
+
+	counts, err := l.loanBillRepo.GetTotalLoanBillOverdueByLoanIDs(ctx, ids)
+	if err != nil {
+		logger.GetLogger().Errorf("[LoanService][CountOverdueBillsByLoanIDs] Error when get total loan bill overdue with err: %v", err)
+		return nil, err
+	}
+
+	return counts, nil
+}
+
 func (l *loanService) CountLoanBillOverdueStatusesByID(ctx context.Context, id int32) (int32, error) {
 	logger.GetLogger().Info("[LoanService][CountLoanBillOverdueStatuses]")
 	total, err := l.loanBillRepo.GetTotalLoanBillOverdueByLoanID(ctx, id)
@@ -151,6 +282,9 @@ func (l *loanService) GetLoansWithBills(ctx context.Context, userID int) ([]mode
 			StartDate:          loan.StartDate,
 			DueDate:            loan.DueDate,
 			LoanTermsPerWeek:   loan.LoanTermsPerWeek,
+			Timezone:           loan.Timezone,
+			InterestMethod:     loan.InterestMethod,
+			DayCountConvention: loan.DayCountConvention,
 			CreatedAt:          loan.CreatedAt,
 			UpdatedAt:          loan.UpdatedAt,
 			LoanBills:          loanBills,
@@ -162,6 +296,320 @@ func (l *loanService) GetLoansWithBills(ctx context.Context, userID int) ([]mode
 	return loansWithBills, nil
 }
 
+// RestructureLoan replaces the unpaid bills of an active loan with a new weekly schedule,
+// keeping the replaced bills as SUPERSEDED for audit
+func (l *loanService) RestructureLoan(ctx context.Context, request dto.RestructureLoanRequest) (*models.LoanRestructureModel, error) {
+	logger.GetLogger().Info("[LoanService][RestructureLoan]")
+
+	// the schedule is built from the loan and bills locked by the transaction, so a payment settled meanwhile is seen
+	restructure, err := l.loanRepo.RestructureLoanInTx(ctx, request.LoanID, func(loan *models.LoanModel, bills []models.LoanBillModel) ([]models.LoanBillModel, *models.LoanRestructureModel, error) {
+		return l.buildRestructure(request, loan, bills)
+	})
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"loan_id": request.LoanID,
+		}).Errorf("[LoanService][RestructureLoan] Error RestructureLoanInTx with err: %v", err)
+		return nil, err
+	}
+
+	return restructure, nil
+}
+
+// buildRestructure builds the new schedule replacing the unpaid bills of a loan and updates the loan in place
+func (l *loanService) buildRestructure(request dto.RestructureLoanRequest, loan *models.LoanModel, bills []models.LoanBillModel) ([]models.LoanBillModel, *models.LoanRestructureModel, error) {
+	if loan.Status != models.StatusActive {
+		return nil, nil, errors.New(dto.ErrorLoanIsNotActive)
+	}
+
+	var (
+		paidBills          int32
+		unpaidBills        int32
+		remainingPrincipal int32
+		lastBillingNumber  int
+	)
+	for _, bill := range bills {
+		if bill.BillingNumber > lastBillingNumber {
+			lastBillingNumber = bill.BillingNumber
+		}
+
+		switch bill.Status {
+		case models.StatusPaid:
+			paidBills++
+		case models.StatusPending, models.StatusBilled, models.StatusOverdue:
+			unpaidBills++
+			remainingPrincipal += bill.BillingAmount
+		}
+	}
+
+	if unpaidBills == 0 {
+		return nil, nil, errors.New(dto.ErrorLoanHasNoUnpaidBills)
+	}
+
+	// interest already charged stays unless a new interest is applied to the remaining principal
+	interestPercentage := loan.InterestPercentage
+	outstandingAmount := loan.OutstandingAmount
+	if request.InterestPercentage != nil {
+		interestPercentage = *request.InterestPercentage
+		outstandingAmount = int32(float64(remainingPrincipal) + (float64(remainingPrincipal) * interestPercentage / 100))
+	}
+
+	tenor := unpaidBills
+	installmentAmount := outstandingAmount / tenor
+	if request.Tenor > 0 {
+		tenor = request.Tenor
+		installmentAmount = outstandingAmount / tenor
+	} else if request.InstallmentAmount > 0 {
+		installmentAmount = request.InstallmentAmount
+		tenor = (outstandingAmount + installmentAmount - 1) / installmentAmount
+	}
+
+	newBills := buildLoanBillSchedule(loan.ID, remainingPrincipal, outstandingAmount, installmentAmount, tenor, lastBillingNumber+1,
+		helpers.LocalDate(l.clock.Now(), helpers.LoadLocation(loan.Timezone)))
+
+	restructure := &models.LoanRestructureModel{
+		LoanID:                     loan.ID,
+		Reason:                     request.Reason,
+		PreviousOutstandingAmount:  loan.OutstandingAmount,
+		NewOutstandingAmount:       outstandingAmount,
+		PreviousInterestPercentage: loan.InterestPercentage,
+		NewInterestPercentage:      interestPercentage,
+		PreviousDueDate:            loan.DueDate,
+		NewDueDate:                 newBills[len(newBills)-1].BillingDate,
+		NewTerms:                   tenor,
+		InstallmentAmount:          installmentAmount,
+	}
+
+	loan.LoanTotalAmount = loan.LoanTotalAmount - loan.OutstandingAmount + outstandingAmount
+	loan.OutstandingAmount = outstandingAmount
+	loan.InterestPercentage = interestPercentage
+	loan.DueDate = restructure.NewDueDate
+	loan.LoanTermsPerWeek = paidBills + tenor
+
+	return newBills, restructure, nil
+}
+
+// DeferLoan grants a payment holiday, shifting the pending bills from the requested bill onward by the number of weekly periods.
+// The cron doesn't bill the loan nor count it as overdue while the deferral window is active
+func (l *loanService) DeferLoan(ctx context.Context, request dto.DeferLoanRequest) (*models.LoanDeferralModel, error) {
+	logger.GetLogger().Info("[LoanService][DeferLoan]")
+
+	// the deferral is built from the loan and bills locked by the transaction, so a payment settled meanwhile is seen
+	deferral, err := l.loanRepo.DeferLoanInTx(ctx, request.LoanID, func(loan *models.LoanModel, bills []models.LoanBillModel) ([]models.LoanBillModel, *models.LoanDeferralModel, error) {
+		return buildDeferral(request, loan, bills)
+	})
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"loan_id": request.LoanID,
+		}).Errorf("[LoanService][DeferLoan] Error DeferLoanInTx with err: %v", err)
+		return nil, err
+	}
+
+	return deferral, nil
+}
+
+// buildDeferral shifts the pending bills of a loan from the requested bill onward and spreads the capitalized interest
+// over them
+func buildDeferral(request dto.DeferLoanRequest, loan *models.LoanModel, bills []models.LoanBillModel) ([]models.LoanBillModel, *models.LoanDeferralModel, error) {
+	if loan.Status != models.StatusActive {
+		return nil, nil, errors.New(dto.ErrorLoanIsNotActive)
+	}
+
+	var (
+		deferredBills     []models.LoanBillModel
+		deferredPrincipal int32
+	)
+	for _, bill := range bills {
+		if bill.BillingNumber < request.FromBillingNumber {
+			continue
+		}
+		if bill.Status != models.StatusPending && bill.Status != models.StatusBilled {
+			continue
+		}
+		deferredBills = append(deferredBills, bill)
+		deferredPrincipal += bill.BillingAmount
+	}
+
+	if len(deferredBills) == 0 {
+		return nil, nil, errors.New(dto.ErrorLoanHasNoDeferrableBills)
+	}
+
+	// interest of the holiday follows the loan flat interest, spread over the deferred bills
+	var capitalizedInterest int32
+	if request.CapitalizeInterest && loan.LoanTermsPerWeek > 0 {
+		capitalizedInterest = int32(float64(deferredPrincipal) * loan.InterestPercentage / 100 * float64(request.Periods) / float64(loan.LoanTermsPerWeek))
+	}
+
+	// the holiday starts on the first deferred bill and lasts until its new billing date
+	windowStartDate := deferredBills[0].BillingDate
+	deferredCount := int32(len(deferredBills))
+	interestPerBill := capitalizedInterest / deferredCount
+	for i := range deferredBills {
+		deferredBills[i].BillingDate = deferredBills[i].BillingDate.AddDate(0, 0, int(request.Periods)*7)
+		deferredBills[i].BillingTotalAmount += interestPerBill
+		if int32(i) == deferredCount-1 {
+			deferredBills[i].BillingTotalAmount += capitalizedInterest - interestPerBill*deferredCount
+		}
+	}
+
+	newDueDate := loan.DueDate
+	if lastBillingDate := deferredBills[deferredCount-1].BillingDate; lastBillingDate.After(newDueDate) {
+		newDueDate = lastBillingDate
+	}
+
+	deferral := &models.LoanDeferralModel{
+		LoanID:              loan.ID,
+		Reason:              request.Reason,
+		Periods:             request.Periods,
+		FirstBillingNumber:  deferredBills[0].BillingNumber,
+		DeferredBills:       deferredCount,
+		CapitalizedInterest: capitalizedInterest,
+		PreviousDueDate:     loan.DueDate,
+		NewDueDate:          newDueDate,
+		StartDate:           windowStartDate,
+		EndDate:             deferredBills[0].BillingDate.AddDate(0, 0, -1),
+	}
+
+	return deferredBills, deferral, nil
+}
+
+// WriteOffLoan manually writes off an active loan
+func (l *loanService) WriteOffLoan(ctx context.Context, request dto.WriteOffLoanRequest) (*models.LoanWriteOffModel, error) {
+	logger.GetLogger().Info("[LoanService][WriteOffLoan]")
+
+	// the write-off is built from the loan and bills locked by the transaction, so a payment settled meanwhile is seen
+	writeOff, err := l.loanRepo.WriteOffLoanInTx(ctx, request.LoanID, func(loan *models.LoanModel, bills []models.LoanBillModel) (*models.LoanWriteOffModel, error) {
+		if loan.Status != models.StatusActive {
+			return nil, errors.New(dto.ErrorLoanIsNotActive)
+		}
+		return &models.LoanWriteOffModel{
+			LoanID:           loan.ID,
+			WriteOffType:     models.WriteOffTypeManual,
+			Reason:           request.Reason,
+			WrittenOffAmount: loan.OutstandingAmount,
+			DaysPastDue:      int32(l.daysPastDue(loan, bills)),
+		}, nil
+	})
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"loan_id": request.LoanID,
+		}).Errorf("[LoanService][WriteOffLoan] Error WriteOffLoanInTx with err: %v", err)
+		return nil, err
+	}
+
+	return writeOff, nil
+}
+
+// WriteOffOverdueLoans writes off every active loan that is overdue for more than the configured days past due
+func (l *loanService) WriteOffOverdueLoans(ctx context.Context) (int32, error) {
+	logger.GetLogger().Info("[LoanService][WriteOffOverdueLoans]")
+
+	daysPastDue := models.DefaultWriteOffDaysPastDue
+	daysPastDueConfig, err := l.getConfigByName(ctx, models.ConfigWriteOffDaysPastDue)
+	if err != nil {
+		logger.GetLogger().Errorf("[LoanService][WriteOffOverdueLoans] Error getConfigByName for ConfigWriteOffDaysPastDue with err: %v", err)
+		logger.GetLogger().Info("[LoanService][WriteOffOverdueLoans] Will using default config for ConfigWriteOffDaysPastDue")
+	} else if daysPastDueConfig.IsActive {
+		daysPastDue = int(daysPastDueConfig.Value)
+	}
+
+	// the cutoff is a day early so the loans in the timezones already on the next day are fetched,
+	// the days past due are then counted in the loan timezone
+	now := l.clock.Now()
+	loans, err := l.loanBillRepo.FetchLoansOverdueSince(ctx, now.AddDate(0, 0, -daysPastDue+1))
+	if err != nil {
+		logger.GetLogger().Errorf("[LoanService][WriteOffOverdueLoans] Error FetchLoansOverdueSince with err: %v", err)
+		return 0, err
+	}
+
+	var total int32
+	for _, overdue := range loans {
+		if helpers.DaysBetween(overdue.OldestBillingDate, now.In(helpers.LoadLocation(overdue.Timezone))) < daysPastDue {
+			continue
+		}
+
+		// counted again on the locked loan, a loan paid or settled since it was fetched is left as is
+		writeOff, err := l.loanRepo.WriteOffLoanInTx(ctx, overdue.LoanID, func(loan *models.LoanModel, bills []models.LoanBillModel) (*models.LoanWriteOffModel, error) {
+			loanDaysPastDue := l.daysPastDue(loan, bills)
+			if loan.Status != models.StatusActive || loanDaysPastDue < daysPastDue {
+				return nil, nil
+			}
+			return &models.LoanWriteOffModel{
+				LoanID:           loan.ID,
+				WriteOffType:     models.WriteOffTypeAuto,
+				Reason:           fmt.Sprintf(models.WriteOffReasonDaysPastDue, daysPastDue),
+				WrittenOffAmount: loan.OutstandingAmount,
+				DaysPastDue:      int32(loanDaysPastDue),
+			}, nil
+		})
+		if err != nil {
+			logger.GetLogger().WithFields(logrus.Fields{
+				"loan_id": overdue.LoanID,
+			}).Errorf("[LoanService][WriteOffOverdueLoans] Error WriteOffLoanInTx with err: %v", err)
+			return total, err
+		}
+		if writeOff != nil {
+			total++
+		}
+	}
+
+	return total, nil
+}
+
+// daysPastDue counts the days since the oldest OVERDUE bill of a loan was billed, in the loan timezone
+func (l *loanService) daysPastDue(loan *models.LoanModel, bills []models.LoanBillModel) int {
+	var daysPastDue int
+	now := l.clock.Now().In(helpers.LoadLocation(loan.Timezone))
+	for _, bill := range bills {
+		if bill.Status != models.StatusOverdue {
+			continue
+		}
+		if days := helpers.DaysBetween(bill.BillingDate, now); days > daysPastDue {
+			daysPastDue = days
+		}
+	}
+	return daysPastDue
+}
+
+// GetLoanWriteOff get the write-off of a loan with its recovered amount
+func (l *loanService) GetLoanWriteOff(ctx context.Context, loanID int64) (*models.LoanWriteOffSummary, error) {
+	logger.GetLogger().Info("[LoanService][GetLoanWriteOff]")
+	summary, err := l.loanRepo.GetLoanWriteOffByLoanID(ctx, loanID)
+	if err != nil {
+		logger.GetLogger().Errorf("[LoanService][GetLoanWriteOff] Error GetLoanWriteOffByLoanID with err: %v", err)
+		return nil, err
+	}
+	return summary, nil
+}
+
+// buildLoanBillSchedule splits the amounts into weekly bills starting next monday,
+// the last bill takes the rounding remainder so the bills always add up to the total
+func buildLoanBillSchedule(loanID int64, principal, total, installment, tenor int32, firstBillingNumber int, startDate time.Time) []models.LoanBillModel {
+	bills := make([]models.LoanBillModel, 0, tenor)
+	weeklyAmount := principal / tenor
+	billingDate := startDate
+	for i := int32(0); i < tenor; i++ {
+		billingDate = helpers.GetNextMonday(billingDate)
+		billingAmount := weeklyAmount
+		billingTotalAmount := installment
+		if i == tenor-1 {
+			billingAmount = principal - weeklyAmount*(tenor-1)
+			billingTotalAmount = total - installment*(tenor-1)
+		}
+
+		bills = append(bills, models.LoanBillModel{
+			LoanID:             loanID,
+			BillingDate:        billingDate,
+			BillingAmount:      billingAmount,
+			BillingTotalAmount: billingTotalAmount,
+			BillingNumber:      firstBillingNumber + int(i),
+			Status:             models.StatusPending,
+			CreatedAt:          startDate,
+			UpdatedAt:          startDate,
+		})
+	}
+	return bills
+}
+
 // generateLoanBills generates weekly loan bills based on the loan information
 func (l *loanService) generateLoanBills(ctx context.Context, loan *models.LoanModel, id int64) error {
 	logger.GetLogger().Info("[LoanService][generateLoanBills] Start")
@@ -195,8 +643,8 @@ func (l *loanService) generateLoanBills(ctx context.Context, loan *models.LoanMo
 				BillingTotalAmount: weeklyTotalAmount,
 				BillingNumber:      week,
 				Status:             models.StatusPending, // You can adjust this based on the actual status you want
-				CreatedAt:          time.Now(),
-				UpdatedAt:          time.Now(),
+				CreatedAt:          loan.StartDate,
+				UpdatedAt:          loan.StartDate,
 			}
 
 			// Insert the loan bill into the database
@@ -241,17 +689,29 @@ func (l *loanService) getConfigByName(ctx context.Context, name string) (*billin
 }
 
 type LoanServiceInterface interface {
-	GetAllActiveLoan(ctx context.Context) ([]models.LoanModel, error)
+	GetActiveLoans(ctx context.Context, afterID int64, limit int) ([]models.LoanModel, error)
+	CountOverdueBillsByLoanIDs(ctx context.Context, ids []int64) (map[int64]int32, error)
 	CreateLoan(ctx context.Context, request dto.LoanRequest) error
-	UpdateLoanBill(ctx context.Context) error
+	UpdateLoanBill(ctx context.Context, asOf time.Time) error
+	GetMissedBillingDates(ctx context.Context, asOf time.Time) ([]time.Time, error)
+	PreviewBillingChanges(ctx context.Context, asOf time.Time) ([]models.LoanBillStatusChangeModel, []models.DelinquencyChangeModel, error)
 	CountLoanBillOverdueStatusesByID(ctx context.Context, id int32) (int32, error)
 	GetLoansWithBills(ctx context.Context, userID int) ([]models.LoanWithBills, error)
+	RestructureLoan(ctx context.Context, request dto.RestructureLoanRequest) (*models.LoanRestructureModel, error)
+	DeferLoan(ctx context.Context, request dto.DeferLoanRequest) (*models.LoanDeferralModel, error)
+	WriteOffLoan(ctx context.Context, request dto.WriteOffLoanRequest) (*models.LoanWriteOffModel, error)
+	WriteOffOverdueLoans(ctx context.Context) (int32, error)
+	GetLoanWriteOff(ctx context.Context, loanID int64) (*models.LoanWriteOffSummary, error)
+	GetInterestAccrualStates(ctx context.Context, afterID int64, limit int) ([]models.LoanInterestStateModel, error)
+	AccrueInterest(ctx context.Context, state models.LoanInterestStateModel) (int32, error)
+	GetPayoffQuote(ctx context.Context, loanID int64, payoffDate time.Time) (*models.PayoffQuoteModel, error)
 }
 
-func NewLoanService(loanRepo repositories.LoanRepositoryInterface, loanBillRepo repositories.LoanBillRepositoryInterface, billingConfigRepo billingConfigRepo.BillingConfigRepositoryInterface) LoanServiceInterface {
+func NewLoanService(loanRepo repositories.LoanRepositoryInterface, loanBillRepo repositories.LoanBillRepositoryInterface, billingConfigRepo billingConfigRepo.BillingConfigRepositoryInterface, clk clock.Clock) LoanServiceInterface {
 	return &loanService{
 		loanRepo,
 		loanBillRepo,
 		billingConfigRepo,
+		clk,
 	}
 }
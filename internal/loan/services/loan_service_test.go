@@ -16,6 +16,9 @@ import (
 	loan_mock "github.com/okiww/billing-loan-system/gen/mocks/loan"
 
 	"github.com/okiww/billing-loan-system/internal/loan/models"
+	"github.com/okiww/billing-loan-system/internal/loan/repositories"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestCreateLoan(t *testing.T) {
@@ -29,7 +32,8 @@ func TestCreateLoan(t *testing.T) {
 	mockBillingConfig := billing_config_mock.NewMockBillingConfigRepositoryInterface(ctrl)
 
 	// Initialize the loan service
-	loanService := NewLoanService(mockLoanRepo, mockLoanBillRepo, mockBillingConfig)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(mockLoanRepo, mockLoanBillRepo, mockBillingConfig, clock.Fixed(now))
 
 	// Define test cases using a table-driven approach
 	tests := []struct {
@@ -167,8 +171,10 @@ func TestGenerateLoanBills(t *testing.T) {
 	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
 
 	// Initialize the loan service
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
 	loanService := &loanService{
 		loanBillRepo: mockLoanBillRepo,
+		clock:        clock.Fixed(now),
 	}
 
 	// Define test cases using a table-driven approach
@@ -185,7 +191,7 @@ func TestGenerateLoanBills(t *testing.T) {
 				LoanAmount:       10000,
 				LoanTotalAmount:  11000,
 				LoanTermsPerWeek: 4,
-				StartDate:        time.Now(),
+				StartDate:        now,
 			},
 			setup: func() {
 				// Mock the loanBill repository to return no error for creating bills
@@ -200,7 +206,7 @@ func TestGenerateLoanBills(t *testing.T) {
 				LoanAmount:       10000,
 				LoanTotalAmount:  11000,
 				LoanTermsPerWeek: 4,
-				StartDate:        time.Now(),
+				StartDate:        now,
 			},
 			setup: func() {
 				// Mock the loanBill repository to return an error for the first bill
@@ -227,8 +233,12 @@ func TestUpdateLoanBill(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
 	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
-	loanService := NewLoanService(nil, mockLoanBillRepo, nil)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(mockLoanRepo, mockLoanBillRepo, nil, clock.Fixed(now))
+
+	asOf := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
 
 	tests := []struct {
 		name          string
@@ -238,18 +248,52 @@ func TestUpdateLoanBill(t *testing.T) {
 		{
 			name: "Success updating loan bill statuses",
 			setupMocks: func() {
+				mockLoanRepo.EXPECT().FetchActiveLoanTimezones(gomock.Any()).Return([]string{"Asia/Jakarta"}, nil)
+				mockLoanBillRepo.EXPECT().
+					UpdateLoanBillStatuses(gomock.Any(), asOf, "Asia/Jakarta").
+					Return(nil)
 				mockLoanBillRepo.EXPECT().
-					UpdateLoanBillStatuses(gomock.Any()).
+					SaveBillingRun(gomock.Any(), &models.BillingRunModel{RunDate: asOf, Status: models.BillingRunStatusSuccess}).
 					Return(nil)
 			},
 			expectedError: nil,
 		},
+		{
+			name: "Timezone not yet on the billing date is evaluated on its own today",
+			setupMocks: func() {
+				mockLoanRepo.EXPECT().FetchActiveLoanTimezones(gomock.Any()).Return([]string{"Pacific/Kiritimati", "Pacific/Pago_Pago"}, nil)
+				mockLoanBillRepo.EXPECT().
+					UpdateLoanBillStatuses(gomock.Any(), asOf, "Pacific/Kiritimati").
+					Return(nil)
+				mockLoanBillRepo.EXPECT().
+					UpdateLoanBillStatuses(gomock.Any(), asOf.AddDate(0, 0, -1), "Pacific/Pago_Pago").
+					Return(nil)
+				mockLoanBillRepo.EXPECT().
+					SaveBillingRun(gomock.Any(), &models.BillingRunModel{RunDate: asOf, Status: models.BillingRunStatusSuccess}).
+					Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name: "Error fetching loan timezones",
+			setupMocks: func() {
+				mockLoanRepo.EXPECT().FetchActiveLoanTimezones(gomock.Any()).Return(nil, errors.New("db error"))
+				mockLoanBillRepo.EXPECT().
+					SaveBillingRun(gomock.Any(), &models.BillingRunModel{RunDate: asOf, Status: models.BillingRunStatusFailed, Error: "db error"}).
+					Return(nil)
+			},
+			expectedError: errors.New("db error"),
+		},
 		{
 			name: "Error updating loan bill statuses",
 			setupMocks: func() {
+				mockLoanRepo.EXPECT().FetchActiveLoanTimezones(gomock.Any()).Return([]string{"Asia/Jakarta"}, nil)
 				mockLoanBillRepo.EXPECT().
-					UpdateLoanBillStatuses(gomock.Any()).
+					UpdateLoanBillStatuses(gomock.Any(), asOf, "Asia/Jakarta").
 					Return(errors.New("update failed"))
+				mockLoanBillRepo.EXPECT().
+					SaveBillingRun(gomock.Any(), &models.BillingRunModel{RunDate: asOf, Status: models.BillingRunStatusFailed, Error: "update failed"}).
+					Return(nil)
 			},
 			expectedError: errors.New("update failed"),
 		},
@@ -259,11 +303,202 @@ func TestUpdateLoanBill(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setupMocks()
 
-			err := loanService.UpdateLoanBill(context.Background())
+			err := loanService.UpdateLoanBill(context.Background(), asOf)
+
+			if (err != nil && tt.expectedError == nil) || (err == nil && tt.expectedError != nil) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestGetMissedBillingDates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(nil, mockLoanBillRepo, nil, clock.Fixed(now))
+
+	asOf := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+	lastRunDate := time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		setupMocks    func()
+		expectedDates []time.Time
+		expectedError error
+	}{
+		{
+			name: "Catch up missed dates in order",
+			setupMocks: func() {
+				mockLoanBillRepo.EXPECT().GetLastBillingRunDate(gomock.Any()).Return(&lastRunDate, nil)
+			},
+			expectedDates: []time.Time{
+				time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 12, 22, 0, 0, 0, 0, time.UTC),
+				asOf,
+			},
+		},
+		{
+			name: "Already processed",
+			setupMocks: func() {
+				mockLoanBillRepo.EXPECT().GetLastBillingRunDate(gomock.Any()).Return(&asOf, nil)
+			},
+			expectedDates: nil,
+		},
+		{
+			name: "Never ran",
+			setupMocks: func() {
+				mockLoanBillRepo.EXPECT().GetLastBillingRunDate(gomock.Any()).Return(nil, nil)
+			},
+			expectedDates: []time.Time{asOf},
+		},
+		{
+			name: "Error get last billing run",
+			setupMocks: func() {
+				mockLoanBillRepo.EXPECT().GetLastBillingRunDate(gomock.Any()).Return(nil, errors.New("db error"))
+			},
+			expectedError: errors.New("db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMocks()
+
+			dates, err := loanService.GetMissedBillingDates(context.Background(), asOf)
+			if (err != nil && tt.expectedError == nil) || (err == nil && tt.expectedError != nil) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+				return
+			}
+
+			assert.Equal(t, tt.expectedDates, dates)
+		})
+	}
+}
+
+func TestPreviewBillingChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(mockLoanRepo, mockLoanBillRepo, nil, clock.Fixed(now))
+
+	asOf := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+	bills := []models.LoanBillStatusChangeModel{{ID: 1, LoanID: 2, UserID: 3, FromStatus: "BILLED", ToStatus: "OVERDUE"}}
+	loans := []models.DelinquencyChangeModel{{LoanID: 2, UserID: 3, OverdueCount: 2}}
+
+	tests := []struct {
+		name          string
+		setupMocks    func()
+		expectedBills []models.LoanBillStatusChangeModel
+		expectedLoans []models.DelinquencyChangeModel
+		expectedError error
+	}{
+		{
+			name: "Success",
+			setupMocks: func() {
+				mockLoanRepo.EXPECT().FetchActiveLoanTimezones(gomock.Any()).Return([]string{"Asia/Jakarta"}, nil)
+				mockLoanBillRepo.EXPECT().PreviewLoanBillStatuses(gomock.Any(), asOf, "Asia/Jakarta").Return(bills, nil)
+				mockLoanBillRepo.EXPECT().PreviewDelinquentLoans(gomock.Any(), asOf, "Asia/Jakarta").Return(loans, nil)
+			},
+			expectedBills: bills,
+			expectedLoans: loans,
+		},
+		{
+			name: "Error fetch loan timezones",
+			setupMocks: func() {
+				mockLoanRepo.EXPECT().FetchActiveLoanTimezones(gomock.Any()).Return(nil, errors.New("db error"))
+			},
+			expectedError: errors.New("db error"),
+		},
+		{
+			name: "Error preview bill statuses",
+			setupMocks: func() {
+				mockLoanRepo.EXPECT().FetchActiveLoanTimezones(gomock.Any()).Return([]string{"Asia/Jakarta"}, nil)
+				mockLoanBillRepo.EXPECT().PreviewLoanBillStatuses(gomock.Any(), asOf, "Asia/Jakarta").Return(nil, errors.New("db error"))
+			},
+			expectedError: errors.New("db error"),
+		},
+		{
+			name: "Error preview delinquent loans",
+			setupMocks: func() {
+				mockLoanRepo.EXPECT().FetchActiveLoanTimezones(gomock.Any()).Return([]string{"Asia/Jakarta"}, nil)
+				mockLoanBillRepo.EXPECT().PreviewLoanBillStatuses(gomock.Any(), asOf, "Asia/Jakarta").Return(bills, nil)
+				mockLoanBillRepo.EXPECT().PreviewDelinquentLoans(gomock.Any(), asOf, "Asia/Jakarta").Return(nil, errors.New("db error"))
+			},
+			expectedError: errors.New("db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMocks()
+
+			gotBills, gotLoans, err := loanService.PreviewBillingChanges(context.Background(), asOf)
+			if (err != nil && tt.expectedError == nil) || (err == nil && tt.expectedError != nil) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+				return
+			}
+
+			assert.Equal(t, tt.expectedBills, gotBills)
+			assert.Equal(t, tt.expectedLoans, gotLoans)
+		})
+	}
+}
+
+func TestCountOverdueBillsByLoanIDs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(nil, mockLoanBillRepo, nil, clock.Fixed(now))
+
+	tests := []struct {
+		name           string
+		ids            []int64
+		setupMocks     func()
+		expectedCounts map[int64]int32
+		expectedError  error
+	}{
+		{
+			name: "Success",
+			ids:  []int64{1, 2},
+			setupMocks: func() {
+				mockLoanBillRepo.EXPECT().GetTotalLoanBillOverdueByLoanIDs(gomock.Any(), []int64{1, 2}).Return(map[int64]int32{1: 2}, nil)
+			},
+			expectedCounts: map[int64]int32{1: 2},
+		},
+		{
+			name:           "Empty page",
+			setupMocks:     func() {},
+			expectedCounts: map[int64]int32{},
+		},
+		{
+			name: "Error count overdue bills",
+			ids:  []int64{1},
+			setupMocks: func() {
+				mockLoanBillRepo.EXPECT().GetTotalLoanBillOverdueByLoanIDs(gomock.Any(), []int64{1}).Return(nil, errors.New("db error"))
+			},
+			expectedError: errors.New("db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMocks()
 
+			counts, err := loanService.CountOverdueBillsByLoanIDs(context.Background(), tt.ids)
 			if (err != nil && tt.expectedError == nil) || (err == nil && tt.expectedError != nil) {
 				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+				return
 			}
+
+			assert.Equal(t, tt.expectedCounts, counts)
 		})
 	}
 }
@@ -273,7 +508,8 @@ func TestCountLoanBillOverdueStatusesByID(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
-	loanService := NewLoanService(nil, mockLoanBillRepo, nil)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(nil, mockLoanBillRepo, nil, clock.Fixed(now))
 
 	tests := []struct {
 		name          string
@@ -322,3 +558,495 @@ func TestCountLoanBillOverdueStatusesByID(t *testing.T) {
 		})
 	}
 }
+
+func TestRestructureLoan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(mockLoanRepo, mockLoanBillRepo, nil, clock.Fixed(now))
+
+	newInterest := float64(0)
+	activeLoan := func() *models.LoanModel {
+		return &models.LoanModel{
+			ID:                 1,
+			LoanAmount:         4000,
+			LoanTotalAmount:    4400,
+			OutstandingAmount:  3300,
+			InterestPercentage: 10,
+			Status:             models.StatusActive,
+			LoanTermsPerWeek:   4,
+		}
+	}
+	loanBills := []models.LoanBillModel{
+		{ID: 1, LoanID: 1, BillingAmount: 1000, BillingTotalAmount: 1100, BillingNumber: 1, Status: models.StatusPaid},
+		{ID: 2, LoanID: 1, BillingAmount: 1000, BillingTotalAmount: 1100, BillingNumber: 2, Status: models.StatusOverdue},
+		{ID: 3, LoanID: 1, BillingAmount: 1000, BillingTotalAmount: 1100, BillingNumber: 3, Status: models.StatusBilled},
+		{ID: 4, LoanID: 1, BillingAmount: 1000, BillingTotalAmount: 1100, BillingNumber: 4, Status: models.StatusPending},
+	}
+
+	// restructureWith runs the schedule builder against the loan and bills locked by the transaction
+	restructureWith := func(loan *models.LoanModel, bills []models.LoanBillModel, check func(loan *models.LoanModel, newBills []models.LoanBillModel)) {
+		mockLoanRepo.EXPECT().
+			RestructureLoanInTx(gomock.Any(), int64(1), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, build repositories.RestructureFunc) (*models.LoanRestructureModel, error) {
+				newBills, restructure, err := build(loan, bills)
+				if err != nil {
+					return nil, err
+				}
+				check(loan, newBills)
+				return restructure, nil
+			})
+	}
+
+	tests := []struct {
+		name        string
+		request     dto.RestructureLoanRequest
+		setupMocks  func()
+		check       func(t *testing.T, restructure *models.LoanRestructureModel)
+		expectedErr error
+	}{
+		{
+			name:    "Success - Extend Tenor",
+			request: dto.RestructureLoanRequest{LoanID: 1, Reason: "hardship", Tenor: 6},
+			setupMocks: func() {
+				restructureWith(activeLoan(), loanBills, func(loan *models.LoanModel, bills []models.LoanBillModel) {
+					if len(bills) != 6 || bills[0].BillingNumber != 5 {
+						t.Errorf("unexpected new schedule %+v", bills)
+					}
+
+					var total int32
+					for _, bill := range bills {
+						total += bill.BillingTotalAmount
+					}
+					if total != 3300 || loan.OutstandingAmount != 3300 {
+						t.Errorf("expected new schedule to add up to 3300, got %d", total)
+					}
+
+					if loan.LoanTermsPerWeek != 7 || !loan.DueDate.Equal(bills[5].BillingDate) {
+						t.Errorf("unexpected loan update %+v", loan)
+					}
+				})
+			},
+			check: func(t *testing.T, restructure *models.LoanRestructureModel) {
+				if restructure.NewTerms != 6 || restructure.InstallmentAmount != 550 {
+					t.Errorf("unexpected restructure %+v", restructure)
+				}
+			},
+		},
+		{
+			name:    "Success - Installment Amount With New Interest",
+			request: dto.RestructureLoanRequest{LoanID: 1, Reason: "hardship", InstallmentAmount: 700, InterestPercentage: &newInterest},
+			setupMocks: func() {
+				restructureWith(activeLoan(), loanBills, func(loan *models.LoanModel, bills []models.LoanBillModel) {
+					if len(bills) != 5 || bills[4].BillingTotalAmount != 200 {
+						t.Errorf("unexpected new schedule %+v", bills)
+					}
+					if loan.LoanTotalAmount != 4100 || loan.InterestPercentage != 0 {
+						t.Errorf("unexpected loan update %+v", loan)
+					}
+				})
+			},
+			check: func(t *testing.T, restructure *models.LoanRestructureModel) {
+				if restructure.NewOutstandingAmount != 3000 || restructure.PreviousOutstandingAmount != 3300 {
+					t.Errorf("unexpected restructure %+v", restructure)
+				}
+			},
+		},
+		{
+			name:    "Success - Bill Paid Before The Lock Is Left Out",
+			request: dto.RestructureLoanRequest{LoanID: 1, Reason: "hardship", Tenor: 2},
+			setupMocks: func() {
+				loan := activeLoan()
+				loan.OutstandingAmount = 2200
+				paid := append([]models.LoanBillModel{}, loanBills...)
+				paid[1].Status = models.StatusPaid
+				restructureWith(loan, paid, func(loan *models.LoanModel, bills []models.LoanBillModel) {
+					var principal int32
+					for _, bill := range bills {
+						principal += bill.BillingAmount
+					}
+					if len(bills) != 2 || principal != 2000 || loan.LoanTermsPerWeek != 4 {
+						t.Errorf("unexpected new schedule %+v", bills)
+					}
+				})
+			},
+			check: func(t *testing.T, restructure *models.LoanRestructureModel) {
+				if restructure.NewOutstandingAmount != 2200 {
+					t.Errorf("unexpected restructure %+v", restructure)
+				}
+			},
+		},
+		{
+			name:    "Error - Loan Not Active",
+			request: dto.RestructureLoanRequest{LoanID: 1, Reason: "hardship", Tenor: 6},
+			setupMocks: func() {
+				restructureWith(&models.LoanModel{ID: 1, Status: models.StatusClosed}, loanBills, nil)
+			},
+			expectedErr: errors.New(dto.ErrorLoanIsNotActive),
+		},
+		{
+			name:    "Error - No Unpaid Bills",
+			request: dto.RestructureLoanRequest{LoanID: 1, Reason: "hardship", Tenor: 6},
+			setupMocks: func() {
+				restructureWith(activeLoan(), loanBills[:1], nil)
+			},
+			expectedErr: errors.New(dto.ErrorLoanHasNoUnpaidBills),
+		},
+		{
+			name:    "Error - Restructure Failed",
+			request: dto.RestructureLoanRequest{LoanID: 1, Reason: "hardship", Tenor: 6},
+			setupMocks: func() {
+				mockLoanRepo.EXPECT().
+					RestructureLoanInTx(gomock.Any(), int64(1), gomock.Any()).
+					Return(nil, errors.New("db error"))
+			},
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMocks()
+
+			restructure, err := loanService.RestructureLoan(context.Background(), tt.request)
+			if (err != nil && tt.expectedErr == nil) || (err == nil && tt.expectedErr != nil) || (err != nil && err.Error() != tt.expectedErr.Error()) {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+				return
+			}
+
+			if tt.check != nil {
+				tt.check(t, restructure)
+			}
+		})
+	}
+}
+
+func TestDeferLoan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(mockLoanRepo, mockLoanBillRepo, nil, clock.Fixed(now))
+
+	nextMonday := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+	dueDate := nextMonday.AddDate(0, 0, 7)
+	loan := func() *models.LoanModel {
+		return &models.LoanModel{ID: 1, LoanTotalAmount: 4400, OutstandingAmount: 2200, InterestPercentage: 10, LoanTermsPerWeek: 4, DueDate: dueDate, Status: models.StatusActive}
+	}
+	bills := func() []models.LoanBillModel {
+		return []models.LoanBillModel{
+			{ID: 1, BillingNumber: 1, BillingAmount: 1000, BillingTotalAmount: 1100, Status: models.StatusPaid, BillingDate: nextMonday.AddDate(0, 0, -14)},
+			{ID: 2, BillingNumber: 2, BillingAmount: 1000, BillingTotalAmount: 1100, Status: models.StatusPaid, BillingDate: nextMonday.AddDate(0, 0, -7)},
+			{ID: 3, BillingNumber: 3, BillingAmount: 1000, BillingTotalAmount: 1100, Status: models.StatusPending, BillingDate: nextMonday},
+			{ID: 4, BillingNumber: 4, BillingAmount: 1000, BillingTotalAmount: 1100, Status: models.StatusPending, BillingDate: dueDate},
+		}
+	}
+
+	// deferWith runs the deferral builder against the loan and bills locked by the transaction
+	deferWith := func(loan *models.LoanModel, bills []models.LoanBillModel, check func(deferredBills []models.LoanBillModel)) {
+		mockLoanRepo.EXPECT().
+			DeferLoanInTx(gomock.Any(), int64(1), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ int64, build repositories.DeferFunc) (*models.LoanDeferralModel, error) {
+				deferredBills, deferral, err := build(loan, bills)
+				if err != nil {
+					return nil, err
+				}
+				if check != nil {
+					check(deferredBills)
+				}
+				return deferral, nil
+			})
+	}
+
+	tests := []struct {
+		name        string
+		request     dto.DeferLoanRequest
+		setupMocks  func()
+		expectedErr error
+		check       func(t *testing.T, deferral *models.LoanDeferralModel)
+	}{
+		{
+			name:    "Success - Capitalize Interest",
+			request: dto.DeferLoanRequest{LoanID: 1, Reason: "flood", Periods: 2, CapitalizeInterest: true},
+			setupMocks: func() {
+				deferWith(loan(), bills(), func(deferredBills []models.LoanBillModel) {
+					assert.Len(t, deferredBills, 2)
+					assert.Equal(t, nextMonday.AddDate(0, 0, 14), deferredBills[0].BillingDate)
+					assert.Equal(t, int32(1150), deferredBills[0].BillingTotalAmount)
+					assert.Equal(t, int32(1150), deferredBills[1].BillingTotalAmount)
+				})
+			},
+			check: func(t *testing.T, deferral *models.LoanDeferralModel) {
+				// 2000 principal * 10% * 2 weeks / 4 weeks of the loan, added to the loan by the transaction
+				assert.Equal(t, 3, deferral.FirstBillingNumber)
+				assert.Equal(t, int32(2), deferral.DeferredBills)
+				assert.Equal(t, int32(100), deferral.CapitalizedInterest)
+				assert.Equal(t, dueDate.AddDate(0, 0, 14), deferral.NewDueDate)
+				assert.Equal(t, nextMonday, deferral.StartDate)
+				assert.Equal(t, nextMonday.AddDate(0, 0, 13), deferral.EndDate)
+			},
+		},
+		{
+			name:    "Success - From Billing Number",
+			request: dto.DeferLoanRequest{LoanID: 1, Reason: "harvest season", Periods: 1, FromBillingNumber: 4},
+			setupMocks: func() {
+				deferWith(loan(), bills(), nil)
+			},
+			check: func(t *testing.T, deferral *models.LoanDeferralModel) {
+				assert.Equal(t, 4, deferral.FirstBillingNumber)
+				assert.Equal(t, int32(1), deferral.DeferredBills)
+				assert.Equal(t, int32(0), deferral.CapitalizedInterest)
+				assert.Equal(t, dueDate.AddDate(0, 0, 7), deferral.NewDueDate)
+			},
+		},
+		{
+			name:    "Error - No Deferrable Bills",
+			request: dto.DeferLoanRequest{LoanID: 1, Reason: "flood", Periods: 2, FromBillingNumber: 5},
+			setupMocks: func() {
+				deferWith(loan(), bills(), nil)
+			},
+			expectedErr: errors.New(dto.ErrorLoanHasNoDeferrableBills),
+		},
+		{
+			name:    "Error - Loan Not Active",
+			request: dto.DeferLoanRequest{LoanID: 1, Reason: "flood", Periods: 2},
+			setupMocks: func() {
+				deferWith(&models.LoanModel{ID: 1, Status: models.StatusClosed}, bills(), nil)
+			},
+			expectedErr: errors.New(dto.ErrorLoanIsNotActive),
+		},
+		{
+			name:    "Error - Bill Paid Before The Lock Is Left Out",
+			request: dto.DeferLoanRequest{LoanID: 1, Reason: "flood", Periods: 2, FromBillingNumber: 4},
+			setupMocks: func() {
+				paid := bills()
+				paid[3].Status = models.StatusPaid
+				deferWith(loan(), paid, nil)
+			},
+			expectedErr: errors.New(dto.ErrorLoanHasNoDeferrableBills),
+		},
+		{
+			name:    "Error - DeferLoanInTx",
+			request: dto.DeferLoanRequest{LoanID: 1, Reason: "flood", Periods: 2},
+			setupMocks: func() {
+				mockLoanRepo.EXPECT().
+					DeferLoanInTx(gomock.Any(), int64(1), gomock.Any()).
+					Return(nil, errors.New("db error"))
+			},
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMocks()
+
+			deferral, err := loanService.DeferLoan(context.Background(), tt.request)
+			if (err != nil && tt.expectedErr == nil) || (err == nil && tt.expectedErr != nil) || (err != nil && err.Error() != tt.expectedErr.Error()) {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+				return
+			}
+
+			if tt.check != nil {
+				tt.check(t, deferral)
+			}
+		})
+	}
+}
+
+// expectWriteOff runs the write-off builder against the loan and bills locked by the transaction and checks the
+// write-off it built, nil when the loan is left as is
+func expectWriteOff(t *testing.T, mockLoanRepo *loan_mock.MockLoanRepositoryInterface, loan *models.LoanModel, bills []models.LoanBillModel, want *models.LoanWriteOffModel) {
+	mockLoanRepo.EXPECT().
+		WriteOffLoanInTx(gomock.Any(), loan.ID, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ int64, build repositories.WriteOffFunc) (*models.LoanWriteOffModel, error) {
+			writeOff, err := build(loan, bills)
+			if err != nil {
+				return nil, err
+			}
+			assert.Equal(t, want, writeOff)
+			return writeOff, nil
+		})
+}
+
+func TestWriteOffLoan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(mockLoanRepo, mockLoanBillRepo, nil, clock.Fixed(now))
+	bills := []models.LoanBillModel{
+		{Status: models.StatusOverdue, BillingDate: now.AddDate(0, 0, -14)},
+		{Status: models.StatusOverdue, BillingDate: now.AddDate(0, 0, -7)},
+		{Status: models.StatusBilled, BillingDate: now},
+	}
+
+	tests := []struct {
+		name        string
+		request     dto.WriteOffLoanRequest
+		setupMocks  func()
+		expectedErr error
+	}{
+		{
+			name:    "Success",
+			request: dto.WriteOffLoanRequest{LoanID: 1, Reason: "deceased"},
+			setupMocks: func() {
+				expectWriteOff(t, mockLoanRepo, &models.LoanModel{ID: 1, OutstandingAmount: 2200, Status: models.StatusActive}, bills, &models.LoanWriteOffModel{
+					LoanID:           1,
+					WriteOffType:     models.WriteOffTypeManual,
+					Reason:           "deceased",
+					WrittenOffAmount: 2200,
+					DaysPastDue:      14,
+				})
+			},
+		},
+		{
+			name:    "Error - Loan Not Active",
+			request: dto.WriteOffLoanRequest{LoanID: 1, Reason: "deceased"},
+			setupMocks: func() {
+				expectWriteOff(t, mockLoanRepo, &models.LoanModel{ID: 1, Status: models.StatusWrittenOff}, bills, nil)
+			},
+			expectedErr: errors.New(dto.ErrorLoanIsNotActive),
+		},
+		{
+			name:    "Error - WriteOffLoanInTx",
+			request: dto.WriteOffLoanRequest{LoanID: 1, Reason: "deceased"},
+			setupMocks: func() {
+				mockLoanRepo.EXPECT().WriteOffLoanInTx(gomock.Any(), int64(1), gomock.Any()).Return(nil, errors.New("db error"))
+			},
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMocks()
+
+			_, err := loanService.WriteOffLoan(context.Background(), tt.request)
+			if (err != nil && tt.expectedErr == nil) || (err == nil && tt.expectedErr != nil) || (err != nil && err.Error() != tt.expectedErr.Error()) {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestWriteOffOverdueLoans(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
+	mockBillingConfig := billing_config_mock.NewMockBillingConfigRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	loanService := NewLoanService(mockLoanRepo, mockLoanBillRepo, mockBillingConfig, clock.Fixed(now))
+	config := func() {
+		mockBillingConfig.EXPECT().
+			GetBillingConfigByName(gomock.Any(), models.ConfigWriteOffDaysPastDue).
+			Return(&models2.BillingConfig{Name: models.ConfigWriteOffDaysPastDue, Value: `{"is_active":true,"value":30}`}, nil)
+	}
+	overdueSince := func(billingDate time.Time) []models.LoanBillModel {
+		return []models.LoanBillModel{{Status: models.StatusOverdue, BillingDate: billingDate}}
+	}
+
+	tests := []struct {
+		name          string
+		setupMocks    func()
+		expectedTotal int32
+		expectedErr   error
+	}{
+		{
+			name: "Success - Write Off Using Config",
+			setupMocks: func() {
+				config()
+				mockLoanBillRepo.EXPECT().FetchLoansOverdueSince(gomock.Any(), gomock.Any()).
+					Return([]models.LoanOverdueModel{
+						{LoanID: 1, UserID: 1, OldestBillingDate: now.AddDate(0, 0, -35)},
+						{LoanID: 2, UserID: 2, OldestBillingDate: now.AddDate(0, 0, -42)},
+					}, nil)
+				expectWriteOff(t, mockLoanRepo, &models.LoanModel{ID: 1, OutstandingAmount: 1000, Status: models.StatusActive}, overdueSince(now.AddDate(0, 0, -35)), &models.LoanWriteOffModel{
+					LoanID:           1,
+					WriteOffType:     models.WriteOffTypeAuto,
+					Reason:           "overdue for more than 30 days",
+					WrittenOffAmount: 1000,
+					DaysPastDue:      35,
+				})
+				expectWriteOff(t, mockLoanRepo, &models.LoanModel{ID: 2, OutstandingAmount: 2000, Status: models.StatusActive}, overdueSince(now.AddDate(0, 0, -42)), &models.LoanWriteOffModel{
+					LoanID:           2,
+					WriteOffType:     models.WriteOffTypeAuto,
+					Reason:           "overdue for more than 30 days",
+					WrittenOffAmount: 2000,
+					DaysPastDue:      42,
+				})
+			},
+			expectedTotal: 2,
+		},
+		{
+			name: "Success - Days Past Due In Loan Timezone",
+			setupMocks: func() {
+				config()
+				mockLoanBillRepo.EXPECT().FetchLoansOverdueSince(gomock.Any(), now.AddDate(0, 0, -29)).
+					Return([]models.LoanOverdueModel{
+						{LoanID: 1, UserID: 1, OldestBillingDate: time.Date(2024, 11, 24, 0, 0, 0, 0, time.UTC), Timezone: "America/New_York"},
+						{LoanID: 2, UserID: 2, OldestBillingDate: time.Date(2024, 11, 24, 0, 0, 0, 0, time.UTC), Timezone: "Pacific/Kiritimati"},
+					}, nil)
+				loan := &models.LoanModel{ID: 2, OutstandingAmount: 2000, Status: models.StatusActive, Timezone: "Pacific/Kiritimati"}
+				expectWriteOff(t, mockLoanRepo, loan, overdueSince(time.Date(2024, 11, 24, 0, 0, 0, 0, time.UTC)), &models.LoanWriteOffModel{
+					LoanID:           2,
+					WriteOffType:     models.WriteOffTypeAuto,
+					Reason:           "overdue for more than 30 days",
+					WrittenOffAmount: 2000,
+					DaysPastDue:      30,
+				})
+			},
+			expectedTotal: 1,
+		},
+		{
+			name: "Skip - Loan Paid Or Settled Since Fetched",
+			setupMocks: func() {
+				config()
+				mockLoanBillRepo.EXPECT().FetchLoansOverdueSince(gomock.Any(), gomock.Any()).
+					Return([]models.LoanOverdueModel{
+						{LoanID: 1, UserID: 1, OldestBillingDate: now.AddDate(0, 0, -35)},
+						{LoanID: 2, UserID: 2, OldestBillingDate: now.AddDate(0, 0, -42)},
+					}, nil)
+				// the oldest overdue bill of loan 1 was paid, loan 2 was closed before the lock
+				expectWriteOff(t, mockLoanRepo, &models.LoanModel{ID: 1, OutstandingAmount: 1000, Status: models.StatusActive}, overdueSince(now.AddDate(0, 0, -7)), nil)
+				expectWriteOff(t, mockLoanRepo, &models.LoanModel{ID: 2, Status: models.StatusClosed}, nil, nil)
+			},
+			expectedTotal: 0,
+		},
+		{
+			name: "Error - Fetch Overdue Loans With Default Config",
+			setupMocks: func() {
+				mockBillingConfig.EXPECT().
+					GetBillingConfigByName(gomock.Any(), models.ConfigWriteOffDaysPastDue).
+					Return(nil, errors.New("no config found"))
+				mockLoanBillRepo.EXPECT().FetchLoansOverdueSince(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("db error"))
+			},
+			expectedErr: errors.New("db error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setupMocks()
+
+			total, err := loanService.WriteOffOverdueLoans(context.Background())
+			if total != tt.expectedTotal {
+				t.Errorf("expected total %d, got %d", tt.expectedTotal, total)
+			}
+
+			if (err != nil && tt.expectedErr == nil) || (err == nil && tt.expectedErr != nil) || (err != nil && err.Error() != tt.expectedErr.Error()) {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// MandateModel represents the `mandates` table, an authorization to charge the bills of a loan automatically
+type MandateModel struct {
+	ID                 int64      `db:"id" json:"id"`
+	UserID             int64      `db:"user_id" json:"user_id"`
+	LoanID             int64      `db:"loan_id" json:"loan_id"`
+	PaymentMethodToken string     `db:"payment_method_token" json:"-"` // Token of the payment provider, never returned
+	MaxAmount          int32      `db:"max_amount" json:"max_amount"`  // Bills above this amount are not charged
+	Status             string     `db:"status" json:"status"`          // e.g., 'ACTIVE', 'PAUSED', 'REVOKED'
+	CreatedAt          time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt          *time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// MandateDebitModel represents the `mandate_debits` table, the debit of a bill through a mandate
+type MandateDebitModel struct {
+	ID            int64      `db:"id" json:"id"`
+	MandateID     int64      `db:"mandate_id" json:"mandate_id"`
+	LoanBillID    int64      `db:"loan_bill_id" json:"loan_bill_id"`
+	PaymentID     *int64     `db:"payment_id" json:"payment_id"` // Last payment created for the bill
+	Status        string     `db:"status" json:"status"`         // e.g., 'SUBMITTED', 'RETRYING', 'FAILED'
+	Attempts      int32      `db:"attempts" json:"attempts"`
+	NextAttemptAt *time.Time `db:"next_attempt_at" json:"next_attempt_at"`
+	LastError     *string    `db:"last_error" json:"last_error"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt     *time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// DueDebitModel is a BILLED bill of a loan with an active mandate which is due to be charged, with its previous debit
+type DueDebitModel struct {
+	MandateID     int64   `db:"mandate_id"`
+	UserID        int64   `db:"user_id"`
+	LoanID        int64   `db:"loan_id"`
+	MaxAmount     int32   `db:"max_amount"`
+	LoanBillID    int64   `db:"loan_bill_id"`
+	AmountDue     int32   `db:"amount_due"`
+	Attempts      int32   `db:"attempts"`
+	PaymentID     *int64  `db:"payment_id"`
+	PaymentStatus *string `db:"payment_status"` // Status of the last payment, FAILED when the worker couldn't process it
+}
+
+const (
+	MandateStatusActive  = "ACTIVE"
+	MandateStatusPaused  = "PAUSED"
+	MandateStatusRevoked = "REVOKED"
+
+	DebitStatusSubmitted = "SUBMITTED"
+	DebitStatusRetrying  = "RETRYING"
+	DebitStatusFailed    = "FAILED"
+
+	DebitErrorExceedsMaxAmount = "bill amount exceeds the mandate max amount"
+	DebitErrorPaymentFailed    = "payment failed to process"
+)
+
+// DefaultRetryAfterHours is used when no retry schedule is configured, a failed debit is retried 1, 6 and 24 hours later
+var DefaultRetryAfterHours = []int{1, 6, 24}
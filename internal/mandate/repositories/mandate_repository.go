@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/okiww/billing-loan-system/helpers"
+	"github.com/okiww/billing-loan-system/internal/mandate/models"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	repo     MandateRepositoryInterface
+	repoLock sync.Once
+)
+
+type mandateRepository struct {
+	*mysql.DBMySQL
+}
+
+// CreateMandate saves a new mandate
+func (m *mandateRepository) CreateMandate(ctx context.Context, mandate *models.MandateModel) (int64, error) {
+	query := `
+		INSERT INTO mandates (user_id, loan_id, payment_method_token, max_amount, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	result, err := m.DB.ExecContext(ctx, query, mandate.UserID, mandate.LoanID, mandate.PaymentMethodToken,
+		mandate.MaxAmount, mandate.Status, m.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetMandateByID retrieves a mandate by its ID
+func (m *mandateRepository) GetMandateByID(ctx context.Context, id int64) (*models.MandateModel, error) {
+	query := `
+		SELECT id, user_id, loan_id, payment_method_token, max_amount, status, created_at, updated_at
+		FROM mandates
+		WHERE id = ?
+	`
+	var mandate models.MandateModel
+	err := m.DB.GetContext(ctx, &mandate, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("no mandate found with id %d", id)
+		}
+		return nil, err
+	}
+	return &mandate, nil
+}
+
+// HasMandate checks if a loan has a mandate which is not revoked
+func (m *mandateRepository) HasMandate(ctx context.Context, loanID int64) (bool, error) {
+	query := `
+		SELECT COUNT(*) FROM mandates WHERE loan_id = ? AND status != 'REVOKED'
+	`
+	var total int
+	err := m.DB.GetContext(ctx, &total, query, loanID)
+	if err != nil {
+		return false, err
+	}
+	return total > 0, nil
+}
+
+// UpdateMandateStatus pauses, resumes or revokes a mandate
+func (m *mandateRepository) UpdateMandateStatus(ctx context.Context, id int64, status string) error {
+	query := `
+		UPDATE mandates SET status = ?, updated_at = ? WHERE id = ?
+	`
+	_, err := m.DB.ExecContext(ctx, query, status, m.Now(), id)
+	return err
+}
+
+// FetchDueDebits get the BILLED bills of active loans with an active mandate which were never debited, which retry is
+// due or which last payment failed. Bills with a payment in flight or completed are left out so a bill is never
+// charged twice, the bills are paginated by keyset on their id
+func (m *mandateRepository) FetchDueDebits(ctx context.Context, afterBillID int64, limit int) ([]models.DueDebitModel, error) {
+	query := `
+		SELECT m.id AS mandate_id, m.user_id, m.loan_id, m.max_amount, lb.id AS loan_bill_id,
+		       lb.billing_total_amount AS amount_due, COALESCE(md.attempts, 0) AS attempts, md.payment_id,
+		       p.status AS payment_status
+		FROM mandates m
+		JOIN loans l ON l.id = m.loan_id
+		JOIN loan_bills lb ON lb.loan_id = m.loan_id
+		LEFT JOIN mandate_debits md ON md.mandate_id = m.id AND md.loan_bill_id = lb.id
+		LEFT JOIN payments p ON p.id = md.payment_id
+		WHERE m.status = 'ACTIVE' AND l.status = 'ACTIVE' AND lb.status = 'BILLED' AND lb.id > ?
+		AND (md.id IS NULL
+		     OR (md.status = 'RETRYING' AND md.next_attempt_at <= ?)
+		     OR (md.status = 'SUBMITTED' AND p.status = 'FAILED'))
+		AND NOT EXISTS (
+			SELECT 1 FROM payments pp
+			WHERE pp.loan_bill_id = lb.id AND pp.status IN ('PENDING', 'PROCESS', 'COMPLETED')
+		)
+		ORDER BY lb.id
+		LIMIT ?
+	`
+	var debits []models.DueDebitModel
+	err := m.DB.SelectContext(ctx, &debits, query, afterBillID, m.Now(), limit)
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"error":         err,
+			"after_bill_id": afterBillID,
+		}).Error("failed to fetch due debits")
+		return nil, err
+	}
+	return debits, nil
+}
+
+// SaveDebit creates the debit of a bill through a mandate or updates it with the outcome of the latest attempt
+func (m *mandateRepository) SaveDebit(ctx context.Context, debit *models.MandateDebitModel) error {
+	query := `
+		INSERT INTO mandate_debits (mandate_id, loan_bill_id, payment_id, status, attempts, next_attempt_at, last_error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE payment_id = VALUES(payment_id), status = VALUES(status), attempts = VALUES(attempts),
+		    next_attempt_at = VALUES(next_attempt_at), last_error = VALUES(last_error), updated_at = ?
+	`
+	now := m.Now()
+	_, err := m.DB.ExecContext(ctx, query, debit.MandateID, debit.LoanBillID, debit.PaymentID, debit.Status,
+		debit.Attempts, debit.NextAttemptAt, debit.LastError, now, now)
+	return err
+}
+
+type MandateRepositoryInterface interface {
+	CreateMandate(ctx context.Context, mandate *models.MandateModel) (int64, error)
+	GetMandateByID(ctx context.Context, id int64) (*models.MandateModel, error)
+	HasMandate(ctx context.Context, loanID int64) (bool, error)
+	UpdateMandateStatus(ctx context.Context, id int64, status string) error
+	FetchDueDebits(ctx context.Context, afterBillID int64, limit int) ([]models.DueDebitModel, error)
+	SaveDebit(ctx context.Context, debit *models.MandateDebitModel) error
+}
+
+func NewMandateRepository(db *mysql.DBMySQL) MandateRepositoryInterface {
+	if helpers.IsTestEnv() { // Skip singleton in tests
+		return &mandateRepository{
+			db,
+		}
+	}
+
+	repoLock.Do(func() {
+		repo = &mandateRepository{
+			db,
+		}
+	})
+	return repo
+}
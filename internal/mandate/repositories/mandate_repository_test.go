@@ -0,0 +1,264 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/okiww/billing-loan-system/internal/mandate/models"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/stretchr/testify/assert"
+	sqlmock "github.com/zhashkevych/go-sqlxmock"
+)
+
+func TestCreateMandate(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	repo := NewMandateRepository(&mysql.DBMySQL{DB: db, Clock: clock.Fixed(now)})
+	query := regexp.QuoteMeta("INSERT INTO mandates (user_id, loan_id, payment_method_token, max_amount, status, created_at)")
+	mandate := &models.MandateModel{UserID: 1, LoanID: 2, PaymentMethodToken: "tok_123", MaxAmount: 200000, Status: models.MandateStatusActive}
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    int64
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectExec(query).
+					WithArgs(int64(1), int64(2), "tok_123", int32(200000), models.MandateStatusActive, now).
+					WillReturnResult(sqlmock.NewResult(5, 1))
+			},
+			want: 5,
+		},
+		{
+			name: "Database Error",
+			mock: func() {
+				mock.ExpectExec(query).WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := repo.CreateMandate(context.Background(), mandate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateMandate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetMandateByID(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewMandateRepository(&mysql.DBMySQL{DB: db})
+	createdAt := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta("FROM mandates WHERE id = ?")
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    *models.MandateModel
+		wantErr string
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"id", "user_id", "loan_id", "payment_method_token", "max_amount", "status", "created_at", "updated_at"}).
+					AddRow(5, 1, 2, "tok_123", 200000, "ACTIVE", createdAt, nil)
+				mock.ExpectQuery(query).WithArgs(int64(5)).WillReturnRows(rows)
+			},
+			want: &models.MandateModel{ID: 5, UserID: 1, LoanID: 2, PaymentMethodToken: "tok_123", MaxAmount: 200000,
+				Status: models.MandateStatusActive, CreatedAt: createdAt},
+		},
+		{
+			name: "Not Found",
+			mock: func() {
+				mock.ExpectQuery(query).WithArgs(int64(5)).WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: "no mandate found with id 5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := repo.GetMandateByID(context.Background(), 5)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestHasMandate(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewMandateRepository(&mysql.DBMySQL{DB: db})
+	query := regexp.QuoteMeta("SELECT COUNT(*) FROM mandates WHERE loan_id = ? AND status != 'REVOKED'")
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "Has Mandate",
+			mock: func() {
+				mock.ExpectQuery(query).WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			},
+			want: true,
+		},
+		{
+			name: "No Mandate",
+			mock: func() {
+				mock.ExpectQuery(query).WithArgs(int64(2)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			},
+			want: false,
+		},
+		{
+			name: "Database Error",
+			mock: func() {
+				mock.ExpectQuery(query).WithArgs(int64(2)).WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := repo.HasMandate(context.Background(), 2)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HasMandate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFetchDueDebits(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	repo := NewMandateRepository(&mysql.DBMySQL{DB: db, Clock: clock.Fixed(now)})
+	query := regexp.QuoteMeta("OR (md.status = 'RETRYING' AND md.next_attempt_at <= ?)")
+	paymentID := int64(9)
+	failed := "FAILED"
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    []models.DueDebitModel
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"mandate_id", "user_id", "loan_id", "max_amount", "loan_bill_id", "amount_due", "attempts", "payment_id", "payment_status"}).
+					AddRow(5, 1, 2, 200000, 10, 110000, 0, nil, nil).
+					AddRow(6, 3, 4, 200000, 11, 110000, 1, 9, "FAILED")
+				mock.ExpectQuery(query).WithArgs(int64(0), now, 2).WillReturnRows(rows)
+			},
+			want: []models.DueDebitModel{
+				{MandateID: 5, UserID: 1, LoanID: 2, MaxAmount: 200000, LoanBillID: 10, AmountDue: 110000},
+				{MandateID: 6, UserID: 3, LoanID: 4, MaxAmount: 200000, LoanBillID: 11, AmountDue: 110000, Attempts: 1,
+					PaymentID: &paymentID, PaymentStatus: &failed},
+			},
+		},
+		{
+			name: "Database Error",
+			mock: func() {
+				mock.ExpectQuery(query).WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := repo.FetchDueDebits(context.Background(), 0, 2)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FetchDueDebits() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSaveDebit(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	repo := NewMandateRepository(&mysql.DBMySQL{DB: db, Clock: clock.Fixed(now)})
+	query := regexp.QuoteMeta("INSERT INTO mandate_debits")
+	nextAttemptAt := now.Add(time.Hour)
+	lastError := "loan bill status is not billed"
+	debit := &models.MandateDebitModel{MandateID: 5, LoanBillID: 10, Status: models.DebitStatusRetrying, Attempts: 1,
+		NextAttemptAt: &nextAttemptAt, LastError: &lastError}
+
+	tests := []struct {
+		name    string
+		mock    func()
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectExec(query).
+					WithArgs(int64(5), int64(10), nil, models.DebitStatusRetrying, int32(1), &nextAttemptAt, &lastError, now, now).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			name: "Database Error",
+			mock: func() {
+				mock.ExpectExec(query).WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			err := repo.SaveDebit(context.Background(), debit)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SaveDebit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
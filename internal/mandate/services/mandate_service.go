@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/okiww/billing-loan-system/configs"
+	"github.com/okiww/billing-loan-system/internal/dto"
+	loanModel "github.com/okiww/billing-loan-system/internal/loan/models"
+	loanRepo "github.com/okiww/billing-loan-system/internal/loan/repositories"
+	"github.com/okiww/billing-loan-system/internal/mandate/models"
+	"github.com/okiww/billing-loan-system/internal/mandate/repositories"
+	paymentModel "github.com/okiww/billing-loan-system/internal/payment/models"
+	paymentService "github.com/okiww/billing-loan-system/internal/payment/services"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+type mandateService struct {
+	mandateRepo    repositories.MandateRepositoryInterface
+	loanRepo       loanRepo.LoanRepositoryInterface
+	paymentService paymentService.PaymentServiceInterface
+	config         configs.AutoDebitConfig
+	clock          clock.Clock
+}
+
+// CreateMandate authorizes the bills of an active loan of the user to be charged automatically, a loan has one
+// mandate at a time
+func (m *mandateService) CreateMandate(ctx context.Context, request dto.MandateRequest) (*models.MandateModel, error) {
+	logger.GetLogger().Info("[MandateService][CreateMandate]")
+	loan, err := m.loanRepo.GetLoanByID(ctx, request.LoanID)
+	if err != nil {
+		logger.GetLogger().Errorf("[MandateService][CreateMandate] Error GetLoanByID with err: %v", err)
+		return nil, err
+	}
+
+	if loan.UserID != request.UserID {
+		return nil, errors.New(dto.ErrorLoanNotOwnedByUser)
+	}
+	if loan.Status != loanModel.StatusActive {
+		return nil, errors.New(dto.ErrorLoanIsNotActive)
+	}
+
+	exists, err := m.mandateRepo.HasMandate(ctx, request.LoanID)
+	if err != nil {
+		logger.GetLogger().Errorf("[MandateService][CreateMandate] Error HasMandate with err: %v", err)
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New(dto.ErrorMandateAlreadyExists)
+	}
+
+	id, err := m.mandateRepo.CreateMandate(ctx, &models.MandateModel{
+		UserID:             request.UserID,
+		LoanID:             request.LoanID,
+		PaymentMethodToken: request.PaymentMethodToken,
+		MaxAmount:          request.MaxAmount,
+		Status:             models.MandateStatusActive,
+	})
+	if err != nil {
+		logger.GetLogger().Errorf("[MandateService][CreateMandate] Error CreateMandate with err: %v", err)
+		return nil, err
+	}
+
+	return m.mandateRepo.GetMandateByID(ctx, id)
+}
+
+// PauseMandate stops charging the bills until the mandate is resumed
+func (m *mandateService) PauseMandate(ctx context.Context, request dto.MandateStatusRequest) (*models.MandateModel, error) {
+	logger.GetLogger().Info("[MandateService][PauseMandate]")
+	return m.updateStatus(ctx, request, models.MandateStatusPaused, func(status string) error {
+		if status != models.MandateStatusActive {
+			return errors.New(dto.ErrorMandateIsNotActive)
+		}
+		return nil
+	})
+}
+
+// ResumeMandate charges the bills of a paused mandate again, the bills billed during the pause are charged on the next run
+func (m *mandateService) ResumeMandate(ctx context.Context, request dto.MandateStatusRequest) (*models.MandateModel, error) {
+	logger.GetLogger().Info("[MandateService][ResumeMandate]")
+	return m.updateStatus(ctx, request, models.MandateStatusActive, func(status string) error {
+		if status != models.MandateStatusPaused {
+			return errors.New(dto.ErrorMandateIsNotPaused)
+		}
+		return nil
+	})
+}
+
+// RevokeMandate stops charging the bills for good, the user can create a new mandate for the loan afterward
+func (m *mandateService) RevokeMandate(ctx context.Context, request dto.MandateStatusRequest) (*models.MandateModel, error) {
+	logger.GetLogger().Info("[MandateService][RevokeMandate]")
+	return m.updateStatus(ctx, request, models.MandateStatusRevoked, func(status string) error {
+		if status == models.MandateStatusRevoked {
+			return errors.New(dto.ErrorMandateIsRevoked)
+		}
+		return nil
+	})
+}
+
+func (m *mandateService) updateStatus(ctx context.Context, request dto.MandateStatusRequest, status string, allowed func(status string) error) (*models.MandateModel, error) {
+	mandate, err := m.mandateRepo.GetMandateByID(ctx, request.MandateID)
+	if err != nil {
+		logger.GetLogger().Errorf("[MandateService][updateStatus] Error GetMandateByID with err: %v", err)
+		return nil, err
+	}
+
+	if mandate.UserID != request.UserID {
+		return nil, errors.New(dto.ErrorMandateNotOwnedByUser)
+	}
+	if err := allowed(mandate.Status); err != nil {
+		return nil, err
+	}
+
+	err = m.mandateRepo.UpdateMandateStatus(ctx, mandate.ID, status)
+	if err != nil {
+		logger.GetLogger().Errorf("[MandateService][updateStatus] Error UpdateMandateStatus with err: %v", err)
+		return nil, err
+	}
+	mandate.Status = status
+	return mandate, nil
+}
+
+// GetDueDebits get a page of the bills to charge through a mandate, after the given bill id
+func (m *mandateService) GetDueDebits(ctx context.Context, afterBillID int64, limit int) ([]models.DueDebitModel, error) {
+	logger.GetLogger().Info("[MandateService][GetDueDebits]")
+	debits, err := m.mandateRepo.FetchDueDebits(ctx, afterBillID, limit)
+	if err != nil {
+		logger.GetLogger().Errorf("[MandateService][GetDueDebits] Error FetchDueDebits with err: %v", err)
+		return nil, err
+	}
+	return debits, nil
+}
+
+// CollectDebit charges a bill through its mandate, the payment is created like a payment of the user and the outbox
+// relay publishes it to the payment worker. A payment which couldn't be created or which the worker failed to process is retried on the
+// configured schedule, the debit fails once the retries are exhausted. Returns true when a payment was submitted
+func (m *mandateService) CollectDebit(ctx context.Context, due models.DueDebitModel) (bool, error) {
+	debit := &models.MandateDebitModel{
+		MandateID:  due.MandateID,
+		LoanBillID: due.LoanBillID,
+		PaymentID:  due.PaymentID,
+		Attempts:   due.Attempts,
+	}
+
+	// the previous attempt was submitted but the worker failed it, wait for the next retry
+	if due.PaymentStatus != nil && *due.PaymentStatus == paymentModel.StatusFailed {
+		return false, m.saveFailure(ctx, debit, models.DebitErrorPaymentFailed)
+	}
+
+	if due.AmountDue > due.MaxAmount {
+		debit.Status = models.DebitStatusFailed
+		debit.LastError = stringPtr(models.DebitErrorExceedsMaxAmount)
+		return false, m.saveDebit(ctx, debit)
+	}
+
+	debit.Attempts++
+	payment, err := m.paymentService.MakePayment(ctx, &dto.PaymentRequest{
+		UserID:     int(due.UserID),
+		LoanID:     int(due.LoanID),
+		LoanBillID: int(due.LoanBillID),
+		Amount:     int(due.AmountDue),
+	})
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"mandate_id":   due.MandateID,
+			"loan_bill_id": due.LoanBillID,
+			"attempts":     debit.Attempts,
+		}).Errorf("[MandateService][CollectDebit] Error MakePayment with err: %v", err)
+		return false, m.saveFailure(ctx, debit, err.Error())
+	}
+
+	paymentID := int64(payment.ID)
+	debit.PaymentID = &paymentID
+	debit.Status = models.DebitStatusSubmitted
+	if err := m.saveDebit(ctx, debit); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// saveFailure schedules the next retry of a debit after its attempts, or fails it when the retries are exhausted
+func (m *mandateService) saveFailure(ctx context.Context, debit *models.MandateDebitModel, reason string) error {
+	retryAfterHours := m.config.RetryAfterHours
+	if len(retryAfterHours) == 0 {
+		retryAfterHours = models.DefaultRetryAfterHours
+	}
+
+	debit.LastError = stringPtr(reason)
+	debit.Status = models.DebitStatusFailed
+	if retry := int(debit.Attempts) - 1; retry >= 0 && retry < len(retryAfterHours) {
+		nextAttemptAt := m.clock.Now().Add(time.Duration(retryAfterHours[retry]) * time.Hour)
+		debit.Status = models.DebitStatusRetrying
+		debit.NextAttemptAt = &nextAttemptAt
+	}
+	return m.saveDebit(ctx, debit)
+}
+
+func (m *mandateService) saveDebit(ctx context.Context, debit *models.MandateDebitModel) error {
+	err := m.mandateRepo.SaveDebit(ctx, debit)
+	if err != nil {
+		logger.GetLogger().Errorf("[MandateService][CollectDebit] Error SaveDebit with err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+type MandateServiceInterface interface {
+	CreateMandate(ctx context.Context, request dto.MandateRequest) (*models.MandateModel, error)
+	PauseMandate(ctx context.Context, request dto.MandateStatusRequest) (*models.MandateModel, error)
+	ResumeMandate(ctx context.Context, request dto.MandateStatusRequest) (*models.MandateModel, error)
+	RevokeMandate(ctx context.Context, request dto.MandateStatusRequest) (*models.MandateModel, error)
+	GetDueDebits(ctx context.Context, afterBillID int64, limit int) ([]models.DueDebitModel, error)
+	CollectDebit(ctx context.Context, due models.DueDebitModel) (bool, error)
+}
+
+func NewMandateService(mandateRepo repositories.MandateRepositoryInterface, loanRepo loanRepo.LoanRepositoryInterface, paymentService paymentService.PaymentServiceInterface,
+	config configs.AutoDebitConfig, clk clock.Clock) MandateServiceInterface {
+	return &mandateService{mandateRepo, loanRepo, paymentService, config, clk}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/okiww/billing-loan-system/configs"
+	loan_mock "github.com/okiww/billing-loan-system/gen/mocks/loan"
+	mandate_mock "github.com/okiww/billing-loan-system/gen/mocks/mandate"
+	payment_mock "github.com/okiww/billing-loan-system/gen/mocks/payment"
+	"github.com/okiww/billing-loan-system/internal/dto"
+	loanModel "github.com/okiww/billing-loan-system/internal/loan/models"
+	"github.com/okiww/billing-loan-system/internal/mandate/models"
+	paymentModel "github.com/okiww/billing-loan-system/internal/payment/models"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateMandate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockMandateRepo := mandate_mock.NewMockMandateRepositoryInterface(ctrl)
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	mockPaymentService := payment_mock.NewMockPaymentServiceInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	service := NewMandateService(mockMandateRepo, mockLoanRepo, mockPaymentService, configs.AutoDebitConfig{}, clock.Fixed(now))
+
+	request := dto.MandateRequest{UserID: 1, LoanID: 2, PaymentMethodToken: "tok_123", MaxAmount: 200000}
+
+	tests := []struct {
+		name      string
+		mockCalls func()
+		want      *models.MandateModel
+		wantErr   string
+	}{
+		{
+			name: "Success",
+			mockCalls: func() {
+				mockLoanRepo.EXPECT().GetLoanByID(gomock.Any(), int64(2)).Return(&loanModel.LoanModel{ID: 2, UserID: 1, Status: loanModel.StatusActive}, nil)
+				mockMandateRepo.EXPECT().HasMandate(gomock.Any(), int64(2)).Return(false, nil)
+				mockMandateRepo.EXPECT().CreateMandate(gomock.Any(), &models.MandateModel{
+					UserID: 1, LoanID: 2, PaymentMethodToken: "tok_123", MaxAmount: 200000, Status: models.MandateStatusActive,
+				}).Return(int64(5), nil)
+				mockMandateRepo.EXPECT().GetMandateByID(gomock.Any(), int64(5)).Return(&models.MandateModel{ID: 5, UserID: 1, LoanID: 2, Status: models.MandateStatusActive}, nil)
+			},
+			want: &models.MandateModel{ID: 5, UserID: 1, LoanID: 2, Status: models.MandateStatusActive},
+		},
+		{
+			name: "Error loan of another user",
+			mockCalls: func() {
+				mockLoanRepo.EXPECT().GetLoanByID(gomock.Any(), int64(2)).Return(&loanModel.LoanModel{ID: 2, UserID: 3, Status: loanModel.StatusActive}, nil)
+			},
+			wantErr: dto.ErrorLoanNotOwnedByUser,
+		},
+		{
+			name: "Error loan is not active",
+			mockCalls: func() {
+				mockLoanRepo.EXPECT().GetLoanByID(gomock.Any(), int64(2)).Return(&loanModel.LoanModel{ID: 2, UserID: 1, Status: loanModel.StatusPaid}, nil)
+			},
+			wantErr: dto.ErrorLoanIsNotActive,
+		},
+		{
+			name: "Error loan already has a mandate",
+			mockCalls: func() {
+				mockLoanRepo.EXPECT().GetLoanByID(gomock.Any(), int64(2)).Return(&loanModel.LoanModel{ID: 2, UserID: 1, Status: loanModel.StatusActive}, nil)
+				mockMandateRepo.EXPECT().HasMandate(gomock.Any(), int64(2)).Return(true, nil)
+			},
+			wantErr: dto.ErrorMandateAlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockCalls()
+			got, err := service.CreateMandate(context.Background(), request)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUpdateMandateStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockMandateRepo := mandate_mock.NewMockMandateRepositoryInterface(ctrl)
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	mockPaymentService := payment_mock.NewMockPaymentServiceInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	service := NewMandateService(mockMandateRepo, mockLoanRepo, mockPaymentService, configs.AutoDebitConfig{}, clock.Fixed(now))
+
+	request := dto.MandateStatusRequest{MandateID: 5, UserID: 1}
+	mandate := func(status string) *models.MandateModel {
+		return &models.MandateModel{ID: 5, UserID: 1, LoanID: 2, Status: status}
+	}
+
+	tests := []struct {
+		name       string
+		update     func(ctx context.Context, request dto.MandateStatusRequest) (*models.MandateModel, error)
+		mockCalls  func()
+		wantStatus string
+		wantErr    string
+	}{
+		{
+			name:   "Success pause active mandate",
+			update: service.PauseMandate,
+			mockCalls: func() {
+				mockMandateRepo.EXPECT().GetMandateByID(gomock.Any(), int64(5)).Return(mandate(models.MandateStatusActive), nil)
+				mockMandateRepo.EXPECT().UpdateMandateStatus(gomock.Any(), int64(5), models.MandateStatusPaused).Return(nil)
+			},
+			wantStatus: models.MandateStatusPaused,
+		},
+		{
+			name:   "Success resume paused mandate",
+			update: service.ResumeMandate,
+			mockCalls: func() {
+				mockMandateRepo.EXPECT().GetMandateByID(gomock.Any(), int64(5)).Return(mandate(models.MandateStatusPaused), nil)
+				mockMandateRepo.EXPECT().UpdateMandateStatus(gomock.Any(), int64(5), models.MandateStatusActive).Return(nil)
+			},
+			wantStatus: models.MandateStatusActive,
+		},
+		{
+			name:   "Success revoke paused mandate",
+			update: service.RevokeMandate,
+			mockCalls: func() {
+				mockMandateRepo.EXPECT().GetMandateByID(gomock.Any(), int64(5)).Return(mandate(models.MandateStatusPaused), nil)
+				mockMandateRepo.EXPECT().UpdateMandateStatus(gomock.Any(), int64(5), models.MandateStatusRevoked).Return(nil)
+			},
+			wantStatus: models.MandateStatusRevoked,
+		},
+		{
+			name:   "Error pause paused mandate",
+			update: service.PauseMandate,
+			mockCalls: func() {
+				mockMandateRepo.EXPECT().GetMandateByID(gomock.Any(), int64(5)).Return(mandate(models.MandateStatusPaused), nil)
+			},
+			wantErr: dto.ErrorMandateIsNotActive,
+		},
+		{
+			name:   "Error resume revoked mandate",
+			update: service.ResumeMandate,
+			mockCalls: func() {
+				mockMandateRepo.EXPECT().GetMandateByID(gomock.Any(), int64(5)).Return(mandate(models.MandateStatusRevoked), nil)
+			},
+			wantErr: dto.ErrorMandateIsNotPaused,
+		},
+		{
+			name:   "Error revoke revoked mandate",
+			update: service.RevokeMandate,
+			mockCalls: func() {
+				mockMandateRepo.EXPECT().GetMandateByID(gomock.Any(), int64(5)).Return(mandate(models.MandateStatusRevoked), nil)
+			},
+			wantErr: dto.ErrorMandateIsRevoked,
+		},
+		{
+			name:   "Error mandate of another user",
+			update: service.RevokeMandate,
+			mockCalls: func() {
+				mockMandateRepo.EXPECT().GetMandateByID(gomock.Any(), int64(5)).Return(&models.MandateModel{ID: 5, UserID: 3, Status: models.MandateStatusActive}, nil)
+			},
+			wantErr: dto.ErrorMandateNotOwnedByUser,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockCalls()
+			got, err := tt.update(context.Background(), request)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.Nil(t, got)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, got.Status)
+		})
+	}
+}
+
+func TestCollectDebit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockMandateRepo := mandate_mock.NewMockMandateRepositoryInterface(ctrl)
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	mockPaymentService := payment_mock.NewMockPaymentServiceInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	config := configs.AutoDebitConfig{RetryAfterHours: []int{1, 6}}
+	service := NewMandateService(mockMandateRepo, mockLoanRepo, mockPaymentService, config, clock.Fixed(now))
+
+	due := models.DueDebitModel{MandateID: 5, UserID: 1, LoanID: 2, MaxAmount: 200000, LoanBillID: 10, AmountDue: 110000}
+	paymentID := int64(9)
+	failed := paymentModel.StatusFailed
+	strPtr := func(s string) *string { return &s }
+	timePtr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name          string
+		due           models.DueDebitModel
+		mockCalls     func()
+		wantSubmitted bool
+		wantErr       bool
+	}{
+		{
+			name: "Success submit payment on the billing date",
+			due:  due,
+			mockCalls: func() {
+				mockPaymentService.EXPECT().MakePayment(gomock.Any(), &dto.PaymentRequest{UserID: 1, LoanID: 2, LoanBillID: 10, Amount: 110000}).
+					Return(&paymentModel.Payment{ID: 9, UserID: 1, LoanID: 2, LoanBillID: 10, Amount: 110000, PaymentType: paymentModel.PaymentTypeRepayment, Status: paymentModel.StatusPending}, nil)
+				mockMandateRepo.EXPECT().SaveDebit(gomock.Any(), &models.MandateDebitModel{
+					MandateID: 5, LoanBillID: 10, PaymentID: &paymentID, Status: models.DebitStatusSubmitted, Attempts: 1,
+				}).Return(nil)
+			},
+			wantSubmitted: true,
+		},
+		{
+			name: "Retry payment which couldn't be created",
+			due:  due,
+			mockCalls: func() {
+				mockPaymentService.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(nil, errors.New(dto.ErrorLoanBillStatusNotBilled))
+				mockMandateRepo.EXPECT().SaveDebit(gomock.Any(), &models.MandateDebitModel{
+					MandateID: 5, LoanBillID: 10, Status: models.DebitStatusRetrying, Attempts: 1,
+					NextAttemptAt: timePtr(now.Add(time.Hour)), LastError: strPtr(dto.ErrorLoanBillStatusNotBilled),
+				}).Return(nil)
+			},
+		},
+		{
+			name: "Retry payment the worker failed",
+			due: models.DueDebitModel{MandateID: 5, UserID: 1, LoanID: 2, MaxAmount: 200000, LoanBillID: 10, AmountDue: 110000,
+				Attempts: 2, PaymentID: &paymentID, PaymentStatus: &failed},
+			mockCalls: func() {
+				mockMandateRepo.EXPECT().SaveDebit(gomock.Any(), &models.MandateDebitModel{
+					MandateID: 5, LoanBillID: 10, PaymentID: &paymentID, Status: models.DebitStatusRetrying, Attempts: 2,
+					NextAttemptAt: timePtr(now.Add(6 * time.Hour)), LastError: strPtr(models.DebitErrorPaymentFailed),
+				}).Return(nil)
+			},
+		},
+		{
+			name: "Fail debit once the retries are exhausted",
+			due: models.DueDebitModel{MandateID: 5, UserID: 1, LoanID: 2, MaxAmount: 200000, LoanBillID: 10, AmountDue: 110000,
+				Attempts: 2},
+			mockCalls: func() {
+				mockPaymentService.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(nil, errors.New("db error"))
+				mockMandateRepo.EXPECT().SaveDebit(gomock.Any(), &models.MandateDebitModel{
+					MandateID: 5, LoanBillID: 10, Status: models.DebitStatusFailed, Attempts: 3, LastError: strPtr("db error"),
+				}).Return(nil)
+			},
+		},
+		{
+			name: "Fail debit above the max amount",
+			due:  models.DueDebitModel{MandateID: 5, UserID: 1, LoanID: 2, MaxAmount: 100000, LoanBillID: 10, AmountDue: 110000},
+			mockCalls: func() {
+				mockMandateRepo.EXPECT().SaveDebit(gomock.Any(), &models.MandateDebitModel{
+					MandateID: 5, LoanBillID: 10, Status: models.DebitStatusFailed, LastError: strPtr(models.DebitErrorExceedsMaxAmount),
+				}).Return(nil)
+			},
+		},
+		{
+			name: "Error save debit",
+			due:  due,
+			mockCalls: func() {
+				mockPaymentService.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(&paymentModel.Payment{ID: 9}, nil)
+				mockMandateRepo.EXPECT().SaveDebit(gomock.Any(), gomock.Any()).Return(errors.New("db error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockCalls()
+			submitted, err := service.CollectDebit(context.Background(), tt.due)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CollectDebit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantSubmitted, submitted)
+		})
+	}
+}
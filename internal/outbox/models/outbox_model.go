@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/okiww/billing-loan-system/pkg/event"
+)
+
+// OutboxMessageModel represents the `outbox_messages` table, a message waiting to be published by the relay
+type OutboxMessageModel struct {
+	ID            int64      `db:"id" json:"id"`
+	AggregateType string     `db:"aggregate_type" json:"aggregate_type"` // e.g., 'payment'
+	AggregateID   int64      `db:"aggregate_id" json:"aggregate_id"`
+	EventType     string     `db:"event_type" json:"event_type"` // e.g., 'payment.created'
+	Payload       string     `db:"payload" json:"payload"`
+	Status        string     `db:"status" json:"status"` // e.g., 'PENDING', 'SENT', 'FAILED'
+	Attempts      int32      `db:"attempts" json:"attempts"`
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"next_attempt_at"`
+	LastError     *string    `db:"last_error" json:"last_error"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	SentAt        *time.Time `db:"sent_at" json:"sent_at"`
+}
+
+// Route where the relay publishes the messages of an event type, the default exchange "" routes a message to the queue
+// named by the routing key
+type Route struct {
+	Exchange   string
+	RoutingKey string
+}
+
+// NewMessage wraps payload in the envelope of an event, the message announces a change of the aggregate
+func NewMessage(ctx context.Context, aggregateType string, aggregateID int64, eventType string, version int, occurredAt time.Time, payload interface{}) (*OutboxMessageModel, error) {
+	body, err := event.Encode(ctx, eventType, version, occurredAt, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxMessageModel{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		EventType:     eventType,
+		Payload:       body,
+		Status:        StatusPending,
+		CreatedAt:     occurredAt,
+	}, nil
+}
+
+// DomainEvents the event types other services subscribe to
+var DomainEvents = []string{
+	EventLoanCreated,
+	EventLoanClosed,
+	EventBillBilled,
+	EventBillOverdue,
+	EventPaymentCompleted,
+	EventPaymentFailed,
+	EventUserDelinquent,
+	EventUserCured,
+}
+
+const (
+	StatusPending = "PENDING"
+	StatusSent    = "SENT"
+	StatusFailed  = "FAILED"
+
+	AggregatePayment  = "payment"
+	AggregateLoan     = "loan"
+	AggregateLoanBill = "loan_bill"
+	AggregateUser     = "user"
+
+	EventPaymentCreated = "payment.created"
+
+	// Domain events, their type is the routing key they are published with
+	EventLoanCreated      = "loan.created"
+	EventLoanClosed       = "loan.closed"
+	EventBillBilled       = "bill.billed"
+	EventBillOverdue      = "bill.overdue"
+	EventPaymentCompleted = "payment.completed"
+	EventPaymentFailed    = "payment.failed"
+	EventUserDelinquent   = "user.delinquent"
+	EventUserCured        = "user.cured"
+
+	ErrorNoRoute = "no route for the event type"
+
+	// DefaultExchange the topic exchange the domain events are published to, routed by event type
+	DefaultExchange = "billing.events"
+
+	DefaultBatchSize      = 100
+	DefaultMaxAttempts    = 10
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = 5 * time.Minute
+	DefaultPollInterval   = time.Second
+	DefaultClaimTimeout   = 5 * time.Minute
+)
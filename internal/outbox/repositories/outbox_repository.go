@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/okiww/billing-loan-system/helpers"
+	"github.com/okiww/billing-loan-system/internal/outbox/models"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	repo     OutboxRepositoryInterface
+	repoLock sync.Once
+)
+
+type outboxRepository struct {
+	*mysql.DBMySQL
+}
+
+// InsertMessageInTx writes a message to the outbox in the transaction of the change it announces, the relay publishes
+// it once the transaction is committed. Its first attempt is due right away by the database clock, created_at keeps the
+// business date of the change
+func InsertMessageInTx(ctx context.Context, tx *sqlx.Tx, message *models.OutboxMessageModel) error {
+	query := `
+		INSERT INTO outbox_messages (aggregate_type, aggregate_id, event_type, payload, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, NOW(3), ?)
+	`
+	_, err := tx.ExecContext(ctx, query, message.AggregateType, message.AggregateID, message.EventType, message.Payload,
+		message.Status, message.CreatedAt)
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"error":      err,
+			"event_type": message.EventType,
+		}).Error("failed to insert outbox message")
+		return err
+	}
+	return nil
+}
+
+// ClaimPendingMessages claims the oldest PENDING messages which attempt is due for claimTimeout, the messages claimed
+// by another relay are skipped until their claim expires. The attempts and claims are timed by the database clock so
+// relays agree on them whatever the clock of the application, a frozen business clock doesn't stall the retries
+func (o *outboxRepository) ClaimPendingMessages(ctx context.Context, limit int, claimTimeout time.Duration) ([]models.OutboxMessageModel, error) {
+	var messages []models.OutboxMessageModel
+	err := o.ExecTx(ctx, o.DB, func(tx *sqlx.Tx) error {
+		query := `
+			SELECT id, aggregate_type, aggregate_id, event_type, payload, status, attempts, next_attempt_at, last_error,
+			       created_at, sent_at
+			FROM outbox_messages
+			WHERE status = 'PENDING' AND next_attempt_at <= NOW(3) AND (claimed_until IS NULL OR claimed_until < NOW(3))
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		`
+		err := tx.SelectContext(ctx, &messages, query, limit)
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := make([]int64, len(messages))
+		for i, message := range messages {
+			ids[i] = message.ID
+		}
+		query, args, err := sqlx.In(`
+			UPDATE outbox_messages SET claimed_until = NOW(3) + INTERVAL ? MICROSECOND WHERE id IN (?)
+		`, claimTimeout.Microseconds(), ids)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"error": err,
+			"limit": limit,
+		}).Error("failed to claim pending outbox messages")
+		return nil, err
+	}
+	return messages, nil
+}
+
+// MarkSent records a message was published
+func (o *outboxRepository) MarkSent(ctx context.Context, id int64) error {
+	query := `
+		UPDATE outbox_messages
+		SET status = 'SENT', attempts = attempts + 1, last_error = NULL, sent_at = ?, claimed_until = NULL
+		WHERE id = ?
+	`
+	_, err := o.DB.ExecContext(ctx, query, o.Now(), id)
+	return err
+}
+
+// MarkAttemptFailed records a failed publish, the message is attempted again once backoff has passed while it is PENDING
+func (o *outboxRepository) MarkAttemptFailed(ctx context.Context, id int64, status string, backoff time.Duration, lastError string) error {
+	query := `
+		UPDATE outbox_messages
+		SET status = ?, attempts = attempts + 1, next_attempt_at = NOW(3) + INTERVAL ? MICROSECOND, last_error = ?,
+		    claimed_until = NULL
+		WHERE id = ?
+	`
+	_, err := o.DB.ExecContext(ctx, query, status, backoff.Microseconds(), lastError, id)
+	return err
+}
+
+type OutboxRepositoryInterface interface {
+	ClaimPendingMessages(ctx context.Context, limit int, claimTimeout time.Duration) ([]models.OutboxMessageModel, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkAttemptFailed(ctx context.Context, id int64, status string, backoff time.Duration, lastError string) error
+}
+
+func NewOutboxRepository(db *mysql.DBMySQL) OutboxRepositoryInterface {
+	if helpers.IsTestEnv() { // Skip singleton in tests
+		return &outboxRepository{
+			db,
+		}
+	}
+
+	repoLock.Do(func() {
+		repo = &outboxRepository{
+			db,
+		}
+	})
+	return repo
+}
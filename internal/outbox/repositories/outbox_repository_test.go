@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/okiww/billing-loan-system/internal/outbox/models"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/stretchr/testify/assert"
+	sqlmock "github.com/zhashkevych/go-sqlxmock"
+)
+
+func TestClaimPendingMessages(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	repo := NewOutboxRepository(&mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)})
+	query := regexp.QuoteMeta("WHERE status = 'PENDING' AND next_attempt_at <= NOW(3) AND (claimed_until IS NULL OR claimed_until < NOW(3))") +
+		".*FOR UPDATE SKIP LOCKED"
+	claim := regexp.QuoteMeta("UPDATE outbox_messages SET claimed_until = NOW(3) + INTERVAL ? MICROSECOND WHERE id IN (?, ?)")
+	columns := []string{"id", "aggregate_type", "aggregate_id", "event_type", "payload", "status", "attempts",
+		"next_attempt_at", "last_error", "created_at", "sent_at"}
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    []models.OutboxMessageModel
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows(columns).
+					AddRow(1, "payment", 9, "payment.created", `{"ID":9}`, "PENDING", 0, now, nil, now, nil).
+					AddRow(2, "loan", 3, "loan.created", `{"ID":3}`, "PENDING", 1, now, nil, now, nil)
+				mock.ExpectQuery(query).WithArgs(100).WillReturnRows(rows)
+				mock.ExpectExec(claim).WithArgs(int64(time.Minute/time.Microsecond), 1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			},
+			want: []models.OutboxMessageModel{
+				{ID: 1, AggregateType: models.AggregatePayment, AggregateID: 9, EventType: models.EventPaymentCreated,
+					Payload: `{"ID":9}`, Status: models.StatusPending, NextAttemptAt: now, CreatedAt: now},
+				{ID: 2, AggregateType: models.AggregateLoan, AggregateID: 3, EventType: models.EventLoanCreated,
+					Payload: `{"ID":3}`, Status: models.StatusPending, Attempts: 1, NextAttemptAt: now, CreatedAt: now},
+			},
+		},
+		{
+			name: "Success nothing to claim",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(query).WithArgs(100).WillReturnRows(sqlmock.NewRows(columns))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Database Error",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(query).WithArgs(100).WillReturnError(assert.AnError)
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := repo.ClaimPendingMessages(context.Background(), 100, time.Minute)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ClaimPendingMessages() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMarkSent(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	repo := NewOutboxRepository(&mysql.DBMySQL{DB: db, Clock: clock.Fixed(now)})
+	mock.ExpectExec(regexp.QuoteMeta("SET status = 'SENT', attempts = attempts + 1, last_error = NULL, sent_at = ?, claimed_until = NULL")).
+		WithArgs(now, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.MarkSent(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkAttemptFailed(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewOutboxRepository(&mysql.DBMySQL{DB: db})
+	// the backoff is added to the database clock so a frozen business clock doesn't stall the retries
+	mock.ExpectExec(regexp.QuoteMeta("SET status = ?, attempts = attempts + 1, next_attempt_at = NOW(3) + INTERVAL ? MICROSECOND, last_error = ?")).
+		WithArgs(models.StatusPending, int64(2*time.Second/time.Microsecond), "connection closed", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.MarkAttemptFailed(context.Background(), 1, models.StatusPending, 2*time.Second, "connection closed")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/okiww/billing-loan-system/configs"
+	"github.com/okiww/billing-loan-system/internal/outbox/models"
+	"github.com/okiww/billing-loan-system/internal/outbox/repositories"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// lastErrorSize is the size of outbox_messages.last_error
+const lastErrorSize = 255
+
+var errNoRoute = errors.New(models.ErrorNoRoute)
+
+// Publisher publishes a message to an exchange of the message broker, implemented by the mq brokers
+type Publisher interface {
+	Publish(exchange, routingKey, message string) error
+}
+
+type outboxService struct {
+	outboxRepo repositories.OutboxRepositoryInterface
+	publisher  Publisher
+	routes     map[string]models.Route // Route of every event type
+	config     configs.OutboxConfig
+}
+
+// RelayPending publishes a batch of the PENDING outbox messages, oldest first. The batch is claimed so relays running
+// side by side publish different messages. A message which couldn't be published is attempted again with exponential
+// backoff and FAILED once it runs out of attempts, the later messages are published meanwhile so the messages of an
+// aggregate may reach the broker out of order. The broker may receive a message more than once when marking it sent
+// fails or its claim expires while it is published. Returns the messages published
+func (o *outboxService) RelayPending(ctx context.Context) (int32, error) {
+	messages, err := o.outboxRepo.ClaimPendingMessages(ctx, o.BatchSize(), o.claimTimeout())
+	if err != nil {
+		logger.GetLogger().Errorf("[OutboxService][RelayPending] Error ClaimPendingMessages with err: %v", err)
+		return 0, err
+	}
+
+	var sent int32
+	for _, message := range messages {
+		err := o.publish(message)
+		if err != nil {
+			logger.GetLogger().WithFields(logrus.Fields{
+				"outbox_message_id": message.ID,
+				"event_type":        message.EventType,
+				"attempts":          message.Attempts + 1,
+			}).Errorf("[OutboxService][RelayPending] Error publish message with err: %v", err)
+			if err := o.markAttemptFailed(ctx, message, err); err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		err = o.outboxRepo.MarkSent(ctx, message.ID)
+		if err != nil {
+			logger.GetLogger().Errorf("[OutboxService][RelayPending] Error MarkSent with err: %v", err)
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// BatchSize get the messages published per call of RelayPending
+func (o *outboxService) BatchSize() int {
+	if o.config.BatchSize > 0 {
+		return o.config.BatchSize
+	}
+	return models.DefaultBatchSize
+}
+
+// claimTimeout how long a relay has to publish the batch it claimed before the other relays may claim it
+func (o *outboxService) claimTimeout() time.Duration {
+	if o.config.ClaimTimeoutSeconds > 0 {
+		return time.Duration(o.config.ClaimTimeoutSeconds) * time.Second
+	}
+	return models.DefaultClaimTimeout
+}
+
+func (o *outboxService) publish(message models.OutboxMessageModel) error {
+	route, ok := o.routes[message.EventType]
+	if !ok {
+		return errNoRoute
+	}
+	return o.publisher.Publish(route.Exchange, route.RoutingKey, message.Payload)
+}
+
+// markAttemptFailed schedules the next attempt of a message after a backoff doubled on every attempt, or fails it
+// when it ran out of attempts or it has no route
+func (o *outboxService) markAttemptFailed(ctx context.Context, message models.OutboxMessageModel, cause error) error {
+	maxAttempts := o.config.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = models.DefaultMaxAttempts
+	}
+
+	attempts := message.Attempts + 1
+	status := models.StatusPending
+	if attempts >= int32(maxAttempts) || cause == errNoRoute {
+		status = models.StatusFailed
+	}
+
+	lastError := cause.Error()
+	if len(lastError) > lastErrorSize {
+		lastError = lastError[:lastErrorSize]
+	}
+
+	err := o.outboxRepo.MarkAttemptFailed(ctx, message.ID, status, o.backoff(attempts), lastError)
+	if err != nil {
+		logger.GetLogger().Errorf("[OutboxService][RelayPending] Error MarkAttemptFailed with err: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (o *outboxService) backoff(attempts int32) time.Duration {
+	delay := models.DefaultInitialBackoff
+	if o.config.InitialBackoffSeconds > 0 {
+		delay = time.Duration(o.config.InitialBackoffSeconds) * time.Second
+	}
+	maxDelay := models.DefaultMaxBackoff
+	if o.config.MaxBackoffSeconds > 0 {
+		maxDelay = time.Duration(o.config.MaxBackoffSeconds) * time.Second
+	}
+
+	for i := int32(1); i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+type OutboxServiceInterface interface {
+	RelayPending(ctx context.Context) (int32, error)
+	BatchSize() int
+}
+
+func NewOutboxService(outboxRepo repositories.OutboxRepositoryInterface, publisher Publisher, routes map[string]models.Route, config configs.OutboxConfig) OutboxServiceInterface {
+	return &outboxService{outboxRepo, publisher, routes, config}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/okiww/billing-loan-system/configs"
+	outbox_mock "github.com/okiww/billing-loan-system/gen/mocks/outbox"
+	"github.com/okiww/billing-loan-system/internal/outbox/models"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelayPending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := outbox_mock.NewMockOutboxRepositoryInterface(ctrl)
+	mockPublisher := outbox_mock.NewMockPublisher(ctrl)
+	config := configs.OutboxConfig{BatchSize: 10, MaxAttempts: 3, InitialBackoffSeconds: 2, MaxBackoffSeconds: 60}
+	routes := map[string]models.Route{models.EventPaymentCreated: {Exchange: "billing.events", RoutingKey: models.EventPaymentCreated}}
+	service := NewOutboxService(mockRepo, mockPublisher, routes, config)
+
+	message := func(id int64, eventType string, attempts int32) models.OutboxMessageModel {
+		return models.OutboxMessageModel{ID: id, AggregateType: models.AggregatePayment, AggregateID: id, EventType: eventType,
+			Payload: `{"ID":1}`, Status: models.StatusPending, Attempts: attempts}
+	}
+
+	tests := []struct {
+		name      string
+		mockCalls func()
+		want      int32
+		wantErr   bool
+	}{
+		{
+			name: "Success publish messages in order",
+			mockCalls: func() {
+				mockRepo.EXPECT().ClaimPendingMessages(gomock.Any(), 10, models.DefaultClaimTimeout).
+					Return([]models.OutboxMessageModel{message(1, models.EventPaymentCreated, 0), message(2, models.EventPaymentCreated, 1)}, nil)
+				gomock.InOrder(
+					mockPublisher.EXPECT().Publish("billing.events", models.EventPaymentCreated, `{"ID":1}`).Return(nil),
+					mockRepo.EXPECT().MarkSent(gomock.Any(), int64(1)).Return(nil),
+					mockPublisher.EXPECT().Publish("billing.events", models.EventPaymentCreated, `{"ID":1}`).Return(nil),
+					mockRepo.EXPECT().MarkSent(gomock.Any(), int64(2)).Return(nil),
+				)
+			},
+			want: 2,
+		},
+		{
+			name: "Retry with backoff when publish fails",
+			mockCalls: func() {
+				mockRepo.EXPECT().ClaimPendingMessages(gomock.Any(), 10, models.DefaultClaimTimeout).
+					Return([]models.OutboxMessageModel{message(1, models.EventPaymentCreated, 1), message(2, models.EventPaymentCreated, 0)}, nil)
+				mockPublisher.EXPECT().Publish("billing.events", models.EventPaymentCreated, gomock.Any()).Return(errors.New("connection closed"))
+				// second attempt failed, the third one is due 4 seconds later
+				mockRepo.EXPECT().MarkAttemptFailed(gomock.Any(), int64(1), models.StatusPending, 4*time.Second, "connection closed").Return(nil)
+				mockPublisher.EXPECT().Publish("billing.events", models.EventPaymentCreated, gomock.Any()).Return(nil)
+				mockRepo.EXPECT().MarkSent(gomock.Any(), int64(2)).Return(nil)
+			},
+			want: 1,
+		},
+		{
+			name: "Fail message which ran out of attempts",
+			mockCalls: func() {
+				mockRepo.EXPECT().ClaimPendingMessages(gomock.Any(), 10, models.DefaultClaimTimeout).
+					Return([]models.OutboxMessageModel{message(1, models.EventPaymentCreated, 2)}, nil)
+				mockPublisher.EXPECT().Publish("billing.events", models.EventPaymentCreated, gomock.Any()).Return(errors.New("connection closed"))
+				mockRepo.EXPECT().MarkAttemptFailed(gomock.Any(), int64(1), models.StatusFailed, 8*time.Second, "connection closed").Return(nil)
+			},
+			want: 0,
+		},
+		{
+			name: "Fail message without route",
+			mockCalls: func() {
+				mockRepo.EXPECT().ClaimPendingMessages(gomock.Any(), 10, models.DefaultClaimTimeout).
+					Return([]models.OutboxMessageModel{message(1, "loan.created", 0)}, nil)
+				mockRepo.EXPECT().MarkAttemptFailed(gomock.Any(), int64(1), models.StatusFailed, 2*time.Second, models.ErrorNoRoute).Return(nil)
+			},
+			want: 0,
+		},
+		{
+			name: "Error fetch pending messages",
+			mockCalls: func() {
+				mockRepo.EXPECT().ClaimPendingMessages(gomock.Any(), 10, models.DefaultClaimTimeout).Return(nil, errors.New("db error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error mark sent",
+			mockCalls: func() {
+				mockRepo.EXPECT().ClaimPendingMessages(gomock.Any(), 10, models.DefaultClaimTimeout).
+					Return([]models.OutboxMessageModel{message(1, models.EventPaymentCreated, 0)}, nil)
+				mockPublisher.EXPECT().Publish("billing.events", models.EventPaymentCreated, gomock.Any()).Return(nil)
+				mockRepo.EXPECT().MarkSent(gomock.Any(), int64(1)).Return(errors.New("db error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockCalls()
+			got, err := service.RelayPending(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RelayPending() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
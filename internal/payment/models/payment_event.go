@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// PaymentCreatedVersion the schema version of the payment.created payload
+const PaymentCreatedVersion = 1
+
+// PaymentCreatedEvent the payload of the payment.created event, published once a payment is saved for the worker to
+// process it
+type PaymentCreatedEvent struct {
+	PaymentID   int       `json:"payment_id"`
+	UserID      int       `json:"user_id"`
+	LoanID      int       `json:"loan_id"`
+	LoanBillID  int       `json:"loan_bill_id"`
+	Amount      int       `json:"amount"`
+	PaymentType string    `json:"payment_type"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewPaymentCreatedEvent builds the payload announcing a payment
+func NewPaymentCreatedEvent(payment Payment) PaymentCreatedEvent {
+	return PaymentCreatedEvent{
+		PaymentID:   payment.ID,
+		UserID:      payment.UserID,
+		LoanID:      payment.LoanID,
+		LoanBillID:  payment.LoanBillID,
+		Amount:      payment.Amount,
+		PaymentType: payment.PaymentType,
+		Status:      payment.Status,
+		CreatedAt:   payment.CreatedAt,
+	}
+}
+
+// Payment the payment the event announces
+func (e PaymentCreatedEvent) Payment() Payment {
+	return Payment{
+		ID:          e.PaymentID,
+		UserID:      e.UserID,
+		LoanID:      e.LoanID,
+		LoanBillID:  e.LoanBillID,
+		Amount:      e.Amount,
+		PaymentType: e.PaymentType,
+		Status:      e.Status,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+// PaymentSettledVersion the schema version of the payment.completed and payment.failed payloads
+const PaymentSettledVersion = 1
+
+// PaymentSettledEvent the payload of the payment.completed and payment.failed events, published once the worker
+// applied a payment to its loan or failed it
+type PaymentSettledEvent struct {
+	PaymentID   int    `json:"payment_id"`
+	UserID      int    `json:"user_id"`
+	LoanID      int    `json:"loan_id"`
+	LoanBillID  int    `json:"loan_bill_id"`
+	Amount      int    `json:"amount"`
+	PaymentType string `json:"payment_type"`
+	Status      string `json:"status"`
+	Note        string `json:"note,omitempty"` // Why a payment FAILED
+}
+
+// NewPaymentSettledEvent builds the payload announcing the final status of a payment
+func NewPaymentSettledEvent(payment Payment, status, note string) PaymentSettledEvent {
+	return PaymentSettledEvent{
+		PaymentID:   payment.ID,
+		UserID:      payment.UserID,
+		LoanID:      payment.LoanID,
+		LoanBillID:  payment.LoanBillID,
+		Amount:      payment.Amount,
+		PaymentType: payment.PaymentType,
+		Status:      status,
+		Note:        note,
+	}
+}
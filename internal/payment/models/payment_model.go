@@ -3,15 +3,16 @@ package models
 import "time"
 
 type Payment struct {
-	ID         int        `db:"id"`
-	UserID     int        `db:"user_id"`
-	LoanID     int        `db:"loan_id"`
-	LoanBillID int        `db:"loan_bill_id"`
-	Amount     int        `db:"amount"`
-	Status     string     `db:"status"`
-	Note       *string    `db:"note"`
-	CreatedAt  time.Time  `db:"created_at"`
-	UpdatedAt  *time.Time `db:"updated_at"`
+	ID          int        `db:"id"`
+	UserID      int        `db:"user_id"`
+	LoanID      int        `db:"loan_id"`
+	LoanBillID  int        `db:"loan_bill_id"`
+	Amount      int        `db:"amount"`
+	PaymentType string     `db:"payment_type"`
+	Status      string     `db:"status"`
+	Note        *string    `db:"note"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   *time.Time `db:"updated_at"`
 }
 
 const (
@@ -20,6 +21,25 @@ const (
 	StatusCompleted = "COMPLETED"
 	StatusFailed    = "FAILED"
 
+	PaymentTypeRepayment = "REPAYMENT"
+	PaymentTypeRecovery  = "RECOVERY"
+
 	Note_Complete                 = "Payment Completed"
 	Note_Failed_With_ERROR_SYSTEM = "Failed process payment, please try again"
+	Note_Failed_Bill_Already_Paid = "Failed process payment, the bill is already paid"
+	Note_Failed_Bill_Not_Payable  = "Failed process payment, the bill is no longer payable"
+	Note_Failed_Loan_Not_Active   = "Failed process payment, the loan is no longer active"
+
+	// ConsumerPaymentWorker the consumer the worker records its processed messages under
+	ConsumerPaymentWorker = "payment_worker"
+)
+
+// Results of processing a payment message
+const (
+	ProcessResultCompleted      = "COMPLETED"         // The payment is applied to the loan
+	ProcessResultDuplicate      = "DUPLICATE"         // The message was processed already
+	ProcessResultSettled        = "SETTLED"           // The payment was COMPLETED or FAILED already
+	ProcessResultBillPaid       = "BILL_ALREADY_PAID" // The bill was paid by another payment, the payment is FAILED
+	ProcessResultBillNotPayable = "BILL_NOT_PAYABLE"  // The bill was superseded or written off, the payment is FAILED
+	ProcessResultLoanNotActive  = "LOAN_NOT_ACTIVE"   // The loan was closed or written off, the payment is FAILED
 )
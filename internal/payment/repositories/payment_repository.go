@@ -2,12 +2,18 @@ package repositories
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/okiww/billing-loan-system/helpers"
+	loanModel "github.com/okiww/billing-loan-system/internal/loan/models"
+	outboxModel "github.com/okiww/billing-loan-system/internal/outbox/models"
+	outboxRepo "github.com/okiww/billing-loan-system/internal/outbox/repositories"
 	"github.com/okiww/billing-loan-system/internal/payment/models"
 	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/logger"
 )
 
 var (
@@ -21,10 +27,14 @@ type paymentRepository struct {
 
 func (p *paymentRepository) Create(ctx context.Context, payment *models.Payment) (int32, error) {
 	query := `
-		INSERT INTO payments (user_id, loan_id, loan_bill_id, amount, status, created_at)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO payments (user_id, loan_id, loan_bill_id, amount, payment_type, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
-	result, err := p.DB.ExecContext(ctx, query, payment.UserID, payment.LoanID, payment.LoanBillID, payment.Amount, payment.Status, time.Now())
+	paymentType := payment.PaymentType
+	if paymentType == "" {
+		paymentType = models.PaymentTypeRepayment
+	}
+	result, err := p.DB.ExecContext(ctx, query, payment.UserID, payment.LoanID, payment.LoanBillID, payment.Amount, paymentType, payment.Status, p.Now())
 	if err != nil {
 		return 0, err
 	}
@@ -37,11 +47,60 @@ func (p *paymentRepository) Create(ctx context.Context, payment *models.Payment)
 	return int32(id), nil
 }
 
+// CreateWithOutboxInTx saves a payment and the outbox message announcing it in one transaction, the payload of the
+// message is the envelope of the saved payment so the relay publishes it to the worker once the payment is committed.
+// The payment is dated by its CreatedAt, or now when it has none
+func (p *paymentRepository) CreateWithOutboxInTx(ctx context.Context, payment *models.Payment, eventType string) (int32, error) {
+	var id int32
+	err := p.ExecTx(ctx, p.DB, func(tx *sqlx.Tx) error {
+		if payment.PaymentType == "" {
+			payment.PaymentType = models.PaymentTypeRepayment
+		}
+		now := payment.CreatedAt
+		if now.IsZero() {
+			now = p.Now()
+		}
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO payments (user_id, loan_id, loan_bill_id, amount, payment_type, status, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, payment.UserID, payment.LoanID, payment.LoanBillID, payment.Amount, payment.PaymentType, payment.Status, now)
+		if err != nil {
+			logger.GetLogger().Errorf("[PaymentRepository][CreateWithOutboxInTx] Error insert payment with err: %v", err)
+			return err
+		}
+
+		lastInsertID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		payment.ID = int(lastInsertID)
+		payment.CreatedAt = now
+
+		message, err := outboxModel.NewMessage(ctx, outboxModel.AggregatePayment, lastInsertID, eventType, models.PaymentCreatedVersion, now,
+			models.NewPaymentCreatedEvent(*payment))
+		if err != nil {
+			return err
+		}
+		err = outboxRepo.InsertMessageInTx(ctx, tx, message)
+		if err != nil {
+			logger.GetLogger().Errorf("[PaymentRepository][CreateWithOutboxInTx] Error insert outbox message with err: %v", err)
+			return err
+		}
+
+		id = int32(lastInsertID)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
 func (p *paymentRepository) UpdatePaymentStatus(ctx context.Context, id int32, status string, note string) error {
 	query := `
 		UPDATE payments SET status = ?, updated_at = ?, note = ? WHERE id = ?
 	`
-	_, err := p.DB.ExecContext(ctx, query, status, time.Now(), note, id)
+	_, err := p.DB.ExecContext(ctx, query, status, p.Now(), note, id)
 	if err != nil {
 		return err
 	}
@@ -51,7 +110,7 @@ func (p *paymentRepository) UpdatePaymentStatus(ctx context.Context, id int32, s
 
 func (p *paymentRepository) GetPaymentByID(ctx context.Context, id int32) (*models.Payment, error) {
 
-	query := "SELECT id, user_id, loan_id, loan_bill_id, amount, status, created_at, updated_at, note FROM payments WHERE id = ?"
+	query := "SELECT id, user_id, loan_id, loan_bill_id, amount, payment_type, status, created_at, updated_at, note FROM payments WHERE id = ?"
 
 	rows, err := p.DB.QueryxContext(ctx, query, id)
 	if err != nil {
@@ -73,10 +132,205 @@ func (p *paymentRepository) GetPaymentByID(ctx context.Context, id int32) (*mode
 	return &payment, nil
 }
 
+// ProcessPaymentInTx applies a payment to its loan once in one transaction. The message is recorded as processed, the
+// payment row is locked so a concurrent delivery waits, a payment already COMPLETED or FAILED is left as is. A repayment
+// is applied only to a BILLED or OVERDUE bill of an ACTIVE loan, a bill paid, superseded or written off in the meantime
+// or a loan no longer active fails the payment instead of reducing the outstanding amount again
+func (p *paymentRepository) ProcessPaymentInTx(ctx context.Context, messageID string, paymentID int32) (string, error) {
+	var result string
+	err := p.ExecTx(ctx, p.DB, func(tx *sqlx.Tx) error {
+		now := p.Now()
+		inserted, err := tx.ExecContext(ctx, `
+			INSERT IGNORE INTO processed_messages (consumer, message_id, processed_at) VALUES (?, ?, ?)
+		`, models.ConsumerPaymentWorker, messageID, now)
+		if err != nil {
+			logger.GetLogger().Errorf("[PaymentRepository][ProcessPaymentInTx] Error insert processed message with err: %v", err)
+			return err
+		}
+		affected, err := inserted.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			result = models.ProcessResultDuplicate
+			return nil
+		}
+
+		var payment models.Payment
+		err = tx.GetContext(ctx, &payment, `
+			SELECT id, user_id, loan_id, loan_bill_id, amount, payment_type, status, created_at, updated_at, note
+			FROM payments WHERE id = ? FOR UPDATE
+		`, paymentID)
+		if err != nil {
+			logger.GetLogger().Errorf("[PaymentRepository][ProcessPaymentInTx] Error lock payment with err: %v", err)
+			return err
+		}
+		if payment.Status == models.StatusCompleted || payment.Status == models.StatusFailed {
+			result = models.ProcessResultSettled
+			return nil
+		}
+
+		if payment.PaymentType == models.PaymentTypeRecovery {
+			// Add to the recovered amount of the written off loan
+			updated, err := tx.ExecContext(ctx, `
+				UPDATE loans SET recovered_amount = recovered_amount + ? WHERE id = ? AND status = ?
+			`, payment.Amount, payment.LoanID, loanModel.StatusWrittenOff)
+			if err != nil {
+				return err
+			}
+			affected, err := updated.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if affected == 0 {
+				return fmt.Errorf("no written off loan found with id %d", payment.LoanID)
+			}
+		} else {
+			// the loan is locked before its bill, in the order restructuring, deferral and write-off lock them
+			var loanStatus, billStatus string
+			err = tx.GetContext(ctx, &loanStatus, `SELECT status FROM loans WHERE id = ? FOR UPDATE`, payment.LoanID)
+			if err != nil {
+				logger.GetLogger().Errorf("[PaymentRepository][ProcessPaymentInTx] Error lock loan with err: %v", err)
+				return err
+			}
+			err = tx.GetContext(ctx, &billStatus, `SELECT status FROM loan_bills WHERE id = ? FOR UPDATE`, payment.LoanBillID)
+			if err != nil {
+				logger.GetLogger().Errorf("[PaymentRepository][ProcessPaymentInTx] Error lock loan bill with err: %v", err)
+				return err
+			}
+
+			switch {
+			case billStatus == loanModel.StatusPaid:
+				result = models.ProcessResultBillPaid
+				return failPaymentInTx(ctx, tx, payment, models.Note_Failed_Bill_Already_Paid, now)
+			case loanStatus != loanModel.StatusActive:
+				result = models.ProcessResultLoanNotActive
+				return failPaymentInTx(ctx, tx, payment, models.Note_Failed_Loan_Not_Active, now)
+			case billStatus != loanModel.StatusBilled && billStatus != loanModel.StatusOverdue:
+				result = models.ProcessResultBillNotPayable
+				return failPaymentInTx(ctx, tx, payment, models.Note_Failed_Bill_Not_Payable, now)
+			}
+
+			// Update loan bill to Paid, the loan outstanding and status
+			_, err = tx.ExecContext(ctx, `
+				UPDATE loan_bills SET status = ?, updated_at = ? WHERE id = ?
+			`, loanModel.StatusPaid, now, payment.LoanBillID)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, `
+				UPDATE loans
+				SET
+					outstanding_amount = outstanding_amount - ?,
+					status = CASE
+						WHEN outstanding_amount = 0 THEN ?
+						ELSE status
+					END
+				WHERE id = ?
+			`, payment.Amount, loanModel.StatusClosed, payment.LoanID)
+			if err != nil {
+				return err
+			}
+
+			err = tx.GetContext(ctx, &loanStatus, `SELECT status FROM loans WHERE id = ?`, payment.LoanID)
+			if err != nil {
+				return err
+			}
+			if loanStatus == loanModel.StatusClosed {
+				message, err := outboxModel.NewMessage(ctx, outboxModel.AggregateLoan, int64(payment.LoanID), outboxModel.EventLoanClosed,
+					loanModel.LoanClosedVersion, now, loanModel.LoanClosedEvent{
+						LoanID:    int64(payment.LoanID),
+						UserID:    int64(payment.UserID),
+						PaymentID: int64(payment.ID),
+					})
+				if err != nil {
+					return err
+				}
+				err = outboxRepo.InsertMessageInTx(ctx, tx, message)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE payments SET status = ?, updated_at = ?, note = ? WHERE id = ?
+		`, models.StatusCompleted, now, "", payment.ID)
+		if err != nil {
+			return err
+		}
+		result = models.ProcessResultCompleted
+		return insertPaymentSettledInTx(ctx, tx, payment, models.StatusCompleted, "", now)
+	})
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// MarkPaymentFailed fails a payment which isn't COMPLETED or FAILED yet, a payment settled by another delivery is left as is
+func (p *paymentRepository) MarkPaymentFailed(ctx context.Context, id int32, note string) error {
+	return p.ExecTx(ctx, p.DB, func(tx *sqlx.Tx) error {
+		var payment models.Payment
+		err := tx.GetContext(ctx, &payment, `
+			SELECT id, user_id, loan_id, loan_bill_id, amount, payment_type, status, created_at, updated_at, note
+			FROM payments WHERE id = ? FOR UPDATE
+		`, id)
+		if err != nil {
+			return err
+		}
+		if payment.Status != models.StatusPending && payment.Status != models.StatusProcess {
+			return nil
+		}
+
+		now := p.Now()
+		_, err = tx.ExecContext(ctx, `
+			UPDATE payments SET status = ?, updated_at = ?, note = ? WHERE id = ?
+		`, models.StatusFailed, now, note, id)
+		if err != nil {
+			return err
+		}
+		return insertPaymentSettledInTx(ctx, tx, payment, models.StatusFailed, note, now)
+	})
+}
+
+// failPaymentInTx fails a payment which can't be applied with note and writes its payment.failed event to the outbox
+func failPaymentInTx(ctx context.Context, tx *sqlx.Tx, payment models.Payment, note string, now time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE payments SET status = ?, updated_at = ?, note = ? WHERE id = ?
+	`, models.StatusFailed, now, note, payment.ID)
+	if err != nil {
+		return err
+	}
+	return insertPaymentSettledInTx(ctx, tx, payment, models.StatusFailed, note, now)
+}
+
+// insertPaymentSettledInTx writes the payment.completed or payment.failed event of a payment to the outbox
+func insertPaymentSettledInTx(ctx context.Context, tx *sqlx.Tx, payment models.Payment, status, note string, now time.Time) error {
+	eventType := outboxModel.EventPaymentCompleted
+	if status == models.StatusFailed {
+		eventType = outboxModel.EventPaymentFailed
+	}
+	message, err := outboxModel.NewMessage(ctx, outboxModel.AggregatePayment, int64(payment.ID), eventType, models.PaymentSettledVersion,
+		now, models.NewPaymentSettledEvent(payment, status, note))
+	if err != nil {
+		return err
+	}
+	err = outboxRepo.InsertMessageInTx(ctx, tx, message)
+	if err != nil {
+		logger.GetLogger().Errorf("[PaymentRepository][insertPaymentSettledInTx] Error insert outbox message with err: %v", err)
+		return err
+	}
+	return nil
+}
+
 type PaymentRepositoryInterface interface {
 	Create(ctx context.Context, payment *models.Payment) (int32, error)
+	CreateWithOutboxInTx(ctx context.Context, payment *models.Payment, eventType string) (int32, error)
 	UpdatePaymentStatus(ctx context.Context, id int32, status string, note string) error
 	GetPaymentByID(ctx context.Context, id int32) (*models.Payment, error)
+	ProcessPaymentInTx(ctx context.Context, messageID string, paymentID int32) (string, error)
+	MarkPaymentFailed(ctx context.Context, id int32, note string) error
 }
 
 func NewPaymentRepository(db *mysql.DBMySQL) PaymentRepositoryInterface {
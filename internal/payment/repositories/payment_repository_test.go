@@ -2,12 +2,16 @@ package repositories
 
 import (
 	"context"
+	"database/sql/driver"
 	"regexp"
 	"testing"
 	"time"
 
+	outboxModel "github.com/okiww/billing-loan-system/internal/outbox/models"
 	"github.com/okiww/billing-loan-system/internal/payment/models"
+	"github.com/okiww/billing-loan-system/pkg/clock"
 	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/event"
 	"github.com/stretchr/testify/assert"
 	sqlmock "github.com/zhashkevych/go-sqlxmock"
 )
@@ -45,8 +49,8 @@ func TestCreate(t *testing.T) {
 			wantErr: false,
 			mock: func(a args) {
 				mock.ExpectExec(regexp.QuoteMeta(
-					"INSERT INTO payments (user_id, loan_id, loan_bill_id, amount, status, created_at) VALUES (?, ?, ?, ?, ?, ?)")).
-					WithArgs(a.payment.UserID, a.payment.LoanID, a.payment.LoanBillID, a.payment.Amount, a.payment.Status, sqlmock.AnyArg()).
+					"INSERT INTO payments (user_id, loan_id, loan_bill_id, amount, payment_type, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)")).
+					WithArgs(a.payment.UserID, a.payment.LoanID, a.payment.LoanBillID, a.payment.Amount, models.PaymentTypeRepayment, a.payment.Status, sqlmock.AnyArg()).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 		},
@@ -65,8 +69,8 @@ func TestCreate(t *testing.T) {
 			wantErr: true,
 			mock: func(a args) {
 				mock.ExpectExec(regexp.QuoteMeta(
-					"INSERT INTO payments (user_id, loan_id, loan_bill_id, amount, status, created_at) VALUES (?, ?, ?, ?, ?, ?)")).
-					WithArgs(a.payment.UserID, a.payment.LoanID, a.payment.LoanBillID, a.payment.Amount, a.payment.Status, sqlmock.AnyArg()).
+					"INSERT INTO payments (user_id, loan_id, loan_bill_id, amount, payment_type, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)")).
+					WithArgs(a.payment.UserID, a.payment.LoanID, a.payment.LoanBillID, a.payment.Amount, models.PaymentTypeRepayment, a.payment.Status, sqlmock.AnyArg()).
 					WillReturnError(assert.AnError)
 			},
 		},
@@ -188,7 +192,7 @@ func TestGetPaymentByID(t *testing.T) {
 				rows := sqlmock.NewRows([]string{"id", "user_id", "loan_id", "loan_bill_id", "amount", "status", "created_at"}).
 					AddRow(1, 1, 2, 3, 5000, "PAID", time.Now())
 
-				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, user_id, loan_id, loan_bill_id, amount, status, created_at, updated_at, note FROM payments WHERE id = ?")).
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, user_id, loan_id, loan_bill_id, amount, payment_type, status, created_at, updated_at, note FROM payments WHERE id = ?")).
 					WithArgs(a.id).
 					WillReturnRows(rows)
 			},
@@ -199,7 +203,7 @@ func TestGetPaymentByID(t *testing.T) {
 			want:    nil,
 			wantErr: true,
 			mock: func(a args) {
-				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, user_id, loan_id, loan_bill_id, amount, status, created_at, updated_at, note FROM payments WHERE id = ?")).
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, user_id, loan_id, loan_bill_id, amount, payment_type, status, created_at, updated_at, note FROM payments WHERE id = ?")).
 					WithArgs(a.id).
 					WillReturnError(assert.AnError)
 			},
@@ -223,3 +227,364 @@ func TestGetPaymentByID(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateWithOutboxInTx(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	mockDB := &mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)}
+	repo := NewPaymentRepository(mockDB)
+
+	paymentQuery := regexp.QuoteMeta("INSERT INTO payments (user_id, loan_id, loan_bill_id, amount, payment_type, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	outboxQuery := regexp.QuoteMeta("INSERT INTO outbox_messages (aggregate_type, aggregate_id, event_type, payload, status, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, NOW(3), ?)")
+	payload := envelopeOf{
+		eventType:  outboxModel.EventPaymentCreated,
+		version:    models.PaymentCreatedVersion,
+		occurredAt: now,
+		payload:    `{"payment_id":7,"user_id":1,"loan_id":2,"loan_bill_id":3,"amount":5000,"payment_type":"REPAYMENT","status":"PENDING","created_at":"2024-12-23T10:00:00Z"}`,
+	}
+
+	tests := []struct {
+		name    string
+		mock    func()
+		wantID  int32
+		wantErr bool
+	}{
+		{
+			name: "Success - Payment And Outbox Message Created",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(paymentQuery).
+					WithArgs(1, 2, 3, 5000, models.PaymentTypeRepayment, models.StatusPending, now).
+					WillReturnResult(sqlmock.NewResult(7, 1))
+				mock.ExpectExec(outboxQuery).
+					WithArgs(outboxModel.AggregatePayment, 7, outboxModel.EventPaymentCreated, payload, outboxModel.StatusPending, now).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			wantID: 7,
+		},
+		{
+			name: "Outbox Error Rolls Back The Payment",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(paymentQuery).WillReturnResult(sqlmock.NewResult(7, 1))
+				mock.ExpectExec(outboxQuery).WillReturnError(assert.AnError)
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			payment := &models.Payment{UserID: 1, LoanID: 2, LoanBillID: 3, Amount: 5000, Status: models.StatusPending}
+			id, err := repo.CreateWithOutboxInTx(context.Background(), payment, outboxModel.EventPaymentCreated)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateWithOutboxInTx() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantID, id)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// envelopeOf matches an outbox payload wrapping payload in an envelope of the event, the event id is random
+type envelopeOf struct {
+	eventType  string
+	version    int
+	occurredAt time.Time
+	payload    string
+}
+
+func (e envelopeOf) Match(v driver.Value) bool {
+	body, ok := v.(string)
+	if !ok {
+		return false
+	}
+	envelope, err := event.Decode([]byte(body))
+	if err != nil {
+		return false
+	}
+	return envelope.Type == e.eventType && envelope.Version == e.version && envelope.OccurredAt.Equal(e.occurredAt) &&
+		envelope.CorrelationID == envelope.EventID && string(envelope.Payload) == e.payload
+}
+
+func TestProcessPaymentInTx(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	mockDB := &mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)}
+	repo := NewPaymentRepository(mockDB)
+
+	processedQuery := regexp.QuoteMeta("INSERT IGNORE INTO processed_messages (consumer, message_id, processed_at) VALUES (?, ?, ?)")
+	lockPaymentQuery := regexp.QuoteMeta("FROM payments WHERE id = ? FOR UPDATE")
+	lockLoanQuery := regexp.QuoteMeta("SELECT status FROM loans WHERE id = ? FOR UPDATE")
+	lockBillQuery := regexp.QuoteMeta("SELECT status FROM loan_bills WHERE id = ? FOR UPDATE")
+	paidQuery := regexp.QuoteMeta("UPDATE loan_bills SET status = ?, updated_at = ? WHERE id = ?")
+	outstandingQuery := regexp.QuoteMeta("outstanding_amount = outstanding_amount - ?")
+	recoveredQuery := regexp.QuoteMeta("UPDATE loans SET recovered_amount = recovered_amount + ? WHERE id = ? AND status = ?")
+	paymentStatusQuery := regexp.QuoteMeta("UPDATE payments SET status = ?, updated_at = ?, note = ? WHERE id = ?")
+	loanStatusQuery := regexp.QuoteMeta("SELECT status FROM loans WHERE id = ?")
+	outboxQuery := regexp.QuoteMeta("INSERT INTO outbox_messages")
+	paymentColumns := []string{"id", "user_id", "loan_id", "loan_bill_id", "amount", "payment_type", "status", "created_at", "updated_at", "note"}
+	paymentRow := func(paymentType, status string) *sqlmock.Rows {
+		return sqlmock.NewRows(paymentColumns).AddRow(7, 1, 2, 3, 5000, paymentType, status, now, nil, nil)
+	}
+	lockLoanAndBill := func(loanStatus, billStatus string) {
+		mock.ExpectQuery(lockLoanQuery).WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(loanStatus))
+		mock.ExpectQuery(lockBillQuery).WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(billStatus))
+	}
+	failed := func(note string) {
+		mock.ExpectExec(paymentStatusQuery).
+			WithArgs(models.StatusFailed, now, note, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(outboxQuery).
+			WithArgs("payment", int64(7), "payment.failed", sqlmock.AnyArg(), "PENDING", now).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+
+	tests := []struct {
+		name       string
+		mock       func()
+		wantResult string
+		wantErr    bool
+	}{
+		{
+			name: "Success - Repayment Applied",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(processedQuery).
+					WithArgs(models.ConsumerPaymentWorker, "payment.created:7", now).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).
+					WillReturnRows(paymentRow(models.PaymentTypeRepayment, models.StatusPending))
+				lockLoanAndBill("ACTIVE", "BILLED")
+				mock.ExpectExec(paidQuery).WithArgs("PAID", now, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(outstandingQuery).WithArgs(5000, "CLOSED", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(loanStatusQuery).WithArgs(2).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("ACTIVE"))
+				mock.ExpectExec(paymentStatusQuery).
+					WithArgs(models.StatusCompleted, now, "", 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(outboxQuery).
+					WithArgs("payment", int64(7), "payment.completed", sqlmock.AnyArg(), "PENDING", now).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			wantResult: models.ProcessResultCompleted,
+		},
+		{
+			name: "Success - Last Repayment Closes Loan",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(processedQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).
+					WillReturnRows(paymentRow(models.PaymentTypeRepayment, models.StatusPending))
+				lockLoanAndBill("ACTIVE", "BILLED")
+				mock.ExpectExec(paidQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(outstandingQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(loanStatusQuery).WithArgs(2).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("CLOSED"))
+				mock.ExpectExec(outboxQuery).
+					WithArgs("loan", int64(2), "loan.closed", sqlmock.AnyArg(), "PENDING", now).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(paymentStatusQuery).
+					WithArgs(models.StatusCompleted, now, "", 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(outboxQuery).
+					WithArgs("payment", int64(7), "payment.completed", sqlmock.AnyArg(), "PENDING", now).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+			},
+			wantResult: models.ProcessResultCompleted,
+		},
+		{
+			name: "Success - Recovery Applied",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(processedQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).
+					WillReturnRows(paymentRow(models.PaymentTypeRecovery, models.StatusPending))
+				mock.ExpectExec(recoveredQuery).WithArgs(5000, 2, "WRITTEN_OFF").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(paymentStatusQuery).
+					WithArgs(models.StatusCompleted, now, "", 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(outboxQuery).
+					WithArgs("payment", int64(7), "payment.completed", sqlmock.AnyArg(), "PENDING", now).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			wantResult: models.ProcessResultCompleted,
+		},
+		{
+			name: "Skip - Message Processed Already",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(processedQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			wantResult: models.ProcessResultDuplicate,
+		},
+		{
+			name: "Skip - Payment Completed Already",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(processedQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).
+					WillReturnRows(paymentRow(models.PaymentTypeRepayment, models.StatusCompleted))
+				mock.ExpectCommit()
+			},
+			wantResult: models.ProcessResultSettled,
+		},
+		{
+			name: "Fail Payment - Bill Paid Already",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(processedQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).
+					WillReturnRows(paymentRow(models.PaymentTypeRepayment, models.StatusPending))
+				lockLoanAndBill("ACTIVE", "PAID")
+				failed(models.Note_Failed_Bill_Already_Paid)
+			},
+			wantResult: models.ProcessResultBillPaid,
+		},
+		{
+			name: "Fail Payment - Bill Superseded By A Restructure",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(processedQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).
+					WillReturnRows(paymentRow(models.PaymentTypeRepayment, models.StatusPending))
+				lockLoanAndBill("ACTIVE", "SUPERSEDED")
+				failed(models.Note_Failed_Bill_Not_Payable)
+			},
+			wantResult: models.ProcessResultBillNotPayable,
+		},
+		{
+			name: "Fail Payment - Loan Written Off",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(processedQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).
+					WillReturnRows(paymentRow(models.PaymentTypeRepayment, models.StatusPending))
+				lockLoanAndBill("WRITTEN_OFF", "WRITTEN_OFF")
+				failed(models.Note_Failed_Loan_Not_Active)
+			},
+			wantResult: models.ProcessResultLoanNotActive,
+		},
+		{
+			name: "Error - Loan Not Written Off Rolls Back",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(processedQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).
+					WillReturnRows(paymentRow(models.PaymentTypeRecovery, models.StatusPending))
+				mock.ExpectExec(recoveredQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "Error - Outstanding Update Rolls Back",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(processedQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).
+					WillReturnRows(paymentRow(models.PaymentTypeRepayment, models.StatusProcess))
+				lockLoanAndBill("ACTIVE", "OVERDUE")
+				mock.ExpectExec(paidQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(outstandingQuery).WillReturnError(assert.AnError)
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			result, err := repo.ProcessPaymentInTx(context.Background(), "payment.created:7", 7)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ProcessPaymentInTx() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.wantResult, result)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMarkPaymentFailed(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	mockDB := &mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)}
+	repo := NewPaymentRepository(mockDB)
+
+	lockPaymentQuery := regexp.QuoteMeta("FROM payments WHERE id = ? FOR UPDATE")
+	query := regexp.QuoteMeta("UPDATE payments SET status = ?, updated_at = ?, note = ? WHERE id = ?")
+	paymentColumns := []string{"id", "user_id", "loan_id", "loan_bill_id", "amount", "payment_type", "status", "created_at", "updated_at", "note"}
+	paymentRow := func(status string) *sqlmock.Rows {
+		return sqlmock.NewRows(paymentColumns).AddRow(7, 1, 2, 3, 5000, models.PaymentTypeRepayment, status, now, nil, nil)
+	}
+
+	tests := []struct {
+		name    string
+		mock    func()
+		wantErr bool
+	}{
+		{
+			name: "Success - Unsettled Payment Failed",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).WillReturnRows(paymentRow(models.StatusPending))
+				mock.ExpectExec(query).
+					WithArgs(models.StatusFailed, now, models.Note_Failed_With_ERROR_SYSTEM, int32(7)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox_messages")).
+					WithArgs("payment", int64(7), "payment.failed", sqlmock.AnyArg(), "PENDING", now).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Skip - Payment Completed Already",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).WillReturnRows(paymentRow(models.StatusCompleted))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Error - Database Error",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockPaymentQuery).WithArgs(int32(7)).WillReturnRows(paymentRow(models.StatusProcess))
+				mock.ExpectExec(query).WillReturnError(assert.AnError)
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			err := repo.MarkPaymentFailed(context.Background(), 7, models.Note_Failed_With_ERROR_SYSTEM)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MarkPaymentFailed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -7,16 +7,20 @@ import (
 
 	"github.com/okiww/billing-loan-system/internal/dto"
 	loanRepo "github.com/okiww/billing-loan-system/internal/loan/repositories"
+	outboxModel "github.com/okiww/billing-loan-system/internal/outbox/models"
 	"github.com/okiww/billing-loan-system/internal/payment/models"
 	"github.com/okiww/billing-loan-system/internal/payment/repositories"
+	"github.com/okiww/billing-loan-system/pkg/clock"
 	"github.com/okiww/billing-loan-system/pkg/errors"
 	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/okiww/billing-loan-system/pkg/mq"
 )
 
 type paymentService struct {
 	paymentRepo  repositories.PaymentRepositoryInterface
 	loanRepo     loanRepo.LoanRepositoryInterface
 	loanBillRepo loanRepo.LoanBillRepositoryInterface
+	clock        clock.Clock
 }
 
 // MakePayment is for initial payment
@@ -45,16 +49,18 @@ func (p *paymentService) MakePayment(ctx context.Context, paymentRequest *dto.Pa
 		return nil, errors.New(dto.ErrorLoanIsNotActive)
 	}
 
-	// Insert the payment into the database
-	id, err := p.paymentRepo.Create(ctx, &models.Payment{
-		UserID:     paymentRequest.UserID,
-		LoanID:     paymentRequest.LoanID,
-		LoanBillID: paymentRequest.LoanBillID,
-		Amount:     paymentRequest.Amount,
-		Status:     models.StatusPending,
-	})
+	// Insert the payment into the database, the outbox relay publishes it to the worker once committed
+	id, err := p.paymentRepo.CreateWithOutboxInTx(ctx, &models.Payment{
+		UserID:      paymentRequest.UserID,
+		LoanID:      paymentRequest.LoanID,
+		LoanBillID:  paymentRequest.LoanBillID,
+		Amount:      paymentRequest.Amount,
+		PaymentType: models.PaymentTypeRepayment,
+		Status:      models.StatusPending,
+		CreatedAt:   p.clock.Now(),
+	}, outboxModel.EventPaymentCreated)
 	if err != nil {
-		logger.GetLogger().Errorf("[PaymentService][MakePayment] Error Create with err: %v", err)
+		logger.GetLogger().Errorf("[PaymentService][MakePayment] Error CreateWithOutboxInTx with err: %v", err)
 		return nil, err
 	}
 
@@ -67,49 +73,93 @@ func (p *paymentService) MakePayment(ctx context.Context, paymentRequest *dto.Pa
 	return payment, nil
 }
 
-// ProcessUpdatePayment is for update payment via subscriber
-func (p *paymentService) ProcessUpdatePayment(ctx context.Context, payment models.Payment) error {
-	logger.GetLogger().Info("[PaymentService][ProcessUpdatePayment]")
-	// 1. Update Payment to PROCESS
-	err := p.paymentRepo.UpdatePaymentStatus(ctx, int32(payment.ID), models.StatusProcess, "")
+// MakeRecoveryPayment is for initial payment collected from a written off loan
+func (p *paymentService) MakeRecoveryPayment(ctx context.Context, paymentRequest *dto.RecoveryPaymentRequest) (*models.Payment, error) {
+	logger.GetLogger().Info("[PaymentService][MakeRecoveryPayment]")
+	loan, err := p.loanRepo.GetLoanStatusByID(ctx, int64(paymentRequest.LoanID))
+	if err != nil {
+		return nil, err
+	}
+
+	if loan.Status != loanModel.StatusWrittenOff {
+		return nil, errors.New(dto.ErrorLoanIsNotWrittenOff)
+	}
+
+	// Recovery payments are not tied to a bill, bills of a written off loan are frozen
+	id, err := p.paymentRepo.CreateWithOutboxInTx(ctx, &models.Payment{
+		UserID:      paymentRequest.UserID,
+		LoanID:      paymentRequest.LoanID,
+		Amount:      paymentRequest.Amount,
+		PaymentType: models.PaymentTypeRecovery,
+		Status:      models.StatusPending,
+		CreatedAt:   p.clock.Now(),
+	}, outboxModel.EventPaymentCreated)
 	if err != nil {
-		logger.GetLogger().Errorf("[PaymentService][ProcessUpdatePayment] Error UpdatePaymentStatus with err: %v", err)
-		return err
+		logger.GetLogger().Errorf("[PaymentService][MakeRecoveryPayment] Error CreateWithOutboxInTx with err: %v", err)
+		return nil, err
 	}
 
+	payment, err := p.paymentRepo.GetPaymentByID(ctx, id)
+	if err != nil {
+		logger.GetLogger().Errorf("[PaymentService][MakeRecoveryPayment] Error GetPaymentByID with err: %v", err)
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// ProcessUpdatePayment is for update payment via subscriber, a message delivered again or a payment settled already is
+// skipped so the loan is never paid twice. The payment is marked failed on a permanent error or on the last attempt of
+// the message only, a transient error is returned as is for the message to be retried
+func (p *paymentService) ProcessUpdatePayment(ctx context.Context, messageID string, payment models.Payment) error {
+	logger.GetLogger().Info("[PaymentService][ProcessUpdatePayment]")
 	// IN TX
-	// 2. Update Loan Bills to PAID
+	// 1. Record the message and lock the payment
+	// 2. Update Loan Bills to PAID, or add to the recovered amount of a written off loan
 	// 3. Check if it is last bill of the loan, update Loan status to CLOSED
-	err = p.loanRepo.UpdateLoanAndLoanBillsInTx(ctx, payment.LoanID, payment.LoanBillID, payment.Amount)
+	// 4. Update Payment to Completed
+	result, err := p.paymentRepo.ProcessPaymentInTx(ctx, messageID, int32(payment.ID))
 	if err != nil {
-		logger.GetLogger().Errorf("[PaymentService][UpdatePaymentStatus] Error UpdateLoanAndLoanBillsInTx with err: %v", err)
+		logger.GetLogger().Errorf("[PaymentService][ProcessUpdatePayment] Error ProcessPaymentInTx with err: %v", err)
+		// a transient error rolled the tx back, keep the payment pending so the message retried can process it
+		if errors.IsRetryable(err) && !mq.IsLastAttempt(ctx) {
+			return err
+		}
 		// if error, update payment to failed
-		updateErr := p.paymentRepo.UpdatePaymentStatus(ctx, int32(payment.ID), models.StatusFailed, models.Note_Failed_With_ERROR_SYSTEM)
+		updateErr := p.paymentRepo.MarkPaymentFailed(ctx, int32(payment.ID), models.Note_Failed_With_ERROR_SYSTEM)
 		if updateErr != nil {
-			logger.GetLogger().Errorf("[PaymentService][UpdatePaymentStatus] Error UpdatePaymentStatus to Failed with err: %v", err)
+			logger.GetLogger().Errorf("[PaymentService][ProcessUpdatePayment] Error MarkPaymentFailed with err: %v", updateErr)
 			return updateErr
 		}
-		return err
+		return mq.Permanent(err)
 	}
-	// DONE TX
-	// 4. Update Payment to Completed
-	err = p.paymentRepo.UpdatePaymentStatus(ctx, int32(payment.ID), models.StatusCompleted, "")
-	if err != nil {
-		logger.GetLogger().Errorf("[PaymentService][UpdatePaymentStatus] Error UpdatePaymentStatus to Failed with err: %v", err)
-		return err
+
+	switch result {
+	case models.ProcessResultDuplicate:
+		logger.GetLogger().Infof("[PaymentService][ProcessUpdatePayment] Message %s processed already", messageID)
+	case models.ProcessResultSettled:
+		logger.GetLogger().Infof("[PaymentService][ProcessUpdatePayment] Payment %d settled already", payment.ID)
+	case models.ProcessResultBillPaid:
+		logger.GetLogger().Warnf("[PaymentService][ProcessUpdatePayment] Payment %d failed, loan bill %d is already paid", payment.ID, payment.LoanBillID)
+	case models.ProcessResultBillNotPayable:
+		logger.GetLogger().Warnf("[PaymentService][ProcessUpdatePayment] Payment %d failed, loan bill %d is no longer payable", payment.ID, payment.LoanBillID)
+	case models.ProcessResultLoanNotActive:
+		logger.GetLogger().Warnf("[PaymentService][ProcessUpdatePayment] Payment %d failed, loan %d is no longer active", payment.ID, payment.LoanID)
 	}
 	return nil
 }
 
 type PaymentServiceInterface interface {
 	MakePayment(ctx context.Context, paymentRequest *dto.PaymentRequest) (*models.Payment, error)
-	ProcessUpdatePayment(ctx context.Context, request models.Payment) error
+	MakeRecoveryPayment(ctx context.Context, paymentRequest *dto.RecoveryPaymentRequest) (*models.Payment, error)
+	ProcessUpdatePayment(ctx context.Context, messageID string, request models.Payment) error
 }
 
-func NewPaymentService(paymentRepo repositories.PaymentRepositoryInterface, loanRepo loanRepo.LoanRepositoryInterface, loanBillRepo loanRepo.LoanBillRepositoryInterface) PaymentServiceInterface {
+func NewPaymentService(paymentRepo repositories.PaymentRepositoryInterface, loanRepo loanRepo.LoanRepositoryInterface, loanBillRepo loanRepo.LoanBillRepositoryInterface, clk clock.Clock) PaymentServiceInterface {
 	return &paymentService{
 		paymentRepo:  paymentRepo,
 		loanRepo:     loanRepo,
 		loanBillRepo: loanBillRepo,
+		clock:        clk,
 	}
 }
@@ -2,7 +2,10 @@ package services
 
 import (
 	"context"
+	"database/sql/driver"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	loan_mock "github.com/okiww/billing-loan-system/gen/mocks/loan"
 	payment_mock "github.com/okiww/billing-loan-system/gen/mocks/payment"
@@ -10,8 +13,11 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/okiww/billing-loan-system/internal/dto"
 	"github.com/okiww/billing-loan-system/internal/loan/models"
+	outboxModel "github.com/okiww/billing-loan-system/internal/outbox/models"
 	paymentModel "github.com/okiww/billing-loan-system/internal/payment/models"
+	"github.com/okiww/billing-loan-system/pkg/clock"
 	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/okiww/billing-loan-system/pkg/mq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,7 +31,8 @@ func TestMakePayment(t *testing.T) {
 	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
 
 	// Create the service instance with mocked repos
-	service := NewPaymentService(mockPaymentRepo, mockLoanRepo, mockLoanBillRepo)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	service := NewPaymentService(mockPaymentRepo, mockLoanRepo, mockLoanBillRepo, clock.Fixed(now))
 
 	// Test table for MakePayment
 	tests := []struct {
@@ -56,7 +63,7 @@ func TestMakePayment(t *testing.T) {
 					GetLoanStatusByID(context.Background(), int64(1)).
 					Return(&models.LoanModel{Status: models.StatusActive}, nil)
 				mockPaymentRepo.EXPECT().
-					Create(context.Background(), gomock.Any()).
+					CreateWithOutboxInTx(context.Background(), gomock.Any(), outboxModel.EventPaymentCreated).
 					Return(int32(1), nil)
 				mockPaymentRepo.EXPECT().
 					GetPaymentByID(context.Background(), int32(1)).
@@ -139,80 +146,273 @@ func TestProcessUpdatePayment(t *testing.T) {
 	mockLoanBillRepo := loan_mock.NewMockLoanBillRepositoryInterface(ctrl)
 
 	// Create the service instance with mocked repos
-	service := NewPaymentService(mockPaymentRepo, mockLoanRepo, mockLoanBillRepo)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	service := NewPaymentService(mockPaymentRepo, mockLoanRepo, mockLoanBillRepo, clock.Fixed(now))
+	payment := paymentModel.Payment{
+		ID:         1,
+		LoanID:     1,
+		LoanBillID: 1,
+		Amount:     1000,
+		Status:     paymentModel.StatusPending,
+	}
 
 	// Test table for ProcessUpdatePayment
 	tests := []struct {
 		name          string
-		payment       paymentModel.Payment
 		mockRepoCalls func()
 		expectedErr   error
 		wantErr       bool
 	}{
 		{
 			name: "Successful Payment Update",
-			payment: paymentModel.Payment{
-				ID:         1,
-				LoanID:     1,
-				LoanBillID: 1,
-				Amount:     1000,
-				Status:     models.StatusPending,
+			mockRepoCalls: func() {
+				mockPaymentRepo.EXPECT().
+					ProcessPaymentInTx(context.Background(), "payment.created:1", int32(1)).
+					Return(paymentModel.ProcessResultCompleted, nil)
 			},
+		},
+		{
+			name: "Skip message delivered again",
 			mockRepoCalls: func() {
 				mockPaymentRepo.EXPECT().
-					UpdatePaymentStatus(context.Background(), int32(1), paymentModel.StatusProcess, "").
-					Return(nil)
-				mockLoanRepo.EXPECT().
-					UpdateLoanAndLoanBillsInTx(context.Background(), 1, 1, 1000).
-					Return(nil)
+					ProcessPaymentInTx(context.Background(), "payment.created:1", int32(1)).
+					Return(paymentModel.ProcessResultDuplicate, nil)
+			},
+		},
+		{
+			name: "Skip payment settled already",
+			mockRepoCalls: func() {
 				mockPaymentRepo.EXPECT().
-					UpdatePaymentStatus(context.Background(), int32(1), paymentModel.StatusCompleted, "").
-					Return(nil)
+					ProcessPaymentInTx(context.Background(), "payment.created:1", int32(1)).
+					Return(paymentModel.ProcessResultSettled, nil)
 			},
-			expectedErr: nil,
-			wantErr:     false,
 		},
 		{
-			name: "Failed Payment Update",
-			payment: paymentModel.Payment{
-				ID:         1,
-				LoanID:     1,
-				LoanBillID: 1,
-				Amount:     1000,
-				Status:     models.StatusPending,
+			name: "Fail payment of a bill already paid without error",
+			mockRepoCalls: func() {
+				mockPaymentRepo.EXPECT().
+					ProcessPaymentInTx(context.Background(), "payment.created:1", int32(1)).
+					Return(paymentModel.ProcessResultBillPaid, nil)
 			},
+		},
+		{
+			name: "Failed Payment Update",
 			mockRepoCalls: func() {
+				// Simulate the error in ProcessPaymentInTx
 				mockPaymentRepo.EXPECT().
-					UpdatePaymentStatus(context.Background(), int32(1), paymentModel.StatusProcess, "").
-					Return(nil)
+					ProcessPaymentInTx(context.Background(), "payment.created:1", int32(1)).
+					Return("", errors.New("some error"))
 
-				// Simulate the error in UpdateLoanAndLoanBillsInTx
-				mockLoanRepo.EXPECT().
-					UpdateLoanAndLoanBillsInTx(context.Background(), 1, 1, 1000).
-					Return(errors.New("some error"))
-
-				// Simulate the second call to UpdatePaymentStatus with StatusFailed
+				// The payment is failed unless another delivery settled it
 				mockPaymentRepo.EXPECT().
-					UpdatePaymentStatus(context.Background(), int32(1), paymentModel.StatusFailed, paymentModel.Note_Failed_With_ERROR_SYSTEM).
+					MarkPaymentFailed(context.Background(), int32(1), paymentModel.Note_Failed_With_ERROR_SYSTEM).
 					Return(nil)
 			},
 			expectedErr: errors.New("some error"),
 			wantErr:     true,
 		},
+		{
+			name: "Failed Mark Payment Failed",
+			mockRepoCalls: func() {
+				mockPaymentRepo.EXPECT().
+					ProcessPaymentInTx(context.Background(), "payment.created:1", int32(1)).
+					Return("", errors.New("some error"))
+				mockPaymentRepo.EXPECT().
+					MarkPaymentFailed(context.Background(), int32(1), paymentModel.Note_Failed_With_ERROR_SYSTEM).
+					Return(errors.New("connection refused"))
+			},
+			expectedErr: errors.New("connection refused"),
+			wantErr:     true,
+		},
 	}
 
 	// Run tests
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Mock repository calls
 			tt.mockRepoCalls()
 
 			// Call the service method
-			err := service.ProcessUpdatePayment(context.Background(), tt.payment)
+			err := service.ProcessUpdatePayment(context.Background(), "payment.created:1", payment)
 			// Assert the expected results
 			if tt.wantErr {
 				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
 			}
 		})
 	}
 }
+
+func TestProcessUpdatePayment_Retry(t *testing.T) {
+	policy := mq.RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	payment := paymentModel.Payment{ID: 1, LoanID: 1, LoanBillID: 1, Amount: 1000, Status: paymentModel.StatusPending}
+
+	tests := []struct {
+		name          string
+		mockRepoCalls func(mockPaymentRepo *payment_mock.MockPaymentRepositoryInterface)
+		wantDead      bool
+	}{
+		{
+			name: "Payment completed once retried after a transient error",
+			mockRepoCalls: func(mockPaymentRepo *payment_mock.MockPaymentRepositoryInterface) {
+				// the payment is left pending for the retry, it is never marked failed
+				gomock.InOrder(
+					mockPaymentRepo.EXPECT().
+						ProcessPaymentInTx(gomock.Any(), "payment.created:1", int32(1)).
+						Return("", driver.ErrBadConn),
+					mockPaymentRepo.EXPECT().
+						ProcessPaymentInTx(gomock.Any(), "payment.created:1", int32(1)).
+						Return(paymentModel.ProcessResultCompleted, nil),
+				)
+			},
+		},
+		{
+			name: "Payment failed on the last attempt of a transient error",
+			mockRepoCalls: func(mockPaymentRepo *payment_mock.MockPaymentRepositoryInterface) {
+				mockPaymentRepo.EXPECT().
+					ProcessPaymentInTx(gomock.Any(), "payment.created:1", int32(1)).
+					Return("", driver.ErrBadConn).
+					Times(2)
+				mockPaymentRepo.EXPECT().
+					MarkPaymentFailed(gomock.Any(), int32(1), paymentModel.Note_Failed_With_ERROR_SYSTEM).
+					Return(nil)
+			},
+			wantDead: true,
+		},
+		{
+			name: "Payment failed without retry on a permanent error",
+			mockRepoCalls: func(mockPaymentRepo *payment_mock.MockPaymentRepositoryInterface) {
+				mockPaymentRepo.EXPECT().
+					ProcessPaymentInTx(gomock.Any(), "payment.created:1", int32(1)).
+					Return("", errors.New("some error"))
+				mockPaymentRepo.EXPECT().
+					MarkPaymentFailed(gomock.Any(), int32(1), paymentModel.Note_Failed_With_ERROR_SYSTEM).
+					Return(nil)
+			},
+			wantDead: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockPaymentRepo := payment_mock.NewMockPaymentRepositoryInterface(ctrl)
+			now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+			service := NewPaymentService(mockPaymentRepo, nil, nil, clock.Fixed(now))
+			tt.mockRepoCalls(mockPaymentRepo)
+
+			broker := mq.NewMemory()
+			defer broker.Close()
+			assert.NoError(t, broker.DeclareQueueWithRetry("payments", policy))
+
+			var succeeded int32
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				_ = broker.Consume(ctx, "payments", mq.ConsumeOptions{Policy: policy}, func(ctx context.Context, body []byte) error {
+					err := service.ProcessUpdatePayment(ctx, "payment.created:1", payment)
+					if err == nil {
+						atomic.AddInt32(&succeeded, 1)
+					}
+					return err
+				})
+			}()
+			assert.NoError(t, broker.PublishMessage("payments", "{}"))
+
+			assert.Eventually(t, func() bool {
+				if tt.wantDead {
+					return len(broker.Messages(mq.DeadLetterQueueName("payments"))) == 1
+				}
+				return atomic.LoadInt32(&succeeded) == 1
+			}, time.Second, time.Millisecond)
+		})
+	}
+}
+
+func TestMakeRecoveryPayment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPaymentRepo := payment_mock.NewMockPaymentRepositoryInterface(ctrl)
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	service := NewPaymentService(mockPaymentRepo, mockLoanRepo, nil, clock.Fixed(now))
+
+	tests := []struct {
+		name            string
+		paymentRequest  *dto.RecoveryPaymentRequest
+		mockRepoCalls   func()
+		expectedErr     error
+		wantErr         bool
+		expectedPayment *paymentModel.Payment
+	}{
+		{
+			name:           "Successful Recovery Payment",
+			paymentRequest: &dto.RecoveryPaymentRequest{UserID: 1, LoanID: 1, Amount: 500},
+			mockRepoCalls: func() {
+				mockLoanRepo.EXPECT().
+					GetLoanStatusByID(context.Background(), int64(1)).
+					Return(&models.LoanModel{Status: models.StatusWrittenOff}, nil)
+				mockPaymentRepo.EXPECT().
+					CreateWithOutboxInTx(context.Background(), &paymentModel.Payment{
+						UserID:      1,
+						LoanID:      1,
+						Amount:      500,
+						PaymentType: paymentModel.PaymentTypeRecovery,
+						Status:      paymentModel.StatusPending,
+						CreatedAt:   now,
+					}, outboxModel.EventPaymentCreated).
+					Return(int32(1), nil)
+				mockPaymentRepo.EXPECT().
+					GetPaymentByID(context.Background(), int32(1)).
+					Return(&paymentModel.Payment{ID: 1, Amount: 500, PaymentType: paymentModel.PaymentTypeRecovery}, nil)
+			},
+			expectedPayment: &paymentModel.Payment{ID: 1, Amount: 500, PaymentType: paymentModel.PaymentTypeRecovery},
+		},
+		{
+			name:           "Loan Not Written Off",
+			paymentRequest: &dto.RecoveryPaymentRequest{UserID: 1, LoanID: 1, Amount: 500},
+			mockRepoCalls: func() {
+				mockLoanRepo.EXPECT().
+					GetLoanStatusByID(context.Background(), int64(1)).
+					Return(&models.LoanModel{Status: models.StatusActive}, nil)
+			},
+			expectedErr: errors.New(dto.ErrorLoanIsNotWrittenOff),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockRepoCalls()
+
+			payment, err := service.MakeRecoveryPayment(context.Background(), tt.paymentRequest)
+			if tt.wantErr {
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+			}
+			assert.Equal(t, tt.expectedPayment, payment)
+		})
+	}
+}
+
+func TestProcessUpdatePayment_Recovery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockPaymentRepo := payment_mock.NewMockPaymentRepositoryInterface(ctrl)
+	mockLoanRepo := loan_mock.NewMockLoanRepositoryInterface(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	service := NewPaymentService(mockPaymentRepo, mockLoanRepo, nil, clock.Fixed(now))
+
+	payment := paymentModel.Payment{ID: 1, LoanID: 1, Amount: 500, PaymentType: paymentModel.PaymentTypeRecovery}
+
+	// the recovered amount is added in the transaction of the payment
+	mockPaymentRepo.EXPECT().
+		ProcessPaymentInTx(context.Background(), "payment.created:1", int32(1)).
+		Return(paymentModel.ProcessResultCompleted, nil)
+
+	err := service.ProcessUpdatePayment(context.Background(), "payment.created:1", payment)
+	assert.NoError(t, err)
+}
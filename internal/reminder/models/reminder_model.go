@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// BillReminderModel represents the `bill_reminders` table, one row per reminder sent for a bill
+type BillReminderModel struct {
+	ID         int64     `db:"id" json:"id"`
+	LoanBillID int64     `db:"loan_bill_id" json:"loan_bill_id"`
+	DaysBefore int       `db:"days_before" json:"days_before"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// UpcomingBillModel is an unpaid bill of an active loan, the source of the reminders
+type UpcomingBillModel struct {
+	LoanBillID    int64     `db:"loan_bill_id"`
+	LoanID        int64     `db:"loan_id"`
+	UserID        int64     `db:"user_id"`
+	BillingNumber int32     `db:"billing_number"`
+	BillingDate   time.Time `db:"billing_date"`
+	AmountDue     int32     `db:"amount_due"`
+	Timezone      string    `db:"timezone"`
+}
+
+// BillReminderEvent the payload of the bill.reminder event published to the reminder queue
+type BillReminderEvent struct {
+	LoanID        int64  `json:"loan_id"`
+	LoanBillID    int64  `json:"loan_bill_id"`
+	UserID        int64  `json:"user_id"`
+	BillingNumber int32  `json:"billing_number"`
+	DueDate       string `json:"due_date"`
+	AmountDue     int32  `json:"amount_due"`
+	DaysBefore    int    `json:"days_before"`
+}
+
+const (
+	EventBillReminder   = "bill.reminder"
+	BillReminderVersion = 1
+
+	DefaultReminderQueueName = "bill_reminders"
+)
+
+// DefaultReminderDaysBefore is used when no days are configured, 3 days and 1 day before and on the billing date
+var DefaultReminderDaysBefore = []int{3, 1, 0}
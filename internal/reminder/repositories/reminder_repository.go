@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/okiww/billing-loan-system/helpers"
+	"github.com/okiww/billing-loan-system/internal/reminder/models"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	repo     ReminderRepositoryInterface
+	repoLock sync.Once
+)
+
+type reminderRepository struct {
+	*mysql.DBMySQL
+}
+
+// FetchUpcomingBills get the PENDING and BILLED bills of active loans with a billing date between the given dates
+func (r *reminderRepository) FetchUpcomingBills(ctx context.Context, from, to time.Time) ([]models.UpcomingBillModel, error) {
+	query := `
+		SELECT lb.id AS loan_bill_id, lb.loan_id, l.user_id, lb.billing_number, lb.billing_date,
+		       lb.billing_total_amount AS amount_due, l.timezone
+		FROM loan_bills lb
+		JOIN loans l ON lb.loan_id = l.id
+		WHERE l.status = 'ACTIVE' AND lb.status IN ('PENDING', 'BILLED')
+		AND lb.billing_date BETWEEN DATE(?) AND DATE(?)
+		ORDER BY lb.billing_date, lb.id
+	`
+	var bills []models.UpcomingBillModel
+	err := r.DB.SelectContext(ctx, &bills, query, from, to)
+	if err != nil {
+		logger.GetLogger().WithFields(logrus.Fields{
+			"error": err,
+			"from":  from,
+			"to":    to,
+		}).Error("failed to fetch upcoming bills")
+		return nil, err
+	}
+	return bills, nil
+}
+
+// ReserveReminder records the reminder of a bill before it is sent, false when it was already sent
+func (r *reminderRepository) ReserveReminder(ctx context.Context, loanBillID int64, daysBefore int) (bool, error) {
+	query := `
+		INSERT IGNORE INTO bill_reminders (loan_bill_id, days_before) VALUES (?, ?)
+	`
+	result, err := r.DB.ExecContext(ctx, query, loanBillID, daysBefore)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ReleaseReminder removes the record of a reminder which couldn't be sent, so it is sent on the next run
+func (r *reminderRepository) ReleaseReminder(ctx context.Context, loanBillID int64, daysBefore int) error {
+	query := `
+		DELETE FROM bill_reminders WHERE loan_bill_id = ? AND days_before = ?
+	`
+	_, err := r.DB.ExecContext(ctx, query, loanBillID, daysBefore)
+	return err
+}
+
+type ReminderRepositoryInterface interface {
+	FetchUpcomingBills(ctx context.Context, from, to time.Time) ([]models.UpcomingBillModel, error)
+	ReserveReminder(ctx context.Context, loanBillID int64, daysBefore int) (bool, error)
+	ReleaseReminder(ctx context.Context, loanBillID int64, daysBefore int) error
+}
+
+func NewReminderRepository(db *mysql.DBMySQL) ReminderRepositoryInterface {
+	if helpers.IsTestEnv() { // Skip singleton in tests
+		return &reminderRepository{
+			db,
+		}
+	}
+
+	repoLock.Do(func() {
+		repo = &reminderRepository{
+			db,
+		}
+	})
+	return repo
+}
@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/okiww/billing-loan-system/internal/reminder/models"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/stretchr/testify/assert"
+	sqlmock "github.com/zhashkevych/go-sqlxmock"
+)
+
+func TestFetchUpcomingBills(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewReminderRepository(&mysql.DBMySQL{DB: db})
+	from := time.Date(2024, 12, 22, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 12, 27, 0, 0, 0, 0, time.UTC)
+	billingDate := time.Date(2024, 12, 23, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    []models.UpcomingBillModel
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"loan_bill_id", "loan_id", "user_id", "billing_number", "billing_date", "amount_due", "timezone"}).
+					AddRow(10, 1, 2, 3, billingDate, 110000, "Asia/Jakarta")
+				mock.ExpectQuery(regexp.QuoteMeta("AND lb.billing_date BETWEEN DATE(?) AND DATE(?)")).
+					WithArgs(from, to).
+					WillReturnRows(rows)
+			},
+			want: []models.UpcomingBillModel{
+				{LoanBillID: 10, LoanID: 1, UserID: 2, BillingNumber: 3, BillingDate: billingDate, AmountDue: 110000, Timezone: "Asia/Jakarta"},
+			},
+		},
+		{
+			name: "Database Error",
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM loan_bills lb")).WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := repo.FetchUpcomingBills(context.Background(), from, to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FetchUpcomingBills() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReserveReminder(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewReminderRepository(&mysql.DBMySQL{DB: db})
+	query := regexp.QuoteMeta("INSERT IGNORE INTO bill_reminders (loan_bill_id, days_before) VALUES (?, ?)")
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "Reserved",
+			mock: func() {
+				mock.ExpectExec(query).WithArgs(10, 3).WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			want: true,
+		},
+		{
+			name: "Already Sent",
+			mock: func() {
+				mock.ExpectExec(query).WithArgs(10, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want: false,
+		},
+		{
+			name: "Database Error",
+			mock: func() {
+				mock.ExpectExec(query).WithArgs(10, 3).WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := repo.ReserveReminder(context.Background(), 10, 3)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReserveReminder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReleaseReminder(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewReminderRepository(&mysql.DBMySQL{DB: db})
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM bill_reminders WHERE loan_bill_id = ? AND days_before = ?")).
+		WithArgs(10, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.ReleaseReminder(context.Background(), 10, 3)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
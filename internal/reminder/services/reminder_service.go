@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"slices"
+
+	"github.com/okiww/billing-loan-system/configs"
+	"github.com/okiww/billing-loan-system/helpers"
+	"github.com/okiww/billing-loan-system/internal/dto"
+	"github.com/okiww/billing-loan-system/internal/reminder/models"
+	"github.com/okiww/billing-loan-system/internal/reminder/repositories"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	"github.com/okiww/billing-loan-system/pkg/event"
+	"github.com/okiww/billing-loan-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// Publisher publishes a message to a queue of the message broker, implemented by the mq brokers
+type Publisher interface {
+	PublishMessage(queueName, message string) error
+}
+
+type reminderService struct {
+	reminderRepo repositories.ReminderRepositoryInterface
+	publisher    Publisher
+	config       configs.ReminderConfig
+	clock        clock.Clock
+}
+
+// SendReminders publishes a reminder for every unpaid bill due in one of the configured days, counted on the calendar
+// of the loan timezone. Each reminder is recorded before it is published so it is sent once per bill and days before,
+// a reminder which couldn't be published is released and sent on the next run
+func (r *reminderService) SendReminders(ctx context.Context) (int32, error) {
+	logger.GetLogger().Info("[ReminderService][SendReminders]")
+	daysBefore := r.config.DaysBefore
+	if len(daysBefore) == 0 {
+		daysBefore = models.DefaultReminderDaysBefore
+	}
+
+	// a day of margin on both sides covers the loans whose timezone is a day behind or ahead of UTC
+	now := r.clock.Now()
+	today := helpers.LocalDate(now, now.Location())
+	bills, err := r.reminderRepo.FetchUpcomingBills(ctx, today.AddDate(0, 0, -1), today.AddDate(0, 0, slices.Max(daysBefore)+1))
+	if err != nil {
+		logger.GetLogger().Errorf("[ReminderService][SendReminders] Error FetchUpcomingBills with err: %v", err)
+		return 0, err
+	}
+
+	var sent int32
+	for _, bill := range bills {
+		days := helpers.DaysBetween(helpers.LocalDate(now, helpers.LoadLocation(bill.Timezone)), bill.BillingDate)
+		if !slices.Contains(daysBefore, days) {
+			continue
+		}
+
+		reserved, err := r.reminderRepo.ReserveReminder(ctx, bill.LoanBillID, days)
+		if err != nil {
+			logger.GetLogger().Errorf("[ReminderService][SendReminders] Error ReserveReminder with err: %v", err)
+			return sent, err
+		}
+		if !reserved {
+			continue
+		}
+
+		err = r.publish(ctx, bill, days)
+		if err != nil {
+			logger.GetLogger().WithFields(logrus.Fields{
+				"loan_bill_id": bill.LoanBillID,
+				"days_before":  days,
+			}).Errorf("[ReminderService][SendReminders] Error publish reminder with err: %v", err)
+			if err := r.reminderRepo.ReleaseReminder(ctx, bill.LoanBillID, days); err != nil {
+				logger.GetLogger().Errorf("[ReminderService][SendReminders] Error ReleaseReminder with err: %v", err)
+			}
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (r *reminderService) publish(ctx context.Context, bill models.UpcomingBillModel, daysBefore int) error {
+	message, err := event.Encode(ctx, models.EventBillReminder, models.BillReminderVersion, r.clock.Now(), models.BillReminderEvent{
+		LoanID:        bill.LoanID,
+		LoanBillID:    bill.LoanBillID,
+		UserID:        bill.UserID,
+		BillingNumber: bill.BillingNumber,
+		DueDate:       bill.BillingDate.Format(dto.DateFormat),
+		AmountDue:     bill.AmountDue,
+		DaysBefore:    daysBefore,
+	})
+	if err != nil {
+		return err
+	}
+
+	queueName := r.config.QueueName
+	if queueName == "" {
+		queueName = models.DefaultReminderQueueName
+	}
+	return r.publisher.PublishMessage(queueName, message)
+}
+
+type ReminderServiceInterface interface {
+	SendReminders(ctx context.Context) (int32, error)
+}
+
+func NewReminderService(reminderRepo repositories.ReminderRepositoryInterface, publisher Publisher, config configs.ReminderConfig, clk clock.Clock) ReminderServiceInterface {
+	return &reminderService{reminderRepo, publisher, config, clk}
+}
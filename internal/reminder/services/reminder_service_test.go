@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/okiww/billing-loan-system/configs"
+	reminder_mock "github.com/okiww/billing-loan-system/gen/mocks/reminder"
+	"github.com/okiww/billing-loan-system/internal/reminder/models"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/okiww/billing-loan-system/pkg/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// envelopeOf matches a message wrapping payload in an envelope of the event, the event id is random
+type envelopeOf struct {
+	eventType  string
+	occurredAt time.Time
+	payload    string
+}
+
+func (e envelopeOf) Matches(x interface{}) bool {
+	body, ok := x.(string)
+	if !ok {
+		return false
+	}
+	envelope, err := event.Decode([]byte(body))
+	if err != nil {
+		return false
+	}
+	return envelope.Type == e.eventType && envelope.Version == models.BillReminderVersion &&
+		envelope.OccurredAt.Equal(e.occurredAt) && string(envelope.Payload) == e.payload
+}
+
+func (e envelopeOf) String() string {
+	return fmt.Sprintf("is an envelope of %s with payload %s", e.eventType, e.payload)
+}
+
+func TestSendReminders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := reminder_mock.NewMockReminderRepositoryInterface(ctrl)
+	mockPublisher := reminder_mock.NewMockPublisher(ctrl)
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	config := configs.ReminderConfig{DaysBefore: []int{3, 1, 0}, QueueName: "bill_reminders"}
+	service := NewReminderService(mockRepo, mockPublisher, config, clock.Fixed(now))
+
+	from := time.Date(2024, 12, 22, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 12, 27, 0, 0, 0, 0, time.UTC)
+	bills := []models.UpcomingBillModel{
+		{LoanBillID: 1, LoanID: 1, UserID: 1, BillingNumber: 2, BillingDate: time.Date(2024, 12, 26, 0, 0, 0, 0, time.UTC), AmountDue: 110000, Timezone: "Asia/Jakarta"},
+		{LoanBillID: 2, LoanID: 2, UserID: 2, BillingNumber: 1, BillingDate: time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC), AmountDue: 55000, Timezone: "Asia/Jakarta"},
+		{LoanBillID: 3, LoanID: 3, UserID: 3, BillingNumber: 1, BillingDate: time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC), AmountDue: 55000, Timezone: "Asia/Jakarta"},
+		{LoanBillID: 4, LoanID: 4, UserID: 4, BillingNumber: 4, BillingDate: time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC), AmountDue: 55000, Timezone: "Pacific/Kiritimati"},
+	}
+
+	tests := []struct {
+		name        string
+		mockCalls   func()
+		expected    int32
+		expectedErr error
+	}{
+		{
+			name: "Success send reminders once per bill and days before",
+			mockCalls: func() {
+				mockRepo.EXPECT().FetchUpcomingBills(gomock.Any(), from, to).Return(bills, nil)
+				mockRepo.EXPECT().ReserveReminder(gomock.Any(), int64(1), 3).Return(true, nil)
+				mockPublisher.EXPECT().PublishMessage("bill_reminders", envelopeOf{
+					eventType:  models.EventBillReminder,
+					occurredAt: now,
+					payload:    `{"loan_id":1,"loan_bill_id":1,"user_id":1,"billing_number":2,"due_date":"2024-12-26","amount_due":110000,"days_before":3}`,
+				}).Return(nil)
+				mockRepo.EXPECT().ReserveReminder(gomock.Any(), int64(2), 1).Return(false, nil)
+				mockRepo.EXPECT().ReserveReminder(gomock.Any(), int64(4), 0).Return(true, nil)
+				mockPublisher.EXPECT().PublishMessage("bill_reminders", gomock.Any()).Return(nil)
+			},
+			expected: 2,
+		},
+		{
+			name: "Error fetch upcoming bills",
+			mockCalls: func() {
+				mockRepo.EXPECT().FetchUpcomingBills(gomock.Any(), from, to).Return(nil, errors.New("db error"))
+			},
+			expectedErr: errors.New("db error"),
+		},
+		{
+			name: "Error publish releases the reminder",
+			mockCalls: func() {
+				mockRepo.EXPECT().FetchUpcomingBills(gomock.Any(), from, to).Return(bills[:1], nil)
+				mockRepo.EXPECT().ReserveReminder(gomock.Any(), int64(1), 3).Return(true, nil)
+				mockPublisher.EXPECT().PublishMessage("bill_reminders", gomock.Any()).Return(errors.New("connection closed"))
+				mockRepo.EXPECT().ReleaseReminder(gomock.Any(), int64(1), 3).Return(nil)
+			},
+			expectedErr: errors.New("connection closed"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockCalls()
+
+			sent, err := service.SendReminders(context.Background())
+			if tt.expectedErr != nil {
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, sent)
+		})
+	}
+}
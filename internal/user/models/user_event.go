@@ -0,0 +1,11 @@
+package models
+
+// DelinquencyChangedVersion the schema version of the user.delinquent and user.cured payloads
+const DelinquencyChangedVersion = 1
+
+// DelinquencyChangedEvent the payload of the user.delinquent and user.cured events, published when a user is flagged
+// as delinquent or the flag is cleared
+type DelinquencyChangedEvent struct {
+	UserID       int32 `json:"user_id"`
+	IsDelinquent bool  `json:"is_delinquent"`
+}
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/okiww/billing-loan-system/helpers"
 
+	outboxModel "github.com/okiww/billing-loan-system/internal/outbox/models"
+	outboxRepo "github.com/okiww/billing-loan-system/internal/outbox/repositories"
 	"github.com/okiww/billing-loan-system/internal/user/models"
 	mysql "github.com/okiww/billing-loan-system/pkg/db"
 )
@@ -21,14 +24,10 @@ type userRepository struct {
 	*mysql.DBMySQL
 }
 
-// UpdateUserToDelinquent updates the is_delinquent field of a user to true.
+// UpdateUserToDelinquent updates the is_delinquent field of a user to true, the user.delinquent event is written to the
+// outbox in the same transaction unless the user is delinquent already
 func (u *userRepository) UpdateUserToDelinquent(ctx context.Context, userID int32) error {
-	query := `
-		UPDATE users
-		SET is_delinquent = 1, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?
-	`
-	_, err := u.DB.ExecContext(ctx, query, userID)
+	err := u.updateDelinquency(ctx, userID, true, outboxModel.EventUserDelinquent)
 	if err != nil {
 		return fmt.Errorf("error updating user to delinquent: %w", err)
 	}
@@ -36,14 +35,10 @@ func (u *userRepository) UpdateUserToDelinquent(ctx context.Context, userID int3
 	return nil
 }
 
-// UpdateUserToNotDelinquent updates the is_delinquent field of a user to false.
+// UpdateUserToNotDelinquent updates the is_delinquent field of a user to false, the user.cured event is written to the
+// outbox in the same transaction unless the user isn't delinquent
 func (u *userRepository) UpdateUserToNotDelinquent(ctx context.Context, userID int32) error {
-	query := `
-		UPDATE users
-		SET is_delinquent = 0, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?
-	`
-	_, err := u.DB.ExecContext(ctx, query, userID)
+	err := u.updateDelinquency(ctx, userID, false, outboxModel.EventUserCured)
 	if err != nil {
 		return fmt.Errorf("error updating user to not delinquent: %w", err)
 	}
@@ -51,6 +46,34 @@ func (u *userRepository) UpdateUserToNotDelinquent(ctx context.Context, userID i
 	return nil
 }
 
+func (u *userRepository) updateDelinquency(ctx context.Context, userID int32, isDelinquent bool, eventType string) error {
+	return u.ExecTx(ctx, u.DB, func(tx *sqlx.Tx) error {
+		query := `
+			UPDATE users
+			SET is_delinquent = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = ? AND is_delinquent = ?
+		`
+		result, err := tx.ExecContext(ctx, query, isDelinquent, userID, !isDelinquent)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return nil
+		}
+
+		message, err := outboxModel.NewMessage(ctx, outboxModel.AggregateUser, int64(userID), eventType, models.DelinquencyChangedVersion,
+			u.Now(), models.DelinquencyChangedEvent{UserID: userID, IsDelinquent: isDelinquent})
+		if err != nil {
+			return err
+		}
+		return outboxRepo.InsertMessageInTx(ctx, tx, message)
+	})
+}
+
 // GetUserByID retrieves a user by their ID.
 func (u *userRepository) GetUserByID(ctx context.Context, userID int32) (*models.UserModel, error) {
 	query := `
@@ -7,8 +7,10 @@ import (
 	"reflect"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/okiww/billing-loan-system/internal/user/models"
+	"github.com/okiww/billing-loan-system/pkg/clock"
 	mysql "github.com/okiww/billing-loan-system/pkg/db"
 	"github.com/stretchr/testify/assert"
 	sqlmock "github.com/zhashkevych/go-sqlxmock"
@@ -24,9 +26,11 @@ func TestUpdateUserToDelinquent(t *testing.T) {
 		db.Close()
 	}()
 
-	mockDB := &mysql.DBMySQL{DB: db}
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	mockDB := &mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)}
 	repo := NewUserRepository(mockDB)
 	ctx := context.Background()
+	query := `UPDATE users SET is_delinquent = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND is_delinquent = ?`
 
 	// Table-driven test cases
 	type args struct {
@@ -49,10 +53,30 @@ func TestUpdateUserToDelinquent(t *testing.T) {
 			},
 			wantErr: false,
 			mock: func(a args) {
-				mock.ExpectExec(regexp.QuoteMeta(
-					`UPDATE users SET is_delinquent = 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?`)).
-					WithArgs(a.userID).
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(query)).
+					WithArgs(true, a.userID, false).
 					WillReturnResult(sqlmock.NewResult(1, 1)) // Simulate 1 row updated
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox_messages`)).
+					WithArgs("user", int64(a.userID), "user.delinquent", sqlmock.AnyArg(), "PENDING", now).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Success - User Delinquent Already",
+			s:    repo,
+			args: args{
+				userID: 2,
+				id:     2,
+			},
+			wantErr: false,
+			mock: func(a args) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(query)).
+					WithArgs(true, a.userID, false).
+					WillReturnResult(sqlmock.NewResult(0, 0)) // No event for a user which didn't change
+				mock.ExpectCommit()
 			},
 		},
 		{
@@ -64,10 +88,11 @@ func TestUpdateUserToDelinquent(t *testing.T) {
 			},
 			wantErr: true,
 			mock: func(a args) {
-				mock.ExpectExec(regexp.QuoteMeta(
-					`UPDATE users SET is_delinquent = 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?`)).
-					WithArgs(a.userID).
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(query)).
+					WithArgs(true, a.userID, false).
 					WillReturnError(fmt.Errorf("db error"))
+				mock.ExpectRollback()
 			},
 		},
 	}
@@ -0,0 +1,71 @@
+package clock
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/okiww/billing-loan-system/configs"
+)
+
+// Clock tells the current time, every date decision of the services and repositories goes through it
+// so scenarios like month end or a monday can be reproduced
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Real is the wall clock
+func Real() Clock {
+	return realClock{}
+}
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+// Fixed always tells the given time
+func Fixed(now time.Time) Clock {
+	return fixedClock{now: now}
+}
+
+type offsetClock struct {
+	offset time.Duration
+}
+
+func (c offsetClock) Now() time.Time {
+	return time.Now().Add(c.offset)
+}
+
+// Offset runs like the wall clock shifted by the given duration
+func Offset(offset time.Duration) Clock {
+	return offsetClock{offset: offset}
+}
+
+// New builds the clock from the configuration, the wall clock unless time travel is configured
+func New(config configs.ClockConfig) (Clock, error) {
+	if config.TravelTo == "" {
+		return Real(), nil
+	}
+
+	travelTo, err := time.ParseInLocation(time.RFC3339, config.TravelTo, time.Local)
+	if err != nil {
+		travelTo, err = time.ParseInLocation(time.DateOnly, config.TravelTo, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("clock travelTo must be in RFC3339 or YYYY-MM-DD format: %v", err)
+		}
+	}
+
+	if config.Frozen {
+		return Fixed(travelTo), nil
+	}
+	return Offset(time.Until(travelTo)), nil
+}
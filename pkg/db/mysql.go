@@ -3,10 +3,12 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/okiww/billing-loan-system/configs"
+	"github.com/okiww/billing-loan-system/pkg/clock"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -14,6 +16,7 @@ type DBMySQL struct {
 	cfg    *configs.DBConfig
 	DB     *sqlx.DB
 	ExecTx TxExecutor
+	Clock  clock.Clock // Time the repositories query and write with, the wall clock when nil
 }
 
 type DB struct {
@@ -31,6 +34,7 @@ func InitDB(cfg *configs.DBConfig) DBInterface {
 		cfg,
 		nil,
 		ExecTx,
+		nil,
 	}
 }
 
@@ -54,6 +58,14 @@ func (d *DBMySQL) Connect() (*DBMySQL, error) {
 	}, nil
 }
 
+// Now get the current time of the repositories clock
+func (d *DBMySQL) Now() time.Time {
+	if d.Clock == nil {
+		return time.Now()
+	}
+	return d.Clock.Now()
+}
+
 // TxExecutor accesses ExecTx from outer package
 type TxExecutor func(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error
 
@@ -76,6 +76,11 @@ func IsEqual(err1, err2 error) bool {
 	return err1.Error() == err2.Error()
 }
 
+// Unwrap return the annotated error so errors.Is and errors.As can inspect it
+func (e *errWrapper) Unwrap() error {
+	return e.error
+}
+
 // StackTrace return error stack trace information
 func (e *errWrapper) StackTrace() errors.StackTrace {
 	f := make([]errors.Frame, len(*e.stack))
@@ -0,0 +1,42 @@
+package errors
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+// MySQL server errors which succeed when the statement is sent again
+var retryableMySQLErrors = map[uint16]bool{
+	1040: true, // too many connections
+	1205: true, // lock wait timeout exceeded
+	1213: true, // deadlock found when trying to get lock
+}
+
+// IsRetryable reports whether err is transient, such as a lost connection, a deadlock or a network timeout, and the
+// operation can be tried again. Every other error is permanent, a cancelled context is never retried
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return retryableMySQLErrors[mysqlErr.Number]
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,97 @@
+package event
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotEnvelope is returned by Decode when a body isn't wrapped in an envelope, e.g. published before envelopes
+var ErrNotEnvelope = errors.New("event: body is not an envelope")
+
+// Envelope wraps every message published to the broker so consumers dispatch on the type and version of the event
+// and a message can evolve without breaking the consumers of its older versions
+type Envelope struct {
+	EventID       string          `json:"event_id"`       // Unique per event, consumers deduplicate on it
+	Type          string          `json:"type"`           // e.g. payment.created
+	Version       int             `json:"version"`        // Schema version of the payload, bumped on breaking changes
+	OccurredAt    time.Time       `json:"occurred_at"`    // When the change the event announces happened
+	CorrelationID string          `json:"correlation_id"` // Shared by the events caused by the same request or job
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// New wraps payload in an envelope with a new event id, the correlation id is taken from ctx or is the event id when
+// the event starts a new flow
+func New(ctx context.Context, eventType string, version int, occurredAt time.Time, payload interface{}) (Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	eventID := NewID()
+	correlationID := CorrelationID(ctx)
+	if correlationID == "" {
+		correlationID = eventID
+	}
+	return Envelope{
+		EventID:       eventID,
+		Type:          eventType,
+		Version:       version,
+		OccurredAt:    occurredAt.UTC(),
+		CorrelationID: correlationID,
+		Payload:       data,
+	}, nil
+}
+
+// Encode wraps payload in a new envelope and serializes it, see New
+func Encode(ctx context.Context, eventType string, version int, occurredAt time.Time, payload interface{}) (string, error) {
+	envelope, err := New(ctx, eventType, version, occurredAt, payload)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Decode reads the envelope of a message body, a body without an event id or type returns ErrNotEnvelope
+func Decode(body []byte) (Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return Envelope{}, err
+	}
+	if envelope.EventID == "" || envelope.Type == "" {
+		return Envelope{}, ErrNotEnvelope
+	}
+	return envelope, nil
+}
+
+// NewID returns a random version 4 UUID
+func NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a copy of ctx carrying the correlation id the events published under it share
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationID returns the correlation id carried by ctx, empty when there is none
+func CorrelationID(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationIDKey{}).(string)
+	return correlationID
+}
@@ -0,0 +1,59 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/okiww/billing-loan-system/pkg/mq"
+)
+
+// Registry dispatches the events consumed from a queue to the handler registered for their type and version
+type Registry struct {
+	handlers map[string]func(ctx context.Context, envelope Envelope) error
+	fallback mq.Handler
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]func(ctx context.Context, envelope Envelope) error)}
+}
+
+// Register handles the events of a type and version with handler, the payload is decoded into T
+func Register[T any](r *Registry, eventType string, version int, handler func(ctx context.Context, envelope Envelope, payload T) error) {
+	r.handlers[key(eventType, version)] = func(ctx context.Context, envelope Envelope) error {
+		var payload T
+		if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+			return mq.Permanent(fmt.Errorf("decode payload of event %s version %d: %v", envelope.Type, envelope.Version, err))
+		}
+		return handler(ctx, envelope, payload)
+	}
+}
+
+// Fallback handles the bodies which aren't wrapped in an envelope, the messages published before envelopes
+func (r *Registry) Fallback(handler mq.Handler) {
+	r.fallback = handler
+}
+
+// Dispatch decodes the envelope of a message body and calls the handler of its event with the correlation id of the
+// event in ctx. A body which can't be decoded or an event without handler fails permanently and is dead-lettered, it
+// can be replayed once a consumer handles it
+func (r *Registry) Dispatch(ctx context.Context, body []byte) error {
+	envelope, err := Decode(body)
+	if err == ErrNotEnvelope && r.fallback != nil {
+		return r.fallback(ctx, body)
+	}
+	if err != nil {
+		return mq.Permanent(fmt.Errorf("decode envelope: %v", err))
+	}
+
+	handler, ok := r.handlers[key(envelope.Type, envelope.Version)]
+	if !ok {
+		return mq.Permanent(fmt.Errorf("no handler for event %s version %d", envelope.Type, envelope.Version))
+	}
+	return handler(WithCorrelationID(ctx, envelope.CorrelationID), envelope)
+}
+
+func key(eventType string, version int) string {
+	return fmt.Sprintf("%s@v%d", eventType, version)
+}
@@ -0,0 +1,130 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/okiww/billing-loan-system/pkg/mq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type paymentCreated struct {
+	PaymentID int `json:"payment_id"`
+}
+
+func TestEncodeDecode(t *testing.T) {
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+
+	t.Run("New flow correlated by its event id", func(t *testing.T) {
+		body, err := Encode(context.Background(), "payment.created", 1, now, paymentCreated{PaymentID: 3})
+		assert.NoError(t, err)
+
+		envelope, err := Decode([]byte(body))
+		assert.NoError(t, err)
+		assert.Len(t, envelope.EventID, 36)
+		assert.Equal(t, envelope.EventID, envelope.CorrelationID)
+		assert.Equal(t, "payment.created", envelope.Type)
+		assert.Equal(t, 1, envelope.Version)
+		assert.True(t, now.Equal(envelope.OccurredAt))
+		assert.JSONEq(t, `{"payment_id":3}`, string(envelope.Payload))
+	})
+
+	t.Run("Correlation id carried by the context", func(t *testing.T) {
+		body, err := Encode(WithCorrelationID(context.Background(), "request-1"), "payment.created", 1, now, paymentCreated{PaymentID: 3})
+		assert.NoError(t, err)
+
+		envelope, err := Decode([]byte(body))
+		assert.NoError(t, err)
+		assert.Equal(t, "request-1", envelope.CorrelationID)
+		assert.NotEqual(t, "request-1", envelope.EventID)
+	})
+
+	t.Run("Body which isn't an envelope", func(t *testing.T) {
+		_, err := Decode([]byte(`{"ID":3,"LoanID":2}`))
+		assert.ErrorIs(t, err, ErrNotEnvelope)
+	})
+}
+
+func TestDispatch(t *testing.T) {
+	envelope := func(eventType string, version int, payload string) string {
+		return fmt.Sprintf(`{"event_id":"event-1","type":%q,"version":%d,"occurred_at":"2024-12-23T10:00:00Z","correlation_id":"request-1","payload":%s}`,
+			eventType, version, payload)
+	}
+
+	tests := []struct {
+		name          string
+		body          string
+		handlerErr    error
+		wantHandled   string
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{
+			name:        "Success dispatch by type and version",
+			body:        envelope("payment.created", 1, `{"payment_id":3}`),
+			wantHandled: "v1:event-1:request-1:3",
+		},
+		{
+			name:        "Success dispatch newer version to its handler",
+			body:        envelope("payment.created", 2, `{"payment_id":4}`),
+			wantHandled: "v2:4",
+		},
+		{
+			name:        "Success body without envelope handled by the fallback",
+			body:        `{"ID":3}`,
+			wantHandled: `fallback:{"ID":3}`,
+		},
+		{
+			name:        "Retry handler error",
+			body:        envelope("payment.created", 1, `{"payment_id":3}`),
+			handlerErr:  errors.New("db unavailable"),
+			wantHandled: "v1:event-1:request-1:3",
+			wantErr:     true,
+		},
+		{
+			name:          "Dead-letter event without handler",
+			body:          envelope("payment.created", 3, `{"payment_id":3}`),
+			wantErr:       true,
+			wantPermanent: true,
+		},
+		{
+			name:          "Dead-letter payload which can't be decoded",
+			body:          envelope("payment.created", 1, `{"payment_id":"3"}`),
+			wantErr:       true,
+			wantPermanent: true,
+		},
+		{
+			name:          "Dead-letter body which isn't json",
+			body:          `not json`,
+			wantErr:       true,
+			wantPermanent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled string
+			registry := NewRegistry()
+			Register(registry, "payment.created", 1, func(ctx context.Context, envelope Envelope, payload paymentCreated) error {
+				handled = fmt.Sprintf("v1:%s:%s:%d", envelope.EventID, CorrelationID(ctx), payload.PaymentID)
+				return tt.handlerErr
+			})
+			Register(registry, "payment.created", 2, func(ctx context.Context, envelope Envelope, payload paymentCreated) error {
+				handled = fmt.Sprintf("v2:%d", payload.PaymentID)
+				return nil
+			})
+			registry.Fallback(func(ctx context.Context, body []byte) error {
+				handled = "fallback:" + string(body)
+				return nil
+			})
+
+			err := registry.Dispatch(context.Background(), []byte(tt.body))
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantPermanent, mq.IsPermanent(err))
+			assert.Equal(t, tt.wantHandled, handled)
+		})
+	}
+}
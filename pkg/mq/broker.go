@@ -0,0 +1,43 @@
+package mq
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrClosed is returned by Consume when the broker is closed while consuming
+var ErrClosed = errors.New("mq: broker closed")
+
+// Handler processes the body of a message, an error retries the message unless it is marked Permanent
+type Handler func(ctx context.Context, body []byte) error
+
+// Publisher publishes a message to a queue, or to an exchange which routes it to the queues bound to its routing key
+type Publisher interface {
+	PublishMessage(queueName, message string) error
+	Publish(exchange, routingKey, message string) error
+}
+
+// Subscriber delivers the messages of a queue to a handler until ctx is done, a failed message is retried following
+// the policy of the options and dead-lettered once it runs out of attempts
+type Subscriber interface {
+	Consume(ctx context.Context, queueName string, options ConsumeOptions, handler Handler) error
+}
+
+// Broker a message broker the application publishes to and consumes from, RabbitMQ in production, Database when the
+// application runs on MySQL alone or Memory when the http server, relay and worker run together in one process
+type Broker interface {
+	Publisher
+	Subscriber
+	DeclareQueue(queueName string) error
+	DeclareQueueWithRetry(queueName string, policy RetryPolicy) error
+	DeclareExchange(exchange string) error
+	BindQueue(queueName, exchange, pattern string) error
+	Close()
+}
+
+var (
+	_ Broker = (*RabbitMQ)(nil)
+	_ Broker = (*Memory)(nil)
+	_ Broker = (*Database)(nil)
+)
@@ -0,0 +1,409 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/okiww/billing-loan-system/configs"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+)
+
+const (
+	// DriverRabbitMQ the messages go through RabbitMQ
+	DriverRabbitMQ = "rabbitmq"
+	// DriverDatabase the messages go through the queue_messages table of MySQL
+	DriverDatabase = "database"
+
+	defaultPollInterval      = 500 * time.Millisecond
+	defaultVisibilityTimeout = 30 * time.Second
+)
+
+// Database a broker backed by MySQL so a deployment runs without RabbitMQ. A consumer claims the visible messages of
+// its queue with SELECT ... FOR UPDATE SKIP LOCKED and leases them for the visibility timeout, the lease is renewed
+// while the messages are handled so a message is only claimed again once its consumer is gone. A failed message is
+// made visible again after the delay of its attempt and moved to the dead-letter queue once it runs out of attempts.
+// Visibility is timed by NOW(3) of the database, not the business clock which may be offset or frozen
+type Database struct {
+	db                *mysql.DBMySQL
+	pollInterval      time.Duration
+	visibilityTimeout time.Duration
+	closed            chan struct{}
+	once              sync.Once
+}
+
+// databaseMessage a row of the queue_messages table
+type databaseMessage struct {
+	ID         int64  `db:"id"`
+	Body       string `db:"body"`
+	Headers    string `db:"headers"`
+	Deliveries int    `db:"deliveries"`
+}
+
+// NewDatabase creates a new broker storing its messages in the database
+func NewDatabase(db *mysql.DBMySQL, cfg configs.QueueConfig) *Database {
+	d := &Database{
+		db:                db,
+		pollInterval:      defaultPollInterval,
+		visibilityTimeout: defaultVisibilityTimeout,
+		closed:            make(chan struct{}),
+	}
+	if cfg.PollIntervalMs > 0 {
+		d.pollInterval = time.Duration(cfg.PollIntervalMs) * time.Millisecond
+	}
+	if cfg.VisibilityTimeoutMs > 0 {
+		d.visibilityTimeout = time.Duration(cfg.VisibilityTimeoutMs) * time.Millisecond
+	}
+	return d
+}
+
+// DeclareQueue queues are rows of queue_messages, there is nothing to declare
+func (d *Database) DeclareQueue(queueName string) error {
+	return nil
+}
+
+// DeclareQueueWithRetry retries are delayed through the visibility of the messages, there are no delay queues
+func (d *Database) DeclareQueueWithRetry(queueName string, _ RetryPolicy) error {
+	return nil
+}
+
+// DeclareExchange exchanges exist through the queues bound to them, there is nothing to declare
+func (d *Database) DeclareExchange(exchange string) error {
+	return nil
+}
+
+// BindQueue binds a queue to an exchange, see RabbitMQ.BindQueue
+func (d *Database) BindQueue(queueName, exchange, pattern string) error {
+	_, err := d.db.DB.Exec(`
+		INSERT IGNORE INTO queue_bindings (exchange, queue_name, pattern) VALUES (?, ?, ?)
+	`, exchange, queueName, pattern)
+	if err != nil {
+		log.Printf("Failed to bind a queue: %v", err)
+		return err
+	}
+	return nil
+}
+
+// PublishMessage publishes a message to a queue
+func (d *Database) PublishMessage(queueName, message string) error {
+	return d.Publish("", queueName, message)
+}
+
+// Publish publishes a message to an exchange with a routing key, see RabbitMQ.Publish. The message is copied to every
+// queue bound to the exchange in one transaction, a message no queue is bound to is dropped
+func (d *Database) Publish(exchange, routingKey, message string) error {
+	select {
+	case <-d.closed:
+		return ErrClosed
+	default:
+	}
+
+	queueNames := []string{routingKey}
+	if exchange != "" {
+		var err error
+		queueNames, err = d.boundQueues(exchange, routingKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := d.db.ExecTx(context.Background(), d.db.DB, func(tx *sqlx.Tx) error {
+		for _, queueName := range queueNames {
+			_, err := tx.Exec(`
+				INSERT INTO queue_messages (queue_name, body, headers, deliveries, visible_at, created_at)
+				VALUES (?, ?, ?, 0, NOW(3), NOW(3))
+			`, queueName, message, "{}")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to publish a message: %v", err)
+		return err
+	}
+	return nil
+}
+
+// boundQueues get the queues bound to an exchange whose pattern matches a routing key
+func (d *Database) boundQueues(exchange, routingKey string) ([]string, error) {
+	var bindings []struct {
+		QueueName string `db:"queue_name"`
+		Pattern   string `db:"pattern"`
+	}
+	err := d.db.DB.Select(&bindings, `SELECT queue_name, pattern FROM queue_bindings WHERE exchange = ? ORDER BY id`, exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	var queueNames []string
+	routed := make(map[string]bool)
+	for _, binding := range bindings {
+		if routed[binding.QueueName] || !topicMatch(binding.Pattern, routingKey) {
+			continue
+		}
+		routed[binding.QueueName] = true
+		queueNames = append(queueNames, binding.QueueName)
+	}
+	return queueNames, nil
+}
+
+// Consume delivers the messages of a queue to handler until ctx is done or the broker is closed, see RabbitMQ.Consume.
+// At most options.Prefetch messages are leased at a time, the queue is polled every poll interval once drained
+func (d *Database) Consume(ctx context.Context, queueName string, options ConsumeOptions, handler Handler) error {
+	leaseToken := newMessageID()
+	stopRenewing := d.renewLeases(leaseToken)
+	defer stopRenewing()
+	lanes := newLanes(options)
+	defer lanes.drain()
+	handlerCtx := handlerContext(ctx)
+
+	slots := make(chan struct{}, max(options.Prefetch, 1))
+	for {
+		// wait for a free slot then take the other free ones
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-d.closed:
+			return ErrClosed
+		case slots <- struct{}{}:
+		}
+		free := 1
+	fill:
+		for free < cap(slots) {
+			select {
+			case slots <- struct{}{}:
+				free++
+			default:
+				break fill
+			}
+		}
+
+		messages, err := d.claim(ctx, queueName, leaseToken, free)
+		if err != nil {
+			log.Printf("Failed to claim messages from %s: %v", queueName, err)
+		}
+		for i := len(messages); i < free; i++ {
+			<-slots
+		}
+		for _, message := range messages {
+			lanes.dispatch([]byte(message.Body), func() {
+				defer func() { <-slots }()
+				d.handle(handlerCtx, queueName, options.Policy, leaseToken, message, handler)
+			})
+		}
+
+		if len(messages) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-d.closed:
+				return ErrClosed
+			case <-time.After(d.pollInterval):
+			}
+		}
+	}
+}
+
+// claim leases up to limit visible messages of a queue, the messages locked by another consumer are skipped
+func (d *Database) claim(ctx context.Context, queueName, leaseToken string, limit int) ([]databaseMessage, error) {
+	var messages []databaseMessage
+	err := d.db.ExecTx(ctx, d.db.DB, func(tx *sqlx.Tx) error {
+		err := tx.SelectContext(ctx, &messages, `
+			SELECT id, body, headers, deliveries
+			FROM queue_messages
+			WHERE queue_name = ? AND visible_at <= NOW(3)
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		`, queueName, limit)
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := make([]int64, len(messages))
+		for i := range messages {
+			ids[i] = messages[i].ID
+			messages[i].Deliveries++
+		}
+		query, args, err := sqlx.In(`
+			UPDATE queue_messages
+			SET deliveries = deliveries + 1, visible_at = NOW(3) + INTERVAL ? MICROSECOND, lease_token = ?
+			WHERE id IN (?)
+		`, d.visibilityTimeout.Microseconds(), leaseToken, ids)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// renewLeases keeps the messages leased with leaseToken invisible while they wait in the lanes or are handled, until
+// the returned function is called
+func (d *Database) renewLeases(leaseToken string) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(d.visibilityTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				_, err := d.db.DB.Exec(`
+					UPDATE queue_messages SET visible_at = NOW(3) + INTERVAL ? MICROSECOND WHERE lease_token = ?
+				`, d.visibilityTimeout.Microseconds(), leaseToken)
+				if err != nil {
+					log.Printf("Failed to renew the leases of the messages: %v", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+func (d *Database) handle(ctx context.Context, queueName string, policy RetryPolicy, leaseToken string, message databaseMessage, handler Handler) {
+	attempt := message.Deliveries
+	err := handler(withAttempt(ctx, attempt, policy), []byte(message.Body))
+	if err == nil {
+		d.settle(message.ID, leaseToken, `DELETE FROM queue_messages WHERE id = ? AND lease_token = ?`)
+		return
+	}
+
+	if attempt < policy.MaxAttempts && !IsPermanent(err) {
+		log.Printf("Message failed on attempt %d, retry in %s: %v", attempt, policy.Delay(attempt), err)
+		d.settle(message.ID, leaseToken, `
+			UPDATE queue_messages SET visible_at = NOW(3) + INTERVAL ? MICROSECOND, lease_token = NULL
+			WHERE id = ? AND lease_token = ?
+		`, policy.Delay(attempt).Microseconds())
+		return
+	}
+
+	log.Printf("Message failed on attempt %d, moved to the dead-letter queue: %v", attempt, err)
+	headers := decodeHeaders(message.Headers)
+	headers[HeaderAttempt] = attempt
+	headers[HeaderError] = err.Error()
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	headers[HeaderDeadLetterID] = newMessageID()
+	d.settle(message.ID, leaseToken, `
+		UPDATE queue_messages SET queue_name = ?, headers = ?, visible_at = NOW(3), lease_token = NULL
+		WHERE id = ? AND lease_token = ?
+	`, DeadLetterQueueName(queueName), encodeHeaders(headers))
+}
+
+// settle runs the statement acknowledging, retrying or dead-lettering a leased message, its last arguments are the id
+// of the message and the lease token. A message whose lease expired was claimed by another consumer and is left to it
+func (d *Database) settle(id int64, leaseToken, query string, args ...interface{}) {
+	result, err := d.db.DB.Exec(query, append(args, id, leaseToken)...)
+	if err != nil {
+		log.Printf("Failed to settle message %d: %v", id, err)
+		return
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		log.Printf("Lease of message %d expired, the message is handled again", id)
+	}
+}
+
+// DeadLetters get the first limit messages of the dead-letter queue of a queue, see DeadLetterQueue
+func (d *Database) DeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	query := `SELECT id, body, headers, deliveries FROM queue_messages WHERE queue_name = ? ORDER BY id`
+	args := []interface{}{DeadLetterQueueName(queueName)}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	var messages []databaseMessage
+	err := d.db.DB.Select(&messages, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters := make([]DeadLetter, 0, len(messages))
+	for _, message := range messages {
+		deadLetters = append(deadLetters, newDeadLetter(decodeHeaders(message.Headers), []byte(message.Body)))
+	}
+	return deadLetters, nil
+}
+
+// ReplayDeadLetters moves dead-lettered messages back to their queue, see DeadLetterQueue
+func (d *Database) ReplayDeadLetters(queueName string, ids []string) (int, error) {
+	set := idSet(ids)
+	replayed := 0
+	err := d.db.ExecTx(context.Background(), d.db.DB, func(tx *sqlx.Tx) error {
+		var messages []databaseMessage
+		err := tx.Select(&messages, `
+			SELECT id, body, headers, deliveries FROM queue_messages WHERE queue_name = ? ORDER BY id FOR UPDATE
+		`, DeadLetterQueueName(queueName))
+		if err != nil {
+			return err
+		}
+
+		for _, message := range messages {
+			headers := decodeHeaders(message.Headers)
+			if !selected(set, newDeadLetter(headers, []byte(message.Body))) {
+				continue
+			}
+			_, err := tx.Exec(`
+				UPDATE queue_messages
+				SET queue_name = ?, headers = ?, deliveries = 0, visible_at = NOW(3), lease_token = NULL
+				WHERE id = ?
+			`, queueName, encodeHeaders(replayHeaders(headers)), message.ID)
+			if err != nil {
+				return err
+			}
+			replayed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return replayed, nil
+}
+
+// PurgeDeadLetters drops the messages of the dead-letter queue of a queue
+func (d *Database) PurgeDeadLetters(queueName string) (int, error) {
+	result, err := d.db.DB.Exec(`DELETE FROM queue_messages WHERE queue_name = ?`, DeadLetterQueueName(queueName))
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+// Close stops the consumers, the messages they leased are claimed again once their lease expires
+func (d *Database) Close() {
+	d.once.Do(func() {
+		close(d.closed)
+	})
+}
+
+func decodeHeaders(raw string) map[string]interface{} {
+	headers := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(raw), &headers); err != nil && raw != "" {
+		log.Printf("Failed to decode the headers of a message: %v", err)
+	}
+	return headers
+}
+
+func encodeHeaders(headers map[string]interface{}) string {
+	raw, err := json.Marshal(headers)
+	if err != nil {
+		return "{}"
+	}
+	return string(raw)
+}
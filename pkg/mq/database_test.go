@@ -0,0 +1,253 @@
+package mq
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/okiww/billing-loan-system/configs"
+	"github.com/okiww/billing-loan-system/pkg/clock"
+	mysql "github.com/okiww/billing-loan-system/pkg/db"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	sqlmock "github.com/zhashkevych/go-sqlxmock"
+)
+
+func TestDatabasePublish(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	broker := NewDatabase(&mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)}, configs.QueueConfig{})
+	bindings := regexp.QuoteMeta("SELECT queue_name, pattern FROM queue_bindings WHERE exchange = ?")
+	insert := regexp.QuoteMeta("INSERT INTO queue_messages (queue_name, body, headers, deliveries, visible_at, created_at)")
+
+	tests := []struct {
+		name       string
+		exchange   string
+		routingKey string
+		mock       func()
+		wantErr    bool
+	}{
+		{
+			name:       "Success publish to a queue",
+			routingKey: "payments",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(insert).WithArgs("payments", "{}", "{}").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:       "Success publish to the queues bound to the routing key",
+			exchange:   "billing.events",
+			routingKey: "loan.closed",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"queue_name", "pattern"}).
+					AddRow("payments", "payment.created").
+					AddRow("loans", "loan.*").
+					AddRow("audit", "#").
+					AddRow("loans", "#")
+				mock.ExpectQuery(bindings).WithArgs("billing.events").WillReturnRows(rows)
+				mock.ExpectBegin()
+				mock.ExpectExec(insert).WithArgs("loans", "{}", "{}").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(insert).WithArgs("audit", "{}", "{}").WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:       "Database Error",
+			routingKey: "payments",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(insert).WillReturnError(assert.AnError)
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			err := broker.Publish(tt.exchange, tt.routingKey, "{}")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Publish() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabaseClaim(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	broker := NewDatabase(&mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)},
+		configs.QueueConfig{VisibilityTimeoutMs: 30000})
+	query := regexp.QuoteMeta("WHERE queue_name = ? AND visible_at <= NOW(3)")
+	columns := []string{"id", "body", "headers", "deliveries"}
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    []databaseMessage
+		wantErr bool
+	}{
+		{
+			name: "Success lease the visible messages",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows(columns).AddRow(1, `{"a":1}`, "{}", 0).AddRow(2, `{"b":2}`, "{}", 2)
+				mock.ExpectQuery(query+".*FOR UPDATE SKIP LOCKED").WithArgs("payments", 10).WillReturnRows(rows)
+				mock.ExpectExec(regexp.QuoteMeta("SET deliveries = deliveries + 1, visible_at = NOW(3) + INTERVAL ? MICROSECOND, lease_token = ?")).
+					WithArgs((30 * time.Second).Microseconds(), "lease", 1, 2).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			},
+			want: []databaseMessage{
+				{ID: 1, Body: `{"a":1}`, Headers: "{}", Deliveries: 1},
+				{ID: 2, Body: `{"b":2}`, Headers: "{}", Deliveries: 3},
+			},
+		},
+		{
+			name: "Success nothing visible",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(query).WithArgs("payments", 10).WillReturnRows(sqlmock.NewRows(columns))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Database Error",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(query).WithArgs("payments", 10).WillReturnError(assert.AnError)
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := broker.claim(context.Background(), "payments", "lease", 10)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("claim() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabaseHandle(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	broker := NewDatabase(&mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)}, configs.QueueConfig{})
+	policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Second, MaxDelay: time.Minute}
+
+	tests := []struct {
+		name       string
+		deliveries int
+		handleErr  error
+		mock       func()
+	}{
+		{
+			name:       "Success message deleted once handled",
+			deliveries: 1,
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM queue_messages WHERE id = ? AND lease_token = ?")).
+					WithArgs(7, "lease").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:       "Failed message visible again after the delay of its attempt",
+			deliveries: 2,
+			handleErr:  errors.New("db unavailable"),
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("SET visible_at = NOW(3) + INTERVAL ? MICROSECOND, lease_token = NULL")).
+					WithArgs((2 * time.Second).Microseconds(), 7, "lease").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:       "Failed message dead-lettered once out of attempts",
+			deliveries: 3,
+			handleErr:  errors.New("db unavailable"),
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("SET queue_name = ?, headers = ?, visible_at = NOW(3), lease_token = NULL")).
+					WithArgs("payments.dlq", sqlmock.AnyArg(), 7, "lease").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:       "Permanent failure dead-lettered on the first attempt",
+			deliveries: 1,
+			handleErr:  Permanent(errors.New("malformed message")),
+			mock: func() {
+				mock.ExpectExec(regexp.QuoteMeta("SET queue_name = ?, headers = ?, visible_at = NOW(3), lease_token = NULL")).
+					WithArgs("payments.dlq", sqlmock.AnyArg(), 7, "lease").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			message := databaseMessage{ID: 7, Body: "{}", Headers: "{}", Deliveries: tt.deliveries}
+			broker.handle(context.Background(), "payments", policy, "lease", message, func(ctx context.Context, body []byte) error {
+				return tt.handleErr
+			})
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDatabaseDeadLetters(t *testing.T) {
+	db, mock, err := sqlmock.Newx()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	broker := NewDatabase(&mysql.DBMySQL{DB: db, ExecTx: mysql.ExecTx, Clock: clock.Fixed(now)}, configs.QueueConfig{})
+	columns := []string{"id", "body", "headers", "deliveries"}
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows(columns).
+			AddRow(1, `{"a":1}`, `{"x-attempt":3,"x-error":"boom","x-dead-letter-id":"first"}`, 3).
+			AddRow(2, `{"b":2}`, `{"x-attempt":1,"x-dead-letter-id":"second","trace":"abc"}`, 1)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE queue_name = ? ORDER BY id LIMIT ?")).
+		WithArgs("payments.dlq", 10).WillReturnRows(rows())
+	deadLetters, err := broker.DeadLetters("payments", 10)
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 2)
+	assert.Equal(t, "first", deadLetters[0].ID)
+	assert.Equal(t, 3, deadLetters[0].Attempts)
+	assert.Equal(t, "boom", deadLetters[0].Error)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE queue_name = ? ORDER BY id FOR UPDATE")).
+		WithArgs("payments.dlq").WillReturnRows(rows())
+	mock.ExpectExec(regexp.QuoteMeta("deliveries = 0, visible_at = NOW(3), lease_token = NULL")).
+		WithArgs("payments", `{"trace":"abc"}`, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	replayed, err := broker.ReplayDeadLetters("payments", []string{"second", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM queue_messages WHERE queue_name = ?")).
+		WithArgs("payments.dlq").WillReturnResult(sqlmock.NewResult(0, 1))
+	purged, err := broker.PurgeDeadLetters("payments")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
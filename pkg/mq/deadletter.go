@@ -0,0 +1,93 @@
+package mq
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+)
+
+// HeaderDeadLetterID identifies a dead-lettered message so it can be shown and replayed
+const HeaderDeadLetterID = "x-dead-letter-id"
+
+// DeadLetter a message moved to the dead-letter queue of a queue
+type DeadLetter struct {
+	ID       string
+	Attempts int    // Deliveries of the message before it was dead-lettered
+	Error    string // Error of the last delivery
+	FailedAt string // When the message was dead-lettered, RFC3339
+	Headers  map[string]interface{}
+	Body     []byte
+}
+
+// DeadLetterQueue inspects and replays the dead-letter queue of a queue, the messages are left in place while they are
+// inspected
+type DeadLetterQueue interface {
+	// DeadLetters get the first limit messages of the dead-letter queue, in the order they were dead-lettered
+	DeadLetters(queueName string, limit int) ([]DeadLetter, error)
+	// ReplayDeadLetters moves the messages with the given ids, or all of them when ids is empty, back to the queue with
+	// their attempts reset and returns how many were moved
+	ReplayDeadLetters(queueName string, ids []string) (int, error)
+	// PurgeDeadLetters drops the messages of the dead-letter queue and returns how many were dropped
+	PurgeDeadLetters(queueName string) (int, error)
+}
+
+var (
+	_ DeadLetterQueue = (*RabbitMQ)(nil)
+	_ DeadLetterQueue = (*Memory)(nil)
+	_ DeadLetterQueue = (*Database)(nil)
+)
+
+// newDeadLetter reads a dead-lettered message from its headers, a message dead-lettered before it carried an id is
+// identified by the hash of its body
+func newDeadLetter(headers map[string]interface{}, body []byte) DeadLetter {
+	id, _ := headers[HeaderDeadLetterID].(string)
+	if id == "" {
+		hash := fnv.New64a()
+		_, _ = hash.Write(body)
+		id = fmt.Sprintf("%016x", hash.Sum64())
+	}
+	errorMessage, _ := headers[HeaderError].(string)
+	failedAt, _ := headers[HeaderFailedAt].(string)
+	return DeadLetter{
+		ID:       id,
+		Attempts: attemptOf(headers),
+		Error:    errorMessage,
+		FailedAt: failedAt,
+		Headers:  headers,
+		Body:     body,
+	}
+}
+
+// newMessageID generates a random id, it identifies a dead-lettered message or the leases of a consumer
+func newMessageID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// replayHeaders the headers of a dead-lettered message replayed to its queue, its attempts start over
+func replayHeaders(headers map[string]interface{}) map[string]interface{} {
+	table := make(map[string]interface{}, len(headers))
+	for key, value := range headers {
+		switch key {
+		case HeaderAttempt, HeaderError, HeaderFailedAt, HeaderDeadLetterID:
+		default:
+			table[key] = value
+		}
+	}
+	return table
+}
+
+// selected reports whether a dead letter is picked by ids, an empty ids picks all of them
+func selected(ids map[string]bool, deadLetter DeadLetter) bool {
+	return len(ids) == 0 || ids[deadLetter.ID]
+}
+
+func idSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
@@ -0,0 +1,101 @@
+package mq
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/okiww/billing-loan-system/configs"
+)
+
+const (
+	defaultPrefetchPerLane = 2
+
+	// DefaultDrainTimeout how long a consumer waits for the messages in flight on shutdown
+	DefaultDrainTimeout = 30 * time.Second
+)
+
+// KeyFunc get the ordering key of a message, the messages with the same key are handled one at a time in the order they
+// were delivered
+type KeyFunc func(body []byte) string
+
+// ConsumeOptions how the messages of a queue are handled
+type ConsumeOptions struct {
+	Policy      RetryPolicy
+	Concurrency int     // Lanes handling messages concurrently, 1 handles the messages one at a time
+	Prefetch    int     // Unacknowledged messages the broker delivers ahead of the lanes
+	Key         KeyFunc // Picks the lane of a message, without it the messages are spread over the lanes in turn
+}
+
+// NewConsumeOptions builds the options of the consumers from the configuration
+func NewConsumeOptions(cfg configs.RabbitMQConfig, key KeyFunc) ConsumeOptions {
+	options := ConsumeOptions{
+		Policy:      NewRetryPolicy(cfg),
+		Concurrency: 1,
+		Key:         key,
+	}
+	if cfg.Concurrency > 0 {
+		options.Concurrency = cfg.Concurrency
+	}
+	options.Prefetch = options.Concurrency * defaultPrefetchPerLane
+	if cfg.Prefetch > 0 {
+		options.Prefetch = cfg.Prefetch
+	}
+	return options
+}
+
+// lanes a fixed set of goroutines each handling its messages in order, a message always goes to the lane of its key so
+// the messages of a key are never handled concurrently
+type lanes struct {
+	queues []chan func()
+	key    KeyFunc
+	next   int
+	wg     sync.WaitGroup
+}
+
+func newLanes(options ConsumeOptions) *lanes {
+	concurrency := max(options.Concurrency, 1)
+	l := &lanes{queues: make([]chan func(), concurrency), key: options.Key}
+	for i := range l.queues {
+		l.queues[i] = make(chan func(), max(options.Prefetch, 1))
+		l.wg.Add(1)
+		go func(queue chan func()) {
+			defer l.wg.Done()
+			for handle := range queue {
+				handle()
+			}
+		}(l.queues[i])
+	}
+	return l
+}
+
+// dispatch queues handle on the lane of the message, it blocks while the lane is full
+func (l *lanes) dispatch(body []byte, handle func()) {
+	l.queues[l.laneOf(body)] <- handle
+}
+
+func (l *lanes) laneOf(body []byte) int {
+	if l.key == nil {
+		lane := l.next
+		l.next = (l.next + 1) % len(l.queues)
+		return lane
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(l.key(body)))
+	return int(hash.Sum32() % uint32(len(l.queues)))
+}
+
+// drain waits for the messages dispatched to the lanes to be handled, nothing is dispatched afterwards
+func (l *lanes) drain() {
+	for _, queue := range l.queues {
+		close(queue)
+	}
+	l.wg.Wait()
+}
+
+// handlerContext the context the messages are handled with, it isn't canceled with ctx so the messages in flight when
+// the consumer stops are handled to the end
+func handlerContext(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
@@ -0,0 +1,358 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message a message held by the in-memory broker
+type Message struct {
+	Body    []byte
+	Headers map[string]interface{}
+}
+
+// Memory an in-process broker with the semantics of RabbitMQ: a failed message waits the delay of its attempt before it
+// is delivered again and is moved to the dead-letter queue once it runs out of attempts or fails permanently. Messages
+// are kept in memory only, so they are lost when the process exits
+type Memory struct {
+	mu        sync.Mutex
+	queues    map[string]*memoryQueue
+	exchanges map[string][]memoryBinding
+	timers    map[*time.Timer]struct{}
+	closed    chan struct{}
+	once      sync.Once
+}
+
+type memoryBinding struct {
+	queueName string
+	pattern   string
+}
+
+type memoryQueue struct {
+	mu       sync.Mutex
+	messages []Message
+	ready    chan struct{}
+}
+
+// NewMemory creates a new in-memory broker
+func NewMemory() *Memory {
+	return &Memory{
+		queues:    make(map[string]*memoryQueue),
+		exchanges: make(map[string][]memoryBinding),
+		timers:    make(map[*time.Timer]struct{}),
+		closed:    make(chan struct{}),
+	}
+}
+
+// DeclareQueue declares a queue, publishing to a queue which isn't declared declares it
+func (m *Memory) DeclareQueue(queueName string) error {
+	m.queue(queueName)
+	return nil
+}
+
+// DeclareQueueWithRetry declares a queue and its dead-letter queue, retries are delayed in process so there are no
+// delay queues
+func (m *Memory) DeclareQueueWithRetry(queueName string, _ RetryPolicy) error {
+	m.queue(queueName)
+	m.queue(DeadLetterQueueName(queueName))
+	return nil
+}
+
+// PublishMessage publishes a message to a queue
+func (m *Memory) PublishMessage(queueName, message string) error {
+	return m.push(queueName, Message{Body: []byte(message), Headers: map[string]interface{}{}})
+}
+
+// Publish publishes a message to an exchange with a routing key, see RabbitMQ.Publish. A message no queue is bound to
+// is dropped
+func (m *Memory) Publish(exchange, routingKey, message string) error {
+	if exchange == "" {
+		return m.PublishMessage(routingKey, message)
+	}
+
+	m.mu.Lock()
+	bindings, ok := m.exchanges[exchange]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("exchange %s not declared", exchange)
+	}
+
+	routed := make(map[string]bool)
+	for _, binding := range bindings {
+		if routed[binding.queueName] || !topicMatch(binding.pattern, routingKey) {
+			continue
+		}
+		routed[binding.queueName] = true
+		if err := m.PublishMessage(binding.queueName, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeclareExchange declares a topic exchange
+func (m *Memory) DeclareExchange(exchange string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.exchanges[exchange]; !ok {
+		m.exchanges[exchange] = nil
+	}
+	return nil
+}
+
+// BindQueue binds a queue to an exchange, see RabbitMQ.BindQueue
+func (m *Memory) BindQueue(queueName, exchange, pattern string) error {
+	m.queue(queueName)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bindings, ok := m.exchanges[exchange]
+	if !ok {
+		return fmt.Errorf("exchange %s not declared", exchange)
+	}
+	for _, binding := range bindings {
+		if binding.queueName == queueName && binding.pattern == pattern {
+			return nil
+		}
+	}
+	m.exchanges[exchange] = append(bindings, memoryBinding{queueName: queueName, pattern: pattern})
+	return nil
+}
+
+// Consume delivers the messages of a queue to handler until ctx is done or the broker is closed, see RabbitMQ.Consume
+func (m *Memory) Consume(ctx context.Context, queueName string, options ConsumeOptions, handler Handler) error {
+	queue := m.queue(queueName)
+	lanes := newLanes(options)
+	defer lanes.drain()
+	handlerCtx := handlerContext(ctx)
+	for {
+		message, err := m.pop(ctx, queue)
+		if err != nil {
+			return err
+		}
+		lanes.dispatch(message.Body, func() {
+			m.handle(handlerCtx, queueName, options.Policy, message, handler)
+		})
+	}
+}
+
+// Messages returns a copy of the messages waiting in a queue, in the order they are delivered
+func (m *Memory) Messages(queueName string) []Message {
+	queue := m.queue(queueName)
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	messages := make([]Message, len(queue.messages))
+	copy(messages, queue.messages)
+	return messages
+}
+
+// DeadLetters get the first limit messages of the dead-letter queue of a queue, see DeadLetterQueue
+func (m *Memory) DeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	messages := m.Messages(DeadLetterQueueName(queueName))
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+
+	deadLetters := make([]DeadLetter, 0, len(messages))
+	for _, message := range messages {
+		deadLetters = append(deadLetters, newDeadLetter(message.Headers, message.Body))
+	}
+	return deadLetters, nil
+}
+
+// ReplayDeadLetters moves dead-lettered messages back to their queue, see DeadLetterQueue
+func (m *Memory) ReplayDeadLetters(queueName string, ids []string) (int, error) {
+	set := idSet(ids)
+	deadLetterQueue := m.queue(DeadLetterQueueName(queueName))
+
+	deadLetterQueue.mu.Lock()
+	var kept, replayed []Message
+	for _, message := range deadLetterQueue.messages {
+		if selected(set, newDeadLetter(message.Headers, message.Body)) {
+			replayed = append(replayed, message)
+		} else {
+			kept = append(kept, message)
+		}
+	}
+	deadLetterQueue.messages = kept
+	deadLetterQueue.mu.Unlock()
+
+	for i, message := range replayed {
+		err := m.push(queueName, Message{Body: message.Body, Headers: replayHeaders(message.Headers)})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(replayed), nil
+}
+
+// PurgeDeadLetters drops the messages of the dead-letter queue of a queue
+func (m *Memory) PurgeDeadLetters(queueName string) (int, error) {
+	deadLetterQueue := m.queue(DeadLetterQueueName(queueName))
+	deadLetterQueue.mu.Lock()
+	defer deadLetterQueue.mu.Unlock()
+
+	purged := len(deadLetterQueue.messages)
+	deadLetterQueue.messages = nil
+	return purged, nil
+}
+
+// Close stops the consumers and drops the retries which are still waiting
+func (m *Memory) Close() {
+	m.once.Do(func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		close(m.closed)
+		for timer := range m.timers {
+			timer.Stop()
+		}
+		m.timers = nil
+	})
+}
+
+func (m *Memory) handle(ctx context.Context, queueName string, policy RetryPolicy, message Message, handler Handler) {
+	attempt := attemptOf(message.Headers)
+	err := handler(withAttempt(ctx, attempt, policy), message.Body)
+	if err == nil {
+		return
+	}
+
+	if attempt < policy.MaxAttempts && !IsPermanent(err) {
+		log.Printf("Message failed on attempt %d, retry in %s: %v", attempt, policy.Delay(attempt), err)
+		m.retry(queueName, forwarded(message, map[string]interface{}{HeaderAttempt: attempt + 1}), policy.Delay(attempt))
+		return
+	}
+
+	log.Printf("Message failed on attempt %d, moved to the dead-letter queue: %v", attempt, err)
+	err = m.push(DeadLetterQueueName(queueName), forwarded(message, map[string]interface{}{
+		HeaderError:        err.Error(),
+		HeaderFailedAt:     time.Now().UTC().Format(time.RFC3339),
+		HeaderDeadLetterID: newMessageID(),
+	}))
+	if err != nil {
+		log.Printf("Failed to dead-letter a message: %v", err)
+	}
+}
+
+// retry publishes a message back to its queue once delay has passed
+func (m *Memory) retry(queueName string, message Message, delay time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.timers == nil {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		m.mu.Lock()
+		delete(m.timers, timer)
+		m.mu.Unlock()
+
+		if err := m.push(queueName, message); err != nil {
+			log.Printf("Failed to retry a message: %v", err)
+		}
+	})
+	m.timers[timer] = struct{}{}
+}
+
+func (m *Memory) queue(queueName string) *memoryQueue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queue, ok := m.queues[queueName]
+	if !ok {
+		queue = &memoryQueue{ready: make(chan struct{}, 1)}
+		m.queues[queueName] = queue
+	}
+	return queue
+}
+
+func (m *Memory) push(queueName string, message Message) error {
+	select {
+	case <-m.closed:
+		return ErrClosed
+	default:
+	}
+
+	queue := m.queue(queueName)
+	queue.mu.Lock()
+	queue.messages = append(queue.messages, message)
+	queue.mu.Unlock()
+	queue.signal()
+	return nil
+}
+
+// pop waits for the next message of a queue, the other consumers are woken up while messages are left
+func (m *Memory) pop(ctx context.Context, queue *memoryQueue) (Message, error) {
+	for {
+		queue.mu.Lock()
+		if len(queue.messages) > 0 {
+			message := queue.messages[0]
+			queue.messages = queue.messages[1:]
+			left := len(queue.messages)
+			queue.mu.Unlock()
+			if left > 0 {
+				queue.signal()
+			}
+			return message, nil
+		}
+		queue.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-m.closed:
+			return Message{}, ErrClosed
+		case <-queue.ready:
+		}
+	}
+}
+
+func (q *memoryQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// topicMatch reports whether a routing key matches the pattern of a topic binding, the words are separated by dots,
+// * matches exactly one word and # zero or more words
+func topicMatch(pattern, routingKey string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(routingKey, "."))
+}
+
+func matchWords(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchWords(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchWords(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchWords(pattern[1:], words[1:])
+	}
+}
+
+// forwarded copies a message with its headers updated
+func forwarded(message Message, headers map[string]interface{}) Message {
+	table := make(map[string]interface{}, len(message.Headers)+len(headers))
+	for key, value := range message.Headers {
+		table[key] = value
+	}
+	for key, value := range headers {
+		table[key] = value
+	}
+	return Message{Body: message.Body, Headers: table}
+}
@@ -0,0 +1,301 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryConsume(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	tests := []struct {
+		name         string
+		handle       func(attempt int32) error
+		wantAttempts int32
+		wantDead     bool
+		wantError    string
+	}{
+		{
+			name:         "Success message handled on the first attempt",
+			handle:       func(attempt int32) error { return nil },
+			wantAttempts: 1,
+		},
+		{
+			name: "Success message handled once retried",
+			handle: func(attempt int32) error {
+				if attempt < 2 {
+					return errors.New("db unavailable")
+				}
+				return nil
+			},
+			wantAttempts: 2,
+		},
+		{
+			name:         "Dead-letter message which ran out of attempts",
+			handle:       func(attempt int32) error { return errors.New("db unavailable") },
+			wantAttempts: 3,
+			wantDead:     true,
+			wantError:    "db unavailable",
+		},
+		{
+			name:         "Dead-letter message which failed permanently",
+			handle:       func(attempt int32) error { return Permanent(errors.New("invalid payload")) },
+			wantAttempts: 1,
+			wantDead:     true,
+			wantError:    "invalid payload",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := NewMemory()
+			defer broker.Close()
+			assert.NoError(t, broker.DeclareQueueWithRetry("payments", policy))
+
+			var attempts int32
+			handled := make(chan struct{}, policy.MaxAttempts)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				_ = broker.Consume(ctx, "payments", ConsumeOptions{Policy: policy}, func(ctx context.Context, body []byte) error {
+					defer func() { handled <- struct{}{} }()
+					assert.Equal(t, `{"ID":1}`, string(body))
+					attempt := atomic.AddInt32(&attempts, 1)
+					assert.Equal(t, int(attempt) == policy.MaxAttempts, IsLastAttempt(ctx))
+					return tt.handle(attempt)
+				})
+			}()
+
+			assert.NoError(t, broker.PublishMessage("payments", `{"ID":1}`))
+			for i := int32(0); i < tt.wantAttempts; i++ {
+				select {
+				case <-handled:
+				case <-time.After(time.Second):
+					t.Fatalf("message handled %d times, want %d", i, tt.wantAttempts)
+				}
+			}
+
+			if tt.wantDead {
+				assert.Eventually(t, func() bool {
+					return len(broker.Messages(DeadLetterQueueName("payments"))) == 1
+				}, time.Second, time.Millisecond)
+				dead := broker.Messages(DeadLetterQueueName("payments"))[0]
+				assert.Equal(t, `{"ID":1}`, string(dead.Body))
+				assert.Equal(t, tt.wantError, dead.Headers[HeaderError])
+			}
+			// no delivery beyond the expected attempts
+			time.Sleep(20 * time.Millisecond)
+			assert.Equal(t, tt.wantAttempts, atomic.LoadInt32(&attempts))
+			assert.Empty(t, broker.Messages("payments"))
+			if !tt.wantDead {
+				assert.Empty(t, broker.Messages(DeadLetterQueueName("payments")))
+			}
+		})
+	}
+}
+
+func TestMemoryClose(t *testing.T) {
+	broker := NewMemory()
+	assert.NoError(t, broker.DeclareQueue("payments"))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- broker.Consume(context.Background(), "payments", ConsumeOptions{Policy: RetryPolicy{MaxAttempts: 1}}, func(ctx context.Context, body []byte) error {
+			return nil
+		})
+	}()
+
+	broker.Close()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrClosed)
+	case <-time.After(time.Second):
+		t.Fatal("consumer still running once the broker is closed")
+	}
+	assert.ErrorIs(t, broker.PublishMessage("payments", "{}"), ErrClosed)
+}
+
+func TestMemoryPublish(t *testing.T) {
+	broker := NewMemory()
+	defer broker.Close()
+
+	assert.NoError(t, broker.DeclareExchange("billing.events"))
+	assert.NoError(t, broker.BindQueue("payments", "billing.events", "payment.created"))
+	assert.NoError(t, broker.BindQueue("loans", "billing.events", "loan.*"))
+	assert.NoError(t, broker.BindQueue("audit", "billing.events", "#"))
+	assert.NoError(t, broker.BindQueue("audit", "billing.events", "payment.#"))
+
+	for _, routingKey := range []string{"payment.created", "loan.closed", "loan.bill.billed", "user.cured"} {
+		assert.NoError(t, broker.Publish("billing.events", routingKey, routingKey))
+	}
+	assert.NoError(t, broker.Publish("", "payments", "direct"))
+
+	bodies := func(queueName string) []string {
+		var bodies []string
+		for _, message := range broker.Messages(queueName) {
+			bodies = append(bodies, string(message.Body))
+		}
+		return bodies
+	}
+	assert.Equal(t, []string{"payment.created", "direct"}, bodies("payments"))
+	assert.Equal(t, []string{"loan.closed"}, bodies("loans"))
+	// a queue matched by several bindings receives a message once
+	assert.Equal(t, []string{"payment.created", "loan.closed", "loan.bill.billed", "user.cured"}, bodies("audit"))
+	assert.Error(t, broker.Publish("unknown", "payment.created", "{}"))
+}
+
+func TestMemoryConsumeLanes(t *testing.T) {
+	broker := NewMemory()
+	defer broker.Close()
+	assert.NoError(t, broker.DeclareQueue("payments"))
+
+	// the body is "<loan>:<sequence>", the messages of a loan must be handled in order while the loans run concurrently
+	options := ConsumeOptions{
+		Policy:      RetryPolicy{MaxAttempts: 1},
+		Concurrency: 4,
+		Prefetch:    8,
+		Key:         func(body []byte) string { return strings.SplitN(string(body), ":", 2)[0] },
+	}
+	var (
+		mu       sync.Mutex
+		handled  = make(map[string][]string)
+		inFlight int32
+		maxLanes int32
+	)
+	const loans, perLoan = 4, 25
+	done := make(chan struct{}, loans*perLoan)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- broker.Consume(ctx, "payments", options, func(ctx context.Context, body []byte) error {
+			running := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				highest := atomic.LoadInt32(&maxLanes)
+				if running <= highest || atomic.CompareAndSwapInt32(&maxLanes, highest, running) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+
+			parts := strings.SplitN(string(body), ":", 2)
+			mu.Lock()
+			handled[parts[0]] = append(handled[parts[0]], parts[1])
+			mu.Unlock()
+			done <- struct{}{}
+			return nil
+		})
+	}()
+
+	for i := 0; i < perLoan; i++ {
+		for loan := 0; loan < loans; loan++ {
+			assert.NoError(t, broker.PublishMessage("payments", fmt.Sprintf("loan-%d:%02d", loan, i)))
+		}
+	}
+	for i := 0; i < loans*perLoan; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("%d messages handled, want %d", i, loans*perLoan)
+		}
+	}
+	cancel()
+	assert.ErrorIs(t, <-stopped, context.Canceled)
+
+	for loan, sequence := range handled {
+		assert.Len(t, sequence, perLoan, loan)
+		assert.IsIncreasing(t, sequence, loan)
+	}
+	assert.Greater(t, atomic.LoadInt32(&maxLanes), int32(1), "loans handled one at a time")
+}
+
+func TestMemoryConsumeDrain(t *testing.T) {
+	broker := NewMemory()
+	defer broker.Close()
+	assert.NoError(t, broker.DeclareQueue("payments"))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var handled int32
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- broker.Consume(ctx, "payments", ConsumeOptions{Policy: RetryPolicy{MaxAttempts: 1}}, func(ctx context.Context, body []byte) error {
+			close(started)
+			<-release
+			// the message in flight is handled to the end with a context which isn't canceled
+			assert.NoError(t, ctx.Err())
+			atomic.AddInt32(&handled, 1)
+			return nil
+		})
+	}()
+	assert.NoError(t, broker.PublishMessage("payments", "{}"))
+	<-started
+
+	cancel()
+	select {
+	case <-stopped:
+		t.Fatal("consumer stopped before the message in flight was handled")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	assert.ErrorIs(t, <-stopped, context.Canceled)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&handled))
+}
+
+func TestMemoryDeadLetters(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	broker := NewMemory()
+	defer broker.Close()
+	assert.NoError(t, broker.DeclareQueueWithRetry("payments", policy))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = broker.Consume(ctx, "payments", ConsumeOptions{Policy: policy}, func(ctx context.Context, body []byte) error {
+			return errors.New("db unavailable")
+		})
+	}()
+	for _, body := range []string{`{"ID":1}`, `{"ID":2}`, `{"ID":3}`} {
+		assert.NoError(t, broker.PublishMessage("payments", body))
+	}
+	assert.Eventually(t, func() bool {
+		return len(broker.Messages(DeadLetterQueueName("payments"))) == 3
+	}, time.Second, time.Millisecond)
+	cancel()
+
+	deadLetters, err := broker.DeadLetters("payments", 2)
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 2)
+	assert.Equal(t, 2, deadLetters[0].Attempts)
+	assert.Equal(t, "db unavailable", deadLetters[0].Error)
+	assert.NotEmpty(t, deadLetters[0].ID)
+	assert.NotEqual(t, deadLetters[0].ID, deadLetters[1].ID)
+
+	// replay the second message with its attempts reset
+	replayed, err := broker.ReplayDeadLetters("payments", []string{deadLetters[1].ID})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+	messages := broker.Messages("payments")
+	assert.Len(t, messages, 1)
+	assert.Equal(t, deadLetters[1].Body, messages[0].Body)
+	assert.Empty(t, messages[0].Headers)
+
+	left, err := broker.DeadLetters("payments", 0)
+	assert.NoError(t, err)
+	assert.Len(t, left, 2)
+
+	purged, err := broker.PurgeDeadLetters("payments")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	assert.Empty(t, broker.Messages(DeadLetterQueueName("payments")))
+}
@@ -1,99 +1,557 @@
 package mq
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/streadway/amqp"
 )
 
-// RabbitMQ struct to hold connection and channel
+const (
+	defaultReconnectDelay    = time.Second
+	defaultMaxReconnectDelay = 30 * time.Second
+	defaultConfirmTimeout    = 5 * time.Second
+)
+
+// RabbitMQ a connection to RabbitMQ which is reopened with backoff once it is lost. The queues, exchanges and bindings
+// declared are declared again on the new connection and the consumers resume on it. Messages are published in confirm
+// mode, a publish returns once the broker has the message
 type RabbitMQ struct {
-	Connection *amqp.Connection
-	Channel    *amqp.Channel
+	url string
+
+	mu          sync.RWMutex
+	connection  *amqp.Connection
+	channel     *amqp.Channel          // Declares, consumes and acknowledges
+	publisher   *amqp.Channel          // Publishes in confirm mode
+	confirms    chan amqp.Confirmation // Confirmations of the publisher channel
+	published   uint64                 // Delivery tag of the last message published on the publisher channel
+	topology    []func(ch *amqp.Channel) error
+	reconnected chan struct{} // Closed once the connection is reopened, then replaced
+
+	publishMu sync.Mutex // Publishes one message at a time so every confirmation matches its message
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
-// NewRabbitMQ creates a new RabbitMQ instance
+// NewRabbitMQ creates a new RabbitMQ instance and watches its connection
 func NewRabbitMQ(url string) (*RabbitMQ, error) {
-	// Establish a connection to RabbitMQ
-	conn, err := amqp.Dial(url)
+	r := &RabbitMQ{
+		url:         url,
+		reconnected: make(chan struct{}),
+		closed:      make(chan struct{}),
+	}
+	err := r.connect()
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
 		return nil, err
 	}
+	go r.watch()
+	return r, nil
+}
 
-	// Create a channel
+// connect opens the connection with its channels and declares the topology declared so far
+func (r *RabbitMQ) connect() error {
+	conn, err := amqp.Dial(r.url)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to RabbitMQ")
+	}
 	ch, err := conn.Channel()
 	if err != nil {
-		log.Fatalf("Failed to create a channel: %v", err)
-		return nil, err
+		_ = conn.Close()
+		return errors.Wrap(err, "failed to create a channel")
+	}
+	publisher, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return errors.Wrap(err, "failed to create the publisher channel")
+	}
+	err = publisher.Confirm(false)
+	if err != nil {
+		_ = conn.Close()
+		return errors.Wrap(err, "failed to put the publisher channel in confirm mode")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, declare := range r.topology {
+		if err := declare(ch); err != nil {
+			_ = conn.Close()
+			return errors.Wrap(err, "failed to declare the topology")
+		}
 	}
+	r.connection = conn
+	r.channel = ch
+	r.publisher = publisher
+	r.confirms = publisher.NotifyPublish(make(chan amqp.Confirmation, 1))
+	r.published = 0
+	return nil
+}
+
+// watch reopens the connection with backoff once it or one of its channels is closed, until the broker is closed
+func (r *RabbitMQ) watch() {
+	for {
+		r.mu.RLock()
+		conn, ch, publisher := r.connection, r.channel, r.publisher
+		r.mu.RUnlock()
+
+		var reason *amqp.Error
+		select {
+		case <-r.closed:
+			return
+		case reason = <-conn.NotifyClose(make(chan *amqp.Error, 1)):
+		case reason = <-ch.NotifyClose(make(chan *amqp.Error, 1)):
+		case reason = <-publisher.NotifyClose(make(chan *amqp.Error, 1)):
+		}
+		select {
+		case <-r.closed:
+			return
+		default:
+		}
+		log.Printf("RabbitMQ connection lost: %v", reason)
+		_ = conn.Close()
+
+		delay := defaultReconnectDelay
+		for {
+			select {
+			case <-r.closed:
+				return
+			case <-time.After(delay):
+			}
+			err := r.connect()
+			if err == nil {
+				break
+			}
+			delay = min(delay*2, defaultMaxReconnectDelay)
+			log.Printf("Failed to reconnect to RabbitMQ, retry in %s: %v", delay, err)
+		}
+		log.Printf("Reconnected to RabbitMQ")
+
+		r.mu.Lock()
+		close(r.reconnected)
+		r.reconnected = make(chan struct{})
+		r.mu.Unlock()
+	}
+}
+
+// current get the channel consumers use and the channel closed once the connection is reopened
+func (r *RabbitMQ) current() (*amqp.Channel, <-chan struct{}) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.channel, r.reconnected
+}
+
+// declare runs declaration on the channel and records it so it is declared again once the connection is reopened
+func (r *RabbitMQ) declare(declaration func(ch *amqp.Channel) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := declaration(r.channel)
+	if err != nil {
+		return err
+	}
+	r.topology = append(r.topology, declaration)
+	return nil
+}
 
-	return &RabbitMQ{Connection: conn, Channel: ch}, nil
+// DeclareQueue declares a durable queue
+func (r *RabbitMQ) DeclareQueue(queueName string) error {
+	err := r.declare(func(ch *amqp.Channel) error {
+		return declareQueue(ch, queueName, nil)
+	})
+	if err != nil {
+		log.Printf("Failed to declare a queue: %v", err)
+		return err
+	}
+	return nil
 }
 
-// DeclareQueue declares a queue
-func (r *RabbitMQ) DeclareQueue(queueName string) (amqp.Queue, error) {
-	queue, err := r.Channel.QueueDeclare(
+func declareQueue(ch *amqp.Channel, queueName string, args amqp.Table) error {
+	_, err := ch.QueueDeclare(
 		queueName, // Queue name
 		true,      // Durable
 		false,     // Delete when unused
 		false,     // Exclusive
 		false,     // No-wait
-		nil,       // Arguments
+		args,      // Arguments
 	)
-	if err != nil {
-		log.Fatalf("Failed to declare a queue: %v", err)
-		return amqp.Queue{}, err
-	}
-	return queue, nil
+	return err
 }
 
 // PublishMessage publishes a message to a queue
 func (r *RabbitMQ) PublishMessage(queueName, message string) error {
-	err := r.Channel.Publish(
-		"",        // Exchange
-		queueName, // Routing key (queue name)
-		false,     // Mandatory
-		false,     // Immediate
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        []byte(message),
-		},
+	return r.Publish("", queueName, message)
+}
+
+// Publish publishes a message to an exchange with a routing key, the default exchange "" routes it to the queue named
+// by the routing key. It returns once the broker confirmed it has the message
+func (r *RabbitMQ) Publish(exchange, routingKey, message string) error {
+	err := r.publish(exchange, routingKey, newPublishing(message))
+	if err != nil {
+		log.Printf("Failed to publish a message: %v", err)
+		return err
+	}
+	log.Printf("Sent to %s %s: %s", exchange, routingKey, message)
+	return nil
+}
+
+// newPublishing builds a message published by Publish, it is persistent so a durable queue keeps it across a restart of
+// the broker. A copy forwarded to a retry or dead-letter queue keeps its delivery mode
+func newPublishing(message string) amqp.Publishing {
+	return amqp.Publishing{
+		ContentType:  "text/plain",
+		DeliveryMode: amqp.Persistent,
+		Body:         []byte(message),
+	}
+}
+
+// publish publishes a message on the publisher channel and waits for the broker to confirm it, a message which isn't
+// confirmed may or may not have reached the broker. The publisher channel is closed once a confirmation times out so
+// the connection is reopened, its late confirmation can't be mistaken for the one of the next message
+func (r *RabbitMQ) publish(exchange, routingKey string, message amqp.Publishing) error {
+	r.publishMu.Lock()
+	defer r.publishMu.Unlock()
+
+	select {
+	case <-r.closed:
+		return ErrClosed
+	default:
+	}
+
+	r.mu.Lock()
+	publisher, confirms := r.publisher, r.confirms
+	err := publisher.Publish(
+		exchange,   // Exchange
+		routingKey, // Routing key
+		false,      // Mandatory
+		false,      // Immediate
+		message,
 	)
 	if err != nil {
-		log.Fatalf("Failed to publish a message: %v", err)
+		r.mu.Unlock()
+		return err
+	}
+	r.published++
+	tag := r.published
+	r.mu.Unlock()
+
+	select {
+	case confirm, ok := <-confirms:
+		if !ok {
+			return errors.New("mq: channel closed before the message was confirmed")
+		}
+		if confirm.DeliveryTag != tag {
+			return errors.Errorf("mq: confirmation %d received for message %d", confirm.DeliveryTag, tag)
+		}
+		if !confirm.Ack {
+			return errors.New("mq: message rejected by the broker")
+		}
+		return nil
+	case <-time.After(defaultConfirmTimeout):
+		dropPublisher(publisher, confirms)
+		return errors.Errorf("mq: message not confirmed after %s", defaultConfirmTimeout)
+	}
+}
+
+// dropPublisher closes a publisher channel whose confirmation timed out, watch reopens the connection once it is
+// closed. The confirmations are drained until then since a late one would block the reader of the connection
+func dropPublisher(publisher *amqp.Channel, confirms <-chan amqp.Confirmation) {
+	go func() {
+		for range confirms {
+		}
+	}()
+	if err := publisher.Close(); err != nil {
+		log.Printf("Failed to close the publisher channel: %v", err)
+	}
+}
+
+// DeclareExchange declares a durable topic exchange, a queue bound to it receives the messages whose routing key
+// matches the pattern of the binding
+func (r *RabbitMQ) DeclareExchange(exchange string) error {
+	err := r.declare(func(ch *amqp.Channel) error {
+		return ch.ExchangeDeclare(
+			exchange, // Exchange name
+			"topic",  // Kind
+			true,     // Durable
+			false,    // Auto-delete
+			false,    // Internal
+			false,    // No-wait
+			nil,      // Arguments
+		)
+	})
+	if err != nil {
+		log.Printf("Failed to declare an exchange: %v", err)
 		return err
 	}
-	log.Printf("Sent: %s", message)
 	return nil
 }
 
-// ConsumeMessages consumes messages from a queue
-func (r *RabbitMQ) ConsumeMessages(queueName string) (<-chan amqp.Delivery, error) {
-	messages, err := r.Channel.Consume(
-		queueName, // Queue name
-		"",        // Consumer tag
-		true,      // Auto-acknowledge
-		false,     // Exclusive
-		false,     // No-local
-		false,     // No-wait
-		nil,       // Arguments
+// BindQueue binds a queue to an exchange, pattern is a routing key where * matches one word and # zero or more
+func (r *RabbitMQ) BindQueue(queueName, exchange, pattern string) error {
+	err := r.declare(func(ch *amqp.Channel) error {
+		return ch.QueueBind(
+			queueName, // Queue name
+			pattern,   // Binding key
+			exchange,  // Exchange name
+			false,     // No-wait
+			nil,       // Arguments
+		)
+	})
+	if err != nil {
+		log.Printf("Failed to bind a queue: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeclareQueueWithRetry declares a queue with its delay queues, one per retry so a message never waits behind a
+// longer delay, and its dead-letter queue. A delay queue hands its messages back to the queue once their TTL expires
+func (r *RabbitMQ) DeclareQueueWithRetry(queueName string, policy RetryPolicy) error {
+	err := r.DeclareQueue(queueName)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; attempt < policy.MaxAttempts; attempt++ {
+		err := r.declare(func(ch *amqp.Channel) error {
+			return declareQueue(ch, RetryQueueName(queueName, attempt), amqp.Table{
+				"x-message-ttl":             policy.Delay(attempt).Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": queueName,
+			})
+		})
+		if err != nil {
+			log.Printf("Failed to declare a retry queue: %v", err)
+			return err
+		}
+	}
+
+	return r.DeclareQueue(DeadLetterQueueName(queueName))
+}
+
+// Consume delivers the messages of a queue declared with DeclareQueueWithRetry to handler until ctx is done or the
+// broker is closed. A message is acknowledged once handled, a failed message is moved to the delay queue of its
+// attempt and delivered again, or to the dead-letter queue once it runs out of attempts or fails permanently. The
+// messages are handled by options.Concurrency lanes with options.Prefetch messages delivered ahead, once ctx is done
+// no message is delivered anymore and Consume returns when the messages in flight are handled. When the connection is
+// lost the consumer resumes once it is reopened, the messages which weren't acknowledged are delivered again
+func (r *RabbitMQ) Consume(ctx context.Context, queueName string, options ConsumeOptions, handler Handler) error {
+	lanes := newLanes(options)
+	defer lanes.drain()
+	handlerCtx := handlerContext(ctx)
+
+	for {
+		ch, reconnected := r.current()
+		err := r.consume(ctx, ch, queueName, options, func(delivery amqp.Delivery) {
+			lanes.dispatch(delivery.Body, func() {
+				r.handle(handlerCtx, queueName, options.Policy, delivery, handler)
+			})
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && !errors.Is(err, amqp.ErrClosed) {
+			return err
+		}
+
+		log.Printf("Consumer of %s waiting for the connection to be reopened", queueName)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.closed:
+			return ErrClosed
+		case <-reconnected:
+		}
+	}
+}
+
+// consume subscribes to a queue on ch and dispatches its deliveries until ctx is done or ch is closed
+func (r *RabbitMQ) consume(ctx context.Context, ch *amqp.Channel, queueName string, options ConsumeOptions, dispatch func(amqp.Delivery)) error {
+	err := ch.Qos(
+		options.Prefetch, // Prefetch count
+		0,                // Prefetch size
+		false,            // Global
 	)
 	if err != nil {
-		log.Fatalf("Failed to consume messages: %v", err)
-		return nil, err
+		log.Printf("Failed to set the prefetch: %v", err)
+		return err
+	}
+
+	consumerTag := fmt.Sprintf("%s-%d", queueName, time.Now().UnixNano())
+	deliveries, err := ch.Consume(
+		queueName,   // Queue name
+		consumerTag, // Consumer tag
+		false,       // Auto-acknowledge, acknowledged once handled
+		false,       // Exclusive
+		false,       // No-local
+		false,       // No-wait
+		nil,         // Arguments
+	)
+	if err != nil {
+		log.Printf("Failed to consume messages: %v", err)
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// the messages delivered but not handled yet are redelivered once the channel is closed
+			if err := ch.Cancel(consumerTag, false); err != nil {
+				log.Printf("Failed to cancel the consumer: %v", err)
+			}
+			return ctx.Err()
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return amqp.ErrClosed
+			}
+			dispatch(delivery)
+		}
 	}
-	return messages, nil
 }
 
-// Close closes the RabbitMQ connection and channel
-func (r *RabbitMQ) Close() {
-	err := r.Channel.Close()
+func (r *RabbitMQ) handle(ctx context.Context, queueName string, policy RetryPolicy, delivery amqp.Delivery, handler Handler) {
+	attempt := attemptOf(delivery.Headers)
+	err := handler(withAttempt(ctx, attempt, policy), delivery.Body)
+	if err == nil {
+		r.ack(delivery)
+		return
+	}
+
+	if attempt < policy.MaxAttempts && !IsPermanent(err) {
+		log.Printf("Message failed on attempt %d, retry in %s: %v", attempt, policy.Delay(attempt), err)
+		r.forward(delivery, RetryQueueName(queueName, attempt), amqp.Table{HeaderAttempt: int32(attempt + 1)})
+		return
+	}
+
+	log.Printf("Message failed on attempt %d, moved to the dead-letter queue: %v", attempt, err)
+	r.forward(delivery, DeadLetterQueueName(queueName), amqp.Table{
+		HeaderError:        err.Error(),
+		HeaderFailedAt:     time.Now().UTC().Format(time.RFC3339),
+		HeaderDeadLetterID: newMessageID(),
+	})
+}
+
+// forward publishes a copy of a message to another queue with its headers updated and acknowledges the original,
+// the original is requeued when the copy couldn't be published so the message is never lost
+func (r *RabbitMQ) forward(delivery amqp.Delivery, queueName string, headers amqp.Table) {
+	table := amqp.Table{}
+	for key, value := range delivery.Headers {
+		table[key] = value
+	}
+	for key, value := range headers {
+		table[key] = value
+	}
+
+	err := r.publish("", queueName, amqp.Publishing{
+		Headers:      table,
+		ContentType:  delivery.ContentType,
+		DeliveryMode: delivery.DeliveryMode,
+		MessageId:    delivery.MessageId,
+		Timestamp:    delivery.Timestamp,
+		Body:         delivery.Body,
+	})
+	if err != nil {
+		log.Printf("Failed to forward a message to %s: %v", queueName, err)
+		if err := delivery.Nack(false, true); err != nil {
+			log.Printf("Failed to requeue a message: %v", err)
+		}
+		return
+	}
+	r.ack(delivery)
+}
+
+func (r *RabbitMQ) ack(delivery amqp.Delivery) {
+	if err := delivery.Ack(false); err != nil {
+		log.Printf("Failed to acknowledge a message: %v", err)
+	}
+}
+
+// DeadLetters get the first limit messages of the dead-letter queue of a queue, see DeadLetterQueue
+func (r *RabbitMQ) DeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	var deadLetters []DeadLetter
+	err := r.browse(DeadLetterQueueName(queueName), func(delivery amqp.Delivery) (bool, error) {
+		deadLetters = append(deadLetters, newDeadLetter(delivery.Headers, delivery.Body))
+		return limit <= 0 || len(deadLetters) < limit, nil
+	})
 	if err != nil {
-		log.Fatalf("Failed to close channel: %v", err)
+		return nil, err
 	}
-	err = r.Connection.Close()
+	return deadLetters, nil
+}
+
+// ReplayDeadLetters moves dead-lettered messages back to their queue, see DeadLetterQueue. A message is removed from
+// the dead-letter queue once the broker confirmed its copy
+func (r *RabbitMQ) ReplayDeadLetters(queueName string, ids []string) (int, error) {
+	set := idSet(ids)
+	replayed := 0
+	err := r.browse(DeadLetterQueueName(queueName), func(delivery amqp.Delivery) (bool, error) {
+		if !selected(set, newDeadLetter(delivery.Headers, delivery.Body)) {
+			return true, nil
+		}
+		err := r.publish("", queueName, amqp.Publishing{
+			Headers:      replayHeaders(delivery.Headers),
+			ContentType:  delivery.ContentType,
+			DeliveryMode: delivery.DeliveryMode,
+			MessageId:    delivery.MessageId,
+			Timestamp:    delivery.Timestamp,
+			Body:         delivery.Body,
+		})
+		if err != nil {
+			return false, err
+		}
+		err = delivery.Ack(false)
+		if err != nil {
+			return false, err
+		}
+		replayed++
+		return true, nil
+	})
+	return replayed, err
+}
+
+// PurgeDeadLetters drops the messages of the dead-letter queue of a queue
+func (r *RabbitMQ) PurgeDeadLetters(queueName string) (int, error) {
+	ch, _ := r.current()
+	return ch.QueuePurge(DeadLetterQueueName(queueName), false)
+}
+
+// browse gets the messages of a queue one at a time on a channel of its own until the queue is empty or visit returns
+// false. The messages visit doesn't acknowledge are requeued in place once the channel is closed
+func (r *RabbitMQ) browse(queueName string, visit func(delivery amqp.Delivery) (bool, error)) error {
+	r.mu.RLock()
+	ch, err := r.connection.Channel()
+	r.mu.RUnlock()
 	if err != nil {
-		log.Fatalf("Failed to close connection: %v", err)
+		return err
+	}
+	defer ch.Close()
+
+	for {
+		delivery, ok, err := ch.Get(queueName, false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		more, err := visit(delivery)
+		if err != nil || !more {
+			return err
+		}
 	}
 }
+
+// Close stops reconnecting and closes the RabbitMQ connection and channels, the consumers return ErrClosed
+func (r *RabbitMQ) Close() {
+	r.closeOnce.Do(func() {
+		close(r.closed)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		// closing the connection closes its channels
+		if err := r.connection.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+			log.Printf("Failed to close connection: %v", err)
+		}
+	})
+}
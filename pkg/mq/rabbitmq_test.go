@@ -0,0 +1,17 @@
+package mq
+
+import (
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPublishing(t *testing.T) {
+	publishing := newPublishing(`{"ID":1}`)
+
+	// a durable queue keeps the message across a restart of the broker only when it is persistent
+	assert.Equal(t, amqp.Persistent, publishing.DeliveryMode)
+	assert.Equal(t, "text/plain", publishing.ContentType)
+	assert.Equal(t, []byte(`{"ID":1}`), publishing.Body)
+}
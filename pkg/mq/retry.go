@@ -0,0 +1,127 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/okiww/billing-loan-system/configs"
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// HeaderAttempt carries the delivery attempt of a message, the first delivery has no header and is attempt 1
+	HeaderAttempt = "x-attempt"
+	// HeaderError carries the error of the last attempt of a dead-lettered message
+	HeaderError = "x-error"
+	// HeaderFailedAt carries when a message was dead-lettered, RFC3339
+	HeaderFailedAt = "x-failed-at"
+
+	defaultMaxAttempts = 5
+	defaultRetryDelay  = time.Second
+	defaultMaxDelay    = time.Minute
+)
+
+// RetryPolicy how many times a message is delivered and how long apart before it is dead-lettered
+type RetryPolicy struct {
+	MaxAttempts  int           // Total deliveries including the first one
+	InitialDelay time.Duration // Delay before the second delivery, doubled after every delivery
+	MaxDelay     time.Duration
+}
+
+// NewRetryPolicy builds the retry policy of the consumers from the configuration
+func NewRetryPolicy(cfg configs.RabbitMQConfig) RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts:  defaultMaxAttempts,
+		InitialDelay: defaultRetryDelay,
+		MaxDelay:     defaultMaxDelay,
+	}
+	if cfg.MaxAttempts > 0 {
+		policy.MaxAttempts = cfg.MaxAttempts
+	}
+	if cfg.RetryDelayMs > 0 {
+		policy.InitialDelay = time.Duration(cfg.RetryDelayMs) * time.Millisecond
+	}
+	if cfg.MaxRetryDelayMs > 0 {
+		policy.MaxDelay = time.Duration(cfg.MaxRetryDelayMs) * time.Millisecond
+	}
+	return policy
+}
+
+// Delay get how long a message waits after its failed attempt before it is delivered again
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.InitialDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// RetryQueueName get the delay queue a message waits in after its failed attempt
+func RetryQueueName(queueName string, attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", queueName, attempt)
+}
+
+// DeadLetterQueueName get the queue the messages which ran out of attempts or can't be processed are moved to
+func DeadLetterQueueName(queueName string) string {
+	return queueName + ".dlq"
+}
+
+// permanentError is an error retrying the message won't fix, such as a body which can't be decoded
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+// Permanent marks err so the message is moved to the dead-letter queue without being retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// attemptKey carries the delivery attempt of the message a handler processes
+type attemptKey struct{}
+
+type deliveryAttempt struct {
+	attempt     int
+	maxAttempts int
+}
+
+func withAttempt(ctx context.Context, attempt int, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, attemptKey{}, deliveryAttempt{attempt: attempt, maxAttempts: policy.MaxAttempts})
+}
+
+// IsLastAttempt reports whether the message handled with ctx is delivered for the last time, a failure dead-letters
+// it instead of retrying it. It is true outside of a handler since nothing retries the call
+func IsLastAttempt(ctx context.Context) bool {
+	delivery, ok := ctx.Value(attemptKey{}).(deliveryAttempt)
+	return !ok || delivery.attempt >= delivery.maxAttempts
+}
+
+// attemptOf get the delivery attempt of a message from its headers
+func attemptOf(headers amqp.Table) int {
+	switch attempt := headers[HeaderAttempt].(type) {
+	case int32:
+		return int(attempt)
+	case int64:
+		return int(attempt)
+	case int:
+		return attempt
+	case float64:
+		// headers stored as JSON by the database broker
+		return int(attempt)
+	}
+	return 1
+}
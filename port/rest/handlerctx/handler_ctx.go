@@ -5,6 +5,8 @@ import (
 )
 
 type HandlerCtx struct {
-	LoanHandler    handlers.LoanHandlerInterface
-	PaymentHandler handlers.PaymentHandlerInterface
+	LoanHandler       handlers.LoanHandlerInterface
+	PaymentHandler    handlers.PaymentHandlerInterface
+	CollectionHandler handlers.CollectionHandlerInterface
+	MandateHandler    handlers.MandateHandlerInterface
 }
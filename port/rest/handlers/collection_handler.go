@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/okiww/billing-loan-system/internal/ctx/servicectx"
+	"github.com/okiww/billing-loan-system/internal/dto"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/okiww/billing-loan-system/pkg/response"
+)
+
+const defaultQueueLimit = 50
+
+type collectionHandler struct {
+	servicectx.ServiceCtx
+}
+
+func (c *collectionHandler) BuildQueue(w http.ResponseWriter, r *http.Request) {
+	total, err := c.CollectionService.BuildQueue(context.Background())
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorInternalServer).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	response.NewJSONResponse().SetData(map[string]int32{"total": total}).SetMessage("Success build collection queue").WriteResponse(w)
+}
+
+func (c *collectionHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	var (
+		agentID int
+		limit   = defaultQueueLimit
+		err     error
+	)
+
+	if agentIDStr := r.URL.Query().Get("agent_id"); agentIDStr != "" {
+		agentID, err = strconv.Atoi(agentIDStr)
+		if err != nil {
+			response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+			return
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage("limit must be greater than 0").WriteResponse(w)
+			return
+		}
+	}
+
+	cases, err := c.CollectionService.GetQueue(context.Background(), int64(agentID), limit)
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorInternalServer).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	response.NewJSONResponse().SetData(cases).SetMessage("Success get collection queue").WriteResponse(w)
+}
+
+func (c *collectionHandler) AssignCase(w http.ResponseWriter, r *http.Request) {
+	var request dto.AssignCaseRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage("Request body is not valid").WriteResponse(w)
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	err = c.CollectionService.AssignCase(context.Background(), request)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+
+	response.NewJSONResponse().SetData(nil).SetMessage("Success assign collection case").WriteResponse(w)
+}
+
+func (c *collectionHandler) RecordContactAttempt(w http.ResponseWriter, r *http.Request) {
+	var request dto.ContactAttemptRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage("Request body is not valid").WriteResponse(w)
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	attempt, err := c.CollectionService.RecordContactAttempt(context.Background(), request)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+
+	response.NewJSONResponse().SetData(attempt).SetMessage("Success record contact attempt").WriteResponse(w)
+}
+
+func (c *collectionHandler) RecordPromiseToPay(w http.ResponseWriter, r *http.Request) {
+	var request dto.PromiseToPayRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage("Request body is not valid").WriteResponse(w)
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	promise, err := c.CollectionService.RecordPromiseToPay(context.Background(), request)
+	if err != nil {
+		writeCollectionError(w, err)
+		return
+	}
+
+	response.NewJSONResponse().SetData(promise).SetMessage("Success record promise to pay").WriteResponse(w)
+}
+
+func writeCollectionError(w http.ResponseWriter, err error) {
+	if err.Error() == dto.ErrorCollectionCaseIsClosed || err.Error() == dto.ErrorPromisedDateInThePast || err.Error() == dto.ErrorInvalidContactAttempt {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+	response.NewJSONResponse().SetError(errors.ErrorInternalServer).SetMessage(err.Error()).WriteResponse(w)
+}
+
+func NewCollectionHandler(ctx servicectx.ServiceCtx) CollectionHandlerInterface {
+	return &collectionHandler{ctx}
+}
+
+type CollectionHandlerInterface interface {
+	BuildQueue(w http.ResponseWriter, r *http.Request)
+	GetQueue(w http.ResponseWriter, r *http.Request)
+	AssignCase(w http.ResponseWriter, r *http.Request)
+	RecordContactAttempt(w http.ResponseWriter, r *http.Request)
+	RecordPromiseToPay(w http.ResponseWriter, r *http.Request)
+}
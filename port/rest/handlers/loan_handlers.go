@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/okiww/billing-loan-system/internal/ctx/servicectx"
 	"github.com/okiww/billing-loan-system/internal/dto"
@@ -69,6 +70,135 @@ func (l *loanHandler) GetLoans(w http.ResponseWriter, r *http.Request) {
 	response.NewJSONResponse().SetData(loansWithBills).SetMessage("Success get loans").WriteResponse(w)
 }
 
+func (l *loanHandler) Restructure(w http.ResponseWriter, r *http.Request) {
+	var request dto.RestructureLoanRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage("Request body is not valid").WriteResponse(w)
+		return
+	}
+
+	// validate request
+	if err := request.Validate(); err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	restructure, err := l.LoanService.RestructureLoan(context.Background(), request)
+	if err != nil {
+		if err.Error() == dto.ErrorLoanIsNotActive || err.Error() == dto.ErrorLoanHasNoUnpaidBills {
+			response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+			return
+		}
+		response.NewJSONResponse().SetError(errors.ErrorInternalServer).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	response.NewJSONResponse().SetData(restructure).SetMessage("Success restructure loan").WriteResponse(w)
+}
+
+func (l *loanHandler) Defer(w http.ResponseWriter, r *http.Request) {
+	var request dto.DeferLoanRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage("Request body is not valid").WriteResponse(w)
+		return
+	}
+
+	// validate request
+	if err := request.Validate(); err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	deferral, err := l.LoanService.DeferLoan(context.Background(), request)
+	if err != nil {
+		if err.Error() == dto.ErrorLoanIsNotActive || err.Error() == dto.ErrorLoanHasNoDeferrableBills {
+			response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+			return
+		}
+		response.NewJSONResponse().SetError(errors.ErrorInternalServer).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	response.NewJSONResponse().SetData(deferral).SetMessage("Success defer loan").WriteResponse(w)
+}
+
+func (l *loanHandler) WriteOff(w http.ResponseWriter, r *http.Request) {
+	var request dto.WriteOffLoanRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage("Request body is not valid").WriteResponse(w)
+		return
+	}
+
+	// validate request
+	if err := request.Validate(); err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	writeOff, err := l.LoanService.WriteOffLoan(context.Background(), request)
+	if err != nil {
+		if err.Error() == dto.ErrorLoanIsNotActive {
+			response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+			return
+		}
+		response.NewJSONResponse().SetError(errors.ErrorInternalServer).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	response.NewJSONResponse().SetData(writeOff).SetMessage("Success write off loan").WriteResponse(w)
+}
+
+func (l *loanHandler) GetWriteOff(w http.ResponseWriter, r *http.Request) {
+	loanIDStr := r.URL.Query().Get("loan_id")
+	loanID, err := strconv.Atoi(loanIDStr)
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	summary, err := l.LoanService.GetLoanWriteOff(context.Background(), int64(loanID))
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorNotFound).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	response.NewJSONResponse().SetData(summary).SetMessage("Success get loan write off").WriteResponse(w)
+}
+
+func (l *loanHandler) GetPayoffQuote(w http.ResponseWriter, r *http.Request) {
+	loanIDStr := r.URL.Query().Get("loan_id")
+	loanID, err := strconv.Atoi(loanIDStr)
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	// the payoff date is optional, today in the loan timezone when empty
+	var payoffDate time.Time
+	if date := r.URL.Query().Get("date"); date != "" {
+		payoffDate, err = time.Parse(dto.DateFormat, date)
+		if err != nil {
+			response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage("date must be in YYYY-MM-DD format").WriteResponse(w)
+			return
+		}
+	}
+
+	quote, err := l.LoanService.GetPayoffQuote(context.Background(), int64(loanID), payoffDate)
+	if err != nil {
+		if err.Error() == dto.ErrorLoanIsNotActive || err.Error() == dto.ErrorPayoffDateInThePast {
+			response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+			return
+		}
+		response.NewJSONResponse().SetError(errors.ErrorNotFound).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	response.NewJSONResponse().SetData(quote).SetMessage("Success get payoff quote").WriteResponse(w)
+}
+
 func NewLoanHandler(ctx servicectx.ServiceCtx) LoanHandlerInterface {
 	return &loanHandler{ctx}
 }
@@ -76,4 +206,9 @@ func NewLoanHandler(ctx servicectx.ServiceCtx) LoanHandlerInterface {
 type LoanHandlerInterface interface {
 	Create(w http.ResponseWriter, r *http.Request)
 	GetLoans(w http.ResponseWriter, r *http.Request)
+	Restructure(w http.ResponseWriter, r *http.Request)
+	Defer(w http.ResponseWriter, r *http.Request)
+	WriteOff(w http.ResponseWriter, r *http.Request)
+	GetWriteOff(w http.ResponseWriter, r *http.Request)
+	GetPayoffQuote(w http.ResponseWriter, r *http.Request)
 }
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/okiww/billing-loan-system/internal/ctx/servicectx"
+	"github.com/okiww/billing-loan-system/internal/dto"
+	"github.com/okiww/billing-loan-system/internal/mandate/models"
+	"github.com/okiww/billing-loan-system/pkg/errors"
+	"github.com/okiww/billing-loan-system/pkg/response"
+)
+
+type mandateHandler struct {
+	servicectx.ServiceCtx
+}
+
+func (m *mandateHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var request dto.MandateRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage("Request body is not valid").WriteResponse(w)
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	mandate, err := m.MandateService.CreateMandate(context.Background(), request)
+	if err != nil {
+		writeMandateError(w, err)
+		return
+	}
+
+	response.NewJSONResponse().SetData(mandate).SetMessage("Success create mandate").WriteResponse(w)
+}
+
+func (m *mandateHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	m.updateStatus(w, r, m.MandateService.PauseMandate, "Success pause mandate")
+}
+
+func (m *mandateHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	m.updateStatus(w, r, m.MandateService.ResumeMandate, "Success resume mandate")
+}
+
+func (m *mandateHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	m.updateStatus(w, r, m.MandateService.RevokeMandate, "Success revoke mandate")
+}
+
+func (m *mandateHandler) updateStatus(w http.ResponseWriter, r *http.Request, update func(ctx context.Context, request dto.MandateStatusRequest) (*models.MandateModel, error), message string) {
+	var request dto.MandateStatusRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage("Request body is not valid").WriteResponse(w)
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+
+	mandate, err := update(context.Background(), request)
+	if err != nil {
+		writeMandateError(w, err)
+		return
+	}
+
+	response.NewJSONResponse().SetData(mandate).SetMessage(message).WriteResponse(w)
+}
+
+func writeMandateError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case dto.ErrorLoanNotOwnedByUser, dto.ErrorMandateNotOwnedByUser, dto.ErrorLoanIsNotActive, dto.ErrorMandateAlreadyExists,
+		dto.ErrorMandateIsNotActive, dto.ErrorMandateIsNotPaused, dto.ErrorMandateIsRevoked:
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
+	}
+	response.NewJSONResponse().SetError(errors.ErrorInternalServer).SetMessage(err.Error()).WriteResponse(w)
+}
+
+func NewMandateHandler(ctx servicectx.ServiceCtx) MandateHandlerInterface {
+	return &mandateHandler{ctx}
+}
+
+type MandateHandlerInterface interface {
+	Create(w http.ResponseWriter, r *http.Request)
+	Pause(w http.ResponseWriter, r *http.Request)
+	Resume(w http.ResponseWriter, r *http.Request)
+	Revoke(w http.ResponseWriter, r *http.Request)
+}
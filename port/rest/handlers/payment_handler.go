@@ -1,12 +1,13 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 
 	"github.com/okiww/billing-loan-system/configs"
+	outboxModel "github.com/okiww/billing-loan-system/internal/outbox/models"
 	"github.com/okiww/billing-loan-system/internal/payment/models"
+	"github.com/okiww/billing-loan-system/pkg/event"
 	"github.com/okiww/billing-loan-system/pkg/logger"
 	"github.com/okiww/billing-loan-system/pkg/mq"
 
@@ -18,7 +19,7 @@ import (
 
 type paymentHandler struct {
 	servicectx.ServiceCtx
-	*mq.RabbitMQ
+	publisher mq.Publisher
 	*configs.RabbitMQConfig
 }
 
@@ -28,13 +29,13 @@ func (p *paymentHandler) TestPublishMessage(w http.ResponseWriter, r *http.Reque
 		ID: 3, UserID: 123, LoanID: 2, LoanBillID: 1, Amount: 1375000, Status: "PENDING",
 	}
 
-	// Serialize the array to JSON
-	jsonData, err := json.Marshal(payments)
+	// Wrap the payment in the envelope of its event
+	message, err := event.Encode(r.Context(), outboxModel.EventPaymentCreated, models.PaymentCreatedVersion, p.Clock.Now(), models.NewPaymentCreatedEvent(payments))
 	if err != nil {
-		logger.GetLogger().Fatalf("Failed to marshal array to JSON: %v", err)
+		logger.GetLogger().Fatalf("Failed to encode event: %v", err)
 	}
 
-	err = p.RabbitMQ.PublishMessage(p.RabbitMQConfig.QueueName, string(jsonData))
+	err = p.publisher.PublishMessage(p.RabbitMQConfig.QueueName, message)
 	if err != nil {
 		logger.GetLogger().Fatalf("Failed to publish message: %v", err)
 	}
@@ -58,8 +59,8 @@ func (p *paymentHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Step 4: Create the payment record in the database
-	payment, err := p.ServiceCtx.PaymentService.MakePayment(context.Background(), &request)
+	// Step 4: Create the payment record in the database, the outbox relay pushes it to rabbitMQ
+	_, err = p.ServiceCtx.PaymentService.MakePayment(r.Context(), &request)
 	if err != nil {
 		if err.Error() == dto.ErrorLoanIsNotActive || err.Error() == dto.ErrorPaymentAmountNotMatchWithBill || err.Error() == dto.ErrorLoanBillStatusNotBilled {
 			response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
@@ -69,32 +70,43 @@ func (p *paymentHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Step 5: Push to rabbitMQ
-	go p.publishPayment(payment)
-	// Step 6: Respond with success message
+	// Step 5: Respond with success message
 	response.NewJSONResponse().SetData(nil).SetMessage("Payment successfully created").WriteResponse(w)
 }
 
-func (p *paymentHandler) publishPayment(payment *models.Payment) {
-	// Serialize the array to JSON
-	jsonData, err := json.Marshal(payment)
+func (p *paymentHandler) CreateRecovery(w http.ResponseWriter, r *http.Request) {
+	var request dto.RecoveryPaymentRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
-		logger.GetLogger().Fatalf("Failed to marshal array to JSON: %v", err)
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage("Request body is not valid").WriteResponse(w)
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+		return
 	}
 
-	err = p.RabbitMQ.PublishMessage(p.RabbitMQConfig.QueueName, string(jsonData))
+	_, err = p.ServiceCtx.PaymentService.MakeRecoveryPayment(r.Context(), &request)
 	if err != nil {
-		logger.GetLogger().Fatalf("Failed to publish message: %v", err)
+		if err.Error() == dto.ErrorLoanIsNotWrittenOff {
+			response.NewJSONResponse().SetError(errors.ErrorBadRequest).SetMessage(err.Error()).WriteResponse(w)
+			return
+		}
+		response.NewJSONResponse().SetError(errors.ErrorInternalServer).SetMessage(err.Error()).WriteResponse(w)
+		return
 	}
 
-	logger.GetLogger().Println("Message published successfully!")
+	// Recovery payments are published by the outbox relay to the same worker as regular repayments
+	response.NewJSONResponse().SetData(nil).SetMessage("Recovery payment successfully created").WriteResponse(w)
 }
 
-func NewPaymentHandler(ctx servicectx.ServiceCtx, rabbitMQ *mq.RabbitMQ, rabbitMQCfg *configs.RabbitMQConfig) PaymentHandlerInterface {
-	return &paymentHandler{ctx, rabbitMQ, rabbitMQCfg}
+func NewPaymentHandler(ctx servicectx.ServiceCtx, publisher mq.Publisher, rabbitMQCfg *configs.RabbitMQConfig) PaymentHandlerInterface {
+	return &paymentHandler{ctx, publisher, rabbitMQCfg}
 }
 
 type PaymentHandlerInterface interface {
 	Create(w http.ResponseWriter, r *http.Request)
+	CreateRecovery(w http.ResponseWriter, r *http.Request)
 	TestPublishMessage(w http.ResponseWriter, r *http.Request)
 }
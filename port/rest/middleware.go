@@ -0,0 +1,23 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/okiww/billing-loan-system/pkg/event"
+)
+
+// HeaderCorrelationID carries the correlation id of a request, the events published while serving it share it
+const HeaderCorrelationID = "X-Correlation-ID"
+
+// correlationID puts the correlation id sent by the client in the request context, or a new one when there is none,
+// and returns it in the response
+func correlationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderCorrelationID)
+		if id == "" {
+			id = event.NewID()
+		}
+		w.Header().Set(HeaderCorrelationID, id)
+		next.ServeHTTP(w, r.WithContext(event.WithCorrelationID(r.Context(), id)))
+	})
+}
@@ -13,13 +13,35 @@ type Domain struct {
 
 // RegisterRoutes defines all application routes
 func RegisterRoutes(router *mux.Router, h Domain) {
+	router.Use(correlationID)
 	baseRouter := router.PathPrefix("/api/v1").Subrouter()
 
 	loanRouter := baseRouter.PathPrefix("/loan").Subrouter()
 	loanRouter.HandleFunc("/create", h.Domain.LoanHandler.Create).Methods(http.MethodPost)
 	loanRouter.HandleFunc("/all", h.Domain.LoanHandler.GetLoans).Methods(http.MethodGet)
+	loanRouter.HandleFunc("/payoff-quote", h.Domain.LoanHandler.GetPayoffQuote).Methods(http.MethodGet)
 
 	paymentRouter := baseRouter.PathPrefix("/payment").Subrouter()
 	paymentRouter.HandleFunc("/create", h.Domain.PaymentHandler.Create).Methods(http.MethodPost)
+	paymentRouter.HandleFunc("/recovery", h.Domain.PaymentHandler.CreateRecovery).Methods(http.MethodPost)
 	paymentRouter.HandleFunc("/test-publish", h.Domain.PaymentHandler.TestPublishMessage).Methods(http.MethodPost)
+
+	collectionRouter := baseRouter.PathPrefix("/collection").Subrouter()
+	collectionRouter.HandleFunc("/queue/build", h.Domain.CollectionHandler.BuildQueue).Methods(http.MethodPost)
+	collectionRouter.HandleFunc("/queue", h.Domain.CollectionHandler.GetQueue).Methods(http.MethodGet)
+	collectionRouter.HandleFunc("/case/assign", h.Domain.CollectionHandler.AssignCase).Methods(http.MethodPost)
+	collectionRouter.HandleFunc("/case/contact", h.Domain.CollectionHandler.RecordContactAttempt).Methods(http.MethodPost)
+	collectionRouter.HandleFunc("/case/promise", h.Domain.CollectionHandler.RecordPromiseToPay).Methods(http.MethodPost)
+
+	mandateRouter := baseRouter.PathPrefix("/mandate").Subrouter()
+	mandateRouter.HandleFunc("/create", h.Domain.MandateHandler.Create).Methods(http.MethodPost)
+	mandateRouter.HandleFunc("/pause", h.Domain.MandateHandler.Pause).Methods(http.MethodPost)
+	mandateRouter.HandleFunc("/resume", h.Domain.MandateHandler.Resume).Methods(http.MethodPost)
+	mandateRouter.HandleFunc("/revoke", h.Domain.MandateHandler.Revoke).Methods(http.MethodPost)
+
+	adminRouter := baseRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.HandleFunc("/loan/restructure", h.Domain.LoanHandler.Restructure).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/loan/defer", h.Domain.LoanHandler.Defer).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/loan/write-off", h.Domain.LoanHandler.WriteOff).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/loan/write-off", h.Domain.LoanHandler.GetWriteOff).Methods(http.MethodGet)
 }